@@ -13,9 +13,18 @@ import (
 
 	"github.com/mdnaeem95/lifesync/backend/internal/config"
 	"github.com/mdnaeem95/lifesync/backend/internal/middleware"
+	"github.com/mdnaeem95/lifesync/backend/pkg/authz"
 	"github.com/mdnaeem95/lifesync/backend/pkg/database"
+	"github.com/mdnaeem95/lifesync/backend/pkg/jwks"
 	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/mailer"
+	"github.com/mdnaeem95/lifesync/backend/pkg/password"
+	"github.com/mdnaeem95/lifesync/backend/pkg/pat"
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
+	"github.com/mdnaeem95/lifesync/backend/pkg/userdata"
+	"github.com/mdnaeem95/lifesync/backend/pkg/webauthn"
 	"github.com/mdnaeem95/lifesync/backend/services/auth/handlers"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/oidc"
 	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
 	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
 )
@@ -28,6 +37,9 @@ func main() {
 	// Load configuration
 	cfg := config.LoadAuthConfig()
 	log.WithField("config", cfg.GetSafeConfig()).Info("Configuration loaded")
+	if cfg.InternalAPIToken == "" {
+		log.Fatal("INTERNAL_API_TOKEN must be set outside development")
+	}
 
 	// Initialize database
 	db, err := database.New(cfg.DatabaseURL)
@@ -43,16 +55,137 @@ func main() {
 
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db, log)
+	outboxRepo := repository.NewOutboxRepository(db, log)
+	identityRepo := repository.NewIdentityRepository(db, log)
+	mfaRepo := repository.NewMFARepository(db, log)
+	webAuthnRepo := repository.NewWebAuthnRepository(db, log)
+	loginThrottleRepo := repository.NewLoginThrottleRepository(db, log)
+	passwordHistoryRepo := repository.NewPasswordHistoryRepository(db, log)
+	auditRepo := repository.NewAuditRepository(db, log)
+	personalAccessTokenRepo := repository.NewPersonalAccessTokenRepository(db, log)
+	authEventRepo := repository.NewAuthEventRepository(db, log)
+	loginHistoryRepo := repository.NewLoginHistoryRepository(db, log)
+	oauthRepo := repository.NewOAuthRepository(db, log)
+	orgRepo := repository.NewOrganizationRepository(db, log)
+
+	grantAdminRoles(userRepo, cfg.AdminEmails, log)
+	revocationStore := revocation.NewCachedStore(revocation.NewPostgresStore(db), cfg.RevocationCacheTTL, 10000)
+
+	// Initialize mail delivery
+	mailSender, err := mailer.New(mailer.Config{
+		Backend:      cfg.MailBackend,
+		SMTPHost:     cfg.SMTPHost,
+		SMTPPort:     cfg.SMTPPort,
+		SMTPUser:     cfg.SMTPUser,
+		SMTPPassword: cfg.SMTPPassword,
+		From:         cfg.FromEmail,
+		OutputDir:    cfg.MailOutputDir,
+	}, log)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to create mailer")
+	}
+
+	mailRenderer, err := mailer.NewRenderer()
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load email templates")
+	}
 
 	// Initialize services
-	jwtService := services.NewJWTService(cfg.JWTSecret, log)
-	authService := services.NewAuthService(userRepo, jwtService, log)
+	jwtService, keyRing := newJWTService(cfg, log)
+	emailService := services.NewEmailService(outboxRepo, mailRenderer, cfg, log)
+	passwordHasher, err := services.NewPasswordHasher(cfg)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid password hashing configuration")
+	}
+	loginGuard := services.NewLoginGuard(
+		loginThrottleRepo,
+		services.ThrottlePolicy{
+			FreeAttempts: cfg.LoginFreeAttempts,
+			MaxFailures:  cfg.LoginMaxFailures,
+			BaseDelay:    cfg.LoginBaseDelay,
+			MaxDelay:     cfg.LoginMaxDelay,
+			Lockout:      cfg.LoginLockoutDuration,
+		},
+		services.ThrottlePolicy{
+			FreeAttempts: cfg.LoginIPFreeAttempts,
+			MaxFailures:  cfg.LoginIPMaxFailures,
+			BaseDelay:    cfg.LoginBaseDelay,
+			MaxDelay:     cfg.LoginMaxDelay,
+			Lockout:      cfg.LoginLockoutDuration,
+		},
+		cfg.LoginFailureWindow,
+		log,
+	)
+	mfaService := services.NewMFAService(mfaRepo, userRepo, jwtService, emailService, passwordHasher, loginGuard, log)
+	passwordPolicy := services.NewPasswordPolicy(passwordHistoryRepo, loadBreachedPasswords(cfg, log), passwordHasher, cfg, log)
+	authEventService := services.NewAuthEventService(authEventRepo, cfg.AuthEventRetention, log)
+	var loginNotifier services.LoginNotifier
+	if cfg.NewLoginAlerts {
+		loginNotifier = services.NewEmailLoginNotifier(emailService)
+	}
+	loginHistoryService := services.NewLoginHistoryService(loginHistoryRepo, userRepo, loginNotifier, cfg.LoginHistoryRetention, log)
+	authService := services.NewAuthService(userRepo, jwtService, emailService, mfaService, loginGuard, passwordPolicy, passwordHasher, revocationStore, authEventService, loginHistoryService, cfg, log)
+	magicLinkService := services.NewMagicLinkService(userRepo, mfaService, authService, emailService, loginGuard, authEventService, loginHistoryService, cfg, log)
+	socialAuthService := services.NewSocialAuthService(buildOIDCVerifiers(cfg, log), userRepo, identityRepo, mfaService, authService, authEventService, loginHistoryService, log)
+
+	relyingParty := webauthn.New(webauthn.Config{
+		RPID:             cfg.WebAuthnRPID,
+		RPName:           cfg.WebAuthnRPName,
+		Origins:          cfg.WebAuthnOrigins,
+		Timeout:          cfg.WebAuthnChallengeTTL,
+		UserVerification: webauthn.VerificationRequired,
+	})
+	webAuthnService := services.NewWebAuthnService(relyingParty, webAuthnRepo, userRepo, authService, emailService, authEventService, loginHistoryService, []byte(cfg.WebAuthnDecoySecret), cfg.WebAuthnChallengeTTL, log)
+	sessionService := services.NewSessionService(userRepo, revocationStore, log)
+	// Services holding user data, for exports and account deletion, and
+	// data shared in organizations
+	flowtimeSource := userdata.NewHTTPSource("flowtime", cfg.FlowTimeServiceURL, cfg.InternalAPIToken, 10*time.Second)
+	userDataSources := []userdata.Source{flowtimeSource}
+	orgDataSources := []userdata.OrgSource{flowtimeSource}
+	accountService := services.NewAccountService(userRepo, jwtService, authService, emailService, loginGuard, passwordPolicy, passwordHasher, revocationStore, userDataSources, cfg, log)
+	tokenService := services.NewPersonalAccessTokenService(personalAccessTokenRepo, userRepo, log)
+	// Requests to this service check tokens through a cache; other services
+	// verify them through the uncached internal endpoint and cache themselves
+	cachedTokens := pat.NewCachedVerifier(tokenService, cfg.RevocationCacheTTL, 10000)
+	adminService := services.NewAdminService(userRepo, auditRepo, loginGuard, authService, revocationStore, log)
+	oauthService := services.NewOAuthService(oauthRepo, userRepo, jwtService, revocationStore, authEventService, cfg, log)
+	organizationService := services.NewOrganizationService(orgRepo, userRepo, jwtService, emailService, revocationStore, orgDataSources, cfg, log)
+
+	// Start background workers
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	outboxWorker := services.NewOutboxWorker(outboxRepo, mailSender, cfg.OutboxPollInterval, cfg.OutboxBatchSize, cfg.OutboxMaxAttempts, log)
+	go outboxWorker.Start(workerCtx)
+
+	if keyRing != nil {
+		go reloadSigningKeys(workerCtx, keyRing, cfg.JWTKeyReloadInterval, log)
+	}
+
+	go cleanupExpiredRecords(workerCtx, loginGuard, revocationStore, authEventService, loginHistoryService, oauthService, organizationService, log)
+
+	accountPurger := services.NewAccountPurger(userRepo, userDataSources, revocationStore, time.Hour, log)
+	go accountPurger.Start(workerCtx)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService, log)
+	socialAuthHandler := handlers.NewSocialAuthHandler(socialAuthService, log)
+	magicLinkHandler := handlers.NewMagicLinkHandler(magicLinkService, log)
+	mfaHandler := handlers.NewMFAHandler(mfaService, log)
+	webAuthnHandler := handlers.NewWebAuthnHandler(webAuthnService, log)
+	sessionHandler := handlers.NewSessionHandler(sessionService, log)
+	accountHandler := handlers.NewAccountHandler(accountService, log)
+	adminHandler := handlers.NewAdminHandler(adminService, log)
+	tokenHandler := handlers.NewTokenHandler(tokenService, log)
+	authEventHandler := handlers.NewAuthEventHandler(authEventService, log)
+	loginHistoryHandler := handlers.NewLoginHistoryHandler(loginHistoryService, log)
+	oauthHandler := handlers.NewOAuthHandler(oauthService, log)
+	organizationHandler := handlers.NewOrganizationHandler(organizationService, log)
+	internalHandler := handlers.NewInternalHandler(revocationStore, tokenService, log)
+	jwksHandler := handlers.NewJWKSHandler(keyRing)
 
 	// Setup router
-	router := setupRouter(cfg, authHandler, jwtService, log)
+	router := setupRouter(cfg, authHandler, socialAuthHandler, magicLinkHandler, mfaHandler, webAuthnHandler, sessionHandler, accountHandler, adminHandler, tokenHandler, authEventHandler, loginHistoryHandler, oauthHandler, organizationHandler, internalHandler, jwksHandler, jwtService, revocationStore, cachedTokens, log)
 
 	// Create server
 	srv := &http.Server{
@@ -78,6 +211,9 @@ func main() {
 
 	log.Info("Shutting down server...")
 
+	// Stop background workers
+	stopWorkers()
+
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -89,7 +225,158 @@ func main() {
 	log.Info("Server exited")
 }
 
-func setupRouter(cfg *config.AuthConfig, authHandler *handlers.AuthHandler, jwtService services.JWTService, log logger.Logger) *gin.Engine {
+// newJWTService signs with the key ring in JWT_SIGNING_KEYS_DIR, falling
+// back to the shared HS256 secret when no directory is configured
+func newJWTService(cfg *config.AuthConfig, log logger.Logger) (services.JWTService, *jwks.KeyRing) {
+	if cfg.JWTSigningKeysDir == "" {
+		log.Warn("JWT_SIGNING_KEYS_DIR not set, signing tokens with the shared HS256 secret")
+		return services.NewJWTService(cfg.JWTSecret, log), nil
+	}
+
+	keyRing, err := jwks.LoadKeyRing(cfg.JWTSigningKeysDir, cfg.JWTKeyVerifyWindow)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load JWT signing keys")
+	}
+
+	key, err := keyRing.SigningKey()
+	if err != nil {
+		log.WithError(err).Fatal("No active JWT signing key")
+	}
+
+	log.WithFields(map[string]interface{}{
+		"kid": key.Kid,
+		"alg": key.Alg,
+	}).Info("JWT signing keys loaded")
+
+	return services.NewKeyRingJWTService(keyRing, log), keyRing
+}
+
+// reloadSigningKeys picks up keys added by the rotation command
+func reloadSigningKeys(ctx context.Context, keyRing *jwks.KeyRing, interval time.Duration, log logger.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := keyRing.Reload(); err != nil {
+				log.WithError(err).Error("Failed to reload JWT signing keys")
+			}
+		}
+	}
+}
+
+// cleanupExpiredRecords deletes failed sign-in counters, token revocations
+// OAuth codes and tokens and organization invitations that no longer have
+// any effect, and auth events and login history past their retention period
+func cleanupExpiredRecords(ctx context.Context, loginGuard services.LoginGuard, revocations revocation.Store, authEvents services.AuthEventService, loginHistory services.LoginHistoryService, oauth services.OAuthService, orgs services.OrganizationService, log logger.Logger) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if deleted, err := loginGuard.Cleanup(ctx); err != nil {
+				log.WithError(err).Error("Failed to clean up login throttles")
+			} else if deleted > 0 {
+				log.WithField("deleted", deleted).Debug("Expired login throttles removed")
+			}
+
+			if deleted, err := revocations.DeleteExpired(ctx); err != nil {
+				log.WithError(err).Error("Failed to clean up token revocations")
+			} else if deleted > 0 {
+				log.WithField("deleted", deleted).Debug("Expired token revocations removed")
+			}
+
+			if deleted, err := authEvents.Purge(ctx); err != nil {
+				log.WithError(err).Error("Failed to purge old auth events")
+			} else if deleted > 0 {
+				log.WithField("deleted", deleted).Info("Old auth events purged")
+			}
+
+			if deleted, err := loginHistory.Purge(ctx); err != nil {
+				log.WithError(err).Error("Failed to purge old login history")
+			} else if deleted > 0 {
+				log.WithField("deleted", deleted).Info("Old login history purged")
+			}
+
+			if deleted, err := oauth.Cleanup(ctx); err != nil {
+				log.WithError(err).Error("Failed to clean up oauth codes and tokens")
+			} else if deleted > 0 {
+				log.WithField("deleted", deleted).Debug("Expired oauth codes and tokens removed")
+			}
+
+			if deleted, err := orgs.Cleanup(ctx); err != nil {
+				log.WithError(err).Error("Failed to clean up organization invitations")
+			} else if deleted > 0 {
+				log.WithField("deleted", deleted).Debug("Expired organization invitations removed")
+			}
+		}
+	}
+}
+
+// buildOIDCVerifiers returns a verifier for every identity provider that has
+// client IDs configured
+func buildOIDCVerifiers(cfg *config.AuthConfig, log logger.Logger) map[string]*oidc.Verifier {
+	verifiers := make(map[string]*oidc.Verifier)
+
+	if len(cfg.GoogleClientIDs) > 0 {
+		verifiers[oidc.ProviderGoogle] = oidc.NewVerifier(oidc.GoogleProvider(cfg.GoogleClientIDs, cfg.GoogleJWKSURL, log))
+	}
+	if len(cfg.AppleClientIDs) > 0 {
+		verifiers[oidc.ProviderApple] = oidc.NewVerifier(oidc.AppleProvider(cfg.AppleClientIDs, cfg.AppleJWKSURL, log))
+	}
+
+	for provider := range verifiers {
+		log.WithField("provider", provider).Info("Social sign-in enabled")
+	}
+
+	return verifiers
+}
+
+// loadBreachedPasswords reads the breached password list, if one is
+// configured. A configured list that can't be read stops startup rather than
+// silently dropping the check.
+func loadBreachedPasswords(cfg *config.AuthConfig, log logger.Logger) *password.BreachedList {
+	if cfg.PasswordBreachedListFile == "" {
+		log.Info("Breached password check disabled")
+		return nil
+	}
+
+	list, err := password.LoadBreachedList(cfg.PasswordBreachedListFile)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load breached password list")
+	}
+
+	log.WithField("prefixes", list.Len()).Info("Breached password list loaded")
+	return list
+}
+
+// grantAdminRoles gives the accounts listed in ADMIN_EMAILS the admin role,
+// so a fresh deployment has someone who can manage roles
+func grantAdminRoles(userRepo repository.UserRepository, emails []string, log logger.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, email := range emails {
+		granted, err := userRepo.AddRoleByEmail(ctx, email, authz.RoleAdmin)
+		if err != nil {
+			log.WithError(err).WithField("email", email).Error("Failed to grant admin role")
+			continue
+		}
+		if !granted {
+			log.WithField("email", email).Warn("Admin account not found, sign up first")
+			continue
+		}
+		log.WithField("email", email).Info("Admin role granted")
+	}
+}
+
+func setupRouter(cfg *config.AuthConfig, authHandler *handlers.AuthHandler, socialAuthHandler *handlers.SocialAuthHandler, magicLinkHandler *handlers.MagicLinkHandler, mfaHandler *handlers.MFAHandler, webAuthnHandler *handlers.WebAuthnHandler, sessionHandler *handlers.SessionHandler, accountHandler *handlers.AccountHandler, adminHandler *handlers.AdminHandler, tokenHandler *handlers.TokenHandler, authEventHandler *handlers.AuthEventHandler, loginHistoryHandler *handlers.LoginHistoryHandler, oauthHandler *handlers.OAuthHandler, organizationHandler *handlers.OrganizationHandler, internalHandler *handlers.InternalHandler, jwksHandler *handlers.JWKSHandler, jwtService services.JWTService, revocations revocation.Checker, tokens pat.Verifier, log logger.Logger) *gin.Engine {
 	// Set Gin mode
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -97,12 +384,20 @@ func setupRouter(cfg *config.AuthConfig, authHandler *handlers.AuthHandler, jwtS
 
 	router := gin.New()
 
+	// Only believe X-Forwarded-For from our own proxies, or any client could
+	// pick the IP address that rate limits and sign-in throttling go by
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.WithError(err).Fatal("Invalid TRUSTED_PROXIES")
+	}
+
 	// Global middleware
 	router.Use(middleware.Logger(log))
+	router.Use(middleware.Metrics("auth-service"))
 	router.Use(middleware.Recovery(log))
 	router.Use(middleware.CORS(cfg.AllowedOrigins))
 	router.Use(middleware.RequestID())
 	router.Use(middleware.RateLimit(cfg.RateLimitPerMinute))
+	router.Use(middleware.ClientInfo())
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
@@ -113,16 +408,150 @@ func setupRouter(cfg *config.AuthConfig, authHandler *handlers.AuthHandler, jwtS
 		})
 	})
 
+	// Prometheus metrics
+	router.GET("/metrics", middleware.MetricsHandler())
+
+	// Public keys for verifying issued tokens
+	router.GET("/.well-known/jwks.json", jwksHandler.GetKeySet)
+
+	authRequired := middleware.AuthRequired(jwtService, revocations, tokens)
+
 	// Auth routes
 	auth := router.Group("/auth")
 	{
 		auth.POST("/signup", authHandler.SignUp)
 		auth.POST("/signin", authHandler.SignIn)
+		auth.POST("/signin/2fa", authHandler.SignInMFA)
 		auth.POST("/refresh", authHandler.RefreshToken)
-		auth.POST("/signout", middleware.AuthRequired(jwtService), authHandler.SignOut)
+		auth.POST("/signout", authRequired, middleware.RequireSession(), authHandler.SignOut)
 		auth.GET("/verify-email/:token", authHandler.VerifyEmail)
+		auth.POST("/verify-email/resend", authHandler.ResendVerification)
 		auth.POST("/reset-password", authHandler.RequestPasswordReset)
 		auth.POST("/reset-password/:token", authHandler.ResetPassword)
+		auth.GET("/confirm-email/:token", accountHandler.ConfirmEmailChange)
+		auth.POST("/oauth/:provider", socialAuthHandler.SignIn)
+		auth.POST("/magic-link", magicLinkHandler.RequestLink)
+		auth.POST("/magic-link/verify", magicLinkHandler.VerifyLink)
+	}
+
+	// The signed-in user's own account. Personal access tokens can read and
+	// update the profile, nothing more.
+	me := router.Group("/auth/me", authRequired)
+	{
+		me.GET("", middleware.RequireScope(authz.ScopeProfileRead), accountHandler.GetProfile)
+		me.PATCH("", middleware.RequireScope(authz.ScopeProfileWrite), accountHandler.UpdateProfile)
+
+		session := me.Group("", middleware.RequireSession())
+		session.POST("/password", accountHandler.ChangePassword)
+		session.POST("/email", accountHandler.RequestEmailChange)
+		session.DELETE("", accountHandler.DeleteAccount)
+		session.POST("/deletion/cancel", accountHandler.CancelDeletion)
+		session.GET("/export", accountHandler.ExportData)
+		session.GET("/activity", authEventHandler.ListMyActivity)
+		session.GET("/logins", loginHistoryHandler.ListLogins)
+		session.GET("/apps", oauthHandler.ListApps)
+		session.DELETE("/apps/:id", oauthHandler.RevokeApp)
+		session.PUT("/org", organizationHandler.SwitchOrganization)
+	}
+
+	// OAuth2 provider for third-party apps. The consent page calls the
+	// authorize endpoints for the signed-in user; apps call the rest with
+	// their client credentials.
+	oauth := router.Group("/oauth")
+	{
+		oauth.GET("/authorize", authRequired, middleware.RequireSession(), oauthHandler.GetAuthorization)
+		oauth.POST("/authorize", authRequired, middleware.RequireSession(), oauthHandler.Authorize)
+		oauth.POST("/token", oauthHandler.Token)
+		oauth.POST("/introspect", oauthHandler.Introspect)
+		oauth.POST("/revoke", oauthHandler.Revoke)
+	}
+
+	// Organizations. Invitations are accepted outside /auth/orgs since the
+	// token, not the path, names the organization.
+	orgs := router.Group("/auth/orgs", authRequired, middleware.RequireSession())
+	{
+		orgs.POST("", organizationHandler.CreateOrganization)
+		orgs.GET("", organizationHandler.ListOrganizations)
+		orgs.GET("/:id", organizationHandler.GetOrganization)
+		orgs.PATCH("/:id", organizationHandler.RenameOrganization)
+		orgs.DELETE("/:id", organizationHandler.DeleteOrganization)
+		orgs.GET("/:id/members", organizationHandler.ListMembers)
+		orgs.PUT("/:id/members/:userId", organizationHandler.UpdateMemberRole)
+		orgs.DELETE("/:id/members/:userId", organizationHandler.RemoveMember)
+		orgs.POST("/:id/invitations", organizationHandler.Invite)
+		orgs.GET("/:id/invitations", organizationHandler.ListInvitations)
+		orgs.DELETE("/:id/invitations/:invitationId", organizationHandler.RevokeInvitation)
+	}
+	router.POST("/auth/invitations/accept", authRequired, middleware.RequireSession(), organizationHandler.AcceptInvitation)
+
+	// Personal access tokens
+	tokenRoutes := router.Group("/auth/tokens", authRequired, middleware.RequireSession())
+	{
+		tokenRoutes.GET("", tokenHandler.ListTokens)
+		tokenRoutes.POST("", tokenHandler.CreateToken)
+		tokenRoutes.DELETE("/:id", tokenHandler.RevokeToken)
+	}
+
+	// Two-factor authentication management
+	twoFactor := router.Group("/auth/2fa", authRequired, middleware.RequireSession())
+	{
+		twoFactor.GET("", mfaHandler.Status)
+		twoFactor.POST("/setup", mfaHandler.Setup)
+		twoFactor.POST("/enable", mfaHandler.Enable)
+		twoFactor.POST("/disable", mfaHandler.Disable)
+		twoFactor.POST("/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+	}
+
+	// Passkeys
+	passkeys := router.Group("/auth/webauthn")
+	{
+		passkeys.POST("/login/begin", webAuthnHandler.BeginLogin)
+		passkeys.POST("/login/finish", webAuthnHandler.FinishLogin)
+
+		authed := passkeys.Group("", authRequired, middleware.RequireSession())
+		authed.POST("/register/begin", webAuthnHandler.BeginRegistration)
+		authed.POST("/register/finish", webAuthnHandler.FinishRegistration)
+		authed.GET("/credentials", webAuthnHandler.ListCredentials)
+		authed.DELETE("/credentials/:id", webAuthnHandler.DeleteCredential)
+	}
+
+	// Signed-in devices
+	sessions := router.Group("/auth/sessions", authRequired, middleware.RequireSession())
+	{
+		sessions.GET("", sessionHandler.ListSessions)
+		sessions.DELETE("", sessionHandler.RevokeOtherSessions)
+		sessions.DELETE("/:id", sessionHandler.RevokeSession)
+	}
+
+	// Staff endpoints, served only by this service and not routed by the gateway
+	admin := router.Group("/admin",
+		authRequired,
+		middleware.RequireRole(authz.RoleSupport, authz.RoleAdmin),
+	)
+	{
+		read := middleware.RequireScope(authz.ScopeUsersRead)
+		write := middleware.RequireScope(authz.ScopeUsersWrite)
+
+		admin.GET("/users", read, adminHandler.ListUsers)
+		admin.GET("/users/:id", read, adminHandler.GetUser)
+		admin.POST("/users/:id/unlock", write, adminHandler.UnlockUser)
+		admin.POST("/users/:id/deactivate", write, adminHandler.DeactivateUser)
+		admin.POST("/users/:id/activate", write, adminHandler.ActivateUser)
+		admin.POST("/users/:id/password-reset", write, adminHandler.ForcePasswordReset)
+		admin.DELETE("/users/:id/sessions", write, adminHandler.RevokeSessions)
+		admin.PUT("/users/:id/roles", middleware.RequireRole(authz.RoleAdmin), middleware.RequireScope(authz.ScopeRolesWrite), adminHandler.SetRoles)
+		admin.GET("/audit", middleware.RequireScope(authz.ScopeAuditRead), adminHandler.ListAudit)
+		admin.GET("/auth-events", read, authEventHandler.ListEvents)
+		admin.GET("/oauth/clients", read, oauthHandler.ListClients)
+		admin.POST("/oauth/clients", middleware.RequireRole(authz.RoleAdmin), middleware.RequireScope(authz.ScopeClientsWrite), oauthHandler.RegisterClient)
+		admin.DELETE("/oauth/clients/:id", middleware.RequireRole(authz.RoleAdmin), middleware.RequireScope(authz.ScopeClientsWrite), oauthHandler.DeleteClient)
+	}
+
+	// Service-to-service endpoints, not routed by the gateway
+	internal := router.Group("/internal", middleware.InternalTokenRequired(cfg.InternalAPIToken))
+	{
+		internal.GET("/revocations/check", internalHandler.CheckRevocation)
+		internal.POST("/tokens/verify", internalHandler.VerifyPersonalAccessToken)
 	}
 
 	return router
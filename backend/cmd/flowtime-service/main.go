@@ -12,8 +12,12 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/mdnaeem95/lifesync/backend/internal/config"
 	"github.com/mdnaeem95/lifesync/backend/internal/middleware"
+	"github.com/mdnaeem95/lifesync/backend/pkg/authz"
 	"github.com/mdnaeem95/lifesync/backend/pkg/database"
+	"github.com/mdnaeem95/lifesync/backend/pkg/jwks"
 	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/pat"
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
 	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
 	"github.com/mdnaeem95/lifesync/backend/services/flowtime/handlers"
 	"github.com/mdnaeem95/lifesync/backend/services/flowtime/repository"
@@ -28,6 +32,9 @@ func main() {
 	// Load configuration
 	cfg := config.LoadFlowTimeConfig()
 	log.WithField("config", cfg.GetSafeConfig()).Info("Configuration loaded")
+	if cfg.InternalAPIToken == "" {
+		log.Fatal("INTERNAL_API_TOKEN must be set outside development")
+	}
 
 	// Initialize database
 	db, err := database.New(cfg.DatabaseURL)
@@ -46,14 +53,25 @@ func main() {
 	energyRepo := repository.NewEnergyRepository(db, log)
 	sessionRepo := repository.NewSessionRepository(db, log)
 	prefRepo := repository.NewPreferencesRepository(db, log)
+	userDataRepo := repository.NewUserDataRepository(db, log)
 
 	// Initialize services
-	jwtService := services.NewJWTService(cfg.JWTSecret, log)
+	var jwtService services.JWTService
+	if cfg.JWKSURL != "" {
+		jwtService = services.NewJWTVerifier(jwks.NewRemoteKeySet(cfg.JWKSURL, 10*time.Minute, log), log)
+	} else {
+		jwtService = services.NewJWTService(cfg.JWTSecret, log)
+	}
+	checker := revocation.NewHTTPChecker(cfg.AuthServiceURL, cfg.InternalAPIToken, 2*time.Second)
+	revocations := revocation.NewCachedChecker(checker, cfg.RevocationCacheTTL, 10000)
+	verifier := pat.NewHTTPVerifier(cfg.AuthServiceURL, cfg.InternalAPIToken, 2*time.Second)
+	tokens := pat.NewCachedVerifier(verifier, cfg.RevocationCacheTTL, 10000)
 	taskService := flowtimeServices.NewTaskService(taskRepo, log)
 	energyService := flowtimeServices.NewEnergyService(energyRepo, log)
 	sessionService := flowtimeServices.NewSessionService(sessionRepo, taskRepo, log)
 	scheduleService := flowtimeServices.NewScheduleService(taskRepo, energyService, prefRepo, log)
 	statsService := flowtimeServices.NewStatsService(taskRepo, sessionRepo, energyRepo, log)
+	userDataService := flowtimeServices.NewUserDataService(taskRepo, energyRepo, sessionRepo, prefRepo, userDataRepo, log)
 
 	// Initialize handlers
 	taskHandler := handlers.NewTaskHandler(taskService, log)
@@ -62,9 +80,10 @@ func main() {
 	scheduleHandler := handlers.NewScheduleHandler(scheduleService, taskService, log)
 	statsHandler := handlers.NewStatsHandler(statsService, log)
 	preferencesHandler := handlers.NewPreferencesHandler(prefRepo, log)
+	internalHandler := handlers.NewInternalHandler(userDataService, userDataService, log)
 
 	// Setup router
-	router := setupRouter(cfg, jwtService, taskHandler, energyHandler, sessionHandler, scheduleHandler, statsHandler, preferencesHandler, log)
+	router := setupRouter(cfg, jwtService, revocations, tokens, taskHandler, energyHandler, sessionHandler, scheduleHandler, statsHandler, preferencesHandler, internalHandler, log)
 
 	// Create server
 	srv := &http.Server{
@@ -104,12 +123,15 @@ func main() {
 func setupRouter(
 	cfg *config.FlowTimeConfig,
 	jwtService services.JWTService,
+	revocations revocation.Checker,
+	tokens pat.Verifier,
 	taskHandler *handlers.TaskHandler,
 	energyHandler *handlers.EnergyHandler,
 	sessionHandler *handlers.SessionHandler,
 	scheduleHandler *handlers.ScheduleHandler,
 	statsHandler *handlers.StatsHandler,
 	preferencesHandler *handlers.PreferencesHandler,
+	internalHandler *handlers.InternalHandler,
 	log logger.Logger,
 ) *gin.Engine {
 	// Set Gin mode
@@ -119,8 +141,15 @@ func setupRouter(
 
 	router := gin.New()
 
+	// Only believe X-Forwarded-For from our own proxies, or any client could
+	// pick the IP address that rate limits and sign-in throttling go by
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.WithError(err).Fatal("Invalid TRUSTED_PROXIES")
+	}
+
 	// Global middleware
 	router.Use(middleware.Logger(log))
+	router.Use(middleware.Metrics("flowtime-service"))
 	router.Use(middleware.Recovery(log))
 	router.Use(middleware.CORS(cfg.AllowedOrigins))
 	router.Use(middleware.RequestID())
@@ -134,9 +163,13 @@ func setupRouter(
 		})
 	})
 
+	// Prometheus metrics
+	router.GET("/metrics", middleware.MetricsHandler())
+
 	// API routes - all require authentication
 	api := router.Group("/api/v1")
-	api.Use(middleware.AuthRequired(jwtService))
+	api.Use(middleware.AuthRequired(jwtService, revocations, tokens))
+	api.Use(middleware.RequireMethodScope(authz.ScopeFlowtimeRead, authz.ScopeFlowtimeWrite))
 	{
 		// Task routes
 		api.POST("/tasks", taskHandler.CreateTask)
@@ -178,5 +211,13 @@ func setupRouter(
 		api.PUT("/preferences", preferencesHandler.UpdatePreferences)
 	}
 
+	// Service-to-service endpoints, not routed by the gateway
+	internal := router.Group("/internal", middleware.InternalTokenRequired(cfg.InternalAPIToken))
+	{
+		internal.GET("/users/:id/export", internalHandler.ExportUserData)
+		internal.DELETE("/users/:id", internalHandler.DeleteUserData)
+		internal.DELETE("/orgs/:id", internalHandler.DeleteOrganizationData)
+	}
+
 	return router
 }
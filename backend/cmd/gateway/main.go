@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -14,12 +15,17 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/mdnaeem95/lifesync/backend/internal/config"
 	"github.com/mdnaeem95/lifesync/backend/internal/middleware"
+	"github.com/mdnaeem95/lifesync/backend/pkg/jwks"
 	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/pat"
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
 	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
+	"github.com/mdnaeem95/lifesync/backend/services/gateway/cache"
 	"github.com/mdnaeem95/lifesync/backend/services/gateway/discovery"
 	gatewayMiddleware "github.com/mdnaeem95/lifesync/backend/services/gateway/middleware"
 	"github.com/mdnaeem95/lifesync/backend/services/gateway/proxy"
 	"github.com/mdnaeem95/lifesync/backend/services/gateway/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func main() {
@@ -30,9 +36,12 @@ func main() {
 	// Load configuration
 	cfg := loadConfig()
 	log.WithField("config", cfg).Info("Configuration loaded")
+	if cfg.Auth.InternalAPIToken == "" {
+		log.Fatal("INTERNAL_API_TOKEN must be set outside development")
+	}
 
 	// Initialize service discovery
-	serviceDiscovery := discovery.NewServiceDiscovery(log, 30*time.Second)
+	serviceDiscovery := discovery.NewServiceDiscovery(log, 30*time.Second, cfg.CircuitBreaker)
 
 	// Register services
 	for name, svc := range cfg.Services {
@@ -43,6 +52,7 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go serviceDiscovery.Start(ctx)
+	discovery.RegisterMetrics(serviceDiscovery, prometheus.DefaultRegisterer)
 
 	// Initialize rate limiter
 	rateLimiter, err := ratelimit.NewRateLimiter(cfg.RateLimit, log)
@@ -51,13 +61,26 @@ func main() {
 	}
 
 	// Initialize JWT service for auth validation
-	jwtService := services.NewJWTService(cfg.Auth.JWTSecret, log)
+	var jwtService services.JWTService
+	if cfg.Auth.JWKSURL != "" {
+		jwtService = services.NewJWTVerifier(jwks.NewRemoteKeySet(cfg.Auth.JWKSURL, 10*time.Minute, log), log)
+	} else {
+		jwtService = services.NewJWTService(cfg.Auth.JWTSecret, log)
+	}
+
+	// Check access tokens against the auth service's revocation list, and
+	// have it verify personal access tokens
+	checker := revocation.NewHTTPChecker(cfg.Auth.AuthServiceURL, cfg.Auth.InternalAPIToken, 2*time.Second)
+	revocations := revocation.NewCachedChecker(checker, cfg.Auth.RevocationCacheTTL, 10000)
+	verifier := pat.NewHTTPVerifier(cfg.Auth.AuthServiceURL, cfg.Auth.InternalAPIToken, 2*time.Second)
+	tokens := pat.NewCachedVerifier(verifier, cfg.Auth.RevocationCacheTTL, 10000)
 
 	// Initialize proxy handler
 	proxyHandler := proxy.NewProxyHandler(serviceDiscovery, cfg.Services, log)
+	responseCache := cache.NewResponseCache(cfg.Services, log)
 
 	// Setup router
-	router := setupRouter(cfg, serviceDiscovery, proxyHandler, rateLimiter, jwtService, log)
+	router := setupRouter(cfg, serviceDiscovery, proxyHandler, responseCache, rateLimiter, jwtService, revocations, tokens, log)
 
 	// Create server
 	srv := &http.Server{
@@ -98,8 +121,11 @@ func setupRouter(
 	cfg config.GatewayConfig,
 	sd discovery.ServiceDiscovery,
 	proxyHandler *proxy.ProxyHandler,
+	responseCache *cache.ResponseCache,
 	rateLimiter ratelimit.RateLimiter,
 	jwtService services.JWTService,
+	revocations revocation.Checker,
+	tokens pat.Verifier,
 	log logger.Logger,
 ) *gin.Engine {
 	if cfg.Environment == "production" {
@@ -108,6 +134,12 @@ func setupRouter(
 
 	router := gin.New()
 
+	// Only believe X-Forwarded-For from our own proxies, or any client could
+	// pick the IP address that rate limits and sign-in throttling go by
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.WithError(err).Fatal("Invalid TRUSTED_PROXIES")
+	}
+
 	// Global middleware
 	router.Use(middleware.Recovery(log))
 	router.Use(middleware.RequestID())
@@ -126,10 +158,10 @@ func setupRouter(
 	api := router.Group("/api/v1")
 
 	// Apply auth middleware to API routes
-	api.Use(gatewayMiddleware.AuthMiddleware(cfg.Auth, jwtService, log))
+	api.Use(gatewayMiddleware.AuthMiddleware(cfg.Auth, jwtService, revocations, tokens, log))
 
 	// Setup service routes
-	setupServiceRoutes(api, cfg.Services, proxyHandler, rateLimiter, log)
+	setupServiceRoutes(api, cfg.Services, proxyHandler, responseCache, rateLimiter, log)
 
 	return router
 }
@@ -138,6 +170,7 @@ func setupServiceRoutes(
 	group *gin.RouterGroup,
 	services map[string]config.ServiceConfig,
 	proxyHandler *proxy.ProxyHandler,
+	responseCache *cache.ResponseCache,
 	rateLimiter ratelimit.RateLimiter,
 	log logger.Logger,
 ) {
@@ -172,13 +205,15 @@ func setupServiceRoutes(
 			return
 		}
 
-		// Set target service
+		// Set target service, and the route for metrics
 		c.Set("target_service", targetService)
+		c.Set("target_route", targetRoute.PathPrefix)
 
 		// Apply route-specific rate limit if configured
 		if targetRoute.RateLimit != nil {
 			key := fmt.Sprintf("%s:%s:%s", c.ClientIP(), targetService, fullPath)
 			if !rateLimiter.Allow(key, targetRoute.RateLimit) {
+				gatewayMiddleware.RateLimitedRequests.WithLabelValues("route").Inc()
 				c.JSON(http.StatusTooManyRequests, gin.H{
 					"error": "Rate limit exceeded",
 				})
@@ -189,8 +224,8 @@ func setupServiceRoutes(
 		// Fix the request path to include the full path
 		c.Request.URL.Path = fullPath
 
-		// Proxy the request
-		proxyHandler.HandleProxy(targetService)(c)
+		// Proxy the request, through the route's response cache
+		responseCache.Handle(c, targetService, targetRoute, proxyHandler.HandleProxy(targetService))
 	})
 }
 
@@ -234,6 +269,8 @@ func loadConfig() config.GatewayConfig {
 		Port:        8000,
 		Environment: getEnv("ENVIRONMENT", "development"),
 		LogLevel:    getEnv("LOG_LEVEL", "info"),
+		// Clients connect directly unless a load balancer is configured here
+		TrustedProxies: getEnvAsSlice("TRUSTED_PROXIES"),
 		Services: map[string]config.ServiceConfig{
 			"auth": {
 				Name:            "auth",
@@ -250,6 +287,12 @@ func loadConfig() config.GatewayConfig {
 						TargetPath:   "",
 						RequiresAuth: false,
 					},
+					{
+						Method:       "*",
+						PathPrefix:   "/oauth",
+						TargetPath:   "",
+						RequiresAuth: false,
+					},
 				},
 			},
 			"flowtime": {
@@ -260,8 +303,35 @@ func loadConfig() config.GatewayConfig {
 				RetryCount:      2,
 				StripPrefix:     false,
 				RequiresAuth:    true,
+				LoadBalancing: config.LoadBalanceConfig{
+					Strategy: getEnv("FLOWTIME_LB_STRATEGY", "round-robin"),
+					Backends: getEnvAsSlice("FLOWTIME_BACKENDS"),
+					Weights:  getEnvAsWeights("FLOWTIME_BACKEND_WEIGHTS"),
+				},
 				Routes: []config.RouteConfig{
 					{Method: "*", PathPrefix: "/tasks", RequiresAuth: true},
+					{
+						Method:       "GET",
+						PathPrefix:   "/energy/patterns",
+						RequiresAuth: true,
+						CacheConfig: &config.CacheConfig{
+							Enabled:      true,
+							TTL:          10 * time.Minute,
+							MaxSize:      8 << 20,
+							InvalidateOn: []string{"/energy"},
+						},
+					},
+					{
+						Method:       "GET",
+						PathPrefix:   "/stats/insights",
+						RequiresAuth: true,
+						CacheConfig: &config.CacheConfig{
+							Enabled:      true,
+							TTL:          10 * time.Minute,
+							MaxSize:      8 << 20,
+							InvalidateOn: []string{"/tasks", "/sessions", "/energy", "/preferences"},
+						},
+					},
 					{Method: "*", PathPrefix: "/energy", RequiresAuth: true},
 					{Method: "*", PathPrefix: "/sessions", RequiresAuth: true},
 					{Method: "*", PathPrefix: "/schedule", RequiresAuth: true},
@@ -276,17 +346,38 @@ func loadConfig() config.GatewayConfig {
 			BurstSize:       10,
 			ByIP:            true,
 			ByUser:          true,
-			Storage:         "memory",
+			Storage:         getEnv("RATE_LIMIT_STORAGE", "memory"),
+			RedisURL:        getEnv("REDIS_URL", ""),
+			RedisPoolSize:   getEnvAsInt("REDIS_POOL_SIZE", 20),
 			CleanupInterval: 5 * time.Minute,
+			FailOpen:        getEnvAsBool("RATE_LIMIT_FAIL_OPEN", true),
 		},
 		Auth: config.AuthGatewayConfig{
 			JWTSecret: getEnv("JWT_SECRET", "development-secret-key"),
+			JWKSURL:   getEnv("JWT_JWKS_URL", ""),
+
+			AuthServiceURL:     getEnv("AUTH_SERVICE_URL", "http://auth-service:8080"),
+			InternalAPIToken:   config.InternalAPIToken(),
+			RevocationCacheTTL: 15 * time.Second,
+
 			SkipPaths: []string{
 				"/health",
 				"/metrics",
 				"/api/v1/auth/signin",
+				"/api/v1/auth/signin/2fa",
 				"/api/v1/auth/signup",
 				"/api/v1/auth/refresh",
+				"/api/v1/auth/verify-email/*",
+				"/api/v1/auth/confirm-email/*",
+				"/api/v1/auth/reset-password",
+				"/api/v1/auth/reset-password/*",
+				"/api/v1/auth/oauth/*",
+				"/api/v1/auth/magic-link",
+				"/api/v1/auth/magic-link/*",
+				"/api/v1/auth/webauthn/login/*",
+				"/api/v1/oauth/token",
+				"/api/v1/oauth/introspect",
+				"/api/v1/oauth/revoke",
 			},
 		},
 		Timeouts: config.TimeoutConfig{
@@ -300,7 +391,7 @@ func loadConfig() config.GatewayConfig {
 		CORS: config.CORSConfig{
 			AllowedOrigins:   []string{"http://localhost:3000", "http://localhost:8080"},
 			AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-			AllowedHeaders:   []string{"Origin", "Content-Type", "Accept", "Authorization"},
+			AllowedHeaders:   []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Device-Name", "X-Device-ID"},
 			ExposedHeaders:   []string{"Content-Length", "X-Request-ID"},
 			AllowCredentials: true,
 			MaxAge:           12 * 3600,
@@ -321,3 +412,40 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(getEnv(key, "")); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvAsInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(getEnv(key, "")); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvAsSlice(key string) []string {
+	value := getEnv(key, "")
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// getEnvAsWeights parses backend weights written as url=weight,url=weight
+func getEnvAsWeights(key string) map[string]int {
+	weights := make(map[string]int)
+	for _, entry := range getEnvAsSlice(key) {
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			continue
+		}
+		if weight, err := strconv.Atoi(entry[i+1:]); err == nil {
+			weights[entry[:i]] = weight
+		}
+	}
+	return weights
+}
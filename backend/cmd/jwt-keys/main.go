@@ -0,0 +1,138 @@
+// Command jwt-keys manages the auth service's token signing keys.
+//
+//	jwt-keys rotate -dir /etc/flowtime/jwt-keys -alg EdDSA
+//	jwt-keys list -dir /etc/flowtime/jwt-keys
+//	jwt-keys prune -dir /etc/flowtime/jwt-keys
+//
+// rotate is meant to run on a schedule (e.g. a monthly cron job). A new key
+// is published immediately but only starts signing after -activate-in, so
+// every auth instance and JWKS consumer has picked it up before tokens
+// signed with it appear. Keys past their verify window are pruned.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/jwks"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "rotate":
+		err = rotate(os.Args[2:])
+	case "list":
+		err = list(os.Args[2:])
+	case "prune":
+		err = prune(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: jwt-keys <rotate|list|prune> [flags]")
+}
+
+func defaultDir() string {
+	if dir := os.Getenv("JWT_SIGNING_KEYS_DIR"); dir != "" {
+		return dir
+	}
+	return "keys"
+}
+
+func defaultVerifyWindow() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("JWT_KEY_VERIFY_WINDOW")); err == nil {
+		return d
+	}
+	return 31 * 24 * time.Hour
+}
+
+func rotate(args []string) error {
+	fs := flag.NewFlagSet("rotate", flag.ExitOnError)
+	dir := fs.String("dir", defaultDir(), "signing key directory")
+	alg := fs.String("alg", jwks.AlgEdDSA, "signing algorithm: EdDSA, RS256 or ES256")
+	bits := fs.Int("bits", 3072, "RSA key size")
+	activateIn := fs.Duration("activate-in", 15*time.Minute, "delay before the new key starts signing")
+	verifyWindow := fs.Duration("verify-window", defaultVerifyWindow(), "how long superseded keys keep verifying tokens")
+	_ = fs.Parse(args)
+
+	key, err := jwks.GenerateKey(*alg, *bits, time.Now().Add(*activateIn))
+	if err != nil {
+		return err
+	}
+
+	path, err := jwks.WriteKeyFile(*dir, key)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created %s key %s (%s), active from %s\n", key.Alg, key.Kid, path, key.ActiveAt.Format(time.RFC3339))
+
+	removed, err := jwks.PruneKeyDir(*dir, *verifyWindow)
+	for _, kid := range removed {
+		fmt.Printf("removed expired key %s\n", kid)
+	}
+	return err
+}
+
+func list(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	dir := fs.String("dir", defaultDir(), "signing key directory")
+	verifyWindow := fs.Duration("verify-window", defaultVerifyWindow(), "how long superseded keys keep verifying tokens")
+	_ = fs.Parse(args)
+
+	ring, err := jwks.LoadKeyRing(*dir, *verifyWindow)
+	if err != nil {
+		return err
+	}
+
+	var signingKid string
+	if key, err := ring.SigningKey(); err == nil {
+		signingKid = key.Kid
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KID\tALG\tACTIVE AT\tSTATUS")
+	for _, key := range ring.Keys() {
+		status := "verifying until " + key.ExpiresAt.Format(time.RFC3339)
+		switch {
+		case key.Kid == signingKid:
+			status = "signing"
+		case key.ActiveAt.After(now):
+			status = "published"
+		case !key.ExpiresAt.IsZero() && now.After(key.ExpiresAt):
+			status = "expired"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", key.Kid, key.Alg, key.ActiveAt.Format(time.RFC3339), status)
+	}
+	return w.Flush()
+}
+
+func prune(args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	dir := fs.String("dir", defaultDir(), "signing key directory")
+	verifyWindow := fs.Duration("verify-window", defaultVerifyWindow(), "how long superseded keys keep verifying tokens")
+	_ = fs.Parse(args)
+
+	removed, err := jwks.PruneKeyDir(*dir, *verifyWindow)
+	for _, kid := range removed {
+		fmt.Printf("removed expired key %s\n", kid)
+	}
+	return err
+}
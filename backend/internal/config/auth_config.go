@@ -4,6 +4,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type AuthConfig struct {
@@ -15,6 +16,14 @@ type AuthConfig struct {
 	LogLevel           string
 	RateLimitPerMinute int
 	AllowedOrigins     []string
+	// TrustedProxies are the addresses or CIDRs whose X-Forwarded-For is
+	// believed; the client IP is the connection's address for everyone else
+	TrustedProxies []string
+
+	// Asymmetric token signing (HS256 with JWTSecret when no key directory is set)
+	JWTSigningKeysDir    string
+	JWTKeyVerifyWindow   time.Duration
+	JWTKeyReloadInterval time.Duration
 
 	// Email configuration (optional)
 	SMTPHost     string
@@ -22,6 +31,96 @@ type AuthConfig struct {
 	SMTPUser     string
 	SMTPPassword string
 	FromEmail    string
+
+	// Email delivery
+	MailBackend        string // smtp, file, log
+	MailOutputDir      string
+	AppBaseURL         string
+	OutboxPollInterval time.Duration
+	OutboxBatchSize    int
+	OutboxMaxAttempts  int
+
+	// Email verification
+	RequireEmailVerification bool
+	EmailVerificationTTL     time.Duration
+
+	// Social sign-in (OpenID Connect)
+	GoogleClientIDs []string
+	GoogleJWKSURL   string
+	AppleClientIDs  []string
+	AppleJWKSURL    string
+
+	// Passkeys (WebAuthn)
+	WebAuthnRPID         string
+	WebAuthnRPName       string
+	WebAuthnOrigins      []string
+	WebAuthnChallengeTTL time.Duration
+	// Key for the decoy credentials offered to emails without passkeys
+	WebAuthnDecoySecret string
+
+	// Passwordless sign-in links
+	MagicLinkTTL time.Duration
+
+	// Accounts without a password confirm sensitive changes by having
+	// signed in this recently
+	RecentSignInWindow time.Duration
+
+	// Sign-in throttling. Failures are counted per account and per IP; after
+	// the free attempts each failure doubles the wait before the next try,
+	// and reaching the maximum locks sign-in for the lockout duration.
+	LoginFreeAttempts    int
+	LoginMaxFailures     int
+	LoginIPFreeAttempts  int
+	LoginIPMaxFailures   int
+	LoginBaseDelay       time.Duration
+	LoginMaxDelay        time.Duration
+	LoginLockoutDuration time.Duration
+	LoginFailureWindow   time.Duration
+
+	// Accounts granted the admin role at startup
+	AdminEmails []string
+
+	// Service-to-service calls and access token revocation
+	InternalAPIToken   string
+	RevocationCacheTTL time.Duration
+
+	// Account deletion and data export. Services holding user data are
+	// asked to export or erase it through their internal API.
+	FlowTimeServiceURL         string
+	AccountDeletionGracePeriod time.Duration
+
+	// Password policy. The breached list is a local file of SHA-1 prefixes;
+	// the check is skipped when no file is configured.
+	PasswordMinLength        int
+	PasswordMinScore         int
+	PasswordHistory          int
+	PasswordBreachedListFile string
+
+	// Password hashing. New hashes use the algorithm; stored hashes of the
+	// other algorithm or other parameters are replaced at the next sign-in.
+	// Argon2Memory is in KiB.
+	PasswordHashAlgorithm string
+	Argon2Memory          int
+	Argon2Iterations      int
+	Argon2Parallelism     int
+	BcryptCost            int
+
+	// How long auth events are kept before they are purged
+	AuthEventRetention time.Duration
+
+	// Login history. Users are alerted to sign-ins from devices and
+	// networks not in their history.
+	LoginHistoryRetention time.Duration
+	NewLoginAlerts        bool
+
+	// OAuth2 provider. Authorization codes must be exchanged within
+	// OAuthCodeTTL; refresh tokens issued to apps last OAuthRefreshTokenTTL
+	// from their last use.
+	OAuthCodeTTL         time.Duration
+	OAuthRefreshTokenTTL time.Duration
+
+	// How long an invitation to join an organization can be accepted
+	OrgInvitationTTL time.Duration
 }
 
 func LoadAuthConfig() *AuthConfig {
@@ -34,6 +133,12 @@ func LoadAuthConfig() *AuthConfig {
 		LogLevel:           getEnv("LOG_LEVEL", "info"),
 		RateLimitPerMinute: getEnvAsInt("RATE_LIMIT_PER_MINUTE", 5),
 		AllowedOrigins:     getEnvAsSlice("ALLOWED_ORIGINS", []string{"http://localhost:3000"}),
+		TrustedProxies:     getEnvAsSlice("TRUSTED_PROXIES", nil),
+
+		// Token signing keys
+		JWTSigningKeysDir:    getEnv("JWT_SIGNING_KEYS_DIR", ""),
+		JWTKeyVerifyWindow:   getEnvAsDuration("JWT_KEY_VERIFY_WINDOW", 31*24*time.Hour),
+		JWTKeyReloadInterval: getEnvAsDuration("JWT_KEY_RELOAD_INTERVAL", time.Minute),
 
 		// Email configuration
 		SMTPHost:     getEnv("SMTP_HOST", ""),
@@ -41,6 +146,96 @@ func LoadAuthConfig() *AuthConfig {
 		SMTPUser:     getEnv("SMTP_USER", ""),
 		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
 		FromEmail:    getEnv("FROM_EMAIL", "noreply@flowtime.app"),
+
+		// Email delivery
+		MailBackend:        getEnv("MAIL_BACKEND", ""),
+		MailOutputDir:      getEnv("MAIL_OUTPUT_DIR", "tmp/mail"),
+		AppBaseURL:         strings.TrimSuffix(getEnv("APP_BASE_URL", "http://localhost:3000"), "/"),
+		OutboxPollInterval: getEnvAsDuration("EMAIL_OUTBOX_POLL_INTERVAL", 5*time.Second),
+		OutboxBatchSize:    getEnvAsInt("EMAIL_OUTBOX_BATCH_SIZE", 20),
+		OutboxMaxAttempts:  getEnvAsInt("EMAIL_OUTBOX_MAX_ATTEMPTS", 8),
+
+		// Email verification
+		RequireEmailVerification: getEnvAsBool("REQUIRE_EMAIL_VERIFICATION", false),
+		EmailVerificationTTL:     getEnvAsDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+
+		// Social sign-in
+		GoogleClientIDs: getEnvAsSlice("GOOGLE_CLIENT_IDS", nil),
+		GoogleJWKSURL:   getEnv("GOOGLE_JWKS_URL", "https://www.googleapis.com/oauth2/v3/certs"),
+		AppleClientIDs:  getEnvAsSlice("APPLE_CLIENT_IDS", nil),
+		AppleJWKSURL:    getEnv("APPLE_JWKS_URL", "https://appleid.apple.com/auth/keys"),
+
+		// Passkeys
+		WebAuthnRPID:         getEnv("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnRPName:       getEnv("WEBAUTHN_RP_NAME", "FlowTime"),
+		WebAuthnOrigins:      getEnvAsSlice("WEBAUTHN_ORIGINS", []string{"http://localhost:3000"}),
+		WebAuthnChallengeTTL: getEnvAsDuration("WEBAUTHN_CHALLENGE_TTL", 5*time.Minute),
+		WebAuthnDecoySecret:  getEnv("WEBAUTHN_DECOY_SECRET", ""),
+
+		// Magic links
+		MagicLinkTTL: getEnvAsDuration("MAGIC_LINK_TTL", 15*time.Minute),
+
+		// Re-authentication
+		RecentSignInWindow: getEnvAsDuration("RECENT_SIGN_IN_WINDOW", 10*time.Minute),
+
+		// Sign-in throttling
+		LoginFreeAttempts:    getEnvAsInt("LOGIN_FREE_ATTEMPTS", 3),
+		LoginMaxFailures:     getEnvAsInt("LOGIN_MAX_FAILURES", 10),
+		LoginIPFreeAttempts:  getEnvAsInt("LOGIN_IP_FREE_ATTEMPTS", 20),
+		LoginIPMaxFailures:   getEnvAsInt("LOGIN_IP_MAX_FAILURES", 100),
+		LoginBaseDelay:       getEnvAsDuration("LOGIN_BASE_DELAY", time.Second),
+		LoginMaxDelay:        getEnvAsDuration("LOGIN_MAX_DELAY", time.Minute),
+		LoginLockoutDuration: getEnvAsDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		LoginFailureWindow:   getEnvAsDuration("LOGIN_FAILURE_WINDOW", time.Hour),
+
+		// Admin API
+		AdminEmails: getEnvAsSlice("ADMIN_EMAILS", []string{}),
+
+		// Internal API
+		InternalAPIToken:   InternalAPIToken(),
+		RevocationCacheTTL: getEnvAsDuration("REVOCATION_CACHE_TTL", 15*time.Second),
+
+		// Account deletion
+		FlowTimeServiceURL:         getEnv("FLOWTIME_SERVICE_URL", "http://flowtime-service:8081"),
+		AccountDeletionGracePeriod: getEnvAsDuration("ACCOUNT_DELETION_GRACE_PERIOD", 14*24*time.Hour),
+
+		// Password policy
+		PasswordMinLength:        getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMinScore:         getEnvAsInt("PASSWORD_MIN_SCORE", 2),
+		PasswordHistory:          getEnvAsInt("PASSWORD_HISTORY", 5),
+		PasswordBreachedListFile: getEnv("PASSWORD_BREACHED_LIST_FILE", ""),
+
+		// Password hashing
+		PasswordHashAlgorithm: getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+		Argon2Memory:          getEnvAsInt("ARGON2_MEMORY", 64*1024),
+		Argon2Iterations:      getEnvAsInt("ARGON2_ITERATIONS", 3),
+		Argon2Parallelism:     getEnvAsInt("ARGON2_PARALLELISM", 2),
+		BcryptCost:            getEnvAsInt("BCRYPT_COST", 10),
+
+		// Auth event log
+		AuthEventRetention: getEnvAsDuration("AUTH_EVENT_RETENTION", 90*24*time.Hour),
+
+		// Login history
+		LoginHistoryRetention: getEnvAsDuration("LOGIN_HISTORY_RETENTION", 180*24*time.Hour),
+		NewLoginAlerts:        getEnvAsBool("NEW_LOGIN_ALERTS", true),
+
+		// OAuth2 provider
+		OAuthCodeTTL:         getEnvAsDuration("OAUTH_CODE_TTL", time.Minute),
+		OAuthRefreshTokenTTL: getEnvAsDuration("OAUTH_REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		// Organizations
+		OrgInvitationTTL: getEnvAsDuration("ORG_INVITATION_TTL", 7*24*time.Hour),
+	}
+
+	// Default to SMTP when a mail server is configured. Other backends must
+	// be chosen explicitly.
+	if cfg.MailBackend == "" && cfg.SMTPHost != "" {
+		cfg.MailBackend = "smtp"
+	}
+
+	// Any secret the deployment already keeps will do for passkey decoys
+	if cfg.WebAuthnDecoySecret == "" {
+		cfg.WebAuthnDecoySecret = cfg.JWTSecret
 	}
 
 	return cfg
@@ -54,11 +249,77 @@ func (c *AuthConfig) GetSafeConfig() map[string]interface{} {
 		"port":            c.Port,
 		"database_url":    redactConnectionString(c.DatabaseURL),
 		"jwt_secret":      redact(c.JWTSecret),
+		"jwt_keys_dir":    c.JWTSigningKeysDir,
 		"log_level":       c.LogLevel,
 		"rate_limit":      c.RateLimitPerMinute,
 		"allowed_origins": c.AllowedOrigins,
+		"trusted_proxies": c.TrustedProxies,
 		"smtp_configured": c.SMTPHost != "",
+		"mail_backend":    c.MailBackend,
+		"app_base_url":    c.AppBaseURL,
+
+		"require_email_verification": c.RequireEmailVerification,
+		"email_verification_ttl":     c.EmailVerificationTTL.String(),
+
+		"google_sign_in": len(c.GoogleClientIDs) > 0,
+		"apple_sign_in":  len(c.AppleClientIDs) > 0,
+
+		"webauthn_rp_id":         c.WebAuthnRPID,
+		"webauthn_origins":       c.WebAuthnOrigins,
+		"webauthn_challenge_ttl": c.WebAuthnChallengeTTL.String(),
+
+		"magic_link_ttl": c.MagicLinkTTL.String(),
+
+		"recent_sign_in_window": c.RecentSignInWindow.String(),
+
+		"login_max_failures":     c.LoginMaxFailures,
+		"login_ip_max_failures":  c.LoginIPMaxFailures,
+		"login_lockout_duration": c.LoginLockoutDuration.String(),
+
+		"admin_emails": len(c.AdminEmails),
+
+		"internal_api_token":   redact(c.InternalAPIToken),
+		"revocation_cache_ttl": c.RevocationCacheTTL.String(),
+
+		"flowtime_service_url":          c.FlowTimeServiceURL,
+		"account_deletion_grace_period": c.AccountDeletionGracePeriod.String(),
+
+		"password_min_length":    c.PasswordMinLength,
+		"password_min_score":     c.PasswordMinScore,
+		"password_history":       c.PasswordHistory,
+		"password_breached_list": c.PasswordBreachedListFile,
+
+		"password_hash_algorithm": c.PasswordHashAlgorithm,
+		"argon2_memory":           c.Argon2Memory,
+		"argon2_iterations":       c.Argon2Iterations,
+		"argon2_parallelism":      c.Argon2Parallelism,
+		"bcrypt_cost":             c.BcryptCost,
+
+		"auth_event_retention": c.AuthEventRetention.String(),
+
+		"login_history_retention": c.LoginHistoryRetention.String(),
+		"new_login_alerts":        c.NewLoginAlerts,
+
+		"oauth_code_ttl":          c.OAuthCodeTTL.String(),
+		"oauth_refresh_token_ttl": c.OAuthRefreshTokenTTL.String(),
+
+		"org_invitation_ttl": c.OrgInvitationTTL.String(),
+	}
+}
+
+// developmentInternalAPIToken lets services talk to each other in
+// development without any setup
+const developmentInternalAPIToken = "development-internal-token"
+
+// InternalAPIToken returns INTERNAL_API_TOKEN, the shared secret for
+// service-to-service calls. Only development falls back on a built-in
+// token; elsewhere it is empty when unset, and the services refuse to start
+// rather than run with a secret anyone can read here.
+func InternalAPIToken() string {
+	if getEnv("ENVIRONMENT", "development") == "development" {
+		return getEnv("INTERNAL_API_TOKEN", developmentInternalAPIToken)
 	}
+	return getEnv("INTERNAL_API_TOKEN", "")
 }
 
 // Helper functions
@@ -77,6 +338,22 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	valueStr := getEnv(key, "")
+	if value, err := time.ParseDuration(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
 func getEnvAsSlice(key string, defaultValue []string) []string {
 	valueStr := getEnv(key, "")
 	if valueStr == "" {
@@ -15,6 +15,9 @@ type GatewayConfig struct {
 	Timeouts       TimeoutConfig            `yaml:"timeouts" json:"timeouts"`
 	CORS           CORSConfig               `yaml:"cors" json:"cors"`
 	CircuitBreaker CircuitBreakerConfig     `yaml:"circuit_breaker" json:"circuit_breaker"`
+	// TrustedProxies are the load balancers in front of the gateway, by
+	// address or CIDR; X-Forwarded-For is ignored from anyone else
+	TrustedProxies []string `yaml:"trusted_proxies" json:"trusted_proxies"`
 }
 
 // ServiceConfig represents configuration for a single service
@@ -44,14 +47,19 @@ type RouteConfig struct {
 
 // RateLimitConfig represents rate limiting configuration
 type RateLimitConfig struct {
-	Enabled         bool          `yaml:"enabled" json:"enabled"`
-	RequestsPerMin  int           `yaml:"requests_per_min" json:"requests_per_min"`
-	BurstSize       int           `yaml:"burst_size" json:"burst_size"`
-	ByIP            bool          `yaml:"by_ip" json:"by_ip"`
-	ByUser          bool          `yaml:"by_user" json:"by_user"`
-	Storage         string        `yaml:"storage" json:"storage"` // memory, redis
-	RedisURL        string        `yaml:"redis_url,omitempty" json:"redis_url,omitempty"`
+	Enabled        bool   `yaml:"enabled" json:"enabled"`
+	RequestsPerMin int    `yaml:"requests_per_min" json:"requests_per_min"`
+	BurstSize      int    `yaml:"burst_size" json:"burst_size"`
+	ByIP           bool   `yaml:"by_ip" json:"by_ip"`
+	ByUser         bool   `yaml:"by_user" json:"by_user"`
+	Storage        string `yaml:"storage" json:"storage"` // memory, redis
+	RedisURL       string `yaml:"redis_url,omitempty" json:"-"`
+	// RedisPoolSize caps the connections each gateway keeps to Redis
+	RedisPoolSize   int           `yaml:"redis_pool_size" json:"redis_pool_size"`
 	CleanupInterval time.Duration `yaml:"cleanup_interval" json:"cleanup_interval"`
+	// FailOpen lets requests through when Redis can't be reached, instead
+	// of rejecting them
+	FailOpen bool `yaml:"fail_open" json:"fail_open"`
 }
 
 // RateLimitRule represents a specific rate limit rule
@@ -63,10 +71,15 @@ type RateLimitRule struct {
 // AuthConfig represents authentication configuration
 type AuthGatewayConfig struct {
 	JWTSecret      string        `yaml:"jwt_secret" json:"jwt_secret"`
+	JWKSURL        string        `yaml:"jwks_url" json:"jwks_url"`
 	SkipPaths      []string      `yaml:"skip_paths" json:"skip_paths"`
 	AuthServiceURL string        `yaml:"auth_service_url" json:"auth_service_url"`
 	CacheTokens    bool          `yaml:"cache_tokens" json:"cache_tokens"`
 	TokenCacheTTL  time.Duration `yaml:"token_cache_ttl" json:"token_cache_ttl"`
+
+	// Revocation checks against the auth service (disabled without a token)
+	InternalAPIToken   string        `yaml:"internal_api_token" json:"-"`
+	RevocationCacheTTL time.Duration `yaml:"revocation_cache_ttl" json:"revocation_cache_ttl"`
 }
 
 // TimeoutConfig represents timeout settings
@@ -98,20 +111,29 @@ type CircuitBreakerConfig struct {
 	MaxConcurrentRequests int           `yaml:"max_concurrent_requests" json:"max_concurrent_requests"`
 }
 
-// LoadBalanceConfig represents load balancing configuration
+// LoadBalanceConfig represents load balancing configuration. Without
+// Backends the service's URL is its only instance.
 type LoadBalanceConfig struct {
-	Strategy string   `yaml:"strategy" json:"strategy"` // round-robin, random, least-conn
+	Strategy string   `yaml:"strategy" json:"strategy"` // round-robin, random, least-conn, weighted-round-robin
 	Backends []string `yaml:"backends" json:"backends"`
+	// Weights for weighted-round-robin, keyed by backend URL; backends
+	// without a positive weight count as 1
+	Weights map[string]int `yaml:"weights,omitempty" json:"weights,omitempty"`
 }
 
-// CacheConfig represents caching configuration for routes
+// CacheConfig represents caching configuration for routes. Cached responses
+// are kept per user, and dropped when the user changes anything under the
+// route's path prefix or under one of the InvalidateOn prefixes.
 type CacheConfig struct {
-	Enabled bool          `yaml:"enabled" json:"enabled"`
-	TTL     time.Duration `yaml:"ttl" json:"ttl"`
-	MaxSize int           `yaml:"max_size" json:"max_size"`
+	Enabled      bool          `yaml:"enabled" json:"enabled"`
+	TTL          time.Duration `yaml:"ttl" json:"ttl"`
+	MaxSize      int           `yaml:"max_size" json:"max_size"` // bytes
+	InvalidateOn []string      `yaml:"invalidate_on,omitempty" json:"invalidate_on,omitempty"`
 }
 
-// ServiceHealth represents the health status of a service
+// ServiceHealth represents the health status of a service, or of one of its
+// instances. A service is degraded when only some of its instances are
+// healthy.
 type ServiceHealth struct {
 	Name         string        `json:"name"`
 	URL          string        `json:"url"`
@@ -119,4 +141,9 @@ type ServiceHealth struct {
 	LastChecked  time.Time     `json:"last_checked"`
 	ResponseTime time.Duration `json:"response_time"`
 	Error        string        `json:"error,omitempty"`
+
+	// Instance details
+	CircuitState      string           `json:"circuit_state,omitempty"` // closed, open, half-open
+	ActiveConnections int64            `json:"active_connections,omitempty"`
+	Instances         []*ServiceHealth `json:"instances,omitempty"`
 }
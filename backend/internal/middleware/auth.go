@@ -1,15 +1,22 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mdnaeem95/lifesync/backend/pkg/authz"
+	"github.com/mdnaeem95/lifesync/backend/pkg/pat"
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
 	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
 )
 
-// AuthRequired validates JWT tokens
-func AuthRequired(jwtService services.JWTService) gin.HandlerFunc {
+// AuthRequired validates JWT tokens and rejects revoked ones. Personal
+// access tokens are accepted in place of a JWT when tokens is set.
+// revocations may be nil to skip the revocation check.
+func AuthRequired(jwtService services.JWTService, revocations revocation.Checker, tokens pat.Verifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get token from Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -29,6 +36,13 @@ func AuthRequired(jwtService services.JWTService) gin.HandlerFunc {
 
 		token := parts[1]
 
+		if pat.IsToken(token) {
+			if authenticatePersonalAccessToken(c, tokens, token) {
+				c.Next()
+			}
+			return
+		}
+
 		// Validate token
 		claims, err := jwtService.ValidateAccessToken(token)
 		if err != nil {
@@ -37,16 +51,36 @@ func AuthRequired(jwtService services.JWTService) gin.HandlerFunc {
 			return
 		}
 
+		accessToken := claims.RevocationToken()
+		if isRevoked(c, revocations, accessToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
 		// Store user information in context
 		c.Set("userID", claims.UserID)
 		c.Set("userEmail", claims.Email)
+		c.Set("sessionID", claims.SessionID)
+		c.Set("oauthClientID", claims.ClientID)
+		c.Set("orgID", claims.OrgID)
+		c.Set("accessToken", accessToken)
+		c.Set("roles", claims.Roles)
+		c.Set("scopes", claims.Scopes())
+
+		// Repositories scope their queries to the organization through the
+		// request context
+		if claims.OrgID != "" {
+			c.Request = c.Request.WithContext(authz.WithOrg(c.Request.Context(), claims.OrgID))
+		}
 
 		c.Next()
 	}
 }
 
-// OptionalAuth validates JWT tokens if present but doesn't require them
-func OptionalAuth(jwtService services.JWTService) gin.HandlerFunc {
+// OptionalAuth validates JWT tokens and personal access tokens if present
+// but doesn't require them
+func OptionalAuth(jwtService services.JWTService, revocations revocation.Checker, tokens pat.Verifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -61,12 +95,80 @@ func OptionalAuth(jwtService services.JWTService) gin.HandlerFunc {
 		}
 
 		token := parts[1]
+		if pat.IsToken(token) {
+			if tokens != nil {
+				if principal, err := tokens.Verify(c.Request.Context(), token); err == nil {
+					setPrincipal(c, principal)
+				}
+			}
+			c.Next()
+			return
+		}
+
 		claims, err := jwtService.ValidateAccessToken(token)
-		if err == nil {
+		if err == nil && !isRevoked(c, revocations, claims.RevocationToken()) {
 			c.Set("userID", claims.UserID)
 			c.Set("userEmail", claims.Email)
+			c.Set("roles", claims.Roles)
+			c.Set("scopes", claims.Scopes())
+			if claims.OrgID != "" {
+				c.Set("orgID", claims.OrgID)
+				c.Request = c.Request.WithContext(authz.WithOrg(c.Request.Context(), claims.OrgID))
+			}
 		}
 
 		c.Next()
 	}
 }
+
+// isRevoked fails open: if the revocation store can't be reached the token
+// is accepted and the error is attached to the request for the logger
+func isRevoked(c *gin.Context, revocations revocation.Checker, token revocation.Token) bool {
+	if revocations == nil {
+		return false
+	}
+
+	revoked, err := revocations.IsRevoked(c.Request.Context(), token)
+	if err != nil {
+		c.Error(fmt.Errorf("token revocation check failed: %w", err))
+		return false
+	}
+
+	return revoked
+}
+
+// authenticatePersonalAccessToken verifies a personal access token and
+// stores its owner in the context. It writes the error response and reports
+// false if the token can't be used. Unlike the revocation check this fails
+// closed, since without the auth service nothing is known about the token.
+func authenticatePersonalAccessToken(c *gin.Context, tokens pat.Verifier, token string) bool {
+	if tokens == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		c.Abort()
+		return false
+	}
+
+	principal, err := tokens.Verify(c.Request.Context(), token)
+	if err != nil {
+		if errors.Is(err, pat.ErrInvalidToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		} else {
+			c.Error(fmt.Errorf("personal access token verification failed: %w", err))
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify token"})
+		}
+		c.Abort()
+		return false
+	}
+
+	setPrincipal(c, principal)
+	return true
+}
+
+// setPrincipal stores the owner of a personal access token in the context.
+// There is no session, and no roles: the token can only use its scopes.
+func setPrincipal(c *gin.Context, principal *pat.Principal) {
+	c.Set("userID", principal.UserID)
+	c.Set("userEmail", principal.Email)
+	c.Set("scopes", principal.Scopes)
+	c.Set("personalAccessTokenID", principal.TokenID)
+}
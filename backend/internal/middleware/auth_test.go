@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mdnaeem95/lifesync/backend/pkg/pat"
+)
+
+// stubVerifier answers every verification with principal and err
+type stubVerifier struct {
+	principal *pat.Principal
+	err       error
+}
+
+func (v stubVerifier) Verify(ctx context.Context, token string) (*pat.Principal, error) {
+	return v.principal, v.err
+}
+
+func TestAuthRequiredPersonalAccessToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	principal := &pat.Principal{TokenID: "token-1", UserID: "user-1", Email: "user@example.com", Scopes: []string{"flowtime:read"}}
+
+	tests := []struct {
+		name       string
+		tokens     pat.Verifier
+		wantStatus int
+	}{
+		{"valid token", stubVerifier{principal: principal}, http.StatusOK},
+		{"invalid token", stubVerifier{err: pat.ErrInvalidToken}, http.StatusUnauthorized},
+		{"verifier unavailable", stubVerifier{err: errors.New("auth service unavailable")}, http.StatusServiceUnavailable},
+		{"no verifier configured", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reached bool
+			var got map[string]any
+
+			router := gin.New()
+			router.GET("/", AuthRequired(nil, nil, tt.tokens), func(c *gin.Context) {
+				reached = true
+				got = c.Keys
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+pat.Prefix+"0123456789abcdef")
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if reached != (tt.wantStatus == http.StatusOK) {
+				t.Fatalf("handler reached = %v", reached)
+			}
+			if !reached {
+				return
+			}
+
+			if got["userID"] != "user-1" || got["userEmail"] != "user@example.com" || got["personalAccessTokenID"] != "token-1" {
+				t.Errorf("context = %v", got)
+			}
+			if !reflect.DeepEqual(got["scopes"], principal.Scopes) {
+				t.Errorf("scopes = %v, want %v", got["scopes"], principal.Scopes)
+			}
+			for _, key := range []string{"roles", "sessionID", "accessToken"} {
+				if _, ok := got[key]; ok {
+					t.Errorf("%s set for a personal access token", key)
+				}
+			}
+		})
+	}
+}
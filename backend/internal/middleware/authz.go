@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mdnaeem95/lifesync/backend/pkg/authz"
+)
+
+// RequireRole lets the request through if the access token holds any of
+// roles. It must run after AuthRequired.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authz.HasAny(c.GetStringSlice("roles"), roles...) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireScope lets the request through if the access token carries every
+// one of scopes. It must run after AuthRequired.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authz.HasAll(c.GetStringSlice("scopes"), scopes...) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":          "Insufficient scope",
+				"required_scope": authz.FormatScope(scopes),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireMethodScope requires readScope for GET and HEAD requests and
+// writeScope for everything else. It must run after AuthRequired.
+func RequireMethodScope(readScope, writeScope string) gin.HandlerFunc {
+	read := RequireScope(readScope)
+	write := RequireScope(writeScope)
+
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead:
+			read(c)
+		default:
+			write(c)
+		}
+	}
+}
+
+// RequireSession turns away personal access tokens and tokens issued to
+// third-party apps, for endpoints that manage sign-in, credentials or other
+// users. It must run after AuthRequired.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("personalAccessTokenID") != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not available with a personal access token"})
+			c.Abort()
+			return
+		}
+		if c.GetString("oauthClientID") != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not available to third-party apps"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
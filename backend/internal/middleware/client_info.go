@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+)
+
+const (
+	maxDeviceNameLength = 100
+	maxDeviceIDLength   = 100
+)
+
+// ClientInfo records the caller's IP address, user agent, optional
+// X-Device-Name and X-Device-ID headers and request ID on the request
+// context for session tracking, auth events and login history. It must run
+// after RequestID.
+func ClientInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info := models.ClientInfo{
+			DeviceName:     headerValue(c, "X-Device-Name", maxDeviceNameLength),
+			DeviceID:       headerValue(c, "X-Device-ID", maxDeviceIDLength),
+			UserAgent:      c.Request.UserAgent(),
+			AcceptLanguage: c.GetHeader("Accept-Language"),
+			IPAddress:      c.ClientIP(),
+			RequestID:      c.GetString("request_id"),
+		}
+
+		c.Request = c.Request.WithContext(models.WithClientInfo(c.Request.Context(), info))
+		c.Next()
+	}
+}
+
+// headerValue returns the trimmed header, cut to at most maxLength runes
+func headerValue(c *gin.Context, name string, maxLength int) string {
+	value := strings.TrimSpace(c.GetHeader(name))
+	if runes := []rune(value); len(runes) > maxLength {
+		value = string(runes[:maxLength])
+	}
+	return value
+}
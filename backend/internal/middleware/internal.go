@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
+)
+
+// InternalTokenRequired guards service-to-service endpoints with the shared
+// INTERNAL_API_TOKEN sent in the X-Internal-Token header
+func InternalTokenRequired(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := c.GetHeader(revocation.InternalTokenHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid internal token"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mdnaeem95/lifesync/backend/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by route template and status code.",
+	}, []string{"service", "method", "route", "status"})
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to handle HTTP requests, by route template and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "method", "route", "status"})
+	httpRequestsInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being handled.",
+	}, []string{"service"})
+)
+
+// Metrics records every request's count and latency. Requests are labelled
+// with the route template, such as /tasks/:id, rather than the path, so the
+// number of series stays bounded; requests no route matched share the route
+// "unmatched".
+func Metrics(service string) gin.HandlerFunc {
+	inFlight := httpRequestsInFlight.WithLabelValues(service)
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		labels := []string{service, metrics.Method(c.Request.Method), route, strconv.Itoa(c.Writer.Status())}
+
+		httpRequests.WithLabelValues(labels...).Inc()
+		httpRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	}
+}
+
+// MetricsHandler serves the metrics in the Prometheus text format
+func MetricsHandler() gin.HandlerFunc {
+	return gin.WrapH(metrics.Handler())
+}
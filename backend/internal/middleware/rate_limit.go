@@ -7,8 +7,15 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+var rateLimitedRequests = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "http_rate_limited_requests_total",
+	Help: "Requests rejected by the rate limiter.",
+})
+
 type rateLimiter struct {
 	requests map[string][]time.Time
 	mu       sync.Mutex
@@ -42,6 +49,7 @@ func RateLimit(limit int) gin.HandlerFunc {
 
 		clientIP := c.ClientIP()
 		if !limiter.allow(clientIP) {
+			rateLimitedRequests.Inc()
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error": "Rate limit exceeded. Please try again later.",
 			})
@@ -0,0 +1,150 @@
+// Package authz defines the roles users hold and the scopes access tokens
+// carry.
+//
+// Roles are stored on the user and copied into access tokens. Scopes are what
+// routes check: a token's scopes are derived from its roles when it is
+// issued, so a route can demand a capability without listing every role that
+// has it.
+package authz
+
+import (
+	"sort"
+	"strings"
+)
+
+// Roles
+const (
+	// RoleUser is held by every account
+	RoleUser = "user"
+	// RoleSupport is for staff helping users with their accounts
+	RoleSupport = "support"
+	// RoleAdmin can do everything, including changing roles
+	RoleAdmin = "admin"
+)
+
+// Scopes
+const (
+	ScopeProfileRead   = "profile:read"
+	ScopeProfileWrite  = "profile:write"
+	ScopeFlowtimeRead  = "flowtime:read"
+	ScopeFlowtimeWrite = "flowtime:write"
+	ScopeUsersRead     = "users:read"
+	ScopeUsersWrite    = "users:write"
+	ScopeRolesWrite    = "roles:write"
+	ScopeAuditRead     = "audit:read"
+	ScopeClientsWrite  = "clients:write"
+)
+
+var userScopes = []string{ScopeProfileRead, ScopeProfileWrite, ScopeFlowtimeRead, ScopeFlowtimeWrite}
+
+var roleScopes = map[string][]string{
+	RoleUser:    userScopes,
+	RoleSupport: {ScopeUsersRead, ScopeUsersWrite},
+	RoleAdmin:   {ScopeUsersRead, ScopeUsersWrite, ScopeRolesWrite, ScopeAuditRead, ScopeClientsWrite},
+}
+
+// roleRanks orders the roles by how much they can do. Staff can only act on
+// accounts that rank no higher than they do.
+var roleRanks = map[string]int{
+	RoleUser:    0,
+	RoleSupport: 1,
+	RoleAdmin:   2,
+}
+
+// Rank returns the rank of the highest of roles
+func Rank(roles []string) int {
+	rank := 0
+	for _, role := range roles {
+		if r := roleRanks[role]; r > rank {
+			rank = r
+		}
+	}
+	return rank
+}
+
+// ValidRole reports whether role is known
+func ValidRole(role string) bool {
+	_, ok := roleScopes[role]
+	return ok
+}
+
+// Roles returns every known role, sorted
+func Roles() []string {
+	roles := make([]string, 0, len(roleScopes))
+	for role := range roleScopes {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// NormalizeRoles returns the known roles in roles, sorted and without
+// duplicates. RoleUser is always included.
+func NormalizeRoles(roles []string) []string {
+	set := map[string]bool{RoleUser: true}
+	for _, role := range roles {
+		if ValidRole(role) {
+			set[role] = true
+		}
+	}
+	return sortedKeys(set)
+}
+
+// ScopesForRoles returns the scopes granted by holding all of roles
+func ScopesForRoles(roles []string) []string {
+	set := make(map[string]bool)
+	for _, role := range NormalizeRoles(roles) {
+		for _, scope := range roleScopes[role] {
+			set[scope] = true
+		}
+	}
+	return sortedKeys(set)
+}
+
+// ParseScope splits a space separated scope claim
+func ParseScope(scope string) []string {
+	return strings.Fields(scope)
+}
+
+// FormatScope joins scopes into a space separated scope claim
+func FormatScope(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// HasAll reports whether granted includes every one of required
+func HasAll(granted []string, required ...string) bool {
+	for _, want := range required {
+		if !contains(granted, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasAny reports whether granted includes at least one of wanted
+func HasAny(granted []string, wanted ...string) bool {
+	for _, want := range wanted {
+		if contains(granted, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
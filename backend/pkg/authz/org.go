@@ -0,0 +1,18 @@
+package authz
+
+import "context"
+
+type orgKey struct{}
+
+// WithOrg returns a copy of ctx scoped to an organization, the one named by
+// the org_id claim of the caller's access token
+func WithOrg(ctx context.Context, orgID string) context.Context {
+	return context.WithValue(ctx, orgKey{}, orgID)
+}
+
+// OrgFromContext returns the organization the request is scoped to, or ""
+// when it works in the caller's personal workspace
+func OrgFromContext(ctx context.Context) string {
+	orgID, _ := ctx.Value(orgKey{}).(string)
+	return orgID
+}
@@ -12,6 +12,7 @@ func RunFlowTimeMigrations(db *sql.DB) error {
 		createFocusSessionsTable,
 		createUserPreferencesTable,
 		createEnergyPatternsTable,
+		addTaskOrgColumn,
 		createFlowTimeIndexes,
 	}
 
@@ -101,11 +102,22 @@ CREATE TABLE IF NOT EXISTS energy_patterns (
 );
 `
 
+// tasks.org_id marks a task shared with an organization's members rather
+// than kept in its creator's personal workspace. Organizations belong to the
+// auth service, so there is no foreign key: the auth service deletes an
+// organization's tasks through the internal API before the organization
+// itself. The constraint is dropped where an earlier version created it.
+const addTaskOrgColumn = `
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS org_id UUID;
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_org_id_fkey;
+`
+
 const createFlowTimeIndexes = `
 CREATE INDEX IF NOT EXISTS idx_tasks_user_id ON tasks(user_id);
 CREATE INDEX IF NOT EXISTS idx_tasks_scheduled_at ON tasks(scheduled_at);
 CREATE INDEX IF NOT EXISTS idx_tasks_completed_at ON tasks(completed_at);
 CREATE INDEX IF NOT EXISTS idx_tasks_created_at ON tasks(created_at);
+CREATE INDEX IF NOT EXISTS idx_tasks_org_id ON tasks(org_id) WHERE org_id IS NOT NULL;
 
 CREATE INDEX IF NOT EXISTS idx_energy_levels_user_id ON energy_levels(user_id);
 CREATE INDEX IF NOT EXISTS idx_energy_levels_recorded_at ON energy_levels(recorded_at);
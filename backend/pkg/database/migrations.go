@@ -9,7 +9,43 @@ func RunAuthMigrations(db *sql.DB) error {
 	migrations := []string{
 		createUsersTable,
 		createRefreshTokensTable,
+		upgradeRefreshTokensTable,
+		addRefreshTokenSessionColumns,
+		addUserDeletionColumn,
+		addUserRoleColumns,
+		addUserLastLoginColumn,
 		createPasswordResetTokensTable,
+		createEmailVerificationTokensTable,
+		createEmailChangeTokensTable,
+		createMagicLinkTokensTable,
+		allowSeveralMagicLinks,
+		createPasswordHistoryTable,
+		createEmailOutboxTable,
+		clearDeliveredEmailBodies,
+		createUserIdentitiesTable,
+		createUserTOTPTable,
+		createMFARecoveryCodesTable,
+		createMFAChallengesTable,
+		createWebAuthnCredentialsTable,
+		createWebAuthnChallengesTable,
+		createLoginThrottlesTable,
+		createRevokedAccessTokensTable,
+		createAccessTokenCutoffsTable,
+		createSessionTokenCutoffsTable,
+		createAdminAuditLogTable,
+		createPersonalAccessTokensTable,
+		createAuthEventsTable,
+		createLoginHistoryTable,
+		createOAuthClientsTable,
+		createOAuthAuthorizationCodesTable,
+		createOAuthGrantsTable,
+		createOAuthRefreshTokensTable,
+		createOrganizationsTable,
+		createOrganizationMembersTable,
+		createOrganizationInvitationsTable,
+		addRefreshTokenOrgColumn,
+		addOAuthCodeRedirectURIExplicitColumn,
+		normalizeUserEmails,
 		createIndexes,
 	}
 
@@ -40,14 +76,67 @@ const createRefreshTokensTable = `
 CREATE TABLE IF NOT EXISTS refresh_tokens (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-    token TEXT NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    family_id UUID NOT NULL,
+    device_name VARCHAR(100),
+    user_agent TEXT,
+    ip_address VARCHAR(45),
+    last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
     revoked_at TIMESTAMP WITH TIME ZONE,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    UNIQUE(user_id, token)
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 `
 
+// upgradeRefreshTokensTable moves databases created before tokens were
+// hashed to the current schema: raw tokens are replaced by their SHA-256
+// hash and each existing token becomes its own family.
+const upgradeRefreshTokensTable = `
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS token_hash VARCHAR(64);
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS family_id UUID;
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'refresh_tokens' AND column_name = 'token'
+    ) THEN
+        UPDATE refresh_tokens
+        SET token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex')
+        WHERE token_hash IS NULL;
+        ALTER TABLE refresh_tokens DROP COLUMN token;
+    END IF;
+END $$;
+
+UPDATE refresh_tokens SET family_id = id WHERE family_id IS NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN token_hash SET NOT NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+`
+
+const addRefreshTokenSessionColumns = `
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS device_name VARCHAR(100);
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS user_agent TEXT;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS ip_address VARCHAR(45);
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+`
+
+// users.deletion_scheduled_at is set while an account waits out the
+// deletion grace period
+const addUserDeletionColumn = `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP WITH TIME ZONE;
+`
+
+// Every user holds the user role; support and admin are granted on top.
+// password_reset_required blocks password sign-in until the user resets it.
+const addUserRoleColumns = `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS roles TEXT[] NOT NULL DEFAULT '{user}';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_reset_required BOOLEAN NOT NULL DEFAULT false;
+`
+
+const addUserLastLoginColumn = `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMP WITH TIME ZONE;
+`
+
 const createPasswordResetTokensTable = `
 CREATE TABLE IF NOT EXISTS password_reset_tokens (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -60,12 +149,447 @@ CREATE TABLE IF NOT EXISTS password_reset_tokens (
 );
 `
 
+const createEmailVerificationTokensTable = `
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id)
+);
+`
+
+// email_change_tokens holds at most one pending address change per user;
+// the new address only replaces users.email once the link is followed.
+const createEmailChangeTokensTable = `
+CREATE TABLE IF NOT EXISTS email_change_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    new_email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id)
+);
+`
+
+// magic_link_tokens holds the outstanding passwordless sign-in links of
+// each user. device_hash binds a link to the device that asked for it.
+const createMagicLinkTokensTable = `
+CREATE TABLE IF NOT EXISTS magic_link_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    device_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// Users used to have one link at most, so anyone asking for a link to a
+// victim's address replaced the link the victim was waiting for
+const allowSeveralMagicLinks = `
+ALTER TABLE magic_link_tokens DROP CONSTRAINT IF EXISTS magic_link_tokens_user_id_key;
+`
+
+// password_history keeps the hashes of a user's previous passwords so the
+// password policy can refuse reuse; only the most recent few are kept.
+const createPasswordHistoryTable = `
+CREATE TABLE IF NOT EXISTS password_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+const createEmailOutboxTable = `
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    to_address VARCHAR(255) NOT NULL,
+    template VARCHAR(100) NOT NULL,
+    subject TEXT NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// clearDeliveredEmailBodies drops the rendered bodies, and with them the
+// single-use links, of emails that are no longer waiting to be sent. The
+// repository clears them as it finishes each email; this covers older rows.
+const clearDeliveredEmailBodies = `
+UPDATE email_outbox SET text_body = '', html_body = NULL
+WHERE status IN ('sent', 'failed') AND (text_body <> '' OR html_body IS NOT NULL);
+`
+
+const createUserIdentitiesTable = `
+CREATE TABLE IF NOT EXISTS user_identities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(provider, subject)
+);
+`
+
+const createUserTOTPTable = `
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret VARCHAR(64) NOT NULL,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+const createMFARecoveryCodesTable = `
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, code_hash)
+);
+`
+
+// mfa_challenges tracks the sign-in challenges waiting for a second factor,
+// by the jti of their challenge token, so each completes at most once and
+// wrong codes are counted
+const createMFAChallengesTable = `
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+const createWebAuthnCredentialsTable = `
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id TEXT UNIQUE NOT NULL,
+    public_key BYTEA NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    aaguid BYTEA,
+    transports TEXT,
+    name VARCHAR(100),
+    backup_eligible BOOLEAN DEFAULT false,
+    backup_state BOOLEAN DEFAULT false,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+const createWebAuthnChallengesTable = `
+CREATE TABLE IF NOT EXISTS webauthn_challenges (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    challenge TEXT UNIQUE NOT NULL,
+    ceremony VARCHAR(20) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// login_throttles counts recent failed sign-ins per scope ("account" for a
+// normalized email address, whether or not it is registered, and "ip")
+const createLoginThrottlesTable = `
+CREATE TABLE IF NOT EXISTS login_throttles (
+    scope VARCHAR(10) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (scope, subject)
+);
+`
+
+// revoked_access_tokens lists individual access tokens (by jti) that must be
+// rejected before they expire
+const createRevokedAccessTokensTable = `
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// access_token_cutoffs rejects every access token issued to a user before
+// issued_before, e.g. after a password reset
+const createAccessTokenCutoffsTable = `
+CREATE TABLE IF NOT EXISTS access_token_cutoffs (
+    user_id UUID PRIMARY KEY,
+    issued_before TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+`
+
+// session_token_cutoffs rejects every access token issued to a session (the
+// sid claim) before issued_before, once the session has been signed out
+const createSessionTokenCutoffsTable = `
+CREATE TABLE IF NOT EXISTS session_token_cutoffs (
+    session_id VARCHAR(64) PRIMARY KEY,
+    issued_before TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+`
+
+// admin_audit_log is the trail of admin actions. Actor and target are not
+// foreign keys so entries survive the accounts being deleted.
+const createAdminAuditLogTable = `
+CREATE TABLE IF NOT EXISTS admin_audit_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor_id UUID NOT NULL,
+    actor_email VARCHAR(255) NOT NULL,
+    action VARCHAR(100) NOT NULL,
+    target_user_id UUID,
+    details JSONB,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+const createPersonalAccessTokensTable = `
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    token_prefix VARCHAR(20) NOT NULL,
+    scopes TEXT[] NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// auth_events is the security log of sign-ins, token operations and password
+// resets. Events go with the account, and older ones are purged after the
+// retention period.
+const createAuthEventsTable = `
+CREATE TABLE IF NOT EXISTS auth_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255),
+    event_type VARCHAR(50) NOT NULL,
+    outcome VARCHAR(20) NOT NULL,
+    method VARCHAR(50),
+    reason VARCHAR(100),
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    request_id TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// login_history has a row for every successful sign-in. device_fingerprint
+// and network are compared against earlier rows to spot new devices.
+const createLoginHistoryTable = `
+CREATE TABLE IF NOT EXISTS login_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    method VARCHAR(50) NOT NULL,
+    ip_address VARCHAR(45),
+    network VARCHAR(50),
+    user_agent TEXT,
+    device_fingerprint VARCHAR(64) NOT NULL,
+    new_device BOOLEAN NOT NULL DEFAULT false,
+    new_network BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// oauth_clients are the third-party apps registered to use the OAuth2
+// provider. Public clients, which can't keep a secret, have no secret_hash.
+const createOAuthClientsTable = `
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    secret_hash VARCHAR(64),
+    redirect_uris TEXT[] NOT NULL,
+    scopes TEXT[] NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// oauth_authorization_codes are single use: a code is deleted when it is
+// exchanged for tokens
+const createOAuthAuthorizationCodesTable = `
+CREATE TABLE IF NOT EXISTS oauth_authorization_codes (
+    code_hash VARCHAR(64) PRIMARY KEY,
+    client_id UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    code_challenge VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// oauth_grants records the scopes a user has consented to give each app.
+// Deleting a grant deletes the app's refresh tokens with it.
+const createOAuthGrantsTable = `
+CREATE TABLE IF NOT EXISTS oauth_grants (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_id UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    scopes TEXT[] NOT NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, client_id)
+);
+`
+
+// oauth_refresh_tokens rotate like the first-party refresh tokens, in
+// families descended from one authorization code. Each row remembers the
+// access token issued alongside it, so that revoking the app can revoke the
+// access tokens that are still live too.
+const createOAuthRefreshTokensTable = `
+CREATE TABLE IF NOT EXISTS oauth_refresh_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    grant_id UUID NOT NULL REFERENCES oauth_grants(id) ON DELETE CASCADE,
+    family_id UUID NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    access_token_id VARCHAR(64) NOT NULL,
+    access_token_expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+const createOrganizationsTable = `
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// Every organization keeps at least one owner; the repository refuses to
+// demote or remove the last one
+const createOrganizationMembersTable = `
+CREATE TABLE IF NOT EXISTS organization_members (
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (org_id, user_id)
+);
+`
+
+// An address has at most one outstanding invitation per organization:
+// inviting it again replaces the token. Accepting deletes the invitation.
+const createOrganizationInvitationsTable = `
+CREATE TABLE IF NOT EXISTS organization_invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('admin', 'member')),
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(org_id, email)
+);
+`
+
+// refresh_tokens.org_id is the organization the session has switched to,
+// carried into the org_id claim of every access token the session is
+// issued. NULL is the user's personal workspace.
+const addRefreshTokenOrgColumn = `
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS org_id UUID REFERENCES organizations(id) ON DELETE SET NULL;
+`
+
+// redirect_uri_explicit records whether the authorization request named its
+// redirect URI, in which case the token request must repeat it. Codes from
+// before the column existed are treated as if it had.
+const addOAuthCodeRedirectURIExplicitColumn = `
+ALTER TABLE oauth_authorization_codes ADD COLUMN IF NOT EXISTS redirect_uri_explicit BOOLEAN NOT NULL DEFAULT TRUE;
+`
+
+// Emails are stored trimmed and lower-cased (models.NormalizeEmail) and
+// looked up the same way. This brings older rows into that form. Where two
+// accounts differ only by case, the oldest takes the address and the others
+// are left as they are, so nothing is merged or lost.
+const normalizeUserEmails = `
+UPDATE users u SET email = LOWER(TRIM(u.email)), updated_at = NOW()
+WHERE u.email <> LOWER(TRIM(u.email))
+AND NOT EXISTS (SELECT 1 FROM users o WHERE o.email = LOWER(TRIM(u.email)))
+AND u.id = (
+	SELECT o.id FROM users o
+	WHERE LOWER(TRIM(o.email)) = LOWER(TRIM(u.email))
+	ORDER BY o.created_at, o.id
+	LIMIT 1
+);
+`
+
 const createIndexes = `
 CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
 CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(created_at);
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users(deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
 CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
-CREATE INDEX IF NOT EXISTS idx_refresh_tokens_token ON refresh_tokens(token);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens(token_hash);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
 CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
 CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_token ON password_reset_tokens(token);
 CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_expires_at ON password_reset_tokens(expires_at);
+CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_token ON email_verification_tokens(token);
+CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_expires_at ON email_verification_tokens(expires_at);
+CREATE INDEX IF NOT EXISTS idx_email_change_tokens_expires_at ON email_change_tokens(expires_at);
+CREATE INDEX IF NOT EXISTS idx_magic_link_tokens_expires_at ON magic_link_tokens(expires_at);
+CREATE INDEX IF NOT EXISTS idx_magic_link_tokens_user_id ON magic_link_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_password_history_user_id ON password_history(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+CREATE INDEX IF NOT EXISTS idx_webauthn_challenges_expires_at ON webauthn_challenges(expires_at);
+CREATE INDEX IF NOT EXISTS idx_login_throttles_last_failure_at ON login_throttles(last_failure_at);
+CREATE INDEX IF NOT EXISTS idx_revoked_access_tokens_expires_at ON revoked_access_tokens(expires_at);
+CREATE INDEX IF NOT EXISTS idx_access_token_cutoffs_expires_at ON access_token_cutoffs(expires_at);
+CREATE INDEX IF NOT EXISTS idx_session_token_cutoffs_expires_at ON session_token_cutoffs(expires_at);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_created_at ON admin_audit_log(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_actor_id ON admin_audit_log(actor_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_target_user_id ON admin_audit_log(target_user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user_id ON personal_access_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_auth_events_created_at ON auth_events(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_auth_events_user_id ON auth_events(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_auth_events_email ON auth_events(email, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_auth_events_ip_address ON auth_events(ip_address, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_login_history_user_id ON login_history(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_login_history_device ON login_history(user_id, device_fingerprint);
+CREATE INDEX IF NOT EXISTS idx_login_history_network ON login_history(user_id, network);
+CREATE INDEX IF NOT EXISTS idx_login_history_created_at ON login_history(created_at);
+CREATE INDEX IF NOT EXISTS idx_oauth_authorization_codes_expires_at ON oauth_authorization_codes(expires_at);
+CREATE INDEX IF NOT EXISTS idx_oauth_grants_client_id ON oauth_grants(client_id);
+CREATE INDEX IF NOT EXISTS idx_oauth_refresh_tokens_grant_id ON oauth_refresh_tokens(grant_id);
+CREATE INDEX IF NOT EXISTS idx_oauth_refresh_tokens_family_id ON oauth_refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_oauth_refresh_tokens_expires_at ON oauth_refresh_tokens(expires_at);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_org_id ON refresh_tokens(org_id) WHERE org_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_organization_invitations_expires_at ON organization_invitations(expires_at);
+CREATE INDEX IF NOT EXISTS idx_email_outbox_pending ON email_outbox(next_attempt_at) WHERE status = 'pending';
 `
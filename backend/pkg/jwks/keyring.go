@@ -0,0 +1,370 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Signing algorithms supported by the key ring
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// PEM headers recorded on generated key files
+const (
+	headerKid       = "Kid"
+	headerAlg       = "Alg"
+	headerActiveAt  = "Active-At"
+	keyFileSuffix   = ".pem"
+	pemPrivateBlock = "PRIVATE KEY"
+)
+
+var ErrNoSigningKey = errors.New("no active signing key")
+
+// SigningKey is a private key in the ring. A key starts signing at
+// ActiveAt and stays valid for verification until ExpiresAt, which is set
+// once a newer key has taken over.
+type SigningKey struct {
+	Kid       string
+	Alg       string
+	Private   crypto.Signer
+	ActiveAt  time.Time
+	ExpiresAt time.Time // zero while the key is current
+}
+
+func (k *SigningKey) Public() crypto.PublicKey {
+	return k.Private.Public()
+}
+
+// KeyRing is a set of signing keys loaded from a directory of PKCS#8 PEM
+// files. The newest active key signs; older keys keep verifying tokens for
+// the verify window after they are superseded, and keys with a future
+// ActiveAt are published before they start signing.
+type KeyRing struct {
+	dir          string
+	verifyWindow time.Duration
+
+	mu   sync.RWMutex
+	keys []*SigningKey // sorted by ActiveAt
+}
+
+// LoadKeyRing reads all keys in dir. verifyWindow should cover the longest
+// token lifetime so no unexpired token loses its key.
+func LoadKeyRing(dir string, verifyWindow time.Duration) (*KeyRing, error) {
+	ring := &KeyRing{dir: dir, verifyWindow: verifyWindow}
+	if err := ring.Reload(); err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+// Reload re-reads the key directory, picking up rotated keys. The current
+// keys are kept if the directory can't be read.
+func (r *KeyRing) Reload() error {
+	keys, err := readKeyDir(r.dir)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no signing keys found in %s", r.dir)
+	}
+
+	assignExpiry(keys, r.verifyWindow, time.Now())
+
+	r.mu.Lock()
+	r.keys = keys
+	r.mu.Unlock()
+	return nil
+}
+
+// SigningKey returns the key new tokens should be signed with
+func (r *KeyRing) SigningKey() (*SigningKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	var current *SigningKey
+	for _, k := range r.keys {
+		if k.ActiveAt.After(now) {
+			break
+		}
+		current = k
+	}
+	if current == nil {
+		return nil, ErrNoSigningKey
+	}
+	return current, nil
+}
+
+// Key implements KeySource for verification
+func (r *KeyRing) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	for _, k := range r.keys {
+		if k.Kid == kid {
+			if !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt) {
+				return nil, fmt.Errorf("key %q has expired", kid)
+			}
+			return k.Public(), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// PublicSet returns the JWKS document for all unexpired keys, including
+// keys that are published ahead of becoming active
+func (r *KeyRing) PublicSet() *Set {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	set := &Set{Keys: []JWK{}}
+	for _, k := range r.keys {
+		if !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt) {
+			continue
+		}
+		jwk, err := NewJWK(k.Kid, k.Alg, k.Public())
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// Keys returns a snapshot of the ring
+func (r *KeyRing) Keys() []SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]SigningKey, len(r.keys))
+	for i, k := range r.keys {
+		keys[i] = *k
+	}
+	return keys
+}
+
+// GenerateKey creates a new key of the given algorithm. RSA keys use
+// rsaBits (minimum 2048).
+func GenerateKey(alg string, rsaBits int, activeAt time.Time) (*SigningKey, error) {
+	var (
+		priv crypto.Signer
+		err  error
+	)
+
+	switch alg {
+	case AlgRS256:
+		if rsaBits < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		priv, err = rsa.GenerateKey(rand.Reader, rsaBits)
+	case AlgES256:
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	kidBytes := make([]byte, 8)
+	if _, err := rand.Read(kidBytes); err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{
+		Kid:      hex.EncodeToString(kidBytes),
+		Alg:      alg,
+		Private:  priv,
+		ActiveAt: activeAt.UTC().Truncate(time.Second),
+	}, nil
+}
+
+// WriteKeyFile stores key in dir as <kid>.pem, readable only by the owner
+func WriteKeyFile(dir string, key *SigningKey) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	block := &pem.Block{
+		Type: pemPrivateBlock,
+		Headers: map[string]string{
+			headerKid:      key.Kid,
+			headerAlg:      key.Alg,
+			headerActiveAt: key.ActiveAt.Format(time.RFC3339),
+		},
+		Bytes: der,
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create key directory: %w", err)
+	}
+
+	path := filepath.Join(dir, key.Kid+keyFileSuffix)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create key file: %w", err)
+	}
+	defer f.Close()
+
+	if err := pem.Encode(f, block); err != nil {
+		return "", fmt.Errorf("failed to write key file: %w", err)
+	}
+	return path, f.Close()
+}
+
+// PruneKeyDir deletes key files whose verify window has passed and returns
+// their key IDs
+func PruneKeyDir(dir string, verifyWindow time.Duration) ([]string, error) {
+	keys, err := readKeyDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	assignExpiry(keys, verifyWindow, now)
+
+	var removed []string
+	for _, k := range keys {
+		if k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, k.Kid+keyFileSuffix)); err != nil {
+			return removed, fmt.Errorf("failed to remove key %s: %w", k.Kid, err)
+		}
+		removed = append(removed, k.Kid)
+	}
+	return removed, nil
+}
+
+// assignExpiry sets ExpiresAt on every key that a newer active key has
+// superseded
+func assignExpiry(keys []*SigningKey, verifyWindow time.Duration, now time.Time) {
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ActiveAt.Before(keys[j].ActiveAt) })
+
+	for i, k := range keys {
+		k.ExpiresAt = time.Time{}
+		if i+1 < len(keys) && !keys[i+1].ActiveAt.After(now) {
+			k.ExpiresAt = keys[i+1].ActiveAt.Add(verifyWindow)
+		}
+	}
+}
+
+func readKeyDir(dir string) ([]*SigningKey, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key directory: %w", err)
+	}
+
+	var keys []*SigningKey
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), keyFileSuffix) {
+			continue
+		}
+		key, err := readKeyFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// readKeyFile parses a PKCS#8 key. Keys generated elsewhere (e.g. with
+// openssl) have no headers; they take the file name as kid, an algorithm
+// matching the key type and the file's modification time as ActiveAt.
+func readKeyFile(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != pemPrivateBlock {
+		return nil, errors.New("expected a PKCS#8 PRIVATE KEY PEM block")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	key := &SigningKey{
+		Kid: block.Headers[headerKid],
+		Alg: block.Headers[headerAlg],
+	}
+
+	switch priv := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Private = priv
+	case *ecdsa.PrivateKey:
+		if priv.Curve != elliptic.P256() {
+			return nil, errors.New("only P-256 EC keys are supported")
+		}
+		key.Private = priv
+	case ed25519.PrivateKey:
+		key.Private = priv
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	if key.Alg == "" {
+		key.Alg = algorithmFor(key.Private)
+	}
+
+	if expected := algorithmFor(key.Private); key.Alg != expected {
+		return nil, fmt.Errorf("%s header %q does not match %s key", headerAlg, key.Alg, expected)
+	}
+
+	if key.Kid == "" {
+		key.Kid = strings.TrimSuffix(filepath.Base(path), keyFileSuffix)
+	}
+
+	if activeAt := block.Headers[headerActiveAt]; activeAt != "" {
+		if key.ActiveAt, err = time.Parse(time.RFC3339, activeAt); err != nil {
+			return nil, fmt.Errorf("invalid %s header: %w", headerActiveAt, err)
+		}
+	} else {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		key.ActiveAt = info.ModTime()
+	}
+
+	return key, nil
+}
+
+func algorithmFor(key crypto.Signer) string {
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		return AlgRS256
+	case *ecdsa.PrivateKey:
+		return AlgES256
+	case ed25519.PrivateKey:
+		return AlgEdDSA
+	}
+	return ""
+}
@@ -0,0 +1,348 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestKey(t *testing.T, dir, alg string, activeAt time.Time) *SigningKey {
+	t.Helper()
+
+	key, err := GenerateKey(alg, 2048, activeAt)
+	if err != nil {
+		t.Fatalf("GenerateKey(%s) error = %v", alg, err)
+	}
+	if _, err := WriteKeyFile(dir, key); err != nil {
+		t.Fatalf("WriteKeyFile() error = %v", err)
+	}
+	return key
+}
+
+// writePEM writes a PKCS#8 key file the way openssl would, with the given
+// PEM headers
+func writePEM(t *testing.T, path string, priv interface{}, headers map[string]string) {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: pemPrivateBlock, Headers: headers, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func publishedKids(set *Set) []string {
+	kids := make([]string, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		kids = append(kids, k.Kid)
+	}
+	sort.Strings(kids)
+	return kids
+}
+
+func sortedKids(keys ...*SigningKey) []string {
+	kids := make([]string, len(keys))
+	for i, k := range keys {
+		kids[i] = k.Kid
+	}
+	sort.Strings(kids)
+	return kids
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		verifyWindow time.Duration
+		oldUsable    bool
+	}{
+		{"superseded key within verify window", 2 * time.Hour, true},
+		{"superseded key past verify window", 30 * time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			old := writeTestKey(t, dir, AlgES256, now.Add(-2*time.Hour))
+			current := writeTestKey(t, dir, AlgEdDSA, now.Add(-time.Hour))
+			next := writeTestKey(t, dir, AlgRS256, now.Add(time.Hour))
+
+			ring, err := LoadKeyRing(dir, tt.verifyWindow)
+			if err != nil {
+				t.Fatalf("LoadKeyRing() error = %v", err)
+			}
+
+			// The newest active key signs; the pending one waits
+			signing, err := ring.SigningKey()
+			if err != nil || signing.Kid != current.Kid || signing.Alg != AlgEdDSA {
+				t.Fatalf("SigningKey() = %+v, %v, want %s", signing, err, current.Kid)
+			}
+
+			ctx := context.Background()
+			for _, k := range []*SigningKey{current, next} {
+				if _, err := ring.Key(ctx, k.Kid); err != nil {
+					t.Errorf("Key(%s) error = %v", k.Alg, err)
+				}
+			}
+			_, err = ring.Key(ctx, old.Kid)
+			if (err == nil) != tt.oldUsable {
+				t.Errorf("Key(superseded) error = %v, want usable %v", err, tt.oldUsable)
+			}
+			if _, err := ring.Key(ctx, "unknown"); err == nil {
+				t.Error("Key(unknown) succeeded")
+			}
+
+			// Pending keys are published ahead of signing; expired ones aren't
+			want := sortedKids(current, next)
+			if tt.oldUsable {
+				want = sortedKids(old, current, next)
+			}
+			if got := publishedKids(ring.PublicSet()); !reflect.DeepEqual(got, want) {
+				t.Errorf("PublicSet() kids = %v, want %v", got, want)
+			}
+
+			keys := ring.Keys()
+			if len(keys) != 3 || keys[0].Kid != old.Kid || keys[2].Kid != next.Kid {
+				t.Fatalf("Keys() not ordered by ActiveAt: %+v", keys)
+			}
+			if want := current.ActiveAt.Add(tt.verifyWindow); !keys[0].ExpiresAt.Equal(want) {
+				t.Errorf("superseded key expires %v, want %v", keys[0].ExpiresAt, want)
+			}
+			if !keys[1].ExpiresAt.IsZero() || !keys[2].ExpiresAt.IsZero() {
+				t.Error("current or pending key has an expiry")
+			}
+		})
+	}
+}
+
+func TestKeyRingPublicSetVerifies(t *testing.T) {
+	dir := t.TempDir()
+	key := writeTestKey(t, dir, AlgES256, time.Now().Add(-time.Minute))
+
+	ring, err := LoadKeyRing(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("LoadKeyRing() error = %v", err)
+	}
+
+	data, _ := json.Marshal(ring.PublicSet())
+	source, err := NewStaticKeySetFromJWKS(data)
+	if err != nil {
+		t.Fatalf("NewStaticKeySetFromJWKS() error = %v", err)
+	}
+	pub, err := source.Key(context.Background(), key.Kid)
+	if err != nil {
+		t.Fatalf("Key() error = %v", err)
+	}
+	if !pub.(*ecdsa.PublicKey).Equal(key.Public()) {
+		t.Error("published key does not match the signing key")
+	}
+}
+
+func TestKeyRingOnlyPendingKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeTestKey(t, dir, AlgES256, time.Now().Add(time.Hour))
+
+	ring, err := LoadKeyRing(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("LoadKeyRing() error = %v", err)
+	}
+	if _, err := ring.SigningKey(); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("SigningKey() error = %v, want ErrNoSigningKey", err)
+	}
+	if len(ring.PublicSet().Keys) != 1 {
+		t.Error("pending key is not published")
+	}
+}
+
+func TestKeyRingReload(t *testing.T) {
+	dir := t.TempDir()
+	first := writeTestKey(t, dir, AlgES256, time.Now().Add(-time.Hour))
+
+	ring, err := LoadKeyRing(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("LoadKeyRing() error = %v", err)
+	}
+
+	second := writeTestKey(t, dir, AlgES256, time.Now().Add(-time.Minute))
+	if err := ring.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if k, _ := ring.SigningKey(); k.Kid != second.Kid {
+		t.Errorf("SigningKey() after rotation = %s, want %s", k.Kid, second.Kid)
+	}
+
+	// A directory that can't be used leaves the loaded keys in place
+	os.Remove(filepath.Join(dir, first.Kid+keyFileSuffix))
+	os.Remove(filepath.Join(dir, second.Kid+keyFileSuffix))
+	if err := ring.Reload(); err == nil {
+		t.Fatal("Reload() of an empty directory succeeded")
+	}
+	if k, _ := ring.SigningKey(); k == nil || k.Kid != second.Kid {
+		t.Error("failed Reload() dropped the loaded keys")
+	}
+}
+
+func TestLoadKeyRingErrors(t *testing.T) {
+	if _, err := LoadKeyRing(filepath.Join(t.TempDir(), "missing"), time.Hour); err == nil {
+		t.Error("LoadKeyRing() of a missing directory succeeded")
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "README"), []byte("not a key"), 0o600)
+	if _, err := LoadKeyRing(dir, time.Hour); err == nil || !strings.Contains(err.Error(), "no signing keys") {
+		t.Errorf("LoadKeyRing() without keys error = %v", err)
+	}
+}
+
+func TestPruneKeyDir(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	expired := writeTestKey(t, dir, AlgES256, now.Add(-3*time.Hour))
+	superseded := writeTestKey(t, dir, AlgES256, now.Add(-2*time.Hour))
+	current := writeTestKey(t, dir, AlgES256, now.Add(-30*time.Minute))
+	pending := writeTestKey(t, dir, AlgES256, now.Add(time.Hour))
+
+	removed, err := PruneKeyDir(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("PruneKeyDir() error = %v", err)
+	}
+	if !reflect.DeepEqual(removed, []string{expired.Kid}) {
+		t.Errorf("PruneKeyDir() removed %v, want only %s", removed, expired.Kid)
+	}
+
+	for _, k := range []*SigningKey{superseded, current, pending} {
+		if _, err := os.Stat(filepath.Join(dir, k.Kid+keyFileSuffix)); err != nil {
+			t.Errorf("key %s was removed: %v", k.Kid, err)
+		}
+	}
+}
+
+func TestWriteKeyFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "keys")
+	key, _ := GenerateKey(AlgEdDSA, 0, time.Now())
+
+	path, err := WriteKeyFile(dir, key)
+	if err != nil {
+		t.Fatalf("WriteKeyFile() error = %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("key file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if _, err := WriteKeyFile(dir, key); err == nil {
+		t.Error("WriteKeyFile() overwrote an existing key")
+	}
+
+	read, err := readKeyFile(path)
+	if err != nil {
+		t.Fatalf("readKeyFile() error = %v", err)
+	}
+	if read.Kid != key.Kid || read.Alg != key.Alg || !read.ActiveAt.Equal(key.ActiveAt) {
+		t.Errorf("readKeyFile() = %s %s %v, want %s %s %v", read.Kid, read.Alg, read.ActiveAt, key.Kid, key.Alg, key.ActiveAt)
+	}
+	if !read.Private.(ed25519.PrivateKey).Equal(key.Private) {
+		t.Error("readKeyFile() returned a different key")
+	}
+}
+
+func TestReadKeyFile(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name    string
+		priv    interface{}
+		headers map[string]string
+		wantKid string
+		wantAlg string
+		wantErr string
+	}{
+		{name: "openssl ec key", priv: ecKey, wantKid: "openssl", wantAlg: AlgES256},
+		{name: "openssl ed25519 key", priv: edKey, wantKid: "openssl", wantAlg: AlgEdDSA},
+		{name: "headers", priv: ecKey, headers: map[string]string{headerKid: "k1", headerAlg: AlgES256, headerActiveAt: "2026-01-02T03:04:05Z"}, wantKid: "k1", wantAlg: AlgES256},
+		{name: "algorithm mismatch", priv: ecKey, headers: map[string]string{headerAlg: AlgRS256}, wantErr: "does not match"},
+		{name: "unsupported curve", priv: p384Key, wantErr: "P-256"},
+		{name: "invalid active-at", priv: edKey, headers: map[string]string{headerActiveAt: "tomorrow"}, wantErr: headerActiveAt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "openssl.pem")
+			writePEM(t, path, tt.priv, tt.headers)
+
+			key, err := readKeyFile(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readKeyFile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readKeyFile() error = %v", err)
+			}
+			if key.Kid != tt.wantKid || key.Alg != tt.wantAlg {
+				t.Errorf("readKeyFile() = %s %s, want %s %s", key.Kid, key.Alg, tt.wantKid, tt.wantAlg)
+			}
+			if tt.headers == nil {
+				info, _ := os.Stat(path)
+				if !key.ActiveAt.Equal(info.ModTime()) {
+					t.Errorf("ActiveAt = %v, want the file's modification time %v", key.ActiveAt, info.ModTime())
+				}
+			}
+		})
+	}
+
+	t.Run("not pem", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "garbage.pem")
+		os.WriteFile(path, []byte("garbage"), 0o600)
+		if _, err := readKeyFile(path); err == nil {
+			t.Error("readKeyFile() accepted a file without a PEM block")
+		}
+	})
+}
+
+func TestGenerateKey(t *testing.T) {
+	activeAt := time.Date(2026, 1, 2, 3, 4, 5, 678, time.FixedZone("X", 3600))
+
+	for _, alg := range []string{AlgRS256, AlgES256, AlgEdDSA} {
+		key, err := GenerateKey(alg, 2048, activeAt)
+		if err != nil {
+			t.Fatalf("GenerateKey(%s) error = %v", alg, err)
+		}
+		if key.Alg != alg || algorithmFor(key.Private) != alg || len(key.Kid) != 16 {
+			t.Errorf("GenerateKey(%s) = %s %s", alg, key.Kid, key.Alg)
+		}
+		if !key.ActiveAt.Equal(activeAt.Truncate(time.Second)) || key.ActiveAt.Location() != time.UTC {
+			t.Errorf("GenerateKey(%s) ActiveAt = %v", alg, key.ActiveAt)
+		}
+	}
+
+	if _, err := GenerateKey(AlgRS256, 1024, activeAt); err == nil {
+		t.Error("GenerateKey() accepted a 1024 bit RSA key")
+	}
+	if _, err := GenerateKey("HS256", 0, activeAt); err == nil {
+		t.Error("GenerateKey() accepted HS256")
+	}
+}
@@ -0,0 +1,179 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+)
+
+// KeySource resolves the public key for a key ID
+type KeySource interface {
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// StaticKeySet is a fixed set of keys, useful for tests and local key files
+type StaticKeySet struct {
+	keys map[string]crypto.PublicKey
+}
+
+func NewStaticKeySet(keys map[string]crypto.PublicKey) *StaticKeySet {
+	return &StaticKeySet{keys: keys}
+}
+
+// NewStaticKeySetFromJWKS builds a static key set from a JWKS document
+func NewStaticKeySetFromJWKS(data []byte) (*StaticKeySet, error) {
+	set, err := ParseSet(data)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := set.publicKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStaticKeySet(keys), nil
+}
+
+func (s *StaticKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// RemoteKeySet fetches a JWKS document over HTTP and caches it. Unknown key
+// IDs trigger a refetch (at most once per minRefresh) so provider key
+// rotations are picked up without waiting for the cache to expire.
+type RemoteKeySet struct {
+	url        string
+	httpClient *http.Client
+	ttl        time.Duration
+	minRefresh time.Duration
+	log        logger.Logger
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+func NewRemoteKeySet(url string, ttl time.Duration, log logger.Logger) *RemoteKeySet {
+	return &RemoteKeySet{
+		url:        url,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		ttl:        ttl,
+		minRefresh: time.Minute,
+		log:        log,
+	}
+}
+
+func (s *RemoteKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	fresh := time.Since(s.fetchedAt) < s.ttl
+	canRefresh := time.Since(s.attemptedAt) >= s.minRefresh
+	s.mu.RUnlock()
+
+	if ok && (fresh || !canRefresh) {
+		return key, nil
+	}
+
+	if !ok && !canRefresh {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	if err := s.refresh(ctx); err != nil {
+		// Serve stale keys rather than failing while the provider is unreachable
+		if ok {
+			s.log.WithError(err).WithField("jwks_url", s.url).Warn("Failed to refresh JWKS, using cached keys")
+			return key, nil
+		}
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok = s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+func (s *RemoteKeySet) refresh(ctx context.Context) error {
+	s.mu.Lock()
+	s.attemptedAt = time.Now()
+	s.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build jwks request: %w", err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch jwks: HTTP status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read jwks: %w", err)
+	}
+
+	set, err := ParseSet(body)
+	if err != nil {
+		return err
+	}
+
+	keys, err := set.publicKeys()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	s.mu.Unlock()
+
+	s.log.WithFields(map[string]interface{}{
+		"jwks_url": s.url,
+		"keys":     len(keys),
+	}).Debug("JWKS refreshed")
+	return nil
+}
+
+// publicKeys converts every usable signing key in the set. Keys with an
+// unsupported type are skipped rather than failing the whole set.
+func (set *Set) publicKeys() (map[string]crypto.PublicKey, error) {
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks contains no usable signing keys")
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,76 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+)
+
+// logMailer records messages in the service log instead of delivering them.
+// Bodies are left out, since they carry verification and sign-in links and
+// logs are kept and shipped far more widely than mail; use the file mailer
+// to read them. Intended for local development only.
+type logMailer struct {
+	log logger.Logger
+}
+
+func NewLogMailer(log logger.Logger) Mailer {
+	return &logMailer{log: log}
+}
+
+func (m *logMailer) Send(ctx context.Context, msg *Message) error {
+	m.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"message_id": msg.ID,
+		"to":         msg.To,
+		"subject":    msg.Subject,
+		"body_bytes": len(msg.TextBody),
+	}).Info("Email delivered to log")
+	return nil
+}
+
+// fileMailer writes each message as an .eml file so it can be opened in a
+// mail client during development
+type fileMailer struct {
+	dir  string
+	from string
+	log  logger.Logger
+}
+
+func NewFileMailer(dir, from string, log logger.Logger) (Mailer, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("file mailer requires an output directory")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	return &fileMailer{
+		dir:  dir,
+		from: from,
+		log:  log,
+	}, nil
+}
+
+func (m *fileMailer) Send(ctx context.Context, msg *Message) error {
+	body, err := buildMIME(m.from, msg)
+	if err != nil {
+		return fmt.Errorf("failed to build message: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000"), msg.ID)
+	path := filepath.Join(m.dir, name)
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+
+	m.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"message_id": msg.ID,
+		"to":         msg.To,
+		"path":       path,
+	}).Info("Email written to file")
+	return nil
+}
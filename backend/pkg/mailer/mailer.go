@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+)
+
+// Message is a single outgoing email with a plain text and an optional HTML body
+type Message struct {
+	ID       string
+	To       string
+	Subject  string
+	TextBody string
+	HTMLBody string
+}
+
+// Mailer delivers messages to a mail transport
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Config selects and configures the mail backend
+type Config struct {
+	Backend      string // smtp, file, log
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUser     string
+	SMTPPassword string
+	From         string
+	OutputDir    string
+}
+
+// New creates the mailer for the configured backend
+func New(cfg Config, log logger.Logger) (Mailer, error) {
+	switch cfg.Backend {
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("smtp mailer requires SMTP_HOST")
+		}
+		return NewSMTPMailer(cfg, log), nil
+	case "file":
+		return NewFileMailer(cfg.OutputDir, cfg.From, log)
+	case "log":
+		return NewLogMailer(log), nil
+	case "":
+		// Never fall back to the log mailer: it would quietly drop every
+		// email of a deployment that forgot its mail settings
+		return nil, fmt.Errorf("no mail backend configured: set SMTP_HOST, or MAIL_BACKEND to file or log")
+	default:
+		return nil, fmt.Errorf("unknown mail backend: %s", cfg.Backend)
+	}
+}
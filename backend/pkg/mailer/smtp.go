@@ -0,0 +1,130 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+)
+
+type smtpMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	timeout  time.Duration
+	log      logger.Logger
+}
+
+func NewSMTPMailer(cfg Config, log logger.Logger) Mailer {
+	return &smtpMailer{
+		host:     cfg.SMTPHost,
+		port:     cfg.SMTPPort,
+		username: cfg.SMTPUser,
+		password: cfg.SMTPPassword,
+		from:     cfg.From,
+		timeout:  30 * time.Second,
+		log:      log,
+	}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg *Message) error {
+	log := m.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation":  "smtp_send",
+		"message_id": msg.ID,
+	})
+
+	body, err := buildMIME(m.from, msg)
+	if err != nil {
+		return fmt.Errorf("failed to build message: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	client, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if m.username != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			auth := smtp.PlainAuth("", m.username, m.password, m.host)
+			if err := client.Auth(auth); err != nil {
+				return fmt.Errorf("smtp auth failed: %w", err)
+			}
+		}
+	}
+
+	if err := client.Mail(m.from); err != nil {
+		return fmt.Errorf("smtp MAIL FROM failed: %w", err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("smtp RCPT TO failed: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA failed: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finish message: %w", err)
+	}
+
+	if err := client.Quit(); err != nil {
+		log.WithError(err).Debug("SMTP quit failed")
+	}
+
+	log.Debug("Email sent via SMTP")
+	return nil
+}
+
+// dial connects to the SMTP server, using implicit TLS on port 465 and
+// STARTTLS everywhere else when the server offers it
+func (m *smtpMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	tlsConfig := &tls.Config{ServerName: m.host}
+
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if m.port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create smtp client: %w", err)
+	}
+
+	if m.port != 465 {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return nil, fmt.Errorf("smtp STARTTLS failed: %w", err)
+			}
+		}
+	}
+
+	return client, nil
+}
@@ -0,0 +1,67 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Template names
+const (
+	TemplatePasswordReset          = "password_reset"
+	TemplateEmailVerification      = "email_verification"
+	TemplateSecurityAlert          = "security_alert"
+	TemplateEmailChange            = "email_change"
+	TemplateMagicLink              = "magic_link"
+	TemplateOrganizationInvitation = "organization_invitation"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// Renderer turns a named template into a Message. Every template provides a
+// "<name>.subject" and "<name>.text" block in <name>.txt.tmpl and a
+// "<name>.html" block in <name>.html.tmpl.
+type Renderer struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+func NewRenderer() (*Renderer, error) {
+	text, err := texttemplate.ParseFS(templateFS, "templates/*.txt.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse text templates: %w", err)
+	}
+
+	html, err := htmltemplate.ParseFS(templateFS, "templates/*.html.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html templates: %w", err)
+	}
+
+	return &Renderer{text: text, html: html}, nil
+}
+
+// Render executes the named template for the given recipient
+func (r *Renderer) Render(name, to string, data interface{}) (*Message, error) {
+	var subject, text, html bytes.Buffer
+
+	if err := r.text.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s subject: %w", name, err)
+	}
+	if err := r.text.ExecuteTemplate(&text, name+".text", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s text body: %w", name, err)
+	}
+	if err := r.html.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s html body: %w", name, err)
+	}
+
+	return &Message{
+		To:       to,
+		Subject:  strings.TrimSpace(subject.String()),
+		TextBody: strings.TrimSpace(text.String()) + "\n",
+		HTMLBody: html.String(),
+	}, nil
+}
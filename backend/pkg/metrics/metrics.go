@@ -0,0 +1,29 @@
+// Package metrics holds what the services' Prometheus metrics share.
+// Metrics themselves are declared with client_golang, on its default
+// registry, where they are served next to the Go runtime and process
+// metrics.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Handler serves every registered metric in the Prometheus exposition
+// format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Method returns the request method to use as a label value: the standard
+// methods as they are and anything else as OTHER, since clients may send
+// any method name
+func Method(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
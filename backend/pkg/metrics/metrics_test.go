@@ -0,0 +1,19 @@
+package metrics
+
+import "testing"
+
+func TestMethod(t *testing.T) {
+	tests := map[string]string{
+		"GET":     "GET",
+		"DELETE":  "DELETE",
+		"OPTIONS": "OPTIONS",
+		"get":     "OTHER",
+		"PURGE":   "OTHER",
+		"":        "OTHER",
+	}
+	for method, want := range tests {
+		if got := Method(method); got != want {
+			t.Errorf("Method(%q) = %q, want %q", method, got, want)
+		}
+	}
+}
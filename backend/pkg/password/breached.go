@@ -0,0 +1,98 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// BreachedList is a set of SHA-1 hash prefixes of passwords known from
+// breaches. It is loaded from a local file so checks need no network access.
+//
+// The file has one upper or lower case hex prefix per line, optionally
+// followed by ":count" as in the Pwned Passwords downloads. Blank lines and
+// lines starting with # are ignored. All prefixes must be the same length;
+// full 40 character hashes give exact matches, shorter prefixes trade false
+// positives for a smaller file.
+type BreachedList struct {
+	prefixes  []string
+	prefixLen int
+}
+
+// LoadBreachedList reads a breached password file
+func LoadBreachedList(path string) (*BreachedList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer file.Close()
+
+	list := &BreachedList{}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if idx := strings.IndexByte(line, ':'); idx >= 0 {
+			line = line[:idx]
+		}
+
+		prefix := strings.ToUpper(line)
+		if len(prefix) == 0 || len(prefix) > sha1.Size*2 {
+			return nil, fmt.Errorf("breached password list line %d: invalid prefix length", lineNumber)
+		}
+		if _, err := hex.DecodeString(padEven(prefix)); err != nil {
+			return nil, fmt.Errorf("breached password list line %d: not a hex prefix", lineNumber)
+		}
+		if list.prefixLen == 0 {
+			list.prefixLen = len(prefix)
+		} else if len(prefix) != list.prefixLen {
+			return nil, fmt.Errorf("breached password list line %d: prefixes must all be %d characters", lineNumber, list.prefixLen)
+		}
+
+		list.prefixes = append(list.prefixes, prefix)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+
+	sort.Strings(list.prefixes)
+	return list, nil
+}
+
+// Contains reports whether the password's SHA-1 hash starts with a listed
+// prefix
+func (l *BreachedList) Contains(password string) bool {
+	if l == nil || len(l.prefixes) == 0 {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(password))
+	prefix := strings.ToUpper(hex.EncodeToString(sum[:]))[:l.prefixLen]
+
+	idx := sort.SearchStrings(l.prefixes, prefix)
+	return idx < len(l.prefixes) && l.prefixes[idx] == prefix
+}
+
+// Len returns the number of prefixes in the list
+func (l *BreachedList) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.prefixes)
+}
+
+// padEven lets odd length prefixes through hex validation
+func padEven(s string) string {
+	if len(s)%2 == 1 {
+		return s + "0"
+	}
+	return s
+}
@@ -0,0 +1,114 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// SHA-1 of "password" and "hunter2"
+const (
+	passwordSHA1 = "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8"
+	hunter2SHA1  = "F3BBBD66A63D4BF1747940578EC3D0103530E21D"
+)
+
+func writeBreachedList(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBreachedListContains(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		hits  []string
+		miss  []string
+	}{
+		{
+			name:  "full hashes",
+			lines: []string{passwordSHA1, hunter2SHA1},
+			hits:  []string{"password", "hunter2"},
+			miss:  []string{"Password", "correct horse battery staple", ""},
+		},
+		{
+			name:  "lower case with counts",
+			lines: []string{strings.ToLower(passwordSHA1) + ":3861493"},
+			hits:  []string{"password"},
+			miss:  []string{"hunter2"},
+		},
+		{
+			name:  "prefixes",
+			lines: []string{"# k-anonymity prefixes", "", "5BAA6", "00000"},
+			hits:  []string{"password"},
+			miss:  []string{"hunter2", "correct horse battery staple"},
+		},
+		{
+			name:  "odd length prefixes",
+			lines: []string{"F3B"},
+			hits:  []string{"hunter2"},
+			miss:  []string{"password"},
+		},
+		{
+			name:  "only comments",
+			lines: []string{"# nothing yet"},
+			miss:  []string{"password"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := LoadBreachedList(writeBreachedList(t, tt.lines...))
+			if err != nil {
+				t.Fatalf("LoadBreachedList() error = %v", err)
+			}
+			for _, password := range tt.hits {
+				if !list.Contains(password) {
+					t.Errorf("Contains(%q) = false, want true", password)
+				}
+			}
+			for _, password := range tt.miss {
+				if list.Contains(password) {
+					t.Errorf("Contains(%q) = true, want false", password)
+				}
+			}
+		})
+	}
+}
+
+func TestBreachedListMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"not hex", []string{"5BAA6", "XYZ12"}, "line 2: not a hex prefix"},
+		{"too long", []string{passwordSHA1 + "0"}, "line 1: invalid prefix length"},
+		{"count without prefix", []string{":12"}, "line 1: invalid prefix length"},
+		{"mixed lengths", []string{"# comment", "5BAA6", "F3BB"}, "line 3: prefixes must all be 5 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadBreachedList(writeBreachedList(t, tt.lines...))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadBreachedList() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := LoadBreachedList(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("LoadBreachedList() of a missing file succeeded")
+	}
+}
+
+func TestNilBreachedList(t *testing.T) {
+	var list *BreachedList
+	if list.Contains("password") || list.Len() != 0 {
+		t.Error("nil list should contain nothing")
+	}
+}
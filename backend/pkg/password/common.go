@@ -0,0 +1,30 @@
+package password
+
+import (
+	_ "embed"
+	"strings"
+)
+
+// common_passwords.txt lists frequently used passwords and words, most
+// common first; a match's rank is its line number
+//
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = rankedDictionary(commonPasswordList)
+
+func rankedDictionary(list string) map[string]int {
+	dict := make(map[string]int)
+	rank := 1
+	for _, line := range strings.Split(list, "\n") {
+		word := strings.ToLower(strings.TrimSpace(line))
+		if word == "" {
+			continue
+		}
+		if _, ok := dict[word]; !ok {
+			dict[word] = rank
+			rank++
+		}
+	}
+	return dict
+}
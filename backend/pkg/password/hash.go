@@ -0,0 +1,148 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnknownHashFormat is returned for a stored hash that is neither an
+// Argon2id PHC string nor a bcrypt hash
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// Stored Argon2id hashes with a shorter salt or key are refused: an empty
+// key would match any password, and RFC 9106 asks for at least 8 bytes of
+// salt
+const (
+	minArgon2idSaltLength = 8
+	minArgon2idKeyLength  = 16
+)
+
+// Argon2idParams are the cost parameters of an Argon2id hash. Memory is in
+// KiB.
+type Argon2idParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams follow the OWASP recommendation of 64 MiB, three
+// passes and a parallelism of two
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// HashArgon2id hashes password with a random salt and returns it as a PHC
+// string: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+func HashArgon2id(password string, params Argon2idParams) (string, error) {
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// IsArgon2id reports whether encodedHash is an Argon2id PHC string
+func IsArgon2id(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$argon2id$")
+}
+
+// IsBcrypt reports whether encodedHash is a bcrypt hash
+func IsBcrypt(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$2a$") ||
+		strings.HasPrefix(encodedHash, "$2b$") ||
+		strings.HasPrefix(encodedHash, "$2y$")
+}
+
+// VerifyArgon2id checks password against an Argon2id PHC string and returns
+// the parameters the hash was made with
+func VerifyArgon2id(password, encodedHash string) (bool, Argon2idParams, error) {
+	params, salt, key, err := decodeArgon2id(encodedHash)
+	if err != nil {
+		return false, params, err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(key, candidate) == 1, params, nil
+}
+
+// Verify checks password against a stored Argon2id or bcrypt hash
+func Verify(password, encodedHash string) (bool, error) {
+	switch {
+	case IsArgon2id(encodedHash):
+		match, _, err := VerifyArgon2id(password, encodedHash)
+		return match, err
+	case IsBcrypt(encodedHash):
+		err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	default:
+		return false, ErrUnknownHashFormat
+	}
+}
+
+func decodeArgon2id(encodedHash string) (Argon2idParams, []byte, []byte, error) {
+	var params Argon2idParams
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+	// argon2.IDKey panics on zero passes or parallelism
+	if params.Iterations < 1 || params.Parallelism < 1 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: t=%d, p=%d", params.Iterations, params.Parallelism)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	if len(salt) < minArgon2idSaltLength {
+		return params, nil, nil, fmt.Errorf("argon2id salt is %d bytes, want at least %d", len(salt), minArgon2idSaltLength)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash: %w", err)
+	}
+	if len(key) < minArgon2idKeyLength {
+		return params, nil, nil, fmt.Errorf("argon2id hash is %d bytes, want at least %d", len(key), minArgon2idKeyLength)
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
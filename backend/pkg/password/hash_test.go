@@ -0,0 +1,106 @@
+package password
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2idParams keep the tests fast
+var testArgon2idParams = Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	hash, err := HashArgon2id("correct horse", testArgon2idParams)
+	if err != nil {
+		t.Fatalf("HashArgon2id() error = %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") || !IsArgon2id(hash) || IsBcrypt(hash) {
+		t.Errorf("hash = %q, want a PHC string with the parameters", hash)
+	}
+
+	match, params, err := VerifyArgon2id("correct horse", hash)
+	if err != nil || !match {
+		t.Fatalf("VerifyArgon2id() = %v, %v, want a match", match, err)
+	}
+	if params != testArgon2idParams {
+		t.Errorf("params = %+v, want %+v", params, testArgon2idParams)
+	}
+
+	if match, _, err := VerifyArgon2id("correct horse ", hash); err != nil || match {
+		t.Errorf("VerifyArgon2id() of another password = %v, %v, want no match", match, err)
+	}
+
+	other, _ := HashArgon2id("correct horse", testArgon2idParams)
+	if other == hash {
+		t.Error("two hashes of the same password share a salt")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	argon, err := HashArgon2id("secret-value", testArgon2idParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("secret-value"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		hash     string
+		want     bool
+		err      error
+	}{
+		{"argon2id", "secret-value", argon, true, nil},
+		{"argon2id mismatch", "Secret-value", argon, false, nil},
+		{"bcrypt", "secret-value", string(bcryptHash), true, nil},
+		{"bcrypt mismatch", "Secret-value", string(bcryptHash), false, nil},
+		{"unknown", "secret-value", "plaintext", false, ErrUnknownHashFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Verify(tt.password, tt.hash)
+			if got != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf("Verify() = %v, %v, want %v, %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyArgon2idMalformed(t *testing.T) {
+	b64 := func(n int) string { return base64.RawStdEncoding.EncodeToString(make([]byte, n)) }
+	salt, key := b64(16), b64(32)
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"not argon2id", "$argon2i$v=19$m=64,t=1,p=1$" + salt + "$" + key},
+		{"missing part", "$argon2id$v=19$m=64,t=1,p=1$" + salt},
+		{"other version", "$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key},
+		{"bad parameters", "$argon2id$v=19$m=64,p=1$" + salt + "$" + key},
+		{"zero passes", "$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + key},
+		{"zero parallelism", "$argon2id$v=19$m=64,t=1,p=0$" + salt + "$" + key},
+		{"bad salt", "$argon2id$v=19$m=64,t=1,p=1$!!!$" + key},
+		{"empty salt", "$argon2id$v=19$m=64,t=1,p=1$$" + key},
+		{"short salt", "$argon2id$v=19$m=64,t=1,p=1$" + b64(4) + "$" + key},
+		{"bad key", "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$!!!"},
+		{"empty key", "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$"},
+		{"short key", "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$" + b64(8)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, _, err := VerifyArgon2id("", tt.hash)
+			if err == nil || match {
+				t.Errorf("VerifyArgon2id() = %v, %v, want an error", match, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,410 @@
+package password
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// l33tTable maps substitutions back to the letters they replace. Characters
+// that stand in for more than one letter are tried both ways.
+var l33tTable = map[rune][]rune{
+	'4': {'a'}, '@': {'a'}, '8': {'b'}, '(': {'c'}, '{': {'c'}, '[': {'c'},
+	'3': {'e'}, '6': {'g'}, '9': {'g'}, '1': {'i', 'l'}, '!': {'i'}, '|': {'i', 'l'},
+	'0': {'o'}, '$': {'s'}, '5': {'s'}, '7': {'t', 'l'}, '+': {'t'}, '%': {'x'}, '2': {'z'},
+}
+
+// findMatches returns every pattern found anywhere in the password
+func findMatches(runes []rune, userDict map[string]int) []match {
+	var matches []match
+	matches = append(matches, dictionaryMatches(runes, userDict)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, repeatMatches(runes, userDict)...)
+	matches = append(matches, spatialMatches(runes)...)
+	matches = append(matches, dateMatches(runes)...)
+	return matches
+}
+
+// dictionaryMatches finds common passwords and user inputs, also reversed and
+// with l33t substitutions undone
+func dictionaryMatches(runes []rune, userDict map[string]int) []match {
+	n := len(runes)
+	lower := []rune(strings.ToLower(string(runes)))
+
+	var matches []match
+	lookup := func(word []rune) (int, string, bool) {
+		w := string(word)
+		if rank, ok := userDict[w]; ok {
+			return rank, "user_input", true
+		}
+		if rank, ok := commonPasswords[w]; ok {
+			return rank, "dictionary", true
+		}
+		return 0, "", false
+	}
+
+	for i := 0; i < n; i++ {
+		for j := i + 2; j < n; j++ {
+			token := runes[i : j+1]
+			variations := uppercaseVariations(token)
+			whole := i == 0 && j == n-1
+
+			if rank, pattern, ok := lookup(lower[i : j+1]); ok {
+				matches = append(matches, match{
+					i: i, j: j, pattern: pattern, token: string(token), whole: whole,
+					guesses: float64(rank) * variations,
+				})
+			}
+
+			if rank, pattern, ok := lookup(reversed(lower[i : j+1])); ok {
+				matches = append(matches, match{
+					i: i, j: j, pattern: pattern, token: string(token), whole: whole,
+					guesses: float64(rank) * variations * 2,
+				})
+			}
+
+			for _, sub := range unl33t(lower[i : j+1]) {
+				rank, pattern, ok := lookup(sub.word)
+				if !ok {
+					continue
+				}
+				matches = append(matches, match{
+					i: i, j: j, pattern: pattern, token: string(token),
+					guesses: float64(rank) * variations * l33tVariations(sub.substituted, j-i+1),
+				})
+			}
+		}
+	}
+
+	return matches
+}
+
+type l33tCandidate struct {
+	word        []rune
+	substituted int
+}
+
+// unl33t returns the ways word reads with its l33t characters swapped back
+// for letters. Words without substitutions yield nothing.
+func unl33t(word []rune) []l33tCandidate {
+	candidates := []l33tCandidate{{word: make([]rune, 0, len(word))}}
+	for _, r := range word {
+		letters, ok := l33tTable[r]
+		if !ok {
+			for idx := range candidates {
+				candidates[idx].word = append(candidates[idx].word, r)
+			}
+			continue
+		}
+
+		var next []l33tCandidate
+		for _, c := range candidates {
+			for _, letter := range letters {
+				w := make([]rune, len(c.word), len(word))
+				copy(w, c.word)
+				next = append(next, l33tCandidate{word: append(w, letter), substituted: c.substituted + 1})
+			}
+		}
+		// Ambiguous characters multiply the candidates; keep it bounded
+		if len(next) > 16 {
+			next = next[:16]
+		}
+		candidates = next
+	}
+
+	if candidates[0].substituted == 0 {
+		return nil
+	}
+	return candidates
+}
+
+// l33tVariations estimates how many substitution choices an attacker tries
+func l33tVariations(substituted, length int) float64 {
+	unsubstituted := length - substituted
+	if unsubstituted < substituted {
+		substituted, unsubstituted = unsubstituted, substituted
+	}
+	if substituted == 0 {
+		return 2
+	}
+	var variations float64
+	for i := 1; i <= substituted; i++ {
+		variations += binomial(substituted+unsubstituted, i)
+	}
+	return math.Max(variations, 2)
+}
+
+// uppercaseVariations estimates how many capitalisations an attacker tries.
+// All lower case costs nothing; a capital first or last letter, or all
+// capitals, only doubles the guesses.
+func uppercaseVariations(token []rune) float64 {
+	var upper, lower int
+	for _, r := range token {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 1
+	}
+	if lower == 0 || (upper == 1 && (unicode.IsUpper(token[0]) || unicode.IsUpper(token[len(token)-1]))) {
+		return 2
+	}
+
+	var variations float64
+	for i := 1; i <= upper && i <= lower; i++ {
+		variations += binomial(upper+lower, i)
+	}
+	return variations
+}
+
+// sequenceMatches finds runs like abc, 2468 or zyx
+func sequenceMatches(runes []rune) []match {
+	var matches []match
+	n := len(runes)
+
+	emit := func(i, j, delta int) {
+		if j-i < 2 {
+			return
+		}
+		first := runes[i]
+		var base float64
+		switch {
+		case strings.ContainsRune("aAzZ019", first):
+			base = 4
+		case unicode.IsDigit(first):
+			base = 10
+		default:
+			base = 26
+		}
+		if delta < 0 {
+			base *= 2
+		}
+		matches = append(matches, match{
+			i: i, j: j, pattern: "sequence", token: string(runes[i : j+1]),
+			guesses: base * float64(j-i+1),
+		})
+	}
+
+	start := 0
+	for start < n-2 {
+		delta := int(runes[start+1]) - int(runes[start])
+		if delta == 0 || delta < -2 || delta > 2 || !sameClass(runes[start], runes[start+1]) {
+			start++
+			continue
+		}
+		end := start + 1
+		for end+1 < n && int(runes[end+1])-int(runes[end]) == delta && sameClass(runes[end], runes[end+1]) {
+			end++
+		}
+		emit(start, end, delta)
+		start = end
+	}
+
+	return matches
+}
+
+func sameClass(a, b rune) bool {
+	switch {
+	case unicode.IsDigit(a):
+		return unicode.IsDigit(b)
+	case unicode.IsLower(a):
+		return unicode.IsLower(b)
+	case unicode.IsUpper(a):
+		return unicode.IsUpper(b)
+	}
+	return false
+}
+
+// repeatMatches finds a unit repeated back to back, such as aaaa or abcabc.
+// The repeated unit is itself estimated, so "passwordpassword" is only a
+// little harder than "password". Only the shortest repeating unit at each
+// position is considered.
+func repeatMatches(runes []rune, userDict map[string]int) []match {
+	var matches []match
+	n := len(runes)
+	unitGuesses := make(map[string]float64)
+
+	for i := 0; i < n; i++ {
+		for unit := 1; i+2*unit <= n; unit++ {
+			count := 1
+			for i+(count+1)*unit <= n && equalRunes(runes[i:i+unit], runes[i+count*unit:i+(count+1)*unit]) {
+				count++
+			}
+			if count < 2 || (unit == 1 && count < 3) {
+				continue
+			}
+
+			j := i + count*unit - 1
+			var baseGuesses float64
+			if unit == 1 {
+				baseGuesses = float64(charCardinality(runes[i]))
+			} else {
+				unitRunes := runes[i : i+unit]
+				key := string(unitRunes)
+				guesses, ok := unitGuesses[key]
+				if !ok {
+					guesses, _ = mostGuessableSequence(unitRunes, findMatches(unitRunes, userDict))
+					unitGuesses[key] = guesses
+				}
+				baseGuesses = guesses
+			}
+			matches = append(matches, match{
+				i: i, j: j, pattern: "repeat", token: string(runes[i : j+1]),
+				guesses: baseGuesses * float64(count),
+			})
+			break
+		}
+	}
+
+	return matches
+}
+
+func charCardinality(r rune) int {
+	switch {
+	case unicode.IsDigit(r):
+		return 10
+	case unicode.IsLower(r), unicode.IsUpper(r):
+		return 26
+	}
+	return 33
+}
+
+func equalRunes(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func reversed(word []rune) []rune {
+	r := make([]rune, len(word))
+	for i, c := range word {
+		r[len(word)-1-i] = c
+	}
+	return r
+}
+
+// dateMatches finds years and dates written as digits, with or without
+// separators, such as 1987, 31121987, 12/31/87 or 1987-12-31
+func dateMatches(runes []rune) []match {
+	var matches []match
+	n := len(runes)
+
+	for i := 0; i < n; i++ {
+		for j := i + 3; j < n && j-i < 10; j++ {
+			token := string(runes[i : j+1])
+			year, separated, ok := parseDate(token)
+			if !ok {
+				continue
+			}
+			yearSpace := math.Max(math.Abs(float64(year-referenceYear)), minYearSpace)
+			guesses := yearSpace
+			if len(token) > 4 {
+				guesses *= 365
+			}
+			if separated {
+				guesses *= 4
+			}
+			matches = append(matches, match{i: i, j: j, pattern: "date", token: token, guesses: guesses})
+		}
+	}
+
+	return matches
+}
+
+// parseDate reports the year of a plausible year or day-month-year date.
+// The year may come first or last and day and month in either order.
+func parseDate(token string) (year int, separated bool, ok bool) {
+	isSeparator := func(r rune) bool { return strings.ContainsRune("/-._ ", r) }
+
+	var candidates [][3]string
+	if fields := strings.FieldsFunc(token, isSeparator); len(fields) == 3 {
+		if len(fields[0])+len(fields[1])+len(fields[2])+2 != len(token) || !allDigits(strings.Join(fields, "")) {
+			return 0, false, false
+		}
+		separated = true
+		candidates = append(candidates,
+			[3]string{fields[0], fields[1], fields[2]},
+			[3]string{fields[1], fields[2], fields[0]},
+		)
+	} else if allDigits(token) {
+		if len(token) == 4 {
+			if y, _ := strconv.Atoi(token); y >= 1900 && y <= 2049 {
+				return y, false, true
+			}
+		}
+		// Year last or first, then the rest split into day and month
+		for _, yearLen := range []int{4, 2} {
+			rest := len(token) - yearLen
+			for split := 1; split <= 2; split++ {
+				if rest-split < 1 || rest-split > 2 {
+					continue
+				}
+				candidates = append(candidates,
+					[3]string{token[:split], token[split:rest], token[rest:]},
+					[3]string{token[yearLen : yearLen+split], token[yearLen+split:], token[:yearLen]},
+				)
+			}
+		}
+	}
+
+	for _, c := range candidates {
+		if y, ok := dayMonthYear(c[0], c[1], c[2]); ok {
+			return y, separated, true
+		}
+	}
+	return 0, false, false
+}
+
+// dayMonthYear accepts day and month in either order
+func dayMonthYear(a, b, y string) (int, bool) {
+	if len(a) > 2 || len(b) > 2 {
+		return 0, false
+	}
+	first, _ := strconv.Atoi(a)
+	second, _ := strconv.Atoi(b)
+	year, _ := strconv.Atoi(y)
+
+	validDayMonth := (first >= 1 && first <= 31 && second >= 1 && second <= 12) ||
+		(second >= 1 && second <= 31 && first >= 1 && first <= 12)
+	if !validDayMonth {
+		return 0, false
+	}
+
+	switch len(y) {
+	case 2:
+		// Two digit years are read as 1950-2049
+		if year >= 50 {
+			year += 1900
+		} else {
+			year += 2000
+		}
+	case 4:
+		if year < 1900 || year > 2049 {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	return year, true
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,180 @@
+package password
+
+// QWERTY rows with their shifted counterparts. Each row sits half a key to
+// the right of the one above it.
+var (
+	keyboardRows = []string{
+		"`1234567890-=",
+		"qwertyuiop[]\\",
+		"asdfghjkl;'",
+		"zxcvbnm,./",
+	}
+	shiftedRows = []string{
+		"~!@#$%^&*()_+",
+		"QWERTYUIOP{}|",
+		"ASDFGHJKL:\"",
+		"ZXCVBNM<>?",
+	}
+	// rowOffsets[r] is how far row r's keys are shifted against row r-1:
+	// the key at index k sits below keys k+offset and k+offset+1
+	rowOffsets = []int{0, 1, 0, 0}
+)
+
+type keyPosition struct {
+	row, col int
+	shifted  bool
+}
+
+var (
+	keyPositions   = buildKeyPositions()
+	keyboardKeys   = float64(len(keyPositions) / 2)
+	keyboardDegree = averageDegree()
+)
+
+func buildKeyPositions() map[rune]keyPosition {
+	positions := make(map[rune]keyPosition)
+	for row := range keyboardRows {
+		for col, r := range keyboardRows[row] {
+			positions[r] = keyPosition{row: row, col: col}
+		}
+		for col, r := range shiftedRows[row] {
+			positions[r] = keyPosition{row: row, col: col, shifted: true}
+		}
+	}
+	return positions
+}
+
+// adjacent reports whether two keys touch and the direction between them, so
+// a walk that changes direction can be counted as a turn
+func adjacent(a, b keyPosition) (int, bool) {
+	switch b.row - a.row {
+	case 0:
+		switch b.col - a.col {
+		case -1:
+			return 0, true
+		case 1:
+			return 1, true
+		}
+	case -1:
+		// The row above
+		switch b.col - (a.col + rowOffsets[a.row]) {
+		case 0:
+			return 2, true
+		case 1:
+			return 3, true
+		}
+	case 1:
+		// The row below
+		switch a.col - (b.col + rowOffsets[b.row]) {
+		case 0:
+			return 4, true
+		case 1:
+			return 5, true
+		}
+	}
+	return 0, false
+}
+
+func averageDegree() float64 {
+	var total, keys int
+	for row := range keyboardRows {
+		for col := range keyboardRows[row] {
+			a := keyPosition{row: row, col: col}
+			for other := range keyboardRows {
+				for otherCol := range keyboardRows[other] {
+					if _, ok := adjacent(a, keyPosition{row: other, col: otherCol}); ok {
+						total++
+					}
+				}
+			}
+			keys++
+		}
+	}
+	return float64(total) / float64(keys)
+}
+
+// spatialMatches finds keyboard walks like qwerty, asdf or 1qaz
+func spatialMatches(runes []rune) []match {
+	var matches []match
+	n := len(runes)
+
+	for i := 0; i < n-2; {
+		start, ok := keyPositions[runes[i]]
+		if !ok {
+			i++
+			continue
+		}
+
+		j, turns, shifted := i, 0, 0
+		if start.shifted {
+			shifted++
+		}
+		lastDirection := -1
+		prev := start
+		for j+1 < n {
+			next, ok := keyPositions[runes[j+1]]
+			if !ok {
+				break
+			}
+			direction, ok := adjacent(prev, next)
+			if !ok {
+				break
+			}
+			if direction != lastDirection {
+				turns++
+				lastDirection = direction
+			}
+			if next.shifted {
+				shifted++
+			}
+			prev = next
+			j++
+		}
+
+		if j-i >= 2 {
+			matches = append(matches, match{
+				i: i, j: j, pattern: "spatial", token: string(runes[i : j+1]),
+				guesses: spatialGuesses(j-i+1, turns, shifted),
+			})
+			i = j
+			continue
+		}
+		i++
+	}
+
+	return matches
+}
+
+// spatialGuesses counts the walks of up to length keys with up to turns
+// changes of direction, times the ways to shift some of the keys
+func spatialGuesses(length, turns, shifted int) float64 {
+	var guesses float64
+	for l := 2; l <= length; l++ {
+		for t := 1; t <= turns && t <= l-1; t++ {
+			guesses += binomial(l-1, t-1) * keyboardKeys * pow(keyboardDegree, t)
+		}
+	}
+
+	if shifted > 0 {
+		unshifted := length - shifted
+		if unshifted == 0 {
+			guesses *= 2
+		} else {
+			var variations float64
+			for i := 1; i <= shifted && i <= unshifted; i++ {
+				variations += binomial(shifted+unshifted, i)
+			}
+			guesses *= variations
+		}
+	}
+
+	return guesses
+}
+
+func pow(base float64, exp int) float64 {
+	r := 1.0
+	for i := 0; i < exp; i++ {
+		r *= base
+	}
+	return r
+}
@@ -0,0 +1,254 @@
+// Package password estimates password strength, screens passwords against
+// known breaches and hashes them for storage.
+//
+// The strength estimate follows zxcvbn: the password is broken into the
+// most guessable sequence of patterns (common passwords, the user's own
+// details, l33t variants, sequences, repeats, keyboard walks, dates and
+// brute force), the guesses needed for that sequence are estimated and
+// mapped to a score from 0 (trivial) to 4 (strong).
+package password
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// maxEstimateLength caps the work done on very long inputs; anything past it
+// only adds strength
+const maxEstimateLength = 100
+
+// Score thresholds in guesses, as used by zxcvbn
+var scoreThresholds = []float64{1e3 + 5, 1e6 + 5, 1e8 + 5, 1e10 + 5}
+
+// Strength is the result of Estimate
+type Strength struct {
+	// Score runs from 0 (too guessable) to 4 (very unguessable)
+	Score int
+	// Guesses is the estimated number of guesses needed to crack the password
+	Guesses float64
+	// Warning explains the weakest part of a weak password, if known
+	Warning string
+	// Suggestions help the user pick a stronger password
+	Suggestions []string
+}
+
+// Estimate scores password. userInputs are the user's own details (email
+// address, name) which make a password easier to guess if it contains them.
+func Estimate(password string, userInputs ...string) Strength {
+	runes := []rune(password)
+	if len(runes) > maxEstimateLength {
+		runes = runes[:maxEstimateLength]
+	}
+	if len(runes) == 0 {
+		return Strength{Score: 0, Guesses: 1, Suggestions: []string{"Use a few words, avoid common phrases"}}
+	}
+
+	matches := findMatches(runes, userDictionary(userInputs))
+	guesses, sequence := mostGuessableSequence(runes, matches)
+
+	strength := Strength{Guesses: guesses, Score: len(scoreThresholds)}
+	for i, threshold := range scoreThresholds {
+		if guesses < threshold {
+			strength.Score = i
+			break
+		}
+	}
+
+	if strength.Score <= 2 {
+		strength.Warning, strength.Suggestions = feedback(sequence)
+	}
+
+	return strength
+}
+
+// match is a pattern covering runes[i..j]
+type match struct {
+	i, j    int
+	pattern string // dictionary, user_input, sequence, repeat, spatial, date, bruteforce
+	guesses float64
+	token   string
+	whole   bool // dictionary match that covers the whole password
+}
+
+const (
+	bruteforceCardinality = 10
+	minGuessesSingleChar  = 10
+	minGuessesMultiChar   = 50
+	minYearSpace          = 20
+	referenceYear         = 2020
+)
+
+// mostGuessableSequence finds the non-overlapping matches, padded with brute
+// force, that need the fewest guesses in total. As in zxcvbn a sequence of l
+// matches costs l! * product(guesses) + 10000^(l-1), so an attacker has to
+// try orderings and more pieces are penalised.
+func mostGuessableSequence(runes []rune, matches []match) (float64, []match) {
+	n := len(runes)
+
+	byEnd := make([][]match, n)
+	for _, m := range matches {
+		byEnd[m.j] = append(byEnd[m.j], m)
+	}
+	// Brute force over any span, so gaps between patterns are covered
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			byEnd[j] = append(byEnd[j], match{
+				i: i, j: j, pattern: "bruteforce", token: string(runes[i : j+1]),
+				guesses: bruteforceGuesses(j - i + 1),
+			})
+		}
+	}
+
+	// best[k][l] is the smallest product of guesses covering runes[:k] with
+	// l matches; back[k][l] is the last match of that sequence
+	best := make([][]float64, n+1)
+	back := make([][]*match, n+1)
+	for k := range best {
+		best[k] = make([]float64, n+1)
+		back[k] = make([]*match, n+1)
+		for l := range best[k] {
+			best[k][l] = math.Inf(1)
+		}
+	}
+	best[0][0] = 1
+
+	for k := 1; k <= n; k++ {
+		for idx := range byEnd[k-1] {
+			m := &byEnd[k-1][idx]
+			for l := 1; l <= k; l++ {
+				prev := best[m.i][l-1]
+				if math.IsInf(prev, 1) {
+					continue
+				}
+				// Two brute force runs in a row are really one
+				if m.pattern == "bruteforce" && back[m.i][l-1] != nil && back[m.i][l-1].pattern == "bruteforce" {
+					continue
+				}
+				if candidate := prev * m.guesses; candidate < best[k][l] {
+					best[k][l] = candidate
+					back[k][l] = m
+				}
+			}
+		}
+	}
+
+	guesses, length := math.Inf(1), 0
+	for l := 1; l <= n; l++ {
+		if math.IsInf(best[n][l], 1) {
+			continue
+		}
+		total := factorial(l)*best[n][l] + math.Pow(10000, float64(l-1))
+		if total < guesses {
+			guesses, length = total, l
+		}
+	}
+
+	var sequence []match
+	for k, l := n, length; k > 0 && l > 0; l-- {
+		m := back[k][l]
+		sequence = append([]match{*m}, sequence...)
+		k = m.i
+	}
+
+	return guesses, sequence
+}
+
+func bruteforceGuesses(length int) float64 {
+	guesses := math.Pow(bruteforceCardinality, float64(length))
+	minGuesses := float64(minGuessesMultiChar)
+	if length == 1 {
+		minGuesses = minGuessesSingleChar + 1
+	}
+	return math.Max(guesses, minGuesses)
+}
+
+func feedback(sequence []match) (string, []string) {
+	suggestions := []string{"Add another word or two. Uncommon words are better."}
+
+	// Explain the longest recognisable pattern
+	var longest *match
+	for idx := range sequence {
+		m := &sequence[idx]
+		if m.pattern == "bruteforce" {
+			continue
+		}
+		if longest == nil || m.j-m.i > longest.j-longest.i {
+			longest = m
+		}
+	}
+	if longest == nil {
+		return "", append(suggestions, "Use a longer keyboard pattern with more turns")
+	}
+
+	switch longest.pattern {
+	case "dictionary":
+		if longest.whole {
+			return "This is a very common password", suggestions
+		}
+		return "This is similar to a commonly used password", append(suggestions, "Predictable substitutions like '@' instead of 'a' don't help very much")
+	case "user_input":
+		return "Avoid using your name or email address in your password", suggestions
+	case "sequence":
+		return "Sequences like abc or 6543 are easy to guess", append(suggestions, "Avoid sequences")
+	case "repeat":
+		return "Repeats like \"aaa\" or \"abcabc\" are easy to guess", append(suggestions, "Avoid repeated words and characters")
+	case "spatial":
+		return "Keyboard patterns like qwerty are easy to guess", append(suggestions, "Use a longer keyboard pattern with more turns")
+	case "date":
+		return "Dates and years are easy to guess", append(suggestions, "Avoid dates and years that are associated with you")
+	}
+
+	return "", suggestions
+}
+
+// userDictionary ranks the words in the user's own details
+func userDictionary(inputs []string) map[string]int {
+	dict := make(map[string]int)
+	rank := 1
+	add := func(word string) {
+		word = strings.ToLower(word)
+		if len([]rune(word)) < 3 {
+			return
+		}
+		if _, ok := dict[word]; !ok {
+			dict[word] = rank
+			rank++
+		}
+	}
+
+	for _, input := range inputs {
+		add(input)
+		// Split "jane.doe@example.com" into jane, doe, example
+		for _, word := range strings.FieldsFunc(input, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			add(word)
+		}
+	}
+
+	return dict
+}
+
+func factorial(n int) float64 {
+	f := 1.0
+	for i := 2; i <= n; i++ {
+		f *= float64(i)
+	}
+	return f
+}
+
+// binomial returns n choose k
+func binomial(n, k int) float64 {
+	if k < 0 || k > n {
+		return 0
+	}
+	if k > n-k {
+		k = n - k
+	}
+	r := 1.0
+	for i := 1; i <= k; i++ {
+		r = r * float64(n-k+i) / float64(i)
+	}
+	return r
+}
@@ -0,0 +1,122 @@
+package password
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEstimateScores(t *testing.T) {
+	tests := []struct {
+		password string
+		score    int
+		warning  string
+	}{
+		{"", 0, ""},
+		{"password", 0, "This is a very common password"},
+		{"Password", 0, "This is a very common password"},
+		{"drowssap", 0, "This is a very common password"},
+		{"P@ssw0rd", 0, "This is similar to a commonly used password"},
+		{"hunter2", 1, "This is similar to a commonly used password"},
+		{"abcdefgh", 0, "Sequences like abc or 6543 are easy to guess"},
+		{"zyxwvu", 0, "Sequences like abc or 6543 are easy to guess"},
+		{"aaaaaaaa", 0, "Repeats like \"aaa\" or \"abcabc\" are easy to guess"},
+		{"passwordpassword", 0, "Repeats like \"aaa\" or \"abcabc\" are easy to guess"},
+		{"asdfghjkl;", 1, "Keyboard patterns like qwerty are easy to guess"},
+		{"tyuhjk", 1, "Keyboard patterns like qwerty are easy to guess"},
+		{"31121987", 1, "Dates and years are easy to guess"},
+		{"12/31/1987", 1, "Dates and years are easy to guess"},
+		{"kX9#mQ2$vL7!pR4w", 4, ""},
+		{"correct horse battery staple", 4, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			got := Estimate(tt.password)
+			if got.Score != tt.score {
+				t.Errorf("Score = %d (%.3g guesses), want %d", got.Score, got.Guesses, tt.score)
+			}
+			if got.Warning != tt.warning {
+				t.Errorf("Warning = %q, want %q", got.Warning, tt.warning)
+			}
+			if got.Score <= 2 && len(got.Suggestions) == 0 {
+				t.Error("weak password has no suggestions")
+			}
+			if got.Score > 2 && got.Suggestions != nil {
+				t.Errorf("strong password has suggestions %v", got.Suggestions)
+			}
+		})
+	}
+}
+
+func TestEstimateUserInputs(t *testing.T) {
+	inputs := []string{"jane.doe@example.com", "Jane Doe"}
+
+	tests := []struct {
+		password string
+		without  int
+		with     int
+	}{
+		{"janedoe!", 2, 1},
+		{"Janedoe2024", 3, 2},
+		{"eodenaj", 2, 1}, // reversed
+		{"j4n3d03", 2, 1}, // l33t
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			if got := Estimate(tt.password); got.Score != tt.without {
+				t.Errorf("Score without user inputs = %d, want %d", got.Score, tt.without)
+			}
+			got := Estimate(tt.password, inputs...)
+			if got.Score != tt.with {
+				t.Errorf("Score with user inputs = %d, want %d", got.Score, tt.with)
+			}
+			if want := "Avoid using your name or email address in your password"; got.Warning != want {
+				t.Errorf("Warning = %q, want %q", got.Warning, want)
+			}
+		})
+	}
+}
+
+func TestUserDictionary(t *testing.T) {
+	got := userDictionary([]string{"Jane.Doe@Example.com", "Al Jane"})
+	want := map[string]int{
+		"jane.doe@example.com": 1,
+		"jane":                 2,
+		"doe":                  3,
+		"example":              4,
+		"com":                  5,
+		"al jane":              6,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("userDictionary() = %v, want %v", got, want)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		token     string
+		year      int
+		separated bool
+		ok        bool
+	}{
+		{"1987", 1987, false, true},
+		{"31121987", 1987, false, true},
+		{"19871231", 1987, false, true},
+		{"123187", 1987, false, true},
+		{"12/31/87", 1987, true, true},
+		{"1987-12-31", 1987, true, true},
+		{"3105", 2005, false, true},
+		{"12/31/1850", 0, false, false},
+		{"0000", 0, false, false},
+		{"99999999", 0, false, false},
+		{"12/31/1987x", 0, false, false},
+	}
+
+	for _, tt := range tests {
+		year, separated, ok := parseDate(tt.token)
+		if year != tt.year || separated != tt.separated || ok != tt.ok {
+			t.Errorf("parseDate(%q) = %d, %v, %v, want %d, %v, %v", tt.token, year, separated, ok, tt.year, tt.separated, tt.ok)
+		}
+	}
+}
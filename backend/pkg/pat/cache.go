@@ -0,0 +1,85 @@
+package pat
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CachedVerifier remembers verification results for ttl, keyed by token
+// hash, so that most requests don't reach the underlying verifier. ttl bounds
+// how long a revoked token keeps working.
+type CachedVerifier struct {
+	next       Verifier
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]verifyEntry
+}
+
+type verifyEntry struct {
+	principal *Principal // nil if the token is invalid
+	expiresAt time.Time
+}
+
+func NewCachedVerifier(next Verifier, ttl time.Duration, maxEntries int) *CachedVerifier {
+	return &CachedVerifier{
+		next:       next,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]verifyEntry),
+	}
+}
+
+func (v *CachedVerifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	key := Hash(token)
+	now := time.Now()
+
+	v.mu.Lock()
+	entry, ok := v.entries[key]
+	v.mu.Unlock()
+
+	if ok && now.Before(entry.expiresAt) {
+		if entry.principal == nil {
+			return nil, ErrInvalidToken
+		}
+		return entry.principal, nil
+	}
+
+	principal, err := v.next.Verify(ctx, token)
+	if err != nil && !errors.Is(err, ErrInvalidToken) {
+		return nil, err
+	}
+
+	// Don't keep a token past its own expiry
+	expiresAt := now.Add(v.ttl)
+	if principal != nil && principal.ExpiresAt != nil && principal.ExpiresAt.Before(expiresAt) {
+		expiresAt = *principal.ExpiresAt
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if len(v.entries) >= v.maxEntries {
+		v.evict(now)
+	}
+	v.entries[key] = verifyEntry{principal: principal, expiresAt: expiresAt}
+
+	return principal, err
+}
+
+// evict makes room by dropping expired entries, or everything if none have
+// expired. Must be called with mu held.
+func (v *CachedVerifier) evict(now time.Time) {
+	for key, entry := range v.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(v.entries, key)
+		}
+	}
+
+	if len(v.entries) >= v.maxEntries {
+		v.entries = make(map[string]verifyEntry)
+	}
+}
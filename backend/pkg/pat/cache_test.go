@@ -0,0 +1,121 @@
+package pat
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingVerifier answers from principals, or with err when set, and counts
+// the verifications that reach it
+type countingVerifier struct {
+	principals map[string]*Principal
+	err        error
+	calls      int
+}
+
+func (v *countingVerifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	v.calls++
+	if v.err != nil {
+		return nil, v.err
+	}
+	principal, ok := v.principals[token]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return principal, nil
+}
+
+func TestCachedVerifier(t *testing.T) {
+	ctx := context.Background()
+	valid := Prefix + "valid"
+	unknown := Prefix + "unknown"
+
+	next := &countingVerifier{principals: map[string]*Principal{
+		valid: {TokenID: "t1", UserID: "u1", Scopes: []string{"flowtime:read"}},
+	}}
+	cached := NewCachedVerifier(next, 50*time.Millisecond, 100)
+
+	for i := 0; i < 3; i++ {
+		principal, err := cached.Verify(ctx, valid)
+		if err != nil || principal.UserID != "u1" {
+			t.Fatalf("Verify(valid) = %v, %v", principal, err)
+		}
+		if _, err := cached.Verify(ctx, unknown); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("Verify(unknown) error = %v, want ErrInvalidToken", err)
+		}
+	}
+	if next.calls != 2 {
+		t.Errorf("verifier called %d times, want 2: valid and invalid tokens are cached", next.calls)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if _, err := cached.Verify(ctx, valid); err != nil {
+		t.Fatalf("Verify(valid) after ttl error = %v", err)
+	}
+	if next.calls != 3 {
+		t.Errorf("verifier called %d times, want 3: the entry outlived the ttl", next.calls)
+	}
+}
+
+func TestCachedVerifierDoesNotCacheErrors(t *testing.T) {
+	ctx := context.Background()
+	token := Prefix + "valid"
+	next := &countingVerifier{
+		principals: map[string]*Principal{token: {TokenID: "t1", UserID: "u1"}},
+		err:        errors.New("auth service unavailable"),
+	}
+	cached := NewCachedVerifier(next, time.Minute, 100)
+
+	principal, err := cached.Verify(ctx, token)
+	if err == nil || errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Verify() = %v, %v, want the verifier's error", principal, err)
+	}
+
+	next.err = nil
+	if principal, err := cached.Verify(ctx, token); err != nil || principal.UserID != "u1" {
+		t.Fatalf("Verify() after recovery = %v, %v; the failure was cached", principal, err)
+	}
+	if next.calls != 2 {
+		t.Errorf("verifier called %d times, want 2", next.calls)
+	}
+}
+
+func TestCachedVerifierStopsAtTokenExpiry(t *testing.T) {
+	ctx := context.Background()
+	token := Prefix + "expiring"
+	expiresAt := time.Now().Add(50 * time.Millisecond)
+	next := &countingVerifier{principals: map[string]*Principal{
+		token: {TokenID: "t1", UserID: "u1", ExpiresAt: &expiresAt},
+	}}
+	cached := NewCachedVerifier(next, time.Minute, 100)
+
+	if _, err := cached.Verify(ctx, token); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	delete(next.principals, token)
+
+	if _, err := cached.Verify(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify() after the token expired error = %v, want ErrInvalidToken", err)
+	}
+	if next.calls != 2 {
+		t.Errorf("verifier called %d times, want 2: the entry outlived the token", next.calls)
+	}
+}
+
+func TestCachedVerifierMaxEntries(t *testing.T) {
+	ctx := context.Background()
+	next := &countingVerifier{}
+	cached := NewCachedVerifier(next, time.Minute, 2)
+
+	for _, token := range []string{"a", "b", "c"} {
+		cached.Verify(ctx, Prefix+token)
+	}
+	if len(cached.entries) > 2 {
+		t.Errorf("cache holds %d entries, want at most 2", len(cached.entries))
+	}
+}
@@ -0,0 +1,75 @@
+package pat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
+)
+
+// VerifyPath is the auth service endpoint that verifies personal access
+// tokens
+const VerifyPath = "/internal/tokens/verify"
+
+// VerifyRequest is the body sent to VerifyPath. The token travels in the
+// body so it never appears in access logs.
+type VerifyRequest struct {
+	Token string `json:"token"`
+}
+
+// HTTPVerifier asks the auth service to verify tokens. It is meant to be
+// wrapped in a CachedVerifier.
+type HTTPVerifier struct {
+	url           string
+	internalToken string
+	httpClient    *http.Client
+}
+
+func NewHTTPVerifier(authServiceURL, internalToken string, timeout time.Duration) *HTTPVerifier {
+	return &HTTPVerifier{
+		url:           strings.TrimSuffix(authServiceURL, "/") + VerifyPath,
+		internalToken: internalToken,
+		httpClient:    &http.Client{Timeout: timeout},
+	}
+}
+
+func (v *HTTPVerifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	body, err := json.Marshal(VerifyRequest{Token: token})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode token verification request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build token verification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(revocation.InternalTokenHeader, v.internalToken)
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrInvalidToken
+	default:
+		return nil, fmt.Errorf("token verification returned status %d", resp.StatusCode)
+	}
+
+	var principal Principal
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<10)).Decode(&principal); err != nil {
+		return nil, fmt.Errorf("failed to decode token verification response: %w", err)
+	}
+
+	return &principal, nil
+}
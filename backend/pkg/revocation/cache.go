@@ -0,0 +1,157 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CachedChecker remembers check results so that most requests don't reach
+// the underlying checker. A token found to be revoked stays revoked, so that
+// result is kept until the token expires; a clean result is kept for ttl,
+// which bounds how long a new revocation can go unnoticed.
+type CachedChecker struct {
+	next       Checker
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	userID    string
+	sessionID string
+	revoked   bool
+	expiresAt time.Time
+}
+
+func NewCachedChecker(next Checker, ttl time.Duration, maxEntries int) *CachedChecker {
+	return &CachedChecker{
+		next:       next,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]cacheEntry),
+	}
+}
+
+func (c *CachedChecker) IsRevoked(ctx context.Context, token Token) (bool, error) {
+	key := token.cacheKey()
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok && now.Before(entry.expiresAt) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := c.next.IsRevoked(ctx, token)
+	if err != nil {
+		return false, err
+	}
+
+	expiresAt := now.Add(c.ttl)
+	if revoked && token.ExpiresAt.After(expiresAt) {
+		expiresAt = token.ExpiresAt
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= c.maxEntries {
+		c.evict(now)
+	}
+	c.entries[key] = cacheEntry{userID: token.UserID, sessionID: token.SessionID, revoked: revoked, expiresAt: expiresAt}
+
+	return revoked, nil
+}
+
+// Forget drops the cached result for a token
+func (c *CachedChecker) Forget(token Token) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, token.cacheKey())
+}
+
+// ForgetUser drops the cached results for all of a user's tokens
+func (c *CachedChecker) ForgetUser(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if entry.userID == userID {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// ForgetSession drops the cached results for all of a session's tokens
+func (c *CachedChecker) ForgetSession(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if entry.sessionID == sessionID {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// evict makes room by dropping expired entries, or everything if none have
+// expired. Must be called with mu held.
+func (c *CachedChecker) evict(now time.Time) {
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+
+	if len(c.entries) >= c.maxEntries {
+		c.entries = make(map[string]cacheEntry)
+	}
+}
+
+// CachedStore is a Store whose checks go through a CachedChecker.
+// Revocations made through it take effect in its own cache immediately.
+type CachedStore struct {
+	Store
+	cache *CachedChecker
+}
+
+func NewCachedStore(store Store, ttl time.Duration, maxEntries int) *CachedStore {
+	return &CachedStore{
+		Store: store,
+		cache: NewCachedChecker(store, ttl, maxEntries),
+	}
+}
+
+func (s *CachedStore) IsRevoked(ctx context.Context, token Token) (bool, error) {
+	return s.cache.IsRevoked(ctx, token)
+}
+
+func (s *CachedStore) RevokeToken(ctx context.Context, token Token) error {
+	if err := s.Store.RevokeToken(ctx, token); err != nil {
+		return err
+	}
+	s.cache.Forget(token)
+	return nil
+}
+
+func (s *CachedStore) RevokeUser(ctx context.Context, userID string, before time.Time, ttl time.Duration) error {
+	if err := s.Store.RevokeUser(ctx, userID, before, ttl); err != nil {
+		return err
+	}
+	s.cache.ForgetUser(userID)
+	return nil
+}
+
+func (s *CachedStore) RevokeSession(ctx context.Context, sessionID string, before time.Time, ttl time.Duration) error {
+	if err := s.Store.RevokeSession(ctx, sessionID, before, ttl); err != nil {
+		return err
+	}
+	s.cache.ForgetSession(sessionID)
+	return nil
+}
@@ -0,0 +1,75 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// CheckPath is the auth service endpoint that answers revocation checks
+	CheckPath = "/internal/revocations/check"
+
+	// InternalTokenHeader carries the shared secret for internal endpoints
+	InternalTokenHeader = "X-Internal-Token"
+)
+
+// CheckResponse is the body returned by CheckPath
+type CheckResponse struct {
+	Revoked bool `json:"revoked"`
+}
+
+// HTTPChecker asks the auth service whether a token has been revoked. It is
+// meant to be wrapped in a CachedChecker.
+type HTTPChecker struct {
+	url           string
+	internalToken string
+	httpClient    *http.Client
+}
+
+func NewHTTPChecker(authServiceURL, internalToken string, timeout time.Duration) *HTTPChecker {
+	return &HTTPChecker{
+		url:           strings.TrimSuffix(authServiceURL, "/") + CheckPath,
+		internalToken: internalToken,
+		httpClient:    &http.Client{Timeout: timeout},
+	}
+}
+
+func (c *HTTPChecker) IsRevoked(ctx context.Context, token Token) (bool, error) {
+	query := url.Values{}
+	query.Set("jti", token.ID)
+	query.Set("user_id", token.UserID)
+	if token.SessionID != "" {
+		query.Set("sid", token.SessionID)
+	}
+	query.Set("iat", strconv.FormatInt(token.IssuedAt.Unix(), 10))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"?"+query.Encode(), nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to build revocation request: %w", err)
+	}
+	req.Header.Set(InternalTokenHeader, c.internalToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to check revocation: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("revocation check returned status %d", resp.StatusCode)
+	}
+
+	var body CheckResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<10)).Decode(&body); err != nil {
+		return false, fmt.Errorf("failed to decode revocation response: %w", err)
+	}
+
+	return body.Revoked, nil
+}
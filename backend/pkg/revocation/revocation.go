@@ -0,0 +1,49 @@
+// Package revocation tracks access tokens that must stop working before
+// they expire. Entries either name a single token by its jti or cut off
+// every token a user, or one of their sessions, was issued before a point
+// in time. Entries only need to outlive the tokens they cover, so the store
+// stays small.
+package revocation
+
+import (
+	"context"
+	"time"
+)
+
+// Token identifies an access token
+type Token struct {
+	ID        string // jti, empty for tokens issued before jtis were added
+	UserID    string
+	SessionID string // sid, empty for tokens not issued to a session
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// Checker reports whether an access token has been revoked
+type Checker interface {
+	IsRevoked(ctx context.Context, token Token) (bool, error)
+}
+
+// Store records revocations and answers checks against them
+type Store interface {
+	Checker
+	// RevokeToken revokes a single token until it expires
+	RevokeToken(ctx context.Context, token Token) error
+	// RevokeUser revokes every token issued to the user before the given
+	// time. The entry is kept until ttl after that time, which should be
+	// the access token lifetime.
+	RevokeUser(ctx context.Context, userID string, before time.Time, ttl time.Duration) error
+	// RevokeSession revokes every token issued for the session before the
+	// given time, kept like RevokeUser's entries
+	RevokeSession(ctx context.Context, sessionID string, before time.Time, ttl time.Duration) error
+	// DeleteExpired removes entries that no longer cover a live token
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// cacheKey identifies a token for caching check results
+func (t Token) cacheKey() string {
+	if t.ID != "" {
+		return t.ID
+	}
+	return t.UserID + "@" + t.IssuedAt.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,97 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// memoryStore revokes whole sessions and counts the checks that reach it
+type memoryStore struct {
+	Store
+	sessions map[string]bool
+	checks   int
+}
+
+func (m *memoryStore) IsRevoked(ctx context.Context, token Token) (bool, error) {
+	m.checks++
+	return m.sessions[token.SessionID], nil
+}
+
+func (m *memoryStore) RevokeSession(ctx context.Context, sessionID string, before time.Time, ttl time.Duration) error {
+	m.sessions[sessionID] = true
+	return nil
+}
+
+func TestCachedStoreRevokeSession(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{sessions: make(map[string]bool)}
+	cached := NewCachedStore(store, time.Minute, 100)
+
+	now := time.Now()
+	kept := Token{ID: "jti-1", UserID: "u1", SessionID: "s1", IssuedAt: now, ExpiresAt: now.Add(15 * time.Minute)}
+	ended := Token{ID: "jti-2", UserID: "u1", SessionID: "s2", IssuedAt: now, ExpiresAt: now.Add(15 * time.Minute)}
+
+	for _, token := range []Token{kept, ended} {
+		if revoked, _ := cached.IsRevoked(ctx, token); revoked {
+			t.Fatalf("token %s revoked before its session was", token.ID)
+		}
+	}
+
+	if err := cached.RevokeSession(ctx, "s2", now, 15*time.Minute); err != nil {
+		t.Fatalf("RevokeSession() error = %v", err)
+	}
+
+	if revoked, _ := cached.IsRevoked(ctx, ended); !revoked {
+		t.Error("token of the revoked session still accepted from the cache")
+	}
+	checks := store.checks
+	if revoked, _ := cached.IsRevoked(ctx, kept); revoked {
+		t.Error("token of another session revoked")
+	}
+	if store.checks != checks {
+		t.Error("cached result for another session was dropped")
+	}
+}
+
+func TestHTTPCheckerSendsSessionID(t *testing.T) {
+	tests := []struct {
+		name      string
+		sessionID string
+	}{
+		{"session token", "s1"},
+		{"token without session", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var present bool
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != CheckPath || r.Header.Get(InternalTokenHeader) != "secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				got = r.URL.Query().Get("sid")
+				present = r.URL.Query().Has("sid")
+				json.NewEncoder(w).Encode(CheckResponse{Revoked: got != ""})
+			}))
+			defer server.Close()
+
+			checker := NewHTTPChecker(server.URL, "secret", time.Second)
+			revoked, err := checker.IsRevoked(context.Background(), Token{ID: "jti", UserID: "u1", SessionID: tt.sessionID, IssuedAt: time.Now()})
+			if err != nil {
+				t.Fatalf("IsRevoked() error = %v", err)
+			}
+			if got != tt.sessionID || present != (tt.sessionID != "") {
+				t.Errorf("sid = %q (present %v), want %q", got, present, tt.sessionID)
+			}
+			if revoked != (tt.sessionID != "") {
+				t.Errorf("IsRevoked() = %v", revoked)
+			}
+		})
+	}
+}
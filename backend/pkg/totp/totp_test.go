@@ -0,0 +1,155 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of RFC 6238 appendix B, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B, cut to the last six of its eight digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Code() at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCodeSecretFormats(t *testing.T) {
+	want, _ := Code(rfcSecret, 1)
+
+	for _, secret := range []string{"gezdgnbvgy3tqojqgezdgnbvgy3tqojq", rfcSecret + "===="} {
+		if got, err := Code(secret, 1); err != nil || got != want {
+			t.Errorf("Code(%q) = %q, %v, want %q", secret, got, err, want)
+		}
+	}
+
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code() accepted an invalid secret")
+	}
+}
+
+func TestStep(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want int64
+	}{
+		{0, 0},
+		{29, 0},
+		{30, 1},
+		{59, 1},
+		{1111111109, 37037036},
+	}
+
+	for _, tt := range tests {
+		if got := Step(time.Unix(tt.unix, 0)); got != tt.want {
+			t.Errorf("Step(%d) = %d, want %d", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+	code := func(step int64) string {
+		c, _ := Code(rfcSecret, step)
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		skew     int
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", rfcSecret, code(current), 0, current, true},
+		{"previous step within skew", rfcSecret, code(current - 1), 1, current - 1, true},
+		{"next step within skew", rfcSecret, code(current + 1), 1, current + 1, true},
+		{"previous step without skew", rfcSecret, code(current - 1), 0, 0, false},
+		{"outside skew", rfcSecret, code(current - 2), 1, 0, false},
+		{"spaces", rfcSecret, " " + code(current)[:3] + " " + code(current)[3:] + " ", 0, current, true},
+		{"wrong code", rfcSecret, "000000", 1, 0, false},
+		{"too short", rfcSecret, code(current)[:5], 1, 0, false},
+		{"too long", rfcSecret, code(current) + "0", 1, 0, false},
+		{"empty", rfcSecret, "", 1, 0, false},
+		{"invalid secret", "not base32!", code(current), 1, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(tt.secret, tt.code, now, tt.skew)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("Validate() = %d, %v, want %d, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() error = %v", err)
+	}
+	b, _ := GenerateSecret()
+	if a == b {
+		t.Error("GenerateSecret() returned the same secret twice")
+	}
+
+	key, err := encoding.DecodeString(a)
+	if err != nil || len(key) != secretSz {
+		t.Errorf("GenerateSecret() = %q, decodes to %d bytes, %v", a, len(key), err)
+	}
+	if _, err := Code(a, 1); err != nil {
+		t.Errorf("Code() with a generated secret error = %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	raw := URI(rfcSecret, "Flow Time", "user+tag@example.com")
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("URI() = %q does not parse: %v", raw, err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Errorf("URI() = %q, want an otpauth://totp/ URI", raw)
+	}
+	if u.Path != "/Flow Time:user+tag@example.com" {
+		t.Errorf("URI() label = %q", u.Path)
+	}
+
+	want := map[string]string{
+		"secret":    rfcSecret,
+		"issuer":    "Flow Time",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	}
+	query := u.Query()
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("URI() %s = %q, want %q", key, got, value)
+		}
+	}
+}
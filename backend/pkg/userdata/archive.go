@@ -0,0 +1,67 @@
+package userdata
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"time"
+)
+
+// Export is one source's contribution to an archive
+type Export struct {
+	Source string
+	Files  []File
+}
+
+type manifest struct {
+	UserID      string    `json:"user_id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Sources     []string  `json:"sources"`
+}
+
+// WriteArchive writes a zip archive with each source's files in a directory
+// named after it and a manifest.json describing the export
+func WriteArchive(w io.Writer, userID string, generatedAt time.Time, exports []Export) error {
+	zw := zip.NewWriter(w)
+
+	m := manifest{UserID: userID, GeneratedAt: generatedAt.UTC()}
+	for _, export := range exports {
+		m.Sources = append(m.Sources, export.Source)
+	}
+
+	manifestFile, err := JSONFile("manifest.json", m)
+	if err != nil {
+		return err
+	}
+	if err := writeZipFile(zw, manifestFile.Name, manifestFile.Data, generatedAt); err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		for _, file := range export.Files {
+			// Names come from other services; keep them inside the source's directory
+			name := path.Join(export.Source, path.Base(path.Clean("/"+file.Name)))
+			if err := writeZipFile(zw, name, file.Data, generatedAt); err != nil {
+				return err
+			}
+		}
+	}
+
+	return zw.Close()
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte, modified time.Time) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", name, err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+	return nil
+}
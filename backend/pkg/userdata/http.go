@@ -0,0 +1,114 @@
+package userdata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
+)
+
+const (
+	// UsersPath prefixes the internal endpoints every remote source serves:
+	// GET UsersPath/{id}/export returns an ExportResponse and
+	// DELETE UsersPath/{id} erases the user's data
+	UsersPath = "/internal/users/"
+	// OrgsPath prefixes the endpoint of remote organization sources:
+	// DELETE OrgsPath/{id} erases the organization's data
+	OrgsPath = "/internal/orgs/"
+
+	maxExportSize = 64 << 20
+)
+
+// ExportResponse is the body returned by the export endpoint
+type ExportResponse struct {
+	Files []File `json:"files"`
+}
+
+// HTTPSource is a Source and OrgSource served by another service's internal
+// API
+type HTTPSource struct {
+	name          string
+	baseURL       string
+	internalToken string
+	httpClient    *http.Client
+}
+
+func NewHTTPSource(name, serviceURL, internalToken string, timeout time.Duration) *HTTPSource {
+	return &HTTPSource{
+		name:          name,
+		baseURL:       strings.TrimSuffix(serviceURL, "/"),
+		internalToken: internalToken,
+		httpClient:    &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *HTTPSource) Name() string {
+	return s.name
+}
+
+func (s *HTTPSource) Export(ctx context.Context, userID string) ([]File, error) {
+	resp, err := s.do(ctx, http.MethodGet, UsersPath+url.PathEscape(userID)+"/export")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s export returned status %d", s.name, resp.StatusCode)
+	}
+
+	var body ExportResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxExportSize)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode %s export: %w", s.name, err)
+	}
+
+	return body.Files, nil
+}
+
+func (s *HTTPSource) Delete(ctx context.Context, userID string) error {
+	resp, err := s.do(ctx, http.MethodDelete, UsersPath+url.PathEscape(userID))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("%s deletion returned status %d", s.name, resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (s *HTTPSource) DeleteOrganization(ctx context.Context, orgID string) error {
+	resp, err := s.do(ctx, http.MethodDelete, OrgsPath+url.PathEscape(orgID))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("%s organization deletion returned status %d", s.name, resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (s *HTTPSource) do(ctx context.Context, method, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s request: %w", s.name, err)
+	}
+	req.Header.Set(revocation.InternalTokenHeader, s.internalToken)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", s.name, err)
+	}
+	return resp, nil
+}
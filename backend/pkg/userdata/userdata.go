@@ -0,0 +1,63 @@
+// Package userdata gathers and erases everything a user has stored across
+// services, for data export and account deletion. Each service that keeps
+// per-user data is a Source, and one that keeps data shared in organizations
+// an OrgSource; the auth service drives the others over HTTP.
+package userdata
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+)
+
+// File is one file of a data export
+type File struct {
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+}
+
+// Source is a service holding data about users
+type Source interface {
+	// Name is the directory the source's files are placed in
+	Name() string
+	// Export returns the user's data as files, typically JSON and CSV
+	Export(ctx context.Context, userID string) ([]File, error)
+	// Delete erases everything the source holds about the user. A user
+	// with no data is not an error, so deletions can be retried.
+	Delete(ctx context.Context, userID string) error
+}
+
+// OrgSource is a service holding data shared within organizations
+type OrgSource interface {
+	Name() string
+	// DeleteOrganization erases everything shared in the organization. An
+	// organization with no data is not an error, so deletions can be
+	// retried.
+	DeleteOrganization(ctx context.Context, orgID string) error
+}
+
+// JSONFile encodes v as indented JSON
+func JSONFile(name string, v interface{}) (File, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return File{}, fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	return File{Name: name, Data: data}, nil
+}
+
+// CSVFile writes a header row followed by rows
+func CSVFile(name string, header []string, rows [][]string) (File, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if err := w.Write(header); err != nil {
+		return File{}, fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return File{}, fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	return File{Name: name, Data: buf.Bytes()}, nil
+}
@@ -0,0 +1,73 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Authenticator data flags (WebAuthn section 6.1)
+const (
+	flagUserPresent    byte = 0x01
+	flagUserVerified   byte = 0x04
+	flagBackupEligible byte = 0x08
+	flagBackupState    byte = 0x10
+	flagAttestedData   byte = 0x40
+)
+
+const authDataMinLen = 37 // rpIdHash(32) + flags(1) + signCount(4)
+
+// AuthenticatorData is the parsed authenticatorData structure
+type AuthenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+
+	// Attested credential data, only present during registration
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte // COSE encoded
+}
+
+func (a *AuthenticatorData) UserPresent() bool    { return a.Flags&flagUserPresent != 0 }
+func (a *AuthenticatorData) UserVerified() bool   { return a.Flags&flagUserVerified != 0 }
+func (a *AuthenticatorData) BackupEligible() bool { return a.Flags&flagBackupEligible != 0 }
+func (a *AuthenticatorData) BackupState() bool    { return a.Flags&flagBackupState != 0 }
+
+func parseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < authDataMinLen {
+		return nil, errors.New("authenticator data too short")
+	}
+
+	ad := &AuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+
+	if ad.Flags&flagAttestedData == 0 {
+		return ad, nil
+	}
+
+	rest := data[authDataMinLen:]
+	if len(rest) < 18 {
+		return nil, errors.New("attested credential data too short")
+	}
+	ad.AAGUID = rest[:16]
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+		return nil, errors.New("invalid credential id length")
+	}
+	ad.CredentialID = rest[:idLen]
+	rest = rest[idLen:]
+
+	// The COSE key is followed by optional extension data, so its length is
+	// only known after decoding it
+	_, n, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, err
+	}
+	ad.PublicKey = rest[:n]
+
+	return ad, nil
+}
@@ -0,0 +1,184 @@
+package webauthn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// cborMap keeps map entries in the order they are encoded
+type cborMap []cborPair
+
+type cborPair struct {
+	key, value interface{}
+}
+
+// encodeCBOR is the encoding side of decodeCBOR, for building test inputs
+func encodeCBOR(v interface{}) []byte {
+	switch v := v.(type) {
+	case int:
+		return encodeCBOR(int64(v))
+	case int64:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case []interface{}:
+		out := cborHead(4, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, encodeCBOR(item)...)
+		}
+		return out
+	case cborMap:
+		out := cborHead(5, uint64(len(v)))
+		for _, pair := range v {
+			out = append(out, encodeCBOR(pair.key)...)
+			out = append(out, encodeCBOR(pair.value)...)
+		}
+		return out
+	case bool:
+		if v {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	}
+	panic("encodeCBOR: unsupported type")
+}
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+}
+
+// The valid inputs are the examples of RFC 8949 appendix A that fall within
+// the supported subset
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want interface{}
+	}{
+		{"00", int64(0)},
+		{"17", int64(23)},
+		{"1818", int64(24)},
+		{"1903e8", int64(1000)},
+		{"1a000f4240", int64(1000000)},
+		{"1b000000e8d4a51000", int64(1000000000000)},
+		{"1b7fffffffffffffff", int64(9223372036854775807)},
+		{"20", int64(-1)},
+		{"3863", int64(-100)},
+		{"3903e7", int64(-1000)},
+		{"40", []byte(nil)}, // empty byte strings decode to nil
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"60", ""},
+		{"6161", "a"},
+		{"6449455446", "IETF"},
+		{"62c3bc", "ü"},
+		{"80", []interface{}{}},
+		{"83010203", []interface{}{int64(1), int64(2), int64(3)}},
+		{"8301820203820405", []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{"a0", map[interface{}]interface{}{}},
+		{"a201020304", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{"a26161016162820203", map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{"826161a161626163", []interface{}{"a", map[interface{}]interface{}{"b": "c"}}},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"f7", nil},
+		{"fa47c35000", float64(100000)},
+		{"fb3ff199999999999a", 1.1},
+		{"c11a514b67b0", int64(1363896240)},
+		{"d74401020304", []byte{1, 2, 3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hex, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.hex)
+			got, n, err := decodeCBOR(data)
+			if err != nil {
+				t.Fatalf("decodeCBOR() error = %v", err)
+			}
+			if n != len(data) {
+				t.Errorf("decodeCBOR() consumed %d bytes, want %d", n, len(data))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCBOR() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORStopsAfterFirstItem(t *testing.T) {
+	item := encodeCBOR(cborMap{{int64(1), int64(2)}, {int64(3), []byte{0xaa}}})
+	data := append(append([]byte(nil), item...), 0xde, 0xad)
+
+	_, n, err := decodeCBOR(data)
+	if err != nil {
+		t.Fatalf("decodeCBOR() error = %v", err)
+	}
+	if n != len(item) {
+		t.Errorf("decodeCBOR() consumed %d bytes, want %d", n, len(item))
+	}
+}
+
+func TestDecodeCBORMalformed(t *testing.T) {
+	deep := append(bytes.Repeat([]byte{0x81}, maxCBORDepth+1), 0x00)
+
+	tests := []struct {
+		name    string
+		hex     string
+		wantErr string
+	}{
+		{"empty", "", "unexpected end"},
+		{"truncated argument", "1901", "unexpected end"},
+		{"truncated byte string", "440102", "unexpected end"},
+		{"truncated text string", "6449", "unexpected end"},
+		{"array longer than input", "9affffffff00", "unexpected end"},
+		{"map longer than input", "bbffffffffffffffff", "unexpected end"},
+		{"array missing items", "830102", "unexpected end"},
+		{"map missing value", "a2010203", "unexpected end"},
+		{"truncated float", "fa47c3", "unexpected end"},
+		{"unsigned overflow", "1bffffffffffffffff", "overflow"},
+		{"negative overflow", "3bffffffffffffffff", "overflow"},
+		{"indefinite byte string", "5f4101ff", "indefinite"},
+		{"indefinite array", "9f01ff", "indefinite"},
+		{"reserved additional info", "1c", "indefinite"},
+		{"half float", "f93c00", "simple value"},
+		{"unassigned simple value", "e0", "simple value"},
+		{"byte string map key", "a14001", "map key"},
+		{"array map key", "a18001", "map key"},
+		{"nesting too deep", hex.EncodeToString(deep), "too deep"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.hex)
+			got, _, err := decodeCBOR(data)
+			if err == nil {
+				t.Fatalf("decodeCBOR() = %#v, want an error", got)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("decodeCBOR() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+			if strings.Contains(tt.wantErr, "end") && !errors.Is(err, errCBORTruncated) {
+				t.Errorf("decodeCBOR() error = %v, want errCBORTruncated", err)
+			}
+		})
+	}
+}
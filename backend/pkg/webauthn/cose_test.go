@@ -0,0 +1,144 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func ec2Key(pub *ecdsa.PublicKey) []byte {
+	return encodeCBOR(cborMap{
+		{coseKeyType, coseKeyTypeEC2},
+		{coseAlgorithm, AlgES256},
+		{coseCurve, coseCurveP256},
+		{coseX, pub.X.FillBytes(make([]byte, 32))},
+		{coseY, pub.Y.FillBytes(make([]byte, 32))},
+	})
+}
+
+func okpKey(pub ed25519.PublicKey) []byte {
+	return encodeCBOR(cborMap{
+		{coseKeyType, coseKeyTypeOKP},
+		{coseAlgorithm, AlgEdDSA},
+		{coseCurve, coseCurveEd25519},
+		{coseX, []byte(pub)},
+	})
+}
+
+func rsaKey(pub *rsa.PublicKey) []byte {
+	return encodeCBOR(cborMap{
+		{coseKeyType, coseKeyTypeRSA},
+		{coseAlgorithm, AlgRS256},
+		{coseCurve, pub.N.Bytes()},
+		{coseX, big.NewInt(int64(pub.E)).Bytes()},
+	})
+}
+
+func TestParseCOSEKeyVerify(t *testing.T) {
+	message := []byte("authenticator data and client data hash")
+	digest := sha256.Sum256(message)
+
+	ecPriv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecSig, _ := ecdsa.SignASN1(rand.Reader, ecPriv, digest[:])
+
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	edSig := ed25519.Sign(edPriv, message)
+
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSig, _ := rsa.SignPKCS1v15(rand.Reader, rsaPriv, crypto.SHA256, digest[:])
+
+	tests := []struct {
+		name string
+		key  []byte
+		alg  int64
+		sig  []byte
+	}{
+		{"ES256", ec2Key(&ecPriv.PublicKey), AlgES256, ecSig},
+		{"EdDSA", okpKey(edPub), AlgEdDSA, edSig},
+		{"RS256", rsaKey(&rsaPriv.PublicKey), AlgRS256, rsaSig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseCOSEKey(tt.key)
+			if err != nil {
+				t.Fatalf("ParseCOSEKey() error = %v", err)
+			}
+			if key.Algorithm != tt.alg {
+				t.Errorf("Algorithm = %d, want %d", key.Algorithm, tt.alg)
+			}
+
+			if err := key.Verify(message, tt.sig); err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+			if err := key.Verify([]byte("something else"), tt.sig); err == nil {
+				t.Error("Verify() accepted the signature over different data")
+			}
+
+			corrupt := append([]byte(nil), tt.sig...)
+			corrupt[len(corrupt)-1] ^= 0xff
+			if err := key.Verify(message, corrupt); err == nil {
+				t.Error("Verify() accepted a corrupted signature")
+			}
+		})
+	}
+}
+
+func TestParseCOSEKeyInvalid(t *testing.T) {
+	ecPriv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	x := ecPriv.X.FillBytes(make([]byte, 32))
+	y := ecPriv.Y.FillBytes(make([]byte, 32))
+	offCurve := new(big.Int).Add(ecPriv.Y, big.NewInt(1)).FillBytes(make([]byte, 32))
+
+	ec2 := func(crv int64, x, y []byte) []byte {
+		return encodeCBOR(cborMap{
+			{coseKeyType, coseKeyTypeEC2},
+			{coseAlgorithm, AlgES256},
+			{coseCurve, crv},
+			{coseX, x},
+			{coseY, y},
+		})
+	}
+
+	tests := []struct {
+		name    string
+		key     []byte
+		wantErr string
+	}{
+		{"malformed cbor", []byte{0xa5, 0x01}, "cbor"},
+		{"not a map", encodeCBOR([]interface{}{int64(1)}), "not a map"},
+		{"empty map", encodeCBOR(cborMap{}), "unsupported"},
+		{"ec2 wrong curve", ec2(2, x, y), "invalid ec2"},
+		{"ec2 short coordinate", ec2(coseCurveP256, x[1:], y), "invalid ec2"},
+		{"ec2 missing y", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeEC2}, {coseAlgorithm, AlgES256}, {coseCurve, coseCurveP256}, {coseX, x}}), "invalid ec2"},
+		{"ec2 point off curve", ec2(coseCurveP256, x, offCurve), "not on curve"},
+		{"ec2 with eddsa", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeEC2}, {coseAlgorithm, AlgEdDSA}}), "unsupported"},
+		{"okp wrong curve", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeOKP}, {coseAlgorithm, AlgEdDSA}, {coseCurve, int64(4)}, {coseX, make([]byte, 32)}}), "invalid okp"},
+		{"okp short key", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeOKP}, {coseAlgorithm, AlgEdDSA}, {coseCurve, coseCurveEd25519}, {coseX, make([]byte, 31)}}), "invalid okp"},
+		{"rsa short modulus", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeRSA}, {coseAlgorithm, AlgRS256}, {coseCurve, make([]byte, 128)}, {coseX, []byte{1, 0, 1}}}), "invalid rsa"},
+		{"rsa long exponent", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeRSA}, {coseAlgorithm, AlgRS256}, {coseCurve, make([]byte, 256)}, {coseX, make([]byte, 5)}}), "invalid rsa"},
+		{"unknown algorithm", encodeCBOR(cborMap{{coseKeyType, coseKeyTypeEC2}, {coseAlgorithm, int64(-35)}}), "unsupported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseCOSEKey(tt.key)
+			if err == nil {
+				t.Fatalf("ParseCOSEKey() = %+v, want an error", key)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseCOSEKey() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
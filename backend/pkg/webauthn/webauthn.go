@@ -0,0 +1,431 @@
+// Package webauthn implements the relying party side of the WebAuthn
+// registration and authentication ceremonies (W3C Web Authentication
+// Level 2). Attestation statements are not verified: registrations are
+// accepted on the same terms as attestation conveyance "none".
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrVerification = errors.New("webauthn verification failed")
+
+// User verification requirements
+const (
+	VerificationRequired  = "required"
+	VerificationPreferred = "preferred"
+)
+
+// Config describes the relying party
+type Config struct {
+	RPID             string   // effective domain, e.g. "flowtime.app"
+	RPName           string   // shown by the authenticator
+	Origins          []string // allowed client origins
+	Timeout          time.Duration
+	UserVerification string
+}
+
+type RelyingParty struct {
+	cfg     Config
+	rpIDSum [32]byte
+}
+
+func New(cfg Config) *RelyingParty {
+	if cfg.UserVerification == "" {
+		cfg.UserVerification = VerificationPreferred
+	}
+	return &RelyingParty{
+		cfg:     cfg,
+		rpIDSum: sha256.Sum256([]byte(cfg.RPID)),
+	}
+}
+
+// NewChallenge returns a random base64url encoded challenge
+func NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// User is the account a credential is registered for
+type User struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+// CredentialDescriptor identifies an existing credential to the client
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+func NewCredentialDescriptor(id []byte, transports []string) CredentialDescriptor {
+	return CredentialDescriptor{
+		Type:       "public-key",
+		ID:         base64.RawURLEncoding.EncodeToString(id),
+		Transports: transports,
+	}
+}
+
+// CreationOptions is PublicKeyCredentialCreationOptions in the JSON form
+// accepted by PublicKeyCredential.parseCreationOptionsFromJSON
+type CreationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	RequireResident  bool   `json:"requireResidentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// RequestOptions is PublicKeyCredentialRequestOptions in JSON form
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// CreationOptions builds registration options asking for a discoverable
+// credential (passkey)
+func (rp *RelyingParty) CreationOptions(challenge string, user User, exclude []CredentialDescriptor) *CreationOptions {
+	opts := &CreationOptions{
+		Challenge: challenge,
+		PubKeyCredParams: []credentialParameter{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:            rp.cfg.Timeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: authenticatorSelection{
+			ResidentKey:      "required",
+			RequireResident:  true,
+			UserVerification: rp.cfg.UserVerification,
+		},
+		Attestation: "none",
+	}
+	if opts.ExcludeCredentials == nil {
+		opts.ExcludeCredentials = []CredentialDescriptor{}
+	}
+	opts.RP.ID = rp.cfg.RPID
+	opts.RP.Name = rp.cfg.RPName
+	opts.User.ID = base64.RawURLEncoding.EncodeToString(user.ID)
+	opts.User.Name = user.Name
+	opts.User.DisplayName = user.DisplayName
+	return opts
+}
+
+// RequestOptions builds authentication options. An empty allow list lets
+// the authenticator offer any discoverable credential for the RP.
+func (rp *RelyingParty) RequestOptions(challenge string, allow []CredentialDescriptor) *RequestOptions {
+	if allow == nil {
+		allow = []CredentialDescriptor{}
+	}
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.cfg.Timeout.Milliseconds(),
+		RPID:             rp.cfg.RPID,
+		AllowCredentials: allow,
+		UserVerification: rp.cfg.UserVerification,
+	}
+}
+
+// AttestationResponse is a registration PublicKeyCredential as serialized by
+// PublicKeyCredential.toJSON()
+type AttestationResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON"`
+		AttestationObject string   `json:"attestationObject"`
+		Transports        []string `json:"transports,omitempty"`
+	} `json:"response"`
+}
+
+// AssertionResponse is an authentication PublicKeyCredential as serialized
+// by PublicKeyCredential.toJSON()
+type AssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+type collectedClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// Challenge returns the challenge the client signed, so the caller can look
+// up the matching ceremony state
+func (r *AttestationResponse) Challenge() (string, error) {
+	cd, _, err := parseClientData(r.Response.ClientDataJSON)
+	if err != nil {
+		return "", err
+	}
+	return cd.Challenge, nil
+}
+
+// Challenge returns the challenge the client signed
+func (r *AssertionResponse) Challenge() (string, error) {
+	cd, _, err := parseClientData(r.Response.ClientDataJSON)
+	if err != nil {
+		return "", err
+	}
+	return cd.Challenge, nil
+}
+
+// CredentialID returns the decoded credential ID
+func (r *AssertionResponse) CredentialID() ([]byte, error) {
+	return decodeBase64URL(r.RawID)
+}
+
+// Credential is a newly registered credential
+type Credential struct {
+	ID             []byte
+	PublicKey      []byte // COSE encoded
+	SignCount      uint32
+	AAGUID         []byte
+	Transports     []string
+	UserVerified   bool
+	BackupEligible bool
+	BackupState    bool
+}
+
+// Assertion is the verified result of an authentication ceremony
+type Assertion struct {
+	SignCount    uint32
+	UserHandle   []byte
+	UserVerified bool
+	BackupState  bool
+}
+
+// VerifyRegistration validates a registration response against the
+// challenge issued for it (WebAuthn section 7.1)
+func (rp *RelyingParty) VerifyRegistration(resp *AttestationResponse, challenge string) (*Credential, error) {
+	if resp.Type != "public-key" {
+		return nil, verificationError("unexpected credential type %q", resp.Type)
+	}
+
+	if _, _, err := rp.verifyClientData(resp.Response.ClientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	rawAttestation, err := decodeBase64URL(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, verificationError("invalid attestation object encoding")
+	}
+	v, _, err := decodeCBOR(rawAttestation)
+	if err != nil {
+		return nil, verificationError("invalid attestation object: %v", err)
+	}
+	attestation, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, verificationError("attestation object is not a map")
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, verificationError("attestation object has no authData")
+	}
+
+	authData, err := rp.verifyAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.CredentialID == nil {
+		return nil, verificationError("no attested credential data")
+	}
+
+	rawID, err := decodeBase64URL(resp.RawID)
+	if err != nil || !bytes.Equal(rawID, authData.CredentialID) {
+		return nil, verificationError("credential id mismatch")
+	}
+
+	// Make sure the key is one we can use before accepting it
+	if _, err := ParseCOSEKey(authData.PublicKey); err != nil {
+		return nil, verificationError("unsupported public key: %v", err)
+	}
+
+	return &Credential{
+		ID:             authData.CredentialID,
+		PublicKey:      authData.PublicKey,
+		SignCount:      authData.SignCount,
+		AAGUID:         authData.AAGUID,
+		Transports:     resp.Response.Transports,
+		UserVerified:   authData.UserVerified(),
+		BackupEligible: authData.BackupEligible(),
+		BackupState:    authData.BackupState(),
+	}, nil
+}
+
+// VerifyAssertion validates an authentication response for a stored
+// credential (WebAuthn section 7.2). A signature counter that fails to
+// increase indicates a cloned authenticator and is rejected.
+func (rp *RelyingParty) VerifyAssertion(resp *AssertionResponse, challenge string, publicKey []byte, storedSignCount uint32) (*Assertion, error) {
+	if resp.Type != "public-key" {
+		return nil, verificationError("unexpected credential type %q", resp.Type)
+	}
+
+	_, clientDataHash, err := rp.verifyClientData(resp.Response.ClientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	rawAuthData, err := decodeBase64URL(resp.Response.AuthenticatorData)
+	if err != nil {
+		return nil, verificationError("invalid authenticator data encoding")
+	}
+	authData, err := rp.verifyAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := decodeBase64URL(resp.Response.Signature)
+	if err != nil {
+		return nil, verificationError("invalid signature encoding")
+	}
+
+	key, err := ParseCOSEKey(publicKey)
+	if err != nil {
+		return nil, verificationError("invalid stored public key: %v", err)
+	}
+
+	signed := make([]byte, 0, len(rawAuthData)+len(clientDataHash))
+	signed = append(signed, rawAuthData...)
+	signed = append(signed, clientDataHash...)
+	if err := key.Verify(signed, sig); err != nil {
+		return nil, verificationError("signature check failed")
+	}
+
+	if (authData.SignCount != 0 || storedSignCount != 0) && authData.SignCount <= storedSignCount {
+		return nil, verificationError("signature counter did not increase")
+	}
+
+	var userHandle []byte
+	if resp.Response.UserHandle != "" {
+		if userHandle, err = decodeBase64URL(resp.Response.UserHandle); err != nil {
+			return nil, verificationError("invalid user handle encoding")
+		}
+	}
+
+	return &Assertion{
+		SignCount:    authData.SignCount,
+		UserHandle:   userHandle,
+		UserVerified: authData.UserVerified(),
+		BackupState:  authData.BackupState(),
+	}, nil
+}
+
+func (rp *RelyingParty) verifyClientData(encoded, ceremony, challenge string) (*collectedClientData, []byte, error) {
+	cd, raw, err := parseClientData(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cd.Type != ceremony {
+		return nil, nil, verificationError("unexpected client data type %q", cd.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimRight(cd.Challenge, "=")), []byte(challenge)) != 1 {
+		return nil, nil, verificationError("challenge mismatch")
+	}
+	if !rp.originAllowed(cd.Origin) {
+		return nil, nil, verificationError("origin %q not allowed", cd.Origin)
+	}
+
+	sum := sha256.Sum256(raw)
+	return cd, sum[:], nil
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(raw []byte) (*AuthenticatorData, error) {
+	authData, err := parseAuthenticatorData(raw)
+	if err != nil {
+		return nil, verificationError("%v", err)
+	}
+
+	if subtle.ConstantTimeCompare(authData.RPIDHash, rp.rpIDSum[:]) != 1 {
+		return nil, verificationError("rp id hash mismatch")
+	}
+	if !authData.UserPresent() {
+		return nil, verificationError("user not present")
+	}
+	if rp.cfg.UserVerification == VerificationRequired && !authData.UserVerified() {
+		return nil, verificationError("user not verified")
+	}
+
+	return authData, nil
+}
+
+func (rp *RelyingParty) originAllowed(origin string) bool {
+	for _, allowed := range rp.cfg.Origins {
+		if origin == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func parseClientData(encoded string) (*collectedClientData, []byte, error) {
+	raw, err := decodeBase64URL(encoded)
+	if err != nil {
+		return nil, nil, verificationError("invalid client data encoding")
+	}
+
+	var cd collectedClientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return nil, nil, verificationError("invalid client data")
+	}
+
+	return &cd, raw, nil
+}
+
+// decodeBase64URL accepts base64url with or without padding, which is what
+// browsers and native platform APIs variously send
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+func verificationError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrVerification, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,367 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	testRPID      = "flowtime.app"
+	testOrigin    = "https://flowtime.app"
+	testChallenge = "3q2-7wQ5bTn1mW0sX8yZ4kLpA9cVdE6fGhJ2iKoR1uS"
+)
+
+var testAAGUID = []byte("0123456789abcdef")
+
+// testAuthenticator is a software ES256 authenticator producing the same
+// structures a browser hands the relying party, with attestation "none"
+type testAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+}
+
+func newTestAuthenticator(t *testing.T) *testAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &testAuthenticator{key: key, credentialID: id}
+}
+
+func (a *testAuthenticator) publicKey() []byte {
+	return ec2Key(&a.key.PublicKey)
+}
+
+// authData lays out authenticator data, with the attested credential when
+// attested is set
+func (a *testAuthenticator) authData(rpID string, flags byte, signCount uint32, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte(nil), rpIDHash[:]...)
+	if attested {
+		flags |= flagAttestedData
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, signCount)
+	if attested {
+		data = append(data, testAAGUID...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.publicKey()...)
+	}
+	return data
+}
+
+func (a *testAuthenticator) sign(t *testing.T, authData []byte, clientDataJSON string) string {
+	t.Helper()
+
+	raw, _ := base64.RawURLEncoding.DecodeString(clientDataJSON)
+	clientDataHash := sha256.Sum256(raw)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b64(sig)
+}
+
+func clientData(ceremony, challenge, origin string) string {
+	raw, _ := json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   challenge,
+		"origin":      origin,
+		"crossOrigin": false,
+	})
+	return b64(raw)
+}
+
+func attestationObject(authData []byte) []byte {
+	return encodeCBOR(cborMap{
+		{"fmt", "none"},
+		{"attStmt", cborMap{}},
+		{"authData", authData},
+	})
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func newTestRelyingParty(userVerification string) *RelyingParty {
+	return New(Config{
+		RPID:             testRPID,
+		RPName:           "FlowTime",
+		Origins:          []string{testOrigin},
+		UserVerification: userVerification,
+	})
+}
+
+// registration holds the inputs to one registration response; the zero
+// values of the overrides mean "as a browser would send it"
+type registration struct {
+	credType          string
+	ceremony          string
+	challenge         string
+	origin            string
+	rpID              string
+	flags             byte
+	attested          bool
+	authData          []byte
+	attestationObject []byte
+	rawID             []byte
+	userVerification  string
+}
+
+func (a *testAuthenticator) register(r registration) *AttestationResponse {
+	authData := r.authData
+	if authData == nil {
+		authData = a.authData(r.rpID, r.flags, 0, r.attested)
+	}
+	attObj := r.attestationObject
+	if attObj == nil {
+		attObj = attestationObject(authData)
+	}
+	rawID := r.rawID
+	if rawID == nil {
+		rawID = a.credentialID
+	}
+
+	resp := &AttestationResponse{ID: b64(rawID), RawID: b64(rawID), Type: r.credType}
+	resp.Response.ClientDataJSON = clientData(r.ceremony, r.challenge, r.origin)
+	resp.Response.AttestationObject = b64(attObj)
+	resp.Response.Transports = []string{"internal", "hybrid"}
+	return resp
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	a := newTestAuthenticator(t)
+
+	tests := []struct {
+		name    string
+		mutate  func(r *registration)
+		wantErr string
+	}{
+		{"valid", func(r *registration) {}, ""},
+		{"uv cleared, preferred", func(r *registration) { r.flags &^= flagUserVerified }, ""},
+		{"padded challenge", func(r *registration) { r.challenge += "=" }, ""},
+		{"wrong credential type", func(r *registration) { r.credType = "password" }, "credential type"},
+		{"assertion client data", func(r *registration) { r.ceremony = "webauthn.get" }, "client data type"},
+		{"challenge mismatch", func(r *registration) { r.challenge = "b3RoZXI" }, "challenge mismatch"},
+		{"origin not allowed", func(r *registration) { r.origin = "https://evil.example" }, "not allowed"},
+		{"malformed attestation object", func(r *registration) { r.attestationObject = []byte{0xa3, 0x63, 'f', 'm'} }, "invalid attestation object"},
+		{"attestation object not a map", func(r *registration) { r.attestationObject = encodeCBOR([]interface{}{"none"}) }, "not a map"},
+		{"no authData", func(r *registration) { r.attestationObject = encodeCBOR(cborMap{{"fmt", "none"}}) }, "no authData"},
+		{"authData too short", func(r *registration) { r.authData = a.authData(testRPID, flagUserPresent, 0, false)[:36] }, "too short"},
+		{"attested data truncated", func(r *registration) {
+			r.authData = a.authData(testRPID, flagUserPresent, 0, true)[:authDataMinLen+10]
+		}, "attested credential data too short"},
+		{"credential id truncated", func(r *registration) {
+			r.authData = a.authData(testRPID, flagUserPresent, 0, true)[:authDataMinLen+18+8]
+		}, "credential id length"},
+		{"public key truncated", func(r *registration) {
+			full := a.authData(testRPID, flagUserPresent, 0, true)
+			r.authData = full[:len(full)-5]
+		}, "unexpected end"},
+		{"wrong rp id hash", func(r *registration) { r.rpID = "evil.example" }, "rp id hash"},
+		{"up cleared", func(r *registration) { r.flags = flagUserVerified }, "user not present"},
+		{"uv cleared, required", func(r *registration) {
+			r.flags = flagUserPresent
+			r.userVerification = VerificationRequired
+		}, "user not verified"},
+		{"no attested credential", func(r *registration) { r.attested = false }, "no attested credential"},
+		{"raw id mismatch", func(r *registration) { r.rawID = []byte("another credential") }, "credential id mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := registration{
+				credType:         "public-key",
+				ceremony:         "webauthn.create",
+				challenge:        testChallenge,
+				origin:           testOrigin,
+				rpID:             testRPID,
+				flags:            flagUserPresent | flagUserVerified | flagBackupEligible,
+				attested:         true,
+				userVerification: VerificationPreferred,
+			}
+			tt.mutate(&r)
+
+			cred, err := newTestRelyingParty(r.userVerification).VerifyRegistration(a.register(r), testChallenge)
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrVerification) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("VerifyRegistration() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyRegistration() error = %v", err)
+			}
+
+			if !bytes.Equal(cred.ID, a.credentialID) || !bytes.Equal(cred.PublicKey, a.publicKey()) || !bytes.Equal(cred.AAGUID, testAAGUID) {
+				t.Errorf("VerifyRegistration() = %+v, want the authenticator's credential", cred)
+			}
+			if cred.UserVerified != (r.flags&flagUserVerified != 0) || !cred.BackupEligible || cred.BackupState {
+				t.Errorf("VerifyRegistration() flags = uv %v be %v bs %v", cred.UserVerified, cred.BackupEligible, cred.BackupState)
+			}
+			if len(cred.Transports) != 2 {
+				t.Errorf("Transports = %v", cred.Transports)
+			}
+		})
+	}
+}
+
+// assertion holds the inputs to one authentication response
+type assertion struct {
+	credType         string
+	ceremony         string
+	challenge        string
+	origin           string
+	rpID             string
+	flags            byte
+	signCount        uint32
+	authData         []byte
+	signature        string
+	corruptSignature bool
+	userHandle       string
+}
+
+func (a *testAuthenticator) assert(t *testing.T, r assertion) *AssertionResponse {
+	t.Helper()
+
+	authData := r.authData
+	if authData == nil {
+		authData = a.authData(r.rpID, r.flags, r.signCount, false)
+	}
+
+	resp := &AssertionResponse{ID: b64(a.credentialID), RawID: b64(a.credentialID), Type: r.credType}
+	resp.Response.ClientDataJSON = clientData(r.ceremony, r.challenge, r.origin)
+	resp.Response.AuthenticatorData = b64(authData)
+	resp.Response.Signature = r.signature
+	if r.signature == "" {
+		resp.Response.Signature = a.sign(t, authData, resp.Response.ClientDataJSON)
+	}
+	if r.corruptSignature {
+		// Sign a different challenge's client data, so the signature is
+		// well formed but over the wrong bytes
+		resp.Response.Signature = a.sign(t, authData, clientData(r.ceremony, "b3RoZXI", r.origin))
+	}
+	resp.Response.UserHandle = r.userHandle
+	return resp
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	a := newTestAuthenticator(t)
+	other := newTestAuthenticator(t)
+	userHandle := []byte("user-1234")
+
+	tests := []struct {
+		name             string
+		mutate           func(r *assertion)
+		storedSignCount  uint32
+		publicKey        []byte
+		userVerification string
+		wantErr          string
+	}{
+		{name: "valid", mutate: func(r *assertion) {}, storedSignCount: 4},
+		{name: "counter not in use", mutate: func(r *assertion) { r.signCount = 0 }},
+		{name: "first counter value", mutate: func(r *assertion) { r.signCount = 1 }},
+		{name: "uv cleared, preferred", mutate: func(r *assertion) { r.flags &^= flagUserVerified }, storedSignCount: 4},
+		{name: "no user handle", mutate: func(r *assertion) { r.userHandle = "" }, storedSignCount: 4},
+		{name: "counter regression", mutate: func(r *assertion) { r.signCount = 3 }, storedSignCount: 4, wantErr: "counter did not increase"},
+		{name: "counter repeated", mutate: func(r *assertion) { r.signCount = 4 }, storedSignCount: 4, wantErr: "counter did not increase"},
+		{name: "counter reset to zero", mutate: func(r *assertion) { r.signCount = 0 }, storedSignCount: 4, wantErr: "counter did not increase"},
+		{name: "wrong credential type", mutate: func(r *assertion) { r.credType = "otp" }, wantErr: "credential type"},
+		{name: "registration client data", mutate: func(r *assertion) { r.ceremony = "webauthn.create" }, wantErr: "client data type"},
+		{name: "challenge mismatch", mutate: func(r *assertion) { r.challenge = "b3RoZXI" }, wantErr: "challenge mismatch"},
+		{name: "origin not allowed", mutate: func(r *assertion) { r.origin = "http://flowtime.app" }, wantErr: "not allowed"},
+		{name: "authData too short", mutate: func(r *assertion) { r.authData = make([]byte, authDataMinLen-1) }, wantErr: "too short"},
+		{name: "wrong rp id hash", mutate: func(r *assertion) { r.rpID = "flowtime.app.evil.example" }, wantErr: "rp id hash"},
+		{name: "up cleared", mutate: func(r *assertion) { r.flags = flagUserVerified }, wantErr: "user not present"},
+		{name: "uv cleared, required", mutate: func(r *assertion) { r.flags &^= flagUserVerified }, userVerification: VerificationRequired, wantErr: "user not verified"},
+		{name: "signature over other data", mutate: func(r *assertion) { r.corruptSignature = true }, wantErr: "signature check failed"},
+		{name: "malformed signature", mutate: func(r *assertion) { r.signature = b64([]byte{0x30, 0x02, 0x01}) }, wantErr: "signature check failed"},
+		{name: "signature encoding", mutate: func(r *assertion) { r.signature = "not base64!" }, wantErr: "signature encoding"},
+		{name: "another credential's key", mutate: func(r *assertion) {}, publicKey: other.publicKey(), wantErr: "signature check failed"},
+		{name: "invalid stored key", mutate: func(r *assertion) {}, publicKey: []byte{0xa0}, wantErr: "invalid stored public key"},
+		{name: "user handle encoding", mutate: func(r *assertion) { r.userHandle = "%%%" }, wantErr: "user handle"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := assertion{
+				credType:   "public-key",
+				ceremony:   "webauthn.get",
+				challenge:  testChallenge,
+				origin:     testOrigin,
+				rpID:       testRPID,
+				flags:      flagUserPresent | flagUserVerified | flagBackupEligible | flagBackupState,
+				signCount:  5,
+				userHandle: b64(userHandle),
+			}
+			tt.mutate(&r)
+			publicKey := tt.publicKey
+			if publicKey == nil {
+				publicKey = a.publicKey()
+			}
+			userVerification := tt.userVerification
+			if userVerification == "" {
+				userVerification = VerificationPreferred
+			}
+
+			got, err := newTestRelyingParty(userVerification).VerifyAssertion(a.assert(t, r), testChallenge, publicKey, tt.storedSignCount)
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrVerification) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("VerifyAssertion() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyAssertion() error = %v", err)
+			}
+
+			if got.SignCount != r.signCount {
+				t.Errorf("SignCount = %d, want %d", got.SignCount, r.signCount)
+			}
+			if r.userHandle != "" && !bytes.Equal(got.UserHandle, userHandle) {
+				t.Errorf("UserHandle = %q, want %q", got.UserHandle, userHandle)
+			}
+			if got.UserVerified != (r.flags&flagUserVerified != 0) || !got.BackupState {
+				t.Errorf("VerifyAssertion() flags = uv %v bs %v", got.UserVerified, got.BackupState)
+			}
+		})
+	}
+}
+
+func TestResponseChallenge(t *testing.T) {
+	a := newTestAuthenticator(t)
+
+	reg := a.register(registration{credType: "public-key", ceremony: "webauthn.create", challenge: testChallenge, origin: testOrigin, rpID: testRPID, flags: flagUserPresent, attested: true})
+	if got, err := reg.Challenge(); err != nil || got != testChallenge {
+		t.Errorf("AttestationResponse.Challenge() = %q, %v", got, err)
+	}
+
+	assertion := a.assert(t, assertion{credType: "public-key", ceremony: "webauthn.get", challenge: testChallenge, origin: testOrigin, rpID: testRPID, flags: flagUserPresent})
+	if got, err := assertion.Challenge(); err != nil || got != testChallenge {
+		t.Errorf("AssertionResponse.Challenge() = %q, %v", got, err)
+	}
+	if id, err := assertion.CredentialID(); err != nil || !bytes.Equal(id, a.credentialID) {
+		t.Errorf("CredentialID() = %x, %v", id, err)
+	}
+
+	assertion.Response.ClientDataJSON = b64([]byte("{not json"))
+	if _, err := assertion.Challenge(); !errors.Is(err, ErrVerification) {
+		t.Errorf("Challenge() of malformed client data error = %v, want ErrVerification", err)
+	}
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
+)
+
+type AccountHandler struct {
+	accountService services.AccountService
+	log            logger.Logger
+	validator      *validator.Validate
+}
+
+func NewAccountHandler(accountService services.AccountService, log logger.Logger) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+		log:            log,
+		validator:      validator.New(),
+	}
+}
+
+// GetProfile returns the signed-in user
+func (h *AccountHandler) GetProfile(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	profile, err := h.accountService.GetProfile(ctx, c.GetString("userID"))
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		log.WithError(err).Error("Failed to get profile")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// UpdateProfile changes the user's name and photo
+func (h *AccountHandler) UpdateProfile(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid update profile request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Update profile validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	profile, err := h.accountService.UpdateProfile(ctx, c.GetString("userID"), req)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		log.WithError(err).Error("Failed to update profile")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// ChangePassword replaces the password after checking the current one and
+// signs out the user's other devices
+func (h *AccountHandler) ChangePassword(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid change password request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Change password validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	response, err := h.accountService.ChangePassword(ctx, c.GetString("userID"), c.GetString("sessionID"), req)
+	if err != nil {
+		if h.respondReauthError(c, err) || respondPasswordPolicyError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to change password")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RequestEmailChange sends a confirmation link to the new address
+func (h *AccountHandler) RequestEmailChange(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid change email request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Change email validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	if err := h.accountService.RequestEmailChange(ctx, c.GetString("userID"), c.GetString("sessionID"), req); err != nil {
+		if h.respondReauthError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrEmailUnchanged):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "New email matches the current one"})
+		case errors.Is(err, services.ErrEmailInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+		default:
+			log.WithError(err).Error("Failed to request email change")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request email change"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Check your new email address for a confirmation link"})
+}
+
+// ConfirmEmailChange completes an email change from the emailed link
+func (h *AccountHandler) ConfirmEmailChange(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	token := c.Param("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Confirmation token required"})
+		return
+	}
+
+	if err := h.accountService.ConfirmEmailChange(ctx, token); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidEmailChangeToken):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation token"})
+		case errors.Is(err, services.ErrEmailInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+		default:
+			log.WithError(err).Error("Failed to confirm email change")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm email change"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email address changed"})
+}
+
+// DeleteAccount schedules the account for deletion after the grace period
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	// The body may be omitted by accounts without a password
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		log.WithError(err).Warn("Invalid delete account request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	deleteAt, err := h.accountService.ScheduleDeletion(ctx, c.GetString("userID"), c.GetString("sessionID"), req)
+	if err != nil {
+		if h.respondReauthError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to schedule account deletion")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.AccountDeletionResponse{DeletionScheduledAt: deleteAt})
+}
+
+// CancelDeletion keeps an account that was scheduled for deletion
+func (h *AccountHandler) CancelDeletion(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	if err := h.accountService.CancelDeletion(ctx, c.GetString("userID")); err != nil {
+		if errors.Is(err, services.ErrDeletionNotScheduled) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Account is not scheduled for deletion"})
+			return
+		}
+		log.WithError(err).Error("Failed to cancel account deletion")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel account deletion"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled"})
+}
+
+// ExportData downloads a zip archive of all the user's data
+func (h *AccountHandler) ExportData(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	// Build the whole archive first so a failure can still be reported
+	var buf bytes.Buffer
+	if err := h.accountService.ExportData(ctx, c.GetString("userID"), &buf); err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		log.WithError(err).Error("Failed to export account data")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account data"})
+		return
+	}
+
+	filename := fmt.Sprintf("flowtime-export-%s.zip", time.Now().UTC().Format("2006-01-02"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// respondReauthError writes the response for a failed current-password
+// check and reports whether it did
+func (h *AccountHandler) respondReauthError(c *gin.Context, err error) bool {
+	var throttled *services.LoginThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "Too many failed attempts. Try again later.",
+			"retry_after": retryAfter,
+		})
+	case errors.Is(err, services.ErrIncorrectPassword):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+	case errors.Is(err, services.ErrRecentSignInRequired):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Sign in again to confirm this change", "code": "recent_sign_in_required"})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		return false
+	}
+	return true
+}
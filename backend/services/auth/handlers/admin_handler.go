@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
+)
+
+type AdminHandler struct {
+	adminService services.AdminService
+	log          logger.Logger
+	validator    *validator.Validate
+}
+
+func NewAdminHandler(adminService services.AdminService, log logger.Logger) *AdminHandler {
+	return &AdminHandler{
+		adminService: adminService,
+		log:          log,
+		validator:    validator.New(),
+	}
+}
+
+// ListUsers searches users by email or name, role and active flag
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	filter := models.UserFilter{
+		Query: c.Query("q"),
+		Role:  c.Query("role"),
+	}
+	if activeStr := c.Query("active"); activeStr != "" {
+		active, err := strconv.ParseBool(activeStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid active filter"})
+			return
+		}
+		filter.Active = &active
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			filter.Limit = l
+		}
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o > 0 {
+			filter.Offset = o
+		}
+	}
+
+	result, err := h.adminService.SearchUsers(ctx, adminActor(c), filter)
+	if err != nil {
+		log.WithError(err).Error("Failed to search users")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetUser returns one user with their active sessions
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	detail, err := h.adminService.GetUser(ctx, adminActor(c), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		log.WithError(err).Error("Failed to get user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// UnlockUser lifts a sign-in lockout on an account
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	userID := c.Param("id")
+
+	if err := h.adminService.UnlockUser(ctx, adminActor(c), userID); err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		log.WithError(err).Error("Failed to unlock user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
+
+// DeactivateUser disables an account and signs it out everywhere
+func (h *AdminHandler) DeactivateUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	if err := h.adminService.DeactivateUser(ctx, adminActor(c), c.Param("id")); err != nil {
+		if respondAdminError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to deactivate user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deactivated"})
+}
+
+// ActivateUser re-enables a deactivated account
+func (h *AdminHandler) ActivateUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	if err := h.adminService.ActivateUser(ctx, adminActor(c), c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		log.WithError(err).Error("Failed to activate user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User activated"})
+}
+
+// ForcePasswordReset signs the user out and makes them choose a new password
+func (h *AdminHandler) ForcePasswordReset(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	if err := h.adminService.ForcePasswordReset(ctx, adminActor(c), c.Param("id")); err != nil {
+		if respondAdminError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to force password reset")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to force password reset"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset required"})
+}
+
+// RevokeSessions signs the user out of every device
+func (h *AdminHandler) RevokeSessions(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	if err := h.adminService.RevokeSessions(ctx, adminActor(c), c.Param("id")); err != nil {
+		if respondAdminError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to revoke sessions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions revoked"})
+}
+
+// SetRoles replaces a user's roles
+func (h *AdminHandler) SetRoles(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.SetRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid set roles request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Set roles validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	roles, err := h.adminService.SetRoles(ctx, adminActor(c), c.Param("id"), req.Roles)
+	if err != nil {
+		if respondAdminError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to set roles")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// ListAudit returns the admin audit trail, newest first
+func (h *AdminHandler) ListAudit(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	filter := models.AuditFilter{
+		ActorID:      c.Query("actor_id"),
+		TargetUserID: c.Query("target_user_id"),
+		Action:       c.Query("action"),
+	}
+	if beforeStr := c.Query("before"); beforeStr != "" {
+		before, err := time.Parse(time.RFC3339, beforeStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before timestamp. Use RFC 3339"})
+			return
+		}
+		filter.Before = &before
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			filter.Limit = l
+		}
+	}
+
+	entries, err := h.adminService.ListAuditEntries(ctx, filter)
+	if err != nil {
+		log.WithError(err).Error("Failed to list audit entries")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list audit entries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// adminActor identifies the signed-in staff member for the audit trail
+func adminActor(c *gin.Context) models.AdminActor {
+	return models.AdminActor{
+		UserID: c.GetString("userID"),
+		Email:  c.GetString("userEmail"),
+		Roles:  c.GetStringSlice("roles"),
+	}
+}
+
+// respondAdminError writes the response for errors shared by admin actions
+// on a single user. It reports whether it handled err.
+func respondAdminError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, services.ErrCannotModifySelf):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTargetOutranksActor):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
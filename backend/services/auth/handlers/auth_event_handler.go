@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
+)
+
+type AuthEventHandler struct {
+	authEventService services.AuthEventService
+	log              logger.Logger
+}
+
+func NewAuthEventHandler(authEventService services.AuthEventService, log logger.Logger) *AuthEventHandler {
+	return &AuthEventHandler{
+		authEventService: authEventService,
+		log:              log,
+	}
+}
+
+// ListMyActivity returns the signed-in user's recent auth events
+func (h *AuthEventHandler) ListMyActivity(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	before, ok := parseTimeQuery(c, "before")
+	if !ok {
+		return
+	}
+
+	events, err := h.authEventService.ListForUser(ctx, c.GetString("userID"), before, parseLimitQuery(c))
+	if err != nil {
+		log.WithError(err).Error("Failed to list account activity")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list activity"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// ListEvents searches every user's auth events for staff
+func (h *AuthEventHandler) ListEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	filter := models.AuthEventFilter{
+		UserID:    c.Query("user_id"),
+		Email:     c.Query("email"),
+		Type:      c.Query("event_type"),
+		Outcome:   c.Query("outcome"),
+		IPAddress: c.Query("ip_address"),
+		Limit:     parseLimitQuery(c),
+	}
+
+	var ok bool
+	if filter.Since, ok = parseTimeQuery(c, "since"); !ok {
+		return
+	}
+	if filter.Before, ok = parseTimeQuery(c, "before"); !ok {
+		return
+	}
+
+	events, err := h.authEventService.Search(ctx, filter)
+	if err != nil {
+		log.WithError(err).Error("Failed to list auth events")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list auth events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// parseTimeQuery reads an optional RFC 3339 query parameter. It responds
+// with 400 and reports false when the value doesn't parse.
+func parseTimeQuery(c *gin.Context, name string) (*time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + " timestamp. Use RFC 3339"})
+		return nil, false
+	}
+	return &t, true
+}
+
+// parseLimitQuery reads the limit query parameter, returning 0 for the
+// service default when it is missing or invalid
+func parseLimitQuery(c *gin.Context) int {
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			return l
+		}
+	}
+	return 0
+}
@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
 	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
 	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
 )
@@ -49,6 +53,10 @@ func (h *AuthHandler) SignUp(c *gin.Context) {
 	// Create user
 	response, err := h.authService.SignUp(ctx, req)
 	if err != nil {
+		if respondPasswordPolicyError(c, err) {
+			log.WithField("email", req.Email).Info("Signup password rejected by policy")
+			return
+		}
 		if strings.Contains(err.Error(), "already exists") {
 			log.WithField("email", req.Email).Warn("User already exists")
 			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
@@ -91,11 +99,38 @@ func (h *AuthHandler) SignIn(c *gin.Context) {
 	// Authenticate user
 	response, err := h.authService.SignIn(ctx, req)
 	if err != nil {
+		if respondMFAChallenge(c, err) {
+			log.WithField("email", req.Email).Info("Signin requires second factor")
+			return
+		}
+		var throttled *services.LoginThrottledError
+		if errors.As(err, &throttled) {
+			retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Too many failed sign-in attempts. Try again later or reset your password.",
+				"retry_after": retryAfter,
+			})
+			return
+		}
 		if strings.Contains(err.Error(), "invalid credentials") {
 			log.WithField("email", req.Email).Warn("Signin failed: invalid credentials")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 			return
 		}
+		if strings.Contains(err.Error(), "email not verified") {
+			log.WithField("email", req.Email).Warn("Signin failed: email not verified")
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email address not verified"})
+			return
+		}
+		if strings.Contains(err.Error(), "account is inactive") {
+			log.WithField("email", req.Email).Warn("Signin failed: account is inactive")
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is inactive"})
+			return
+		}
+		if respondPasswordResetRequired(c, err) {
+			return
+		}
 		log.WithError(err).Error("Signin failed")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Authentication failed"})
 		return
@@ -109,6 +144,103 @@ func (h *AuthHandler) SignIn(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// SignInMFA completes a sign-in that was challenged for a second factor
+func (h *AuthHandler) SignInMFA(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.MFASignInRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid 2fa signin request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("2FA signin validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	log.Info("Processing 2fa signin request")
+
+	response, err := h.authService.CompleteMFASignIn(ctx, req)
+	if err != nil {
+		if respondPasswordResetRequired(c, err) {
+			return
+		}
+		var throttled *services.LoginThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Too many failed sign-in attempts. Try again later or reset your password.",
+				"retry_after": retryAfter,
+			})
+		case errors.Is(err, services.ErrInvalidMFACode):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		case strings.Contains(err.Error(), "invalid challenge token"):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge token"})
+		case strings.Contains(err.Error(), "account is inactive"):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is inactive"})
+		default:
+			log.WithError(err).Error("2FA signin failed")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Authentication failed"})
+		}
+		return
+	}
+
+	log.WithField("user_id", response.User.ID).Info("User successfully authenticated with second factor")
+	c.JSON(http.StatusOK, response)
+}
+
+// respondMFAChallenge writes the challenge response if err asks for a second
+// factor, reporting whether it did
+func respondMFAChallenge(c *gin.Context, err error) bool {
+	var mfaErr *services.MFARequiredError
+	if !errors.As(err, &mfaErr) {
+		return false
+	}
+
+	c.JSON(http.StatusOK, models.MFAChallengeResponse{
+		MFARequired:    true,
+		ChallengeToken: mfaErr.ChallengeToken,
+		ExpiresIn:      int(mfaErr.ExpiresIn.Seconds()),
+	})
+	return true
+}
+
+// respondPasswordResetRequired tells the client to reset the password if an
+// admin has forced a reset, reporting whether it did
+func respondPasswordResetRequired(c *gin.Context, err error) bool {
+	if !errors.Is(err, services.ErrPasswordResetRequired) {
+		return false
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "Password reset required",
+		"code":  "password_reset_required",
+	})
+	return true
+}
+
+// respondPasswordPolicyError lists the password rules a new password broke,
+// reporting whether err was a policy error
+func respondPasswordPolicyError(c *gin.Context, err error) bool {
+	var policyErr *services.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":      "Password does not meet the requirements",
+		"violations": policyErr.Violations,
+	})
+	return true
+}
+
 // RefreshToken handles token refresh
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -134,7 +266,14 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	response, err := h.authService.RefreshToken(ctx, req.RefreshToken)
 	if err != nil {
 		log.WithError(err).Warn("Token refresh failed")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		switch {
+		case strings.Contains(err.Error(), "email not verified"):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email address not verified"})
+		case strings.Contains(err.Error(), "account is inactive"):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is inactive"})
+		default:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		}
 		return
 	}
 
@@ -161,8 +300,12 @@ func (h *AuthHandler) SignOut(c *gin.Context) {
 
 	log.WithField("user_id", userID).Info("Processing signout request")
 
+	// The access token used for this request, so it can be revoked too
+	accessToken, _ := c.Get("accessToken")
+	currentToken, _ := accessToken.(revocation.Token)
+
 	// Sign out user
-	if err := h.authService.SignOut(ctx, userID.(string), req.RefreshToken); err != nil {
+	if err := h.authService.SignOut(ctx, userID.(string), req.RefreshToken, currentToken); err != nil {
 		log.WithError(err).Warn("Signout failed")
 		// Continue anyway - user should be signed out
 	}
@@ -195,6 +338,36 @@ func (h *AuthHandler) VerifyEmail(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
 }
 
+// ResendVerification handles requests for a new email verification link
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid resend verification request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Resend verification validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	log.WithField("email", req.Email).Info("Processing resend verification request")
+
+	if err := h.authService.ResendVerificationEmail(ctx, req.Email); err != nil {
+		log.WithError(err).Error("Resend verification failed")
+		// Don't reveal if email exists or not
+	}
+
+	// Always return success to prevent email enumeration
+	c.JSON(http.StatusOK, gin.H{"message": "If the email exists and is unverified, a verification link has been sent"})
+}
+
 // RequestPasswordReset handles password reset requests
 func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -254,6 +427,10 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 	log.Info("Processing password reset")
 
 	if err := h.authService.ResetPassword(ctx, token, req.NewPassword); err != nil {
+		if respondPasswordPolicyError(c, err) {
+			log.Info("Reset password rejected by policy")
+			return
+		}
 		log.WithError(err).Warn("Password reset failed")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
 		return
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/pat"
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
+)
+
+// InternalHandler serves endpoints called by the gateway and other services
+type InternalHandler struct {
+	revocations revocation.Checker
+	tokens      pat.Verifier
+	log         logger.Logger
+}
+
+func NewInternalHandler(revocations revocation.Checker, tokens pat.Verifier, log logger.Logger) *InternalHandler {
+	return &InternalHandler{
+		revocations: revocations,
+		tokens:      tokens,
+		log:         log,
+	}
+}
+
+// CheckRevocation reports whether an access token has been revoked. The
+// token is identified by its jti, user_id, iat (unix seconds) and, for
+// tokens issued to a session, sid.
+func (h *InternalHandler) CheckRevocation(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	userID := c.Query("user_id")
+	issuedAt, err := strconv.ParseInt(c.Query("iat"), 10, 64)
+	if userID == "" || err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id and iat are required"})
+		return
+	}
+
+	token := revocation.Token{
+		ID:        c.Query("jti"),
+		UserID:    userID,
+		SessionID: c.Query("sid"),
+		IssuedAt:  time.Unix(issuedAt, 0),
+	}
+
+	revoked, err := h.revocations.IsRevoked(ctx, token)
+	if err != nil {
+		log.WithError(err).Error("Failed to check token revocation")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check token revocation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, revocation.CheckResponse{Revoked: revoked})
+}
+
+// VerifyPersonalAccessToken resolves a personal access token to its owner,
+// answering 404 if it can't be used
+func (h *InternalHandler) VerifyPersonalAccessToken(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req pat.VerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	principal, err := h.tokens.Verify(ctx, req.Token)
+	if err != nil {
+		if errors.Is(err, pat.ErrInvalidToken) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+			return
+		}
+		log.WithError(err).Error("Failed to verify personal access token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify personal access token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, principal)
+}
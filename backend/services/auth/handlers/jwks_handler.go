@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mdnaeem95/lifesync/backend/pkg/jwks"
+)
+
+type JWKSHandler struct {
+	keyRing *jwks.KeyRing
+}
+
+// NewJWKSHandler publishes the public half of keyRing. keyRing may be nil
+// when tokens are signed with a shared secret, in which case the set is empty.
+func NewJWKSHandler(keyRing *jwks.KeyRing) *JWKSHandler {
+	return &JWKSHandler{keyRing: keyRing}
+}
+
+// GetKeySet serves the JSON Web Key Set used to verify issued tokens
+func (h *JWKSHandler) GetKeySet(c *gin.Context) {
+	set := &jwks.Set{Keys: []jwks.JWK{}}
+	if h.keyRing != nil {
+		set = h.keyRing.PublicSet()
+	}
+
+	// Verifiers refetch on unknown key IDs, so a short cache is safe
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, set)
+}
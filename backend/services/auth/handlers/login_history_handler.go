@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
+)
+
+type LoginHistoryHandler struct {
+	loginHistoryService services.LoginHistoryService
+	log                 logger.Logger
+}
+
+func NewLoginHistoryHandler(loginHistoryService services.LoginHistoryService, log logger.Logger) *LoginHistoryHandler {
+	return &LoginHistoryHandler{
+		loginHistoryService: loginHistoryService,
+		log:                 log,
+	}
+}
+
+// ListLogins returns the signed-in user's sign-ins, newest first
+func (h *LoginHistoryHandler) ListLogins(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	before, ok := parseTimeQuery(c, "before")
+	if !ok {
+		return
+	}
+
+	logins, err := h.loginHistoryService.List(ctx, c.GetString("userID"), before, parseLimitQuery(c))
+	if err != nil {
+		log.WithError(err).Error("Failed to list login history")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list login history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"logins": logins})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
+)
+
+type MagicLinkHandler struct {
+	magicLinkService services.MagicLinkService
+	log              logger.Logger
+	validator        *validator.Validate
+}
+
+func NewMagicLinkHandler(magicLinkService services.MagicLinkService, log logger.Logger) *MagicLinkHandler {
+	return &MagicLinkHandler{
+		magicLinkService: magicLinkService,
+		log:              log,
+		validator:        validator.New(),
+	}
+}
+
+// RequestLink emails a sign-in link and returns the device token the link
+// must be redeemed with
+func (h *MagicLinkHandler) RequestLink(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid magic link request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Magic link validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	log.WithField("email", req.Email).Info("Processing magic link request")
+
+	response, err := h.magicLinkService.RequestLink(ctx, req.Email)
+	if err != nil {
+		log.WithError(err).Error("Magic link request failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send sign-in link"})
+		return
+	}
+
+	// The same response whether or not the email exists
+	c.JSON(http.StatusOK, response)
+}
+
+// VerifyLink signs the user in with a magic link token
+func (h *MagicLinkHandler) VerifyLink(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.MagicLinkVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid magic link verify request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Magic link verify validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	response, err := h.magicLinkService.SignIn(ctx, req)
+	if err != nil {
+		if respondMFAChallenge(c, err) {
+			log.Info("Magic link signin requires second factor")
+			return
+		}
+		if respondPasswordResetRequired(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrInvalidMagicLink):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign-in link"})
+		case strings.Contains(err.Error(), "account is inactive"):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is inactive"})
+		default:
+			log.WithError(err).Error("Magic link signin failed")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Authentication failed"})
+		}
+		return
+	}
+
+	log.WithField("user_id", response.User.ID).Info("User successfully authenticated with magic link")
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
+)
+
+type MFAHandler struct {
+	mfaService services.MFAService
+	log        logger.Logger
+	validator  *validator.Validate
+}
+
+func NewMFAHandler(mfaService services.MFAService, log logger.Logger) *MFAHandler {
+	return &MFAHandler{
+		mfaService: mfaService,
+		log:        log,
+		validator:  validator.New(),
+	}
+}
+
+// Status reports whether two-factor authentication is enabled
+func (h *MFAHandler) Status(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	userID := c.GetString("userID")
+
+	status, err := h.mfaService.Status(ctx, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to get 2fa status")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get two-factor status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Setup starts TOTP enrollment and returns the secret for the authenticator app
+func (h *MFAHandler) Setup(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	userID := c.GetString("userID")
+	log.WithField("user_id", userID).Info("Processing 2fa setup request")
+
+	response, err := h.mfaService.BeginEnrollment(ctx, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrMFAAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		}
+		log.WithError(err).Error("2FA setup failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor setup"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Enable confirms enrollment with a first code and returns recovery codes
+func (h *MFAHandler) Enable(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.MFACodeRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	userID := c.GetString("userID")
+	log.WithField("user_id", userID).Info("Processing 2fa enable request")
+
+	codes, err := h.mfaService.ConfirmEnrollment(ctx, userID, req.Code)
+	if err != nil {
+		h.respondError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable turns off two-factor authentication after re-authentication
+func (h *MFAHandler) Disable(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.MFADisableRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	userID := c.GetString("userID")
+	log.WithField("user_id", userID).Info("Processing 2fa disable request")
+
+	if err := h.mfaService.Disable(ctx, userID, req.Password, req.Code); err != nil {
+		h.respondError(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.MFACodeRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	userID := c.GetString("userID")
+	log.WithField("user_id", userID).Info("Processing recovery code regeneration")
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(ctx, userID, req.Code)
+	if err != nil {
+		h.respondError(c, err, "Failed to regenerate recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *MFAHandler) bind(c *gin.Context, req interface{}) bool {
+	log := h.log.WithContext(c.Request.Context())
+
+	if err := c.ShouldBindJSON(req); err != nil {
+		log.WithError(err).Warn("Invalid 2fa request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return false
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("2FA request validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return false
+	}
+
+	return true
+}
+
+func (h *MFAHandler) respondError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidMFACode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+	case errors.Is(err, services.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+	case errors.Is(err, services.ErrMFANotEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+	case strings.Contains(err.Error(), "invalid credentials"):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+	default:
+		h.log.WithContext(c.Request.Context()).WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
+)
+
+type OAuthHandler struct {
+	oauthService services.OAuthService
+	log          logger.Logger
+	validator    *validator.Validate
+}
+
+func NewOAuthHandler(oauthService services.OAuthService, log logger.Logger) *OAuthHandler {
+	return &OAuthHandler{
+		oauthService: oauthService,
+		log:          log,
+		validator:    validator.New(),
+	}
+}
+
+// RegisterClient registers a third-party app. A confidential client's
+// secret is only returned here.
+func (h *OAuthHandler) RegisterClient(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid register client request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Register client validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	response, err := h.oauthService.RegisterClient(ctx, c.GetString("userID"), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRedirectURI) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid redirect URI", "details": err.Error()})
+			return
+		}
+		log.WithError(err).Error("Failed to register oauth client")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register client"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListClients returns every registered app
+func (h *OAuthHandler) ListClients(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	clients, err := h.oauthService.ListClients(ctx)
+	if err != nil {
+		log.WithError(err).Error("Failed to list oauth clients")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list clients"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"clients": clients})
+}
+
+// DeleteClient removes an app, cutting off every user's access from it
+func (h *OAuthHandler) DeleteClient(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	if err := h.oauthService.DeleteClient(ctx, c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrOAuthClientNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+			return
+		}
+		log.WithError(err).Error("Failed to delete oauth client")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete client"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Client deleted"})
+}
+
+// GetAuthorization checks the authorization request the consent page was
+// opened with and describes it for the page to show
+func (h *OAuthHandler) GetAuthorization(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.OAuthAuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.OAuthInvalidRequest, "error_description": "Malformed authorization request"})
+		return
+	}
+
+	consent, err := h.oauthService.PrepareAuthorization(ctx, c.GetString("userID"), req)
+	if err != nil {
+		if respondOAuthError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to prepare authorization")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check authorization request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, consent)
+}
+
+// Authorize takes the user's answer from the consent page and returns the
+// URL to send the browser back to the app with
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.OAuthConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid authorize request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	redirect, err := h.oauthService.Authorize(ctx, c.GetString("userID"), req)
+	if err != nil {
+		if respondOAuthError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to authorize app")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to authorize app"})
+		return
+	}
+
+	c.JSON(http.StatusOK, redirect)
+}
+
+// Token is the token endpoint. Requests are form encoded, as RFC 6749
+// requires.
+func (h *OAuthHandler) Token(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.OAuthTokenRequest
+	if err := c.ShouldBindWith(&req, binding.Form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.OAuthInvalidRequest, "error_description": "Malformed token request"})
+		return
+	}
+	req.ClientID, req.ClientSecret = clientCredentials(c, req.ClientID, req.ClientSecret)
+
+	response, err := h.oauthService.Token(ctx, req)
+	if err != nil {
+		if respondOAuthError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Token request failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, response)
+}
+
+// Introspect describes a token to the client it was issued to (RFC 7662)
+func (h *OAuthHandler) Introspect(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	req, ok := bindTokenActionRequest(c)
+	if !ok {
+		return
+	}
+
+	introspection, err := h.oauthService.Introspect(ctx, req)
+	if err != nil {
+		if respondOAuthError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Token introspection failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, introspection)
+}
+
+// Revoke revokes a token issued to the calling client (RFC 7009). Unknown
+// tokens get the same empty 200 as revoked ones.
+func (h *OAuthHandler) Revoke(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	req, ok := bindTokenActionRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := h.oauthService.Revoke(ctx, req); err != nil {
+		if respondOAuthError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Token revocation failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// ListApps returns the apps the signed-in user has authorized
+func (h *OAuthHandler) ListApps(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	apps, err := h.oauthService.ListAuthorizedApps(ctx, c.GetString("userID"))
+	if err != nil {
+		log.WithError(err).Error("Failed to list authorized apps")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list apps"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"apps": apps})
+}
+
+// RevokeApp removes an app's access to the signed-in user's account
+func (h *OAuthHandler) RevokeApp(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	if err := h.oauthService.RevokeApp(ctx, c.GetString("userID"), c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrOAuthAppNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+			return
+		}
+		log.WithError(err).Error("Failed to revoke app")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke app"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "App access revoked"})
+}
+
+func bindTokenActionRequest(c *gin.Context) (models.OAuthTokenActionRequest, bool) {
+	var req models.OAuthTokenActionRequest
+	if err := c.ShouldBindWith(&req, binding.Form); err != nil || req.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.OAuthInvalidRequest, "error_description": "token is required"})
+		return req, false
+	}
+	req.ClientID, req.ClientSecret = clientCredentials(c, req.ClientID, req.ClientSecret)
+	return req, true
+}
+
+// clientCredentials prefers HTTP Basic authentication over credentials in
+// the form body. RFC 6749 has clients form encode both parts before
+// building the Basic header.
+func clientCredentials(c *gin.Context, formID, formSecret string) (string, string) {
+	id, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		return formID, formSecret
+	}
+	if unescaped, err := url.QueryUnescape(id); err == nil {
+		id = unescaped
+	}
+	if unescaped, err := url.QueryUnescape(secret); err == nil {
+		secret = unescaped
+	}
+	return id, secret
+}
+
+// respondOAuthError writes an OAuth2 error response if err is one,
+// reporting whether it did. Authorization errors that can go back to the
+// app include the redirect_uri to send the browser to.
+func respondOAuthError(c *gin.Context, err error) bool {
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		return false
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == services.OAuthInvalidClient {
+		status = http.StatusUnauthorized
+		if _, _, ok := c.Request.BasicAuth(); ok {
+			c.Header("WWW-Authenticate", `Basic realm="flowtime"`)
+		}
+	}
+
+	body := gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description}
+	if oauthErr.RedirectURI != "" {
+		body["redirect_uri"] = oauthErr.RedirectURI
+	}
+	c.JSON(status, body)
+	return true
+}
@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
+)
+
+type OrganizationHandler struct {
+	orgService services.OrganizationService
+	log        logger.Logger
+	validator  *validator.Validate
+}
+
+func NewOrganizationHandler(orgService services.OrganizationService, log logger.Logger) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgService: orgService,
+		log:        log,
+		validator:  validator.New(),
+	}
+}
+
+// CreateOrganization creates an organization owned by the caller
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid create organization request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Create organization validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	org, err := h.orgService.Create(ctx, c.GetString("userID"), req)
+	if err != nil {
+		log.WithError(err).Error("Failed to create organization")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// ListOrganizations returns the organizations the caller belongs to, with
+// their role in each
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	orgs, err := h.orgService.List(ctx, c.GetString("userID"))
+	if err != nil {
+		log.WithError(err).Error("Failed to list organizations")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list organizations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organizations": orgs})
+}
+
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	org, err := h.orgService.Get(ctx, c.GetString("userID"), c.Param("id"))
+	if err != nil {
+		if respondOrganizationError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to get organization")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+func (h *OrganizationHandler) RenameOrganization(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid rename organization request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Rename organization validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	org, err := h.orgService.Rename(ctx, c.GetString("userID"), c.Param("id"), req)
+	if err != nil {
+		if respondOrganizationError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to rename organization")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// DeleteOrganization deletes the organization along with its shared tasks
+func (h *OrganizationHandler) DeleteOrganization(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	if err := h.orgService.Delete(ctx, c.GetString("userID"), c.Param("id")); err != nil {
+		if respondOrganizationError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to delete organization")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organization deleted"})
+}
+
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	members, err := h.orgService.ListMembers(ctx, c.GetString("userID"), c.Param("id"))
+	if err != nil {
+		if respondOrganizationError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to list organization members")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+func (h *OrganizationHandler) UpdateMemberRole(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid update member role request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Update member role validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	member, err := h.orgService.UpdateMemberRole(ctx, c.GetString("userID"), c.Param("id"), c.Param("userId"), req.Role)
+	if err != nil {
+		if respondOrganizationError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to update member role")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// RemoveMember removes a member, or lets the caller leave when the user ID
+// in the path is their own
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	if err := h.orgService.RemoveMember(ctx, c.GetString("userID"), c.Param("id"), c.Param("userId")); err != nil {
+		if respondOrganizationError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to remove organization member")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+// Invite emails an invitation to join the organization
+func (h *OrganizationHandler) Invite(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid invitation request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Invitation validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	invitation, err := h.orgService.Invite(ctx, c.GetString("userID"), c.Param("id"), req)
+	if err != nil {
+		if respondOrganizationError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to send invitation")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send invitation"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+// ListInvitations returns the organization's pending invitations
+func (h *OrganizationHandler) ListInvitations(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	invitations, err := h.orgService.ListInvitations(ctx, c.GetString("userID"), c.Param("id"))
+	if err != nil {
+		if respondOrganizationError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to list invitations")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invitations": invitations})
+}
+
+func (h *OrganizationHandler) RevokeInvitation(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	if err := h.orgService.RevokeInvitation(ctx, c.GetString("userID"), c.Param("id"), c.Param("invitationId")); err != nil {
+		if respondOrganizationError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to revoke invitation")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+// AcceptInvitation joins the organization an emailed invitation is for
+func (h *OrganizationHandler) AcceptInvitation(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid accept invitation request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Accept invitation validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	org, err := h.orgService.AcceptInvitation(ctx, c.GetString("userID"), req.Token)
+	if err != nil {
+		if respondOrganizationError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to accept invitation")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// SwitchOrganization moves the current session into an organization, or
+// back to the personal workspace when org_id is empty, and returns a new
+// access token for it
+func (h *OrganizationHandler) SwitchOrganization(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.SwitchOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid switch organization request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Switch organization validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	response, err := h.orgService.Switch(ctx, c.GetString("userID"), c.GetString("sessionID"), req.OrgID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCurrentSessionUnknown):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Current session cannot be determined, please sign in again"})
+			return
+		case errors.Is(err, services.ErrSessionNotFound):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended, please sign in again"})
+			return
+		}
+		if respondOrganizationError(c, err) {
+			return
+		}
+		log.WithError(err).Error("Failed to switch organization")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to switch organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func respondOrganizationError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrOrganizationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case errors.Is(err, services.ErrOrgMemberNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+	case errors.Is(err, services.ErrInvitationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+	case errors.Is(err, services.ErrOrgForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your organization role does not allow this"})
+	case errors.Is(err, services.ErrLastOrgOwner), errors.Is(err, services.ErrAlreadyOrgMember):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidInvitation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invitation"})
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
+)
+
+type SessionHandler struct {
+	sessionService services.SessionService
+	log            logger.Logger
+}
+
+func NewSessionHandler(sessionService services.SessionService, log logger.Logger) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+		log:            log,
+	}
+}
+
+// ListSessions returns the devices the user is signed in on, marking the
+// one making the request
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	userID := c.GetString("userID")
+
+	sessions, err := h.sessionService.List(ctx, userID, c.GetString("sessionID"))
+	if err != nil {
+		log.WithError(err).Error("Failed to list sessions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SessionsResponse{Sessions: sessions})
+}
+
+// RevokeSession signs a single device out
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	userID := c.GetString("userID")
+	sessionID := c.Param("id")
+
+	if err := h.sessionService.Revoke(ctx, userID, sessionID); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		log.WithError(err).Error("Failed to revoke session")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeOtherSessions signs out every device except the one making the request
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	userID := c.GetString("userID")
+
+	revoked, err := h.sessionService.RevokeOthers(ctx, userID, c.GetString("sessionID"))
+	if err != nil {
+		if errors.Is(err, services.ErrCurrentSessionUnknown) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Current session cannot be determined, please sign in again"})
+			return
+		}
+		log.WithError(err).Error("Failed to revoke other sessions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke other sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RevokeSessionsResponse{Revoked: revoked})
+}
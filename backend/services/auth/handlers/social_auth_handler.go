@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/oidc"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
+)
+
+type SocialAuthHandler struct {
+	socialAuthService services.SocialAuthService
+	log               logger.Logger
+	validator         *validator.Validate
+}
+
+func NewSocialAuthHandler(socialAuthService services.SocialAuthService, log logger.Logger) *SocialAuthHandler {
+	return &SocialAuthHandler{
+		socialAuthService: socialAuthService,
+		log:               log,
+		validator:         validator.New(),
+	}
+}
+
+// SignIn handles sign-in with an ID token from an external identity provider
+func (h *SocialAuthHandler) SignIn(c *gin.Context) {
+	ctx := c.Request.Context()
+	provider := strings.ToLower(c.Param("provider"))
+	log := h.log.WithContext(ctx).WithField("provider", provider)
+
+	var req models.OAuthSignInRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid oauth signin request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("OAuth signin validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	log.Info("Processing oauth signin request")
+
+	response, err := h.socialAuthService.SignIn(ctx, provider, req)
+	if err != nil {
+		if respondMFAChallenge(c, err) {
+			log.Info("OAuth signin requires second factor")
+			return
+		}
+		if respondPasswordResetRequired(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrUnknownProvider):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unsupported identity provider"})
+		case errors.Is(err, oidc.ErrInvalidToken), errors.Is(err, oidc.ErrNonceInvalid):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid identity token"})
+		case errors.Is(err, services.ErrProviderEmailUnset):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "A verified email address is required"})
+		case errors.Is(err, services.ErrIdentityLinkRefused):
+			c.JSON(http.StatusConflict, gin.H{"error": "An account with this email already exists. Sign in with its password and verify the email address to link this provider."})
+		case strings.Contains(err.Error(), "account is inactive"):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is inactive"})
+		default:
+			log.WithError(err).Error("OAuth signin failed")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Authentication failed"})
+		}
+		return
+	}
+
+	log.WithField("user_id", response.User.ID).Info("User successfully authenticated with identity provider")
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
+)
+
+type TokenHandler struct {
+	tokenService services.PersonalAccessTokenService
+	log          logger.Logger
+	validator    *validator.Validate
+}
+
+func NewTokenHandler(tokenService services.PersonalAccessTokenService, log logger.Logger) *TokenHandler {
+	return &TokenHandler{
+		tokenService: tokenService,
+		log:          log,
+		validator:    validator.New(),
+	}
+}
+
+// CreateToken issues a personal access token. The token is only returned
+// here.
+func (h *TokenHandler) CreateToken(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.CreatePersonalAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid create token request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Create token validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	response, err := h.tokenService.Create(ctx, c.GetString("userID"), req)
+	if err != nil {
+		log.WithError(err).Error("Failed to create personal access token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListTokens returns the user's personal access tokens, without the tokens
+// themselves
+func (h *TokenHandler) ListTokens(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	tokens, err := h.tokenService.List(ctx, c.GetString("userID"))
+	if err != nil {
+		log.WithError(err).Error("Failed to list personal access tokens")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+// RevokeToken deletes a personal access token
+func (h *TokenHandler) RevokeToken(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	if err := h.tokenService.Revoke(ctx, c.GetString("userID"), c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrPersonalAccessTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+			return
+		}
+		log.WithError(err).Error("Failed to revoke personal access token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked"})
+}
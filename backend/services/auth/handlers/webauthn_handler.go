@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/webauthn"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
+)
+
+type WebAuthnHandler struct {
+	webAuthnService services.WebAuthnService
+	log             logger.Logger
+	validator       *validator.Validate
+}
+
+func NewWebAuthnHandler(webAuthnService services.WebAuthnService, log logger.Logger) *WebAuthnHandler {
+	return &WebAuthnHandler{
+		webAuthnService: webAuthnService,
+		log:             log,
+		validator:       validator.New(),
+	}
+}
+
+// BeginRegistration returns credential creation options for a new passkey
+func (h *WebAuthnHandler) BeginRegistration(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	userID := c.GetString("userID")
+	log.WithField("user_id", userID).Info("Processing passkey registration start")
+
+	options, err := h.webAuthnService.BeginRegistration(ctx, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to start passkey registration")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey registration"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// FinishRegistration verifies and stores a new passkey
+func (h *WebAuthnHandler) FinishRegistration(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.WebAuthnRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid passkey registration request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Passkey registration validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	userID := c.GetString("userID")
+	log.WithField("user_id", userID).Info("Processing passkey registration")
+
+	cred, err := h.webAuthnService.FinishRegistration(ctx, userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWebAuthnChallenge):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired challenge"})
+		case errors.Is(err, webauthn.ErrVerification):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Passkey verification failed"})
+		case strings.Contains(err.Error(), "already registered"):
+			c.JSON(http.StatusConflict, gin.H{"error": "Passkey already registered"})
+		default:
+			log.WithError(err).Error("Passkey registration failed")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register passkey"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, cred)
+}
+
+// BeginLogin returns credential request options for passkey sign-in
+func (h *WebAuthnHandler) BeginLogin(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.WebAuthnLoginBeginRequest
+	// Body is optional - discoverable credentials need no email
+	_ = c.ShouldBindJSON(&req)
+
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		log.WithError(err).Warn("Passkey login validation failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	options, err := h.webAuthnService.BeginLogin(ctx, req.Email)
+	if err != nil {
+		log.WithError(err).Error("Failed to start passkey login")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey sign-in"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// FinishLogin verifies a passkey assertion and returns tokens
+func (h *WebAuthnHandler) FinishLogin(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	var req models.WebAuthnLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.WithError(err).Warn("Invalid passkey login request")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	log.Info("Processing passkey login")
+
+	response, err := h.webAuthnService.FinishLogin(ctx, req)
+	if err != nil {
+		if respondPasswordResetRequired(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrWebAuthnChallenge), errors.Is(err, webauthn.ErrVerification):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Passkey sign-in failed"})
+		case strings.Contains(err.Error(), "account is inactive"):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is inactive"})
+		default:
+			log.WithError(err).Error("Passkey login failed")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Authentication failed"})
+		}
+		return
+	}
+
+	log.WithField("user_id", response.User.ID).Info("User successfully authenticated with passkey")
+	c.JSON(http.StatusOK, response)
+}
+
+// ListCredentials returns the user's registered passkeys
+func (h *WebAuthnHandler) ListCredentials(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	creds, err := h.webAuthnService.ListCredentials(ctx, c.GetString("userID"))
+	if err != nil {
+		log.WithError(err).Error("Failed to list passkeys")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list passkeys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"credentials": creds})
+}
+
+// DeleteCredential removes one of the user's passkeys
+func (h *WebAuthnHandler) DeleteCredential(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	userID := c.GetString("userID")
+	credentialID := c.Param("id")
+
+	if err := h.webAuthnService.DeleteCredential(ctx, userID, credentialID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Passkey not found"})
+			return
+		}
+		log.WithError(err).Error("Failed to delete passkey")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete passkey"})
+		return
+	}
+
+	log.WithFields(map[string]interface{}{
+		"user_id":       userID,
+		"credential_id": credentialID,
+	}).Info("Passkey deleted")
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey deleted"})
+}
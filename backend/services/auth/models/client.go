@@ -0,0 +1,67 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// ClientInfo describes the device a request came from. It is recorded
+// against the session created when tokens are issued, and with auth events
+// and login history.
+type ClientInfo struct {
+	DeviceName     string
+	DeviceID       string
+	UserAgent      string
+	AcceptLanguage string
+	IPAddress      string
+	RequestID      string
+}
+
+var versionPattern = regexp.MustCompile(`[0-9]+([._][0-9]+)*`)
+
+// Fingerprint identifies the device for spotting sign-ins from new ones.
+// Apps that send an X-Device-ID are identified by it; browsers by their user
+// agent without version numbers, so updates don't look like a new device,
+// and their preferred languages.
+func (c ClientInfo) Fingerprint() string {
+	source := "id:" + c.DeviceID
+	if c.DeviceID == "" {
+		userAgent := versionPattern.ReplaceAllString(strings.ToLower(c.UserAgent), "")
+		source = "ua:" + userAgent + "|" + strings.ToLower(c.AcceptLanguage)
+	}
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+// Network is the block the IP address belongs to: the /24 for IPv4 and the
+// /48 for IPv6. Addresses move around within a provider's block, so new
+// networks are compared rather than new addresses. It is empty when the
+// address doesn't parse.
+func (c ClientInfo) Network() string {
+	ip := net.ParseIP(c.IPAddress)
+	if ip == nil {
+		return ""
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
+
+type clientInfoKey struct{}
+
+// WithClientInfo returns a copy of ctx carrying info
+func WithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+// ClientInfoFromContext returns the client info stored in ctx, or the zero
+// value when there is none
+func ClientInfoFromContext(ctx context.Context) ClientInfo {
+	info, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info
+}
@@ -1,42 +1,162 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/webauthn"
+)
+
+// NormalizeEmail trims and lower-cases an email address. Addresses are
+// stored and looked up in this form, so one mailbox can't end up with two
+// accounts or fail to find its own.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
 
 // User represents a user in the system
 type User struct {
-	ID           string    `json:"id" db:"id"`
-	Email        string    `json:"email" db:"email"`
-	Name         string    `json:"name" db:"name"`
-	PasswordHash string    `json:"-" db:"password_hash"`
-	PhotoURL     *string   `json:"photoUrl,omitempty" db:"photo_url"`
-	IsActive     bool      `json:"isActive" db:"is_active"`
-	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt    time.Time `json:"updatedAt" db:"updated_at"`
+	ID            string    `json:"id" db:"id"`
+	Email         string    `json:"email" db:"email"`
+	Name          string    `json:"name" db:"name"`
+	PasswordHash  string    `json:"-" db:"password_hash"`
+	PhotoURL      *string   `json:"photoUrl,omitempty" db:"photo_url"`
+	IsActive      bool      `json:"isActive" db:"is_active"`
+	EmailVerified bool      `json:"emailVerified" db:"email_verified"`
+	CreatedAt     time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time `json:"updatedAt" db:"updated_at"`
+
+	DeletionScheduledAt *time.Time `json:"deletionScheduledAt,omitempty" db:"deletion_scheduled_at"`
+	LastLoginAt         *time.Time `json:"lastLoginAt,omitempty" db:"last_login_at"`
+
+	Roles                 []string `json:"roles" db:"roles"`
+	PasswordResetRequired bool     `json:"passwordResetRequired" db:"password_reset_required"`
 }
 
 // PublicUser represents user data safe to expose
 type PublicUser struct {
-	ID        string    `json:"id"`
-	Email     string    `json:"email"`
-	Name      string    `json:"name"`
-	PhotoURL  *string   `json:"photoUrl,omitempty"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID            string    `json:"id"`
+	Email         string    `json:"email"`
+	Name          string    `json:"name"`
+	PhotoURL      *string   `json:"photoUrl,omitempty"`
+	EmailVerified bool      `json:"emailVerified"`
+	CreatedAt     time.Time `json:"createdAt"`
+
+	DeletionScheduledAt *time.Time `json:"deletionScheduledAt,omitempty"`
+	LastLoginAt         *time.Time `json:"lastLoginAt,omitempty"`
+
+	Roles []string `json:"roles,omitempty"`
 }
 
 func (u *User) ToPublicUser() *PublicUser {
 	return &PublicUser{
-		ID:        u.ID,
-		Email:     u.Email,
-		Name:      u.Name,
-		PhotoURL:  u.PhotoURL,
-		CreatedAt: u.CreatedAt,
+		ID:            u.ID,
+		Email:         u.Email,
+		Name:          u.Name,
+		PhotoURL:      u.PhotoURL,
+		EmailVerified: u.EmailVerified,
+		CreatedAt:     u.CreatedAt,
+
+		DeletionScheduledAt: u.DeletionScheduledAt,
+		LastLoginAt:         u.LastLoginAt,
+
+		Roles: u.Roles,
 	}
 }
 
+// UserIdentity links a user to an account at an external identity provider
+type UserIdentity struct {
+	ID         string     `json:"id" db:"id"`
+	UserID     string     `json:"user_id" db:"user_id"`
+	Provider   string     `json:"provider" db:"provider"`
+	Subject    string     `json:"-" db:"subject"`
+	Email      *string    `json:"email,omitempty" db:"email"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// TOTPCredential is a user's authenticator app secret. It only protects
+// sign-in once ConfirmedAt is set.
+type TOTPCredential struct {
+	UserID       string     `json:"user_id" db:"user_id"`
+	Secret       string     `json:"-" db:"secret"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty" db:"confirmed_at"`
+	LastUsedStep int64      `json:"-" db:"last_used_step"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func (c *TOTPCredential) Enabled() bool {
+	return c != nil && c.ConfirmedAt != nil
+}
+
+// WebAuthnCredential is a registered passkey or security key
+type WebAuthnCredential struct {
+	ID             string     `json:"id" db:"id"`
+	UserID         string     `json:"-" db:"user_id"`
+	CredentialID   string     `json:"credential_id" db:"credential_id"` // base64url
+	PublicKey      []byte     `json:"-" db:"public_key"`                // COSE encoded
+	SignCount      int64      `json:"-" db:"sign_count"`
+	AAGUID         []byte     `json:"-" db:"aaguid"`
+	Transports     []string   `json:"transports,omitempty" db:"transports"`
+	Name           string     `json:"name" db:"name"`
+	BackupEligible bool       `json:"backup_eligible" db:"backup_eligible"`
+	BackupState    bool       `json:"backup_state" db:"backup_state"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// WebAuthnChallenge is the server-side state of an in-progress ceremony
+type WebAuthnChallenge struct {
+	ID        string    `db:"id"`
+	UserID    *string   `db:"user_id"` // nil for discoverable-credential sign-in
+	Challenge string    `db:"challenge"`
+	Ceremony  string    `db:"ceremony"` // registration, login
+	ExpiresAt time.Time `db:"expires_at"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Session is a signed-in device. It corresponds to a refresh token family,
+// so its ID stays the same as the refresh token rotates.
+type Session struct {
+	ID         string    `json:"id" db:"family_id"`
+	DeviceName *string   `json:"device_name,omitempty" db:"device_name"`
+	UserAgent  *string   `json:"user_agent,omitempty" db:"user_agent"`
+	IPAddress  *string   `json:"ip_address,omitempty" db:"ip_address"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at" db:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at" db:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// LoginThrottle is the failed sign-in count for one account or IP address
+type LoginThrottle struct {
+	Scope         string    `db:"scope"`
+	Subject       string    `db:"subject"`
+	Failures      int       `db:"failures"`
+	LastFailureAt time.Time `db:"last_failure_at"`
+}
+
+// OutboxEmail is a rendered email waiting in the durable outbox
+type OutboxEmail struct {
+	ID            string     `json:"id" db:"id"`
+	ToAddress     string     `json:"to_address" db:"to_address"`
+	Template      string     `json:"template" db:"template"`
+	Subject       string     `json:"subject" db:"subject"`
+	TextBody      string     `json:"-" db:"text_body"`
+	HTMLBody      *string    `json:"-" db:"html_body"`
+	Status        string     `json:"status" db:"status"` // pending, sent, failed
+	Attempts      int        `json:"attempts" db:"attempts"`
+	LastError     *string    `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" db:"next_attempt_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty" db:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
 // Request/Response models
 type SignUpRequest struct {
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Password string `json:"password" validate:"required"`
 	Name     string `json:"name" validate:"required,min=2"`
 }
 
@@ -45,11 +165,87 @@ type SignInRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type OAuthSignInRequest struct {
+	IDToken string `json:"id_token" validate:"required"`
+	Nonce   string `json:"nonce,omitempty"`
+	Name    string `json:"name,omitempty" validate:"omitempty,max=255"` // Apple only shares the name with the client
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// MagicLinkResponse is the same whether or not the email has an account.
+// DeviceToken must be sent back with the link's token, so the link only
+// works on the device that asked for it.
+type MagicLinkResponse struct {
+	Message     string `json:"message"`
+	DeviceToken string `json:"device_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type MagicLinkVerifyRequest struct {
+	Token       string `json:"token" validate:"required"`
+	DeviceToken string `json:"device_token" validate:"required"`
+}
+
+// AuthResponse carries a new session's tokens. Sign-up leaves them out and
+// sets EmailVerificationRequired when the address must be verified first.
 type AuthResponse struct {
-	AccessToken  string      `json:"access_token"`
-	RefreshToken string      `json:"refresh_token"`
-	ExpiresIn    int         `json:"expires_in"`
-	User         *PublicUser `json:"user"`
+	AccessToken               string      `json:"access_token,omitempty"`
+	RefreshToken              string      `json:"refresh_token,omitempty"`
+	ExpiresIn                 int         `json:"expires_in,omitempty"`
+	User                      *PublicUser `json:"user"`
+	EmailVerificationRequired bool        `json:"email_verification_required,omitempty"`
+}
+
+// MFAChallengeResponse is returned by sign-in instead of tokens when the
+// account has two-factor authentication enabled
+type MFAChallengeResponse struct {
+	MFARequired    bool   `json:"mfa_required"`
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresIn      int    `json:"expires_in"`
+}
+
+type MFASignInRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"` // TOTP or recovery code
+}
+
+type MFASetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type MFADisableRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFAStatusResponse struct {
+	Enabled                bool `json:"enabled"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+type WebAuthnRegisterRequest struct {
+	Name       string                       `json:"name" validate:"omitempty,max=100"`
+	Credential webauthn.AttestationResponse `json:"credential"`
+}
+
+type WebAuthnLoginBeginRequest struct {
+	Email string `json:"email,omitempty" validate:"omitempty,email"`
+}
+
+type WebAuthnLoginRequest struct {
+	Credential webauthn.AssertionResponse `json:"credential"`
 }
 
 type RefreshTokenRequest struct {
@@ -62,6 +258,51 @@ type TokenResponse struct {
 	ExpiresIn    int    `json:"expires_in"`
 }
 
+type SessionsResponse struct {
+	Sessions []*Session `json:"sessions"`
+}
+
+type RevokeSessionsResponse struct {
+	Revoked int64 `json:"revoked"`
+}
+
+// UpdateProfileRequest changes only the fields that are present. An empty
+// photo_url removes the photo.
+type UpdateProfileRequest struct {
+	Name     *string `json:"name,omitempty" validate:"omitempty,min=2,max=255"`
+	PhotoURL *string `json:"photo_url,omitempty" validate:"omitempty,max=500,url|len=0"`
+}
+
+// ChangePasswordRequest needs the current password unless the account was
+// created through social sign-in and has none yet
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+// PasswordChangeResponse replaces the caller's access token, which the
+// change revoked along with every other. A refresh token is only returned
+// when the caller's session could not be kept.
+type PasswordChangeResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email,max=255"`
+	Password string `json:"password"`
+}
+
+// DeleteAccountRequest needs the password unless the account has none
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+type AccountDeletionResponse struct {
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+}
+
 type SignOutRequest struct {
 	RefreshToken string `json:"refresh_token,omitempty"`
 }
@@ -70,6 +311,356 @@ type PasswordResetRequest struct {
 	Email string `json:"email" validate:"required,email"`
 }
 
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
 type ResetPasswordRequest struct {
-	NewPassword string `json:"new_password" validate:"required,min=6"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+// AdminActor is the staff member performing an admin action
+type AdminActor struct {
+	UserID string
+	Email  string
+	Roles  []string
+}
+
+// UserFilter narrows an admin user search. Query matches email or name.
+type UserFilter struct {
+	Query  string
+	Role   string
+	Active *bool
+	Limit  int
+	Offset int
+}
+
+type UserListResponse struct {
+	Users []*User `json:"users"`
+	Total int     `json:"total"`
+}
+
+// AdminUserDetail is everything support staff see about one user
+type AdminUserDetail struct {
+	User     *User      `json:"user"`
+	Sessions []*Session `json:"sessions"`
+}
+
+type SetRolesRequest struct {
+	Roles []string `json:"roles" validate:"required,dive,oneof=user support admin"`
+}
+
+// AuditEntry records one admin action. Actor and target IDs are kept after
+// the accounts are deleted.
+type AuditEntry struct {
+	ID           string                 `json:"id" db:"id"`
+	ActorID      string                 `json:"actor_id" db:"actor_id"`
+	ActorEmail   string                 `json:"actor_email" db:"actor_email"`
+	Action       string                 `json:"action" db:"action"`
+	TargetUserID *string                `json:"target_user_id,omitempty" db:"target_user_id"`
+	Details      map[string]interface{} `json:"details,omitempty" db:"details"`
+	IPAddress    *string                `json:"ip_address,omitempty" db:"ip_address"`
+	UserAgent    *string                `json:"user_agent,omitempty" db:"user_agent"`
+	CreatedAt    time.Time              `json:"created_at" db:"created_at"`
+}
+
+// AuditFilter narrows an audit trail query. Entries are returned newest
+// first; Before pages back through older ones.
+type AuditFilter struct {
+	ActorID      string
+	TargetUserID string
+	Action       string
+	Before       *time.Time
+	Limit        int
+}
+
+// PersonalAccessToken is a long-lived token a user created for scripts and
+// integrations. The token itself is only shown once, when it is created.
+type PersonalAccessToken struct {
+	ID         string     `json:"id" db:"id"`
+	UserID     string     `json:"-" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"token_prefix"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// CreatePersonalAccessTokenRequest leaves ExpiresInDays unset for a token
+// that doesn't expire
+type CreatePersonalAccessTokenRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=profile:read profile:write flowtime:read flowtime:write"`
+	ExpiresInDays *int     `json:"expires_in_days,omitempty" validate:"omitempty,min=1,max=365"`
+}
+
+type CreatePersonalAccessTokenResponse struct {
+	*PersonalAccessToken
+	Token string `json:"token"`
+}
+
+// AuthEvent records one authentication attempt or token operation. UserID is
+// unset when the attempt couldn't be tied to an account, in which case Email
+// holds the address that was tried.
+type AuthEvent struct {
+	ID        string    `json:"id" db:"id"`
+	UserID    string    `json:"user_id,omitempty" db:"user_id"`
+	Email     string    `json:"email,omitempty" db:"email"`
+	Type      string    `json:"event_type" db:"event_type"`
+	Outcome   string    `json:"outcome" db:"outcome"`
+	Method    string    `json:"method,omitempty" db:"method"`
+	Reason    string    `json:"reason,omitempty" db:"reason"`
+	IPAddress string    `json:"ip_address,omitempty" db:"ip_address"`
+	UserAgent string    `json:"user_agent,omitempty" db:"user_agent"`
+	RequestID string    `json:"request_id,omitempty" db:"request_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// AuthEventFilter narrows an auth event query. Events are returned newest
+// first; Before pages back through older ones.
+type AuthEventFilter struct {
+	UserID    string
+	Email     string
+	Type      string
+	Outcome   string
+	IPAddress string
+	Since     *time.Time
+	Before    *time.Time
+	Limit     int
+}
+
+// LoginRecord is one successful sign-in. NewDevice and NewNetwork are set
+// when the user had signed in before, but never from this device or network.
+type LoginRecord struct {
+	ID                string    `json:"id" db:"id"`
+	UserID            string    `json:"-" db:"user_id"`
+	Method            string    `json:"method" db:"method"`
+	IPAddress         string    `json:"ip_address,omitempty" db:"ip_address"`
+	Network           string    `json:"-" db:"network"`
+	UserAgent         string    `json:"user_agent,omitempty" db:"user_agent"`
+	DeviceFingerprint string    `json:"device_fingerprint" db:"device_fingerprint"`
+	NewDevice         bool      `json:"new_device" db:"new_device"`
+	NewNetwork        bool      `json:"new_network" db:"new_network"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+}
+
+// OAuthClient is a third-party app registered to act for users through the
+// OAuth2 provider. Public clients, such as mobile and single page apps, have
+// no secret and rely on PKCE alone.
+type OAuthClient struct {
+	ID           string    `json:"client_id" db:"id"`
+	Name         string    `json:"name" db:"name"`
+	RedirectURIs []string  `json:"redirect_uris" db:"redirect_uris"`
+	Scopes       []string  `json:"scopes" db:"scopes"`
+	Confidential bool      `json:"confidential"`
+	SecretHash   string    `json:"-" db:"secret_hash"`
+	CreatedBy    string    `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateOAuthClientRequest registers an app. Scopes are the most the app
+// may ask users for.
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	RedirectURIs []string `json:"redirect_uris" validate:"required,min=1,max=10,dive,required,max=2000"`
+	Scopes       []string `json:"scopes" validate:"required,min=1,dive,oneof=profile:read profile:write flowtime:read flowtime:write"`
+	Confidential bool     `json:"confidential"`
+}
+
+// CreateOAuthClientResponse carries the client secret of a confidential
+// client. It is only shown this once.
+type CreateOAuthClientResponse struct {
+	*OAuthClient
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// OAuthAuthorizeRequest is the authorization request an app sent the user's
+// browser with, as passed on by the consent page
+type OAuthAuthorizeRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+}
+
+// OAuthConsentRequest is the user's answer to an authorization request
+type OAuthConsentRequest struct {
+	OAuthAuthorizeRequest
+	Approve bool `json:"approve"`
+}
+
+// OAuthConsent is what the consent page shows the user. ConsentRequired is
+// false when the user has already granted the app every requested scope.
+type OAuthConsent struct {
+	ClientID        string   `json:"client_id"`
+	ClientName      string   `json:"client_name"`
+	RedirectURI     string   `json:"redirect_uri"`
+	Scopes          []string `json:"scopes"`
+	ConsentRequired bool     `json:"consent_required"`
+}
+
+// OAuthRedirect is where the consent page sends the browser back to the app
+type OAuthRedirect struct {
+	RedirectURI string `json:"redirect_uri"`
+}
+
+// OAuthAuthorizationCode is an approved authorization request waiting to be
+// exchanged for tokens
+type OAuthAuthorizationCode struct {
+	ClientID    string
+	UserID      string
+	RedirectURI string
+	// RedirectURIExplicit is set when the authorization request carried
+	// redirect_uri rather than falling back to the client's only one
+	RedirectURIExplicit bool
+	Scopes              []string
+	CodeChallenge       string
+	ExpiresAt           time.Time
+}
+
+// OAuthTokenRequest is a form encoded token endpoint request. The client
+// credentials may also come from HTTP Basic authentication.
+type OAuthTokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// OAuthTokenActionRequest is a form encoded introspection or revocation
+// request
+type OAuthTokenActionRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// OAuthIntrospection describes a token as RFC 7662 defines. Only Active is
+// set for tokens that are invalid or belong to another client.
+type OAuthIntrospection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+}
+
+// OAuthGrant is an app a user has authorized, and the scopes they gave it
+type OAuthGrant struct {
+	ID         string     `json:"id" db:"id"`
+	UserID     string     `json:"-" db:"user_id"`
+	ClientID   string     `json:"client_id" db:"client_id"`
+	ClientName string     `json:"client_name" db:"client_name"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// OAuthRefreshToken is a stored app refresh token, joined with its grant
+type OAuthRefreshToken struct {
+	ID        string
+	GrantID   string
+	FamilyID  string
+	UserID    string
+	ClientID  string
+	Scopes    []string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// OAuthAccessToken identifies an access token issued to an app, so it can
+// be revoked along with the refresh token or grant it came from
+type OAuthAccessToken struct {
+	ID        string
+	UserID    string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// Organization is a workspace shared by its members. Role is the caller's
+// role in it.
+type Organization struct {
+	ID        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Role      string    `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type OrganizationRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// OrganizationMember is a user's membership of an organization
+type OrganizationMember struct {
+	OrgID     string    `json:"-" db:"org_id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Email     string    `json:"email" db:"email"`
+	Name      string    `json:"name,omitempty" db:"name"`
+	Role      string    `json:"role" db:"role"`
+	CreatedAt time.Time `json:"joined_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+// OrganizationInvitation is an outstanding invitation to join an
+// organization. The token is only ever sent to the invited address.
+type OrganizationInvitation struct {
+	ID        string    `json:"id" db:"id"`
+	OrgID     string    `json:"org_id" db:"org_id"`
+	Email     string    `json:"email" db:"email"`
+	Role      string    `json:"role" db:"role"`
+	InvitedBy *string   `json:"invited_by,omitempty" db:"invited_by"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateInvitationRequest invites an address as an admin or member.
+// Owners are made from existing members.
+type CreateInvitationRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+	Role  string `json:"role" validate:"required,oneof=admin member"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// SwitchOrganizationRequest moves the caller's session into an
+// organization. An empty OrgID switches back to the personal workspace.
+type SwitchOrganizationRequest struct {
+	OrgID string `json:"org_id" validate:"omitempty,uuid"`
+}
+
+// OrganizationSwitchResponse replaces the caller's access token with one
+// carrying the new org_id claim
+type OrganizationSwitchResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	OrgID       string `json:"org_id,omitempty"`
 }
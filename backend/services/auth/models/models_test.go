@@ -0,0 +1,21 @@
+package models
+
+import "testing"
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"alice@example.com", "alice@example.com"},
+		{"Alice@Example.COM", "alice@example.com"},
+		{"  alice@example.com\n", "alice@example.com"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeEmail(tt.email); got != tt.want {
+			t.Errorf("NormalizeEmail(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+	}
+}
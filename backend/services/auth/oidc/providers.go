@@ -0,0 +1,38 @@
+package oidc
+
+import (
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/jwks"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+)
+
+// Well-known provider settings
+const (
+	ProviderGoogle = "google"
+	ProviderApple  = "apple"
+
+	jwksCacheTTL = 6 * time.Hour
+)
+
+// GoogleProvider configures Sign in with Google for the given OAuth client IDs
+func GoogleProvider(clientIDs []string, jwksURL string, log logger.Logger) Provider {
+	return Provider{
+		Name:      ProviderGoogle,
+		Issuers:   []string{"https://accounts.google.com", "accounts.google.com"},
+		ClientIDs: clientIDs,
+		Keys:      jwks.NewRemoteKeySet(jwksURL, jwksCacheTTL, log),
+	}
+}
+
+// AppleProvider configures Sign in with Apple for the given bundle/service IDs.
+// Apple tokens must always carry a nonce.
+func AppleProvider(clientIDs []string, jwksURL string, log logger.Logger) Provider {
+	return Provider{
+		Name:         ProviderApple,
+		Issuers:      []string{"https://appleid.apple.com"},
+		ClientIDs:    clientIDs,
+		Keys:         jwks.NewRemoteKeySet(jwksURL, jwksCacheTTL, log),
+		RequireNonce: true,
+	}
+}
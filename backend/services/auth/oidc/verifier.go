@@ -0,0 +1,163 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mdnaeem95/lifesync/backend/pkg/jwks"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid id token")
+	ErrNonceInvalid = errors.New("id token nonce mismatch")
+)
+
+// Provider describes a trusted OpenID Connect identity provider
+type Provider struct {
+	Name         string
+	Issuers      []string
+	ClientIDs    []string
+	Keys         jwks.KeySource
+	RequireNonce bool
+}
+
+// Claims are the ID token claims the auth service relies on
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+type idTokenClaims struct {
+	Email         string   `json:"email"`
+	EmailVerified flexBool `json:"email_verified"`
+	Name          string   `json:"name"`
+	Picture       string   `json:"picture"`
+	Nonce         string   `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// Verifier validates ID tokens issued by a single provider
+type Verifier struct {
+	provider Provider
+	parser   *jwt.Parser
+}
+
+func NewVerifier(provider Provider) *Verifier {
+	return &Verifier{
+		provider: provider,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384"}),
+			jwt.WithExpirationRequired(),
+			jwt.WithIssuedAt(),
+			jwt.WithLeeway(time.Minute),
+		),
+	}
+}
+
+// Verify checks the token signature against the provider's key set and
+// validates issuer, audience, expiry and nonce. The nonce may be supplied
+// either raw or SHA-256 hashed in the token, as Apple's native flow does.
+func (v *Verifier) Verify(ctx context.Context, rawToken, nonce string) (*Claims, error) {
+	var claims idTokenClaims
+	_, err := v.parser.ParseWithClaims(rawToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("missing kid header")
+		}
+		return v.provider.Keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if !contains(v.provider.Issuers, claims.Issuer) {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, claims.Issuer)
+	}
+
+	if !audienceMatches(claims.Audience, v.provider.ClientIDs) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	if err := v.checkNonce(claims.Nonce, nonce); err != nil {
+		return nil, err
+	}
+
+	return &Claims{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+	}, nil
+}
+
+func (v *Verifier) checkNonce(tokenNonce, expected string) error {
+	if expected == "" {
+		if v.provider.RequireNonce || tokenNonce != "" {
+			return ErrNonceInvalid
+		}
+		return nil
+	}
+
+	hashed := sha256.Sum256([]byte(expected))
+	if constantTimeEqual(tokenNonce, expected) || constantTimeEqual(tokenNonce, hex.EncodeToString(hashed[:])) {
+		return nil
+	}
+	return ErrNonceInvalid
+}
+
+func audienceMatches(audience jwt.ClaimStrings, clientIDs []string) bool {
+	for _, aud := range audience {
+		if contains(clientIDs, aud) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// flexBool accepts both JSON booleans and the "true"/"false" strings Apple
+// sends for email_verified
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case bool:
+		*b = flexBool(t)
+	case string:
+		*b = flexBool(t == "true")
+	default:
+		*b = false
+	}
+	return nil
+}
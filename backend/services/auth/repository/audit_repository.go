@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+)
+
+// AuditRepository stores the admin audit trail. Entries are only ever added.
+type AuditRepository interface {
+	Add(ctx context.Context, entry *models.AuditEntry) error
+	List(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error)
+}
+
+type auditRepository struct {
+	db  *sql.DB
+	log logger.Logger
+}
+
+func NewAuditRepository(db *sql.DB, log logger.Logger) AuditRepository {
+	return &auditRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *auditRepository) Add(ctx context.Context, entry *models.AuditEntry) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "add_audit_entry",
+		"action":    entry.Action,
+	})
+
+	var details []byte
+	if len(entry.Details) > 0 {
+		encoded, err := json.Marshal(entry.Details)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit details: %w", err)
+		}
+		details = encoded
+	}
+
+	query := `
+		INSERT INTO admin_audit_log (actor_id, actor_email, action, target_user_id, details, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		entry.ActorID, entry.ActorEmail, entry.Action, entry.TargetUserID, details, entry.IPAddress, entry.UserAgent,
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		log.WithError(err).Error("Failed to add audit entry")
+		return fmt.Errorf("failed to add audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// List returns matching entries, newest first
+func (r *auditRepository) List(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	log := r.log.WithContext(ctx).WithField("operation", "list_audit_entries")
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.ActorID != "" {
+		addCondition("actor_id = $%d", filter.ActorID)
+	}
+	if filter.TargetUserID != "" {
+		addCondition("target_user_id = $%d", filter.TargetUserID)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.Before != nil {
+		addCondition("created_at < $%d", *filter.Before)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, actor_id, actor_email, action, target_user_id, details, ip_address, user_agent, created_at
+		FROM admin_audit_log
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d
+	`, where, len(args)+1)
+
+	rows, err := r.db.QueryContext(ctx, query, append(args, filter.Limit)...)
+	if err != nil {
+		log.WithError(err).Error("Failed to list audit entries")
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*models.AuditEntry{}
+	for rows.Next() {
+		entry := &models.AuditEntry{}
+		var details []byte
+		if err := rows.Scan(
+			&entry.ID, &entry.ActorID, &entry.ActorEmail, &entry.Action, &entry.TargetUserID,
+			&details, &entry.IPAddress, &entry.UserAgent, &entry.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if len(details) > 0 {
+			if err := json.Unmarshal(details, &entry.Details); err != nil {
+				return nil, fmt.Errorf("failed to decode audit details: %w", err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
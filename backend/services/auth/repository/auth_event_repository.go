@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+)
+
+// AuthEventRepository stores the security log of authentication events
+type AuthEventRepository interface {
+	Add(ctx context.Context, event *models.AuthEvent) error
+	List(ctx context.Context, filter models.AuthEventFilter) ([]*models.AuthEvent, error)
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type authEventRepository struct {
+	db  *sql.DB
+	log logger.Logger
+}
+
+func NewAuthEventRepository(db *sql.DB, log logger.Logger) AuthEventRepository {
+	return &authEventRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *authEventRepository) Add(ctx context.Context, event *models.AuthEvent) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation":  "add_auth_event",
+		"event_type": event.Type,
+	})
+
+	query := `
+		INSERT INTO auth_events (user_id, email, event_type, outcome, method, reason, ip_address, user_agent, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		nullString(event.UserID), nullString(event.Email), event.Type, event.Outcome, nullString(event.Method),
+		nullString(event.Reason), nullString(event.IPAddress), nullString(event.UserAgent), nullString(event.RequestID),
+	).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		log.WithError(err).Error("Failed to add auth event")
+		return fmt.Errorf("failed to add auth event: %w", err)
+	}
+
+	return nil
+}
+
+// List returns matching events, newest first
+func (r *authEventRepository) List(ctx context.Context, filter models.AuthEventFilter) ([]*models.AuthEvent, error) {
+	log := r.log.WithContext(ctx).WithField("operation", "list_auth_events")
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.UserID != "" {
+		addCondition("user_id = $%d", filter.UserID)
+	}
+	if filter.Email != "" {
+		addCondition("email = $%d", filter.Email)
+	}
+	if filter.Type != "" {
+		addCondition("event_type = $%d", filter.Type)
+	}
+	if filter.Outcome != "" {
+		addCondition("outcome = $%d", filter.Outcome)
+	}
+	if filter.IPAddress != "" {
+		addCondition("ip_address = $%d", filter.IPAddress)
+	}
+	if filter.Since != nil {
+		addCondition("created_at >= $%d", *filter.Since)
+	}
+	if filter.Before != nil {
+		addCondition("created_at < $%d", *filter.Before)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, user_id, email, event_type, outcome, method, reason, ip_address, user_agent, request_id, created_at
+		FROM auth_events
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d
+	`, where, len(args)+1)
+
+	rows, err := r.db.QueryContext(ctx, query, append(args, filter.Limit)...)
+	if err != nil {
+		log.WithError(err).Error("Failed to list auth events")
+		return nil, fmt.Errorf("failed to list auth events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*models.AuthEvent{}
+	for rows.Next() {
+		event := &models.AuthEvent{}
+		var userID, email, method, reason, ipAddress, userAgent, requestID sql.NullString
+		if err := rows.Scan(
+			&event.ID, &userID, &email, &event.Type, &event.Outcome, &method,
+			&reason, &ipAddress, &userAgent, &requestID, &event.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan auth event: %w", err)
+		}
+		event.UserID = userID.String
+		event.Email = email.String
+		event.Method = method.String
+		event.Reason = reason.String
+		event.IPAddress = ipAddress.String
+		event.UserAgent = userAgent.String
+		event.RequestID = requestID.String
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// DeleteBefore removes events recorded before the cutoff
+func (r *authEventRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	log := r.log.WithContext(ctx).WithField("operation", "delete_old_auth_events")
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM auth_events WHERE created_at < $1`, before)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete old auth events")
+		return 0, fmt.Errorf("failed to delete old auth events: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rows, nil
+}
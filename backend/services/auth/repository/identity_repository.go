@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+)
+
+type IdentityRepository interface {
+	Create(ctx context.Context, identity *models.UserIdentity) error
+	GetByProviderSubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	GetByUser(ctx context.Context, userID string) ([]*models.UserIdentity, error)
+	TouchLastUsed(ctx context.Context, id string) error
+}
+
+type identityRepository struct {
+	db  *sql.DB
+	log logger.Logger
+}
+
+func NewIdentityRepository(db *sql.DB, log logger.Logger) IdentityRepository {
+	return &identityRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *identityRepository) Create(ctx context.Context, identity *models.UserIdentity) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "create_identity",
+		"user_id":   identity.UserID,
+		"provider":  identity.Provider,
+	})
+
+	identity.ID = uuid.New().String()
+	identity.CreatedAt = time.Now()
+
+	query := `
+		INSERT INTO user_identities (id, user_id, provider, subject, email, last_used_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.ExecContext(
+		ctx, query,
+		identity.ID, identity.UserID, identity.Provider, identity.Subject,
+		identity.Email, identity.LastUsedAt, identity.CreatedAt,
+	)
+
+	if err != nil {
+		log.WithError(err).Error("Failed to create identity")
+		return fmt.Errorf("failed to create identity: %w", err)
+	}
+
+	log.Info("Identity linked")
+	return nil
+}
+
+func (r *identityRepository) GetByProviderSubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "get_identity",
+		"provider":  provider,
+	})
+
+	var identity models.UserIdentity
+	query := `
+		SELECT id, user_id, provider, subject, email, last_used_at, created_at
+		FROM user_identities
+		WHERE provider = $1 AND subject = $2
+	`
+
+	err := r.db.QueryRowContext(ctx, query, provider, subject).Scan(
+		&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject,
+		&identity.Email, &identity.LastUsedAt, &identity.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		log.Debug("Identity not found")
+		return nil, errors.New("identity not found")
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to get identity")
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	return &identity, nil
+}
+
+func (r *identityRepository) GetByUser(ctx context.Context, userID string) ([]*models.UserIdentity, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "get_identities_by_user",
+		"user_id":   userID,
+	})
+
+	query := `
+		SELECT id, user_id, provider, subject, email, last_used_at, created_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to get identities")
+		return nil, fmt.Errorf("failed to get identities: %w", err)
+	}
+	defer rows.Close()
+
+	var identities []*models.UserIdentity
+	for rows.Next() {
+		var identity models.UserIdentity
+		if err := rows.Scan(
+			&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject,
+			&identity.Email, &identity.LastUsedAt, &identity.CreatedAt,
+		); err != nil {
+			log.WithError(err).Error("Failed to scan identity")
+			continue
+		}
+		identities = append(identities, &identity)
+	}
+
+	return identities, nil
+}
+
+func (r *identityRepository) TouchLastUsed(ctx context.Context, id string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation":   "touch_identity",
+		"identity_id": id,
+	})
+
+	query := `UPDATE user_identities SET last_used_at = $1 WHERE id = $2`
+
+	_, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		log.WithError(err).Error("Failed to update identity last used")
+		return fmt.Errorf("failed to update identity: %w", err)
+	}
+
+	return nil
+}
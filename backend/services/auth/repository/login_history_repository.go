@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+)
+
+// LoginHistoryRepository stores a row for every successful sign-in
+type LoginHistoryRepository interface {
+	Add(ctx context.Context, record *models.LoginRecord) error
+	// Seen reports whether the user has signed in before at all, and before
+	// from the device and from the network
+	Seen(ctx context.Context, userID, fingerprint, network string) (seenAny, seenDevice, seenNetwork bool, err error)
+	ListByUser(ctx context.Context, userID string, before *time.Time, limit int) ([]*models.LoginRecord, error)
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type loginHistoryRepository struct {
+	db  *sql.DB
+	log logger.Logger
+}
+
+func NewLoginHistoryRepository(db *sql.DB, log logger.Logger) LoginHistoryRepository {
+	return &loginHistoryRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *loginHistoryRepository) Add(ctx context.Context, record *models.LoginRecord) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "add_login_record",
+		"user_id":   record.UserID,
+	})
+
+	query := `
+		INSERT INTO login_history (user_id, method, ip_address, network, user_agent, device_fingerprint, new_device, new_network)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		record.UserID, record.Method, nullString(record.IPAddress), nullString(record.Network),
+		nullString(record.UserAgent), record.DeviceFingerprint, record.NewDevice, record.NewNetwork,
+	).Scan(&record.ID, &record.CreatedAt)
+	if err != nil {
+		log.WithError(err).Error("Failed to add login record")
+		return fmt.Errorf("failed to add login record: %w", err)
+	}
+
+	return nil
+}
+
+func (r *loginHistoryRepository) Seen(ctx context.Context, userID, fingerprint, network string) (bool, bool, bool, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "check_login_history",
+		"user_id":   userID,
+	})
+
+	query := `
+		SELECT
+			EXISTS (SELECT 1 FROM login_history WHERE user_id = $1),
+			EXISTS (SELECT 1 FROM login_history WHERE user_id = $1 AND device_fingerprint = $2),
+			EXISTS (SELECT 1 FROM login_history WHERE user_id = $1 AND network = $3)
+	`
+
+	var seenAny, seenDevice, seenNetwork bool
+	if err := r.db.QueryRowContext(ctx, query, userID, fingerprint, network).Scan(&seenAny, &seenDevice, &seenNetwork); err != nil {
+		log.WithError(err).Error("Failed to check login history")
+		return false, false, false, fmt.Errorf("failed to check login history: %w", err)
+	}
+
+	return seenAny, seenDevice, seenNetwork, nil
+}
+
+// ListByUser returns the user's sign-ins, newest first
+func (r *loginHistoryRepository) ListByUser(ctx context.Context, userID string, before *time.Time, limit int) ([]*models.LoginRecord, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "list_login_history",
+		"user_id":   userID,
+	})
+
+	query := `
+		SELECT id, user_id, method, ip_address, network, user_agent, device_fingerprint, new_device, new_network, created_at
+		FROM login_history
+		WHERE user_id = $1 AND ($2::timestamptz IS NULL OR created_at < $2)
+		ORDER BY created_at DESC
+		LIMIT $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, before, limit)
+	if err != nil {
+		log.WithError(err).Error("Failed to list login history")
+		return nil, fmt.Errorf("failed to list login history: %w", err)
+	}
+	defer rows.Close()
+
+	records := []*models.LoginRecord{}
+	for rows.Next() {
+		record := &models.LoginRecord{}
+		var ipAddress, network, userAgent sql.NullString
+		if err := rows.Scan(
+			&record.ID, &record.UserID, &record.Method, &ipAddress, &network, &userAgent,
+			&record.DeviceFingerprint, &record.NewDevice, &record.NewNetwork, &record.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan login record: %w", err)
+		}
+		record.IPAddress = ipAddress.String
+		record.Network = network.String
+		record.UserAgent = userAgent.String
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// DeleteBefore removes sign-ins recorded before the cutoff
+func (r *loginHistoryRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	log := r.log.WithContext(ctx).WithField("operation", "delete_old_login_history")
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM login_history WHERE created_at < $1`, before)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete old login history")
+		return 0, fmt.Errorf("failed to delete old login history: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rows, nil
+}
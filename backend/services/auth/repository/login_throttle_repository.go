@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+)
+
+type LoginThrottleRepository interface {
+	Get(ctx context.Context, scope, subject string) (*models.LoginThrottle, error)
+	RecordFailure(ctx context.Context, scope, subject string, windowStart time.Time) (*models.LoginThrottle, error)
+	Clear(ctx context.Context, scope, subject string) error
+	DeleteStale(ctx context.Context, before time.Time) (int64, error)
+}
+
+type loginThrottleRepository struct {
+	db  *sql.DB
+	log logger.Logger
+}
+
+func NewLoginThrottleRepository(db *sql.DB, log logger.Logger) LoginThrottleRepository {
+	return &loginThrottleRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Get returns nil, nil when the subject has no recorded failures
+func (r *loginThrottleRepository) Get(ctx context.Context, scope, subject string) (*models.LoginThrottle, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "get_login_throttle",
+		"scope":     scope,
+	})
+
+	throttle := &models.LoginThrottle{}
+	query := `
+		SELECT scope, subject, failures, last_failure_at
+		FROM login_throttles
+		WHERE scope = $1 AND subject = $2
+	`
+
+	err := r.db.QueryRowContext(ctx, query, scope, subject).Scan(
+		&throttle.Scope, &throttle.Subject, &throttle.Failures, &throttle.LastFailureAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to get login throttle")
+		return nil, fmt.Errorf("failed to get login throttle: %w", err)
+	}
+
+	return throttle, nil
+}
+
+// RecordFailure counts a failed sign-in. Failures older than windowStart are
+// forgotten, so the count restarts at one.
+func (r *loginThrottleRepository) RecordFailure(ctx context.Context, scope, subject string, windowStart time.Time) (*models.LoginThrottle, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "record_login_failure",
+		"scope":     scope,
+	})
+
+	throttle := &models.LoginThrottle{}
+	query := `
+		INSERT INTO login_throttles (scope, subject, failures, last_failure_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (scope, subject) DO UPDATE SET
+			failures = CASE
+				WHEN login_throttles.last_failure_at < $4 THEN 1
+				ELSE login_throttles.failures + 1
+			END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING scope, subject, failures, last_failure_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, scope, subject, time.Now(), windowStart).Scan(
+		&throttle.Scope, &throttle.Subject, &throttle.Failures, &throttle.LastFailureAt,
+	)
+	if err != nil {
+		log.WithError(err).Error("Failed to record login failure")
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	return throttle, nil
+}
+
+func (r *loginThrottleRepository) Clear(ctx context.Context, scope, subject string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "clear_login_throttle",
+		"scope":     scope,
+	})
+
+	query := `DELETE FROM login_throttles WHERE scope = $1 AND subject = $2`
+
+	if _, err := r.db.ExecContext(ctx, query, scope, subject); err != nil {
+		log.WithError(err).Error("Failed to clear login throttle")
+		return fmt.Errorf("failed to clear login throttle: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteStale removes counters whose last failure is older than before
+func (r *loginThrottleRepository) DeleteStale(ctx context.Context, before time.Time) (int64, error) {
+	log := r.log.WithContext(ctx).WithField("operation", "delete_stale_login_throttles")
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM login_throttles WHERE last_failure_at < $1`, before)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete stale login throttles")
+		return 0, fmt.Errorf("failed to delete stale login throttles: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,362 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+)
+
+type MFARepository interface {
+	// TOTP
+	SavePendingTOTP(ctx context.Context, userID, secret string) error
+	GetTOTP(ctx context.Context, userID string) (*models.TOTPCredential, error)
+	EnableTOTP(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) error
+	ConsumeTOTPStep(ctx context.Context, userID string, step int64) (bool, error)
+	DeleteTOTP(ctx context.Context, userID string) error
+
+	// Recovery codes
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error
+	ConsumeRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID string) (int, error)
+
+	// Sign-in challenges
+	CreateChallenge(ctx context.Context, id, userID string, expiresAt time.Time) error
+	ChallengeActive(ctx context.Context, id, userID string) (bool, error)
+	RecordChallengeFailure(ctx context.Context, id string, maxAttempts int) error
+	ConsumeChallenge(ctx context.Context, id string) (bool, error)
+}
+
+type mfaRepository struct {
+	db  *sql.DB
+	log logger.Logger
+}
+
+func NewMFARepository(db *sql.DB, log logger.Logger) MFARepository {
+	return &mfaRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// SavePendingTOTP stores a new unconfirmed secret, replacing any earlier
+// unconfirmed one. It fails if TOTP is already enabled for the user.
+func (r *mfaRepository) SavePendingTOTP(ctx context.Context, userID, secret string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "save_pending_totp",
+		"user_id":   userID,
+	})
+
+	query := `
+		INSERT INTO user_totp (user_id, secret, created_at, updated_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, updated_at = EXCLUDED.updated_at
+		WHERE user_totp.confirmed_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, secret, time.Now())
+	if err != nil {
+		log.WithError(err).Error("Failed to save TOTP secret")
+		return fmt.Errorf("failed to save totp secret: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return errors.New("two-factor authentication already enabled")
+	}
+
+	return nil
+}
+
+// GetTOTP returns the user's TOTP credential, or nil if none was set up
+func (r *mfaRepository) GetTOTP(ctx context.Context, userID string) (*models.TOTPCredential, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "get_totp",
+		"user_id":   userID,
+	})
+
+	var cred models.TOTPCredential
+	query := `
+		SELECT user_id, secret, confirmed_at, last_used_step, created_at, updated_at
+		FROM user_totp
+		WHERE user_id = $1
+	`
+
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&cred.UserID,
+		&cred.Secret,
+		&cred.ConfirmedAt,
+		&cred.LastUsedStep,
+		&cred.CreatedAt,
+		&cred.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to get TOTP credential")
+		return nil, fmt.Errorf("failed to get totp credential: %w", err)
+	}
+
+	return &cred, nil
+}
+
+// EnableTOTP confirms the pending secret and stores the initial recovery
+// codes in one transaction
+func (r *mfaRepository) EnableTOTP(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "enable_totp",
+		"user_id":   userID,
+	})
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.ExecContext(ctx, `
+		UPDATE user_totp
+		SET confirmed_at = $2, last_used_step = $3, updated_at = $2
+		WHERE user_id = $1 AND confirmed_at IS NULL
+	`, userID, now, step)
+	if err != nil {
+		log.WithError(err).Error("Failed to confirm TOTP")
+		return fmt.Errorf("failed to confirm totp: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return errors.New("no pending totp enrollment")
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		log.WithError(err).Error("Failed to store recovery codes")
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info("TOTP enabled")
+	return nil
+}
+
+// ConsumeTOTPStep records step as used. It reports false when the step (or a
+// later one) was already used, so a code can't be replayed.
+func (r *mfaRepository) ConsumeTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	query := `
+		UPDATE user_totp
+		SET last_used_step = $2, updated_at = $3
+		WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, step, time.Now())
+	if err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to consume TOTP step")
+		return false, fmt.Errorf("failed to consume totp step: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// DeleteTOTP removes the TOTP secret and all recovery codes
+func (r *mfaRepository) DeleteTOTP(ctx context.Context, userID string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "delete_totp",
+		"user_id":   userID,
+	})
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		log.WithError(err).Error("Failed to delete recovery codes")
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+		log.WithError(err).Error("Failed to delete TOTP credential")
+		return fmt.Errorf("failed to delete totp credential: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info("TOTP disabled")
+	return nil
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to replace recovery codes")
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID string, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	now := time.Now()
+	for _, hash := range codeHashes {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO mfa_recovery_codes (id, user_id, code_hash, created_at)
+			VALUES ($1, $2, $3, $4)
+		`, uuid.New().String(), userID, hash, now)
+		if err != nil {
+			return fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ConsumeRecoveryCode marks an unused recovery code as used
+func (r *mfaRepository) ConsumeRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = $3
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, codeHash, time.Now())
+	if err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to consume recovery code")
+		return false, fmt.Errorf("failed to consume recovery code: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// CountRecoveryCodes returns the number of unused recovery codes
+func (r *mfaRepository) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to count recovery codes")
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+
+	return count, nil
+}
+
+// CreateChallenge stores a sign-in challenge, purging expired ones
+func (r *mfaRepository) CreateChallenge(ctx context.Context, id, userID string, expiresAt time.Time) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "create_mfa_challenge",
+		"user_id":   userID,
+	})
+
+	query := `
+		INSERT INTO mfa_challenges (id, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, id, userID, expiresAt, time.Now()); err != nil {
+		log.WithError(err).Error("Failed to store mfa challenge")
+		return fmt.Errorf("failed to store challenge: %w", err)
+	}
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM mfa_challenges WHERE expires_at < $1`, time.Now()); err != nil {
+		log.WithError(err).Warn("Failed to purge expired mfa challenges")
+	}
+
+	return nil
+}
+
+// ChallengeActive reports whether the user's challenge is unexpired and has
+// not been completed or given up on
+func (r *mfaRepository) ChallengeActive(ctx context.Context, id, userID string) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM mfa_challenges WHERE id = $1 AND user_id = $2 AND expires_at > $3)`
+
+	if err := r.db.QueryRowContext(ctx, query, id, userID, time.Now()).Scan(&exists); err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to look up mfa challenge")
+		return false, fmt.Errorf("failed to get challenge: %w", err)
+	}
+
+	return exists, nil
+}
+
+// RecordChallengeFailure counts a wrong code against the challenge and
+// deletes it once maxAttempts codes have been wrong
+func (r *mfaRepository) RecordChallengeFailure(ctx context.Context, id string, maxAttempts int) error {
+	query := `
+		WITH failed AS (
+			UPDATE mfa_challenges
+			SET failed_attempts = failed_attempts + 1
+			WHERE id = $1
+			RETURNING id, failed_attempts
+		)
+		DELETE FROM mfa_challenges
+		WHERE id IN (SELECT id FROM failed WHERE failed_attempts >= $2)
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, id, maxAttempts); err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to record mfa challenge failure")
+		return fmt.Errorf("failed to record challenge failure: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeChallenge deletes an unexpired challenge, reporting false if it was
+// already gone, so each challenge completes at most one sign-in
+func (r *mfaRepository) ConsumeChallenge(ctx context.Context, id string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM mfa_challenges WHERE id = $1 AND expires_at > $2`, id, time.Now())
+	if err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to consume mfa challenge")
+		return false, fmt.Errorf("failed to consume challenge: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rows == 1, nil
+}
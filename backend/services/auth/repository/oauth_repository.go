@@ -0,0 +1,619 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+)
+
+var (
+	// ErrOAuthClientNotFound is returned when no client has the ID
+	ErrOAuthClientNotFound = errors.New("oauth client not found")
+	// ErrOAuthCodeInvalid is returned for unknown, expired or used
+	// authorization codes
+	ErrOAuthCodeInvalid = errors.New("invalid or expired authorization code")
+	// ErrOAuthGrantNotFound is returned when the user hasn't authorized the
+	// app, or has revoked it
+	ErrOAuthGrantNotFound = errors.New("oauth grant not found")
+	// ErrOAuthRefreshTokenNotFound is returned when a refresh token is
+	// unknown or has expired
+	ErrOAuthRefreshTokenNotFound = errors.New("oauth refresh token not found")
+	// ErrOAuthRefreshTokenReused is returned by RotateRefreshToken when the
+	// presented token had already been rotated or revoked
+	ErrOAuthRefreshTokenReused = errors.New("oauth refresh token reuse detected")
+)
+
+// OAuthRepository stores the OAuth2 provider's clients, authorization codes,
+// grants and refresh tokens. Codes and refresh tokens are stored hashed.
+//
+// The methods that delete grants or revoke refresh tokens return the access
+// tokens issued alongside them that haven't expired yet, for the caller to
+// revoke.
+type OAuthRepository interface {
+	CreateClient(ctx context.Context, client *models.OAuthClient) error
+	GetClient(ctx context.Context, id string) (*models.OAuthClient, error)
+	ListClients(ctx context.Context) ([]*models.OAuthClient, error)
+	DeleteClient(ctx context.Context, id string) ([]models.OAuthAccessToken, error)
+
+	SaveAuthorizationCode(ctx context.Context, code string, authCode *models.OAuthAuthorizationCode) error
+	// ConsumeAuthorizationCode deletes the code and returns what it was
+	// issued for
+	ConsumeAuthorizationCode(ctx context.Context, code string) (*models.OAuthAuthorizationCode, error)
+
+	// SaveGrant records the user's consent, adding scopes to any the app
+	// was already granted
+	SaveGrant(ctx context.Context, userID, clientID string, scopes []string) (*models.OAuthGrant, error)
+	GetGrant(ctx context.Context, userID, clientID string) (*models.OAuthGrant, error)
+	ListGrants(ctx context.Context, userID string) ([]*models.OAuthGrant, error)
+	DeleteGrant(ctx context.Context, userID, clientID string) ([]models.OAuthAccessToken, error)
+
+	// StoreRefreshToken starts a new token family under the grant
+	StoreRefreshToken(ctx context.Context, grantID, token string, scopes []string, access models.OAuthAccessToken, expiresIn time.Duration) error
+	GetRefreshToken(ctx context.Context, token string) (*models.OAuthRefreshToken, error)
+	// RotateRefreshToken revokes oldToken and stores newToken in the same
+	// family with the same scopes
+	RotateRefreshToken(ctx context.Context, oldToken, newToken string, access models.OAuthAccessToken, expiresIn time.Duration) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) ([]models.OAuthAccessToken, error)
+
+	// DeleteExpired removes expired authorization codes, and refresh tokens
+	// whose access tokens have expired too
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type oauthRepository struct {
+	db  *sql.DB
+	log logger.Logger
+}
+
+func NewOAuthRepository(db *sql.DB, log logger.Logger) OAuthRepository {
+	return &oauthRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Clients
+
+func (r *oauthRepository) CreateClient(ctx context.Context, client *models.OAuthClient) error {
+	log := r.log.WithContext(ctx).WithField("operation", "create_oauth_client")
+
+	query := `
+		INSERT INTO oauth_clients (name, secret_hash, redirect_uris, scopes, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		client.Name, nullString(client.SecretHash), pq.Array(client.RedirectURIs), pq.Array(client.Scopes), nullString(client.CreatedBy),
+	).Scan(&client.ID, &client.CreatedAt)
+	if err != nil {
+		log.WithError(err).Error("Failed to create oauth client")
+		return fmt.Errorf("failed to create oauth client: %w", err)
+	}
+
+	log.WithField("client_id", client.ID).Info("OAuth client created")
+	return nil
+}
+
+func (r *oauthRepository) GetClient(ctx context.Context, id string) (*models.OAuthClient, error) {
+	query := `
+		SELECT id, name, secret_hash, redirect_uris, scopes, created_by, created_at
+		FROM oauth_clients
+		WHERE id = $1
+	`
+
+	client, err := scanOAuthClient(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrOAuthClientNotFound
+	}
+	if err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to get oauth client")
+		return nil, fmt.Errorf("failed to get oauth client: %w", err)
+	}
+
+	return client, nil
+}
+
+func (r *oauthRepository) ListClients(ctx context.Context) ([]*models.OAuthClient, error) {
+	log := r.log.WithContext(ctx).WithField("operation", "list_oauth_clients")
+
+	query := `
+		SELECT id, name, secret_hash, redirect_uris, scopes, created_by, created_at
+		FROM oauth_clients
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		log.WithError(err).Error("Failed to list oauth clients")
+		return nil, fmt.Errorf("failed to list oauth clients: %w", err)
+	}
+	defer rows.Close()
+
+	clients := []*models.OAuthClient{}
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			log.WithError(err).Error("Failed to scan oauth client")
+			continue
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, rows.Err()
+}
+
+// DeleteClient removes the client along with every grant, code and refresh
+// token issued to it
+func (r *oauthRepository) DeleteClient(ctx context.Context, id string) ([]models.OAuthAccessToken, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "delete_oauth_client",
+		"client_id": id,
+	})
+
+	// Every part of the statement sees the tables as they were before the
+	// delete, so the cascaded refresh tokens can still be read
+	query := `
+		WITH deleted AS (
+			DELETE FROM oauth_clients WHERE id = $1 RETURNING id
+		)
+		SELECT g.user_id, t.access_token_id, t.created_at, t.access_token_expires_at
+		FROM deleted d
+		LEFT JOIN oauth_grants g ON g.client_id = d.id
+		LEFT JOIN oauth_refresh_tokens t ON t.grant_id = g.id AND t.access_token_expires_at > NOW()
+	`
+
+	found, tokens, err := r.queryAccessTokens(ctx, query, id)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete oauth client")
+		return nil, fmt.Errorf("failed to delete oauth client: %w", err)
+	}
+	if !found {
+		return nil, ErrOAuthClientNotFound
+	}
+
+	log.Info("OAuth client deleted")
+	return tokens, nil
+}
+
+func scanOAuthClient(row rowScanner) (*models.OAuthClient, error) {
+	client := &models.OAuthClient{}
+	var secretHash, createdBy sql.NullString
+
+	err := row.Scan(
+		&client.ID, &client.Name, &secretHash, pq.Array(&client.RedirectURIs), pq.Array(&client.Scopes),
+		&createdBy, &client.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	client.SecretHash = secretHash.String
+	client.Confidential = secretHash.Valid
+	client.CreatedBy = createdBy.String
+	return client, nil
+}
+
+// Authorization codes
+
+func (r *oauthRepository) SaveAuthorizationCode(ctx context.Context, code string, authCode *models.OAuthAuthorizationCode) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "save_oauth_authorization_code",
+		"user_id":   authCode.UserID,
+		"client_id": authCode.ClientID,
+	})
+
+	query := `
+		INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, redirect_uri_explicit, scopes, code_challenge, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		hashToken(code), authCode.ClientID, authCode.UserID, authCode.RedirectURI, authCode.RedirectURIExplicit,
+		pq.Array(authCode.Scopes), authCode.CodeChallenge, authCode.ExpiresAt,
+	)
+	if err != nil {
+		log.WithError(err).Error("Failed to save authorization code")
+		return fmt.Errorf("failed to save authorization code: %w", err)
+	}
+
+	return nil
+}
+
+func (r *oauthRepository) ConsumeAuthorizationCode(ctx context.Context, code string) (*models.OAuthAuthorizationCode, error) {
+	log := r.log.WithContext(ctx).WithField("operation", "consume_oauth_authorization_code")
+
+	query := `
+		DELETE FROM oauth_authorization_codes
+		WHERE code_hash = $1
+		RETURNING client_id, user_id, redirect_uri, redirect_uri_explicit, scopes, code_challenge, expires_at
+	`
+
+	authCode := &models.OAuthAuthorizationCode{}
+	err := r.db.QueryRowContext(ctx, query, hashToken(code)).Scan(
+		&authCode.ClientID, &authCode.UserID, &authCode.RedirectURI, &authCode.RedirectURIExplicit,
+		pq.Array(&authCode.Scopes), &authCode.CodeChallenge, &authCode.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrOAuthCodeInvalid
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to consume authorization code")
+		return nil, fmt.Errorf("failed to consume authorization code: %w", err)
+	}
+
+	if !authCode.ExpiresAt.After(time.Now()) {
+		return nil, ErrOAuthCodeInvalid
+	}
+
+	return authCode, nil
+}
+
+// Grants
+
+func (r *oauthRepository) SaveGrant(ctx context.Context, userID, clientID string, scopes []string) (*models.OAuthGrant, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "save_oauth_grant",
+		"user_id":   userID,
+		"client_id": clientID,
+	})
+
+	query := `
+		INSERT INTO oauth_grants (user_id, client_id, scopes)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, client_id) DO UPDATE
+		SET scopes = ARRAY(SELECT DISTINCT unnest(oauth_grants.scopes || EXCLUDED.scopes) ORDER BY 1),
+			updated_at = NOW()
+		RETURNING id, scopes, last_used_at, created_at, updated_at
+	`
+
+	grant := &models.OAuthGrant{UserID: userID, ClientID: clientID}
+	var lastUsedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, userID, clientID, pq.Array(scopes)).Scan(
+		&grant.ID, pq.Array(&grant.Scopes), &lastUsedAt, &grant.CreatedAt, &grant.UpdatedAt,
+	)
+	if err != nil {
+		log.WithError(err).Error("Failed to save oauth grant")
+		return nil, fmt.Errorf("failed to save oauth grant: %w", err)
+	}
+	if lastUsedAt.Valid {
+		grant.LastUsedAt = &lastUsedAt.Time
+	}
+
+	return grant, nil
+}
+
+func (r *oauthRepository) GetGrant(ctx context.Context, userID, clientID string) (*models.OAuthGrant, error) {
+	query := `
+		SELECT g.id, g.user_id, g.client_id, c.name, g.scopes, g.last_used_at, g.created_at, g.updated_at
+		FROM oauth_grants g
+		JOIN oauth_clients c ON c.id = g.client_id
+		WHERE g.user_id = $1 AND g.client_id = $2
+	`
+
+	grant, err := scanOAuthGrant(r.db.QueryRowContext(ctx, query, userID, clientID))
+	if err == sql.ErrNoRows {
+		return nil, ErrOAuthGrantNotFound
+	}
+	if err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to get oauth grant")
+		return nil, fmt.Errorf("failed to get oauth grant: %w", err)
+	}
+
+	return grant, nil
+}
+
+// ListGrants returns the apps the user has authorized, most recently
+// authorized first
+func (r *oauthRepository) ListGrants(ctx context.Context, userID string) ([]*models.OAuthGrant, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "list_oauth_grants",
+		"user_id":   userID,
+	})
+
+	query := `
+		SELECT g.id, g.user_id, g.client_id, c.name, g.scopes, g.last_used_at, g.created_at, g.updated_at
+		FROM oauth_grants g
+		JOIN oauth_clients c ON c.id = g.client_id
+		WHERE g.user_id = $1
+		ORDER BY g.updated_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to list oauth grants")
+		return nil, fmt.Errorf("failed to list oauth grants: %w", err)
+	}
+	defer rows.Close()
+
+	grants := []*models.OAuthGrant{}
+	for rows.Next() {
+		grant, err := scanOAuthGrant(rows)
+		if err != nil {
+			log.WithError(err).Error("Failed to scan oauth grant")
+			continue
+		}
+		grants = append(grants, grant)
+	}
+
+	return grants, rows.Err()
+}
+
+// DeleteGrant removes the user's consent for the app and every refresh
+// token issued under it
+func (r *oauthRepository) DeleteGrant(ctx context.Context, userID, clientID string) ([]models.OAuthAccessToken, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "delete_oauth_grant",
+		"user_id":   userID,
+		"client_id": clientID,
+	})
+
+	query := `
+		WITH deleted AS (
+			DELETE FROM oauth_grants WHERE user_id = $1 AND client_id = $2 RETURNING id, user_id
+		)
+		SELECT d.user_id, t.access_token_id, t.created_at, t.access_token_expires_at
+		FROM deleted d
+		LEFT JOIN oauth_refresh_tokens t ON t.grant_id = d.id AND t.access_token_expires_at > NOW()
+	`
+
+	found, tokens, err := r.queryAccessTokens(ctx, query, userID, clientID)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete oauth grant")
+		return nil, fmt.Errorf("failed to delete oauth grant: %w", err)
+	}
+	if !found {
+		return nil, ErrOAuthGrantNotFound
+	}
+
+	log.Info("OAuth grant deleted")
+	return tokens, nil
+}
+
+func scanOAuthGrant(row rowScanner) (*models.OAuthGrant, error) {
+	grant := &models.OAuthGrant{}
+	var lastUsedAt sql.NullTime
+
+	err := row.Scan(
+		&grant.ID, &grant.UserID, &grant.ClientID, &grant.ClientName, pq.Array(&grant.Scopes),
+		&lastUsedAt, &grant.CreatedAt, &grant.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastUsedAt.Valid {
+		grant.LastUsedAt = &lastUsedAt.Time
+	}
+	return grant, nil
+}
+
+// Refresh tokens
+
+func (r *oauthRepository) StoreRefreshToken(ctx context.Context, grantID, token string, scopes []string, access models.OAuthAccessToken, expiresIn time.Duration) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "store_oauth_refresh_token",
+		"grant_id":  grantID,
+	})
+
+	query := `
+		WITH touched AS (
+			UPDATE oauth_grants SET last_used_at = NOW() WHERE id = $1
+		)
+		INSERT INTO oauth_refresh_tokens (grant_id, family_id, token_hash, scopes, access_token_id, access_token_expires_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		grantID, uuid.New().String(), hashToken(token), pq.Array(scopes),
+		access.ID, access.ExpiresAt, time.Now().Add(expiresIn),
+	)
+	if err != nil {
+		log.WithError(err).Error("Failed to store oauth refresh token")
+		return fmt.Errorf("failed to store oauth refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// GetRefreshToken returns the token whether or not it has been revoked, so
+// that callers can spot reuse. Expired tokens are not found.
+func (r *oauthRepository) GetRefreshToken(ctx context.Context, token string) (*models.OAuthRefreshToken, error) {
+	query := `
+		SELECT t.id, t.grant_id, t.family_id, g.user_id, g.client_id, t.scopes, t.expires_at, t.revoked_at, t.created_at
+		FROM oauth_refresh_tokens t
+		JOIN oauth_grants g ON g.id = t.grant_id
+		WHERE t.token_hash = $1 AND t.expires_at > NOW()
+	`
+
+	refreshToken := &models.OAuthRefreshToken{}
+	var revokedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, hashToken(token)).Scan(
+		&refreshToken.ID, &refreshToken.GrantID, &refreshToken.FamilyID, &refreshToken.UserID, &refreshToken.ClientID,
+		pq.Array(&refreshToken.Scopes), &refreshToken.ExpiresAt, &revokedAt, &refreshToken.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrOAuthRefreshTokenNotFound
+	}
+	if err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to get oauth refresh token")
+		return nil, fmt.Errorf("failed to get oauth refresh token: %w", err)
+	}
+
+	if revokedAt.Valid {
+		refreshToken.RevokedAt = &revokedAt.Time
+	}
+	return refreshToken, nil
+}
+
+func (r *oauthRepository) RotateRefreshToken(ctx context.Context, oldToken, newToken string, access models.OAuthAccessToken, expiresIn time.Duration) error {
+	log := r.log.WithContext(ctx).WithField("operation", "rotate_oauth_refresh_token")
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.WithError(err).Error("Failed to begin transaction")
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		id, grantID, familyID string
+		scopes                []string
+		expiresAt             time.Time
+		revokedAt             sql.NullTime
+	)
+	query := `
+		SELECT id, grant_id, family_id, scopes, expires_at, revoked_at
+		FROM oauth_refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`
+	err = tx.QueryRowContext(ctx, query, hashToken(oldToken)).Scan(
+		&id, &grantID, &familyID, pq.Array(&scopes), &expiresAt, &revokedAt,
+	)
+	if err == sql.ErrNoRows {
+		return ErrOAuthRefreshTokenNotFound
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to load oauth refresh token")
+		return fmt.Errorf("failed to load oauth refresh token: %w", err)
+	}
+
+	now := time.Now()
+	if revokedAt.Valid {
+		return ErrOAuthRefreshTokenReused
+	}
+	if !expiresAt.After(now) {
+		return ErrOAuthRefreshTokenNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE oauth_refresh_tokens SET revoked_at = $1 WHERE id = $2`, now, id); err != nil {
+		log.WithError(err).Error("Failed to revoke rotated oauth refresh token")
+		return fmt.Errorf("failed to revoke oauth refresh token: %w", err)
+	}
+
+	insert := `
+		INSERT INTO oauth_refresh_tokens (grant_id, family_id, token_hash, scopes, access_token_id, access_token_expires_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err = tx.ExecContext(ctx, insert,
+		grantID, familyID, hashToken(newToken), pq.Array(scopes),
+		access.ID, access.ExpiresAt, now.Add(expiresIn),
+	)
+	if err != nil {
+		log.WithError(err).Error("Failed to store rotated oauth refresh token")
+		return fmt.Errorf("failed to store oauth refresh token: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE oauth_grants SET last_used_at = $1 WHERE id = $2`, now, grantID); err != nil {
+		log.WithError(err).Error("Failed to update oauth grant last used")
+		return fmt.Errorf("failed to update oauth grant: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithError(err).Error("Failed to commit oauth refresh token rotation")
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *oauthRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string) ([]models.OAuthAccessToken, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "revoke_oauth_refresh_token_family",
+		"family_id": familyID,
+	})
+
+	query := `
+		WITH revoked AS (
+			UPDATE oauth_refresh_tokens SET revoked_at = NOW()
+			WHERE family_id = $1 AND revoked_at IS NULL
+		)
+		SELECT g.user_id, t.access_token_id, t.created_at, t.access_token_expires_at
+		FROM oauth_refresh_tokens t
+		JOIN oauth_grants g ON g.id = t.grant_id
+		WHERE t.family_id = $1 AND t.access_token_expires_at > NOW()
+	`
+
+	_, tokens, err := r.queryAccessTokens(ctx, query, familyID)
+	if err != nil {
+		log.WithError(err).Error("Failed to revoke oauth refresh token family")
+		return nil, fmt.Errorf("failed to revoke oauth refresh token family: %w", err)
+	}
+
+	return tokens, nil
+}
+
+func (r *oauthRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	log := r.log.WithContext(ctx).WithField("operation", "delete_expired_oauth_records")
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM oauth_authorization_codes WHERE expires_at < NOW()`)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete expired authorization codes")
+		return 0, fmt.Errorf("failed to delete expired authorization codes: %w", err)
+	}
+	codes, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	query := `
+		DELETE FROM oauth_refresh_tokens
+		WHERE expires_at < NOW() AND access_token_expires_at < NOW()
+	`
+	result, err = r.db.ExecContext(ctx, query)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete expired oauth refresh tokens")
+		return 0, fmt.Errorf("failed to delete expired oauth refresh tokens: %w", err)
+	}
+	tokens, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return codes + tokens, nil
+}
+
+// queryAccessTokens runs a query selecting user_id, access_token_id,
+// created_at and access_token_expires_at, where the token columns may be
+// NULL from an outer join. found reports whether any row came back at all.
+func (r *oauthRepository) queryAccessTokens(ctx context.Context, query string, args ...interface{}) (bool, []models.OAuthAccessToken, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return false, nil, err
+	}
+	defer rows.Close()
+
+	found := false
+	tokens := []models.OAuthAccessToken{}
+	for rows.Next() {
+		found = true
+
+		var (
+			userID, tokenID     sql.NullString
+			issuedAt, expiresAt sql.NullTime
+		)
+		if err := rows.Scan(&userID, &tokenID, &issuedAt, &expiresAt); err != nil {
+			return false, nil, err
+		}
+		if !tokenID.Valid {
+			continue
+		}
+
+		tokens = append(tokens, models.OAuthAccessToken{
+			ID:        tokenID.String,
+			UserID:    userID.String,
+			IssuedAt:  issuedAt.Time,
+			ExpiresAt: expiresAt.Time,
+		})
+	}
+
+	return found, tokens, rows.Err()
+}
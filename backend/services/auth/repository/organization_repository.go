@@ -0,0 +1,633 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+)
+
+var (
+	// ErrOrganizationNotFound is returned when no organization has the ID
+	ErrOrganizationNotFound = errors.New("organization not found")
+	// ErrMemberNotFound is returned when the user doesn't belong to the
+	// organization
+	ErrMemberNotFound = errors.New("organization member not found")
+	// ErrLastOwner is returned instead of demoting or removing an
+	// organization's only owner
+	ErrLastOwner = errors.New("organization must keep an owner")
+	// ErrAlreadyMember is returned when accepting an invitation to an
+	// organization the user already belongs to
+	ErrAlreadyMember = errors.New("already a member of the organization")
+	// ErrInvitationNotFound is returned for unknown and expired invitations,
+	// and for invitations addressed to someone else
+	ErrInvitationNotFound = errors.New("invitation not found")
+)
+
+// OrganizationRepository stores organizations, their members and
+// outstanding invitations, and which organization each session has switched
+// to. Invitation tokens are stored hashed.
+type OrganizationRepository interface {
+	// Create stores the organization with ownerID as its first owner
+	Create(ctx context.Context, org *models.Organization, ownerID string) error
+	// Get returns the organization with the user's role in it, or
+	// ErrOrganizationNotFound if the user isn't a member
+	Get(ctx context.Context, orgID, userID string) (*models.Organization, error)
+	ListForUser(ctx context.Context, userID string) ([]*models.Organization, error)
+	UpdateName(ctx context.Context, orgID, name string) error
+	// Delete removes the organization and returns the IDs of the users who
+	// were its members
+	Delete(ctx context.Context, orgID string) ([]string, error)
+
+	GetMember(ctx context.Context, orgID, userID string) (*models.OrganizationMember, error)
+	ListMembers(ctx context.Context, orgID string) ([]*models.OrganizationMember, error)
+	HasMemberWithEmail(ctx context.Context, orgID, email string) (bool, error)
+	UpdateMemberRole(ctx context.Context, orgID, userID, role string) error
+	// RemoveMember also moves the user's sessions in the organization back
+	// to their personal workspace
+	RemoveMember(ctx context.Context, orgID, userID string) error
+
+	// SaveInvitation stores an invitation, replacing any outstanding one
+	// for the same address
+	SaveInvitation(ctx context.Context, invitation *models.OrganizationInvitation, token string) error
+	ListInvitations(ctx context.Context, orgID string) ([]*models.OrganizationInvitation, error)
+	DeleteInvitation(ctx context.Context, orgID, invitationID string) error
+	// AcceptInvitation adds the user to the organization the token invites
+	// email to and deletes the invitation. Invitations for another address
+	// are left in place.
+	AcceptInvitation(ctx context.Context, token, userID, email string) (*models.OrganizationInvitation, error)
+	DeleteExpiredInvitations(ctx context.Context) (int64, error)
+
+	// SetSessionOrganization switches a live session to orgID, or back to
+	// the personal workspace when orgID is empty
+	SetSessionOrganization(ctx context.Context, userID, sessionID, orgID string) error
+}
+
+type organizationRepository struct {
+	db  *sql.DB
+	log logger.Logger
+}
+
+func NewOrganizationRepository(db *sql.DB, log logger.Logger) OrganizationRepository {
+	return &organizationRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Organizations
+
+func (r *organizationRepository) Create(ctx context.Context, org *models.Organization, ownerID string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "create_organization",
+		"user_id":   ownerID,
+	})
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.WithError(err).Error("Failed to begin transaction")
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO organizations (name, created_by)
+		VALUES ($1, $2)
+		RETURNING id, created_at, updated_at
+	`
+	if err := tx.QueryRowContext(ctx, query, org.Name, ownerID).Scan(&org.ID, &org.CreatedAt, &org.UpdatedAt); err != nil {
+		log.WithError(err).Error("Failed to create organization")
+		return fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	member := `INSERT INTO organization_members (org_id, user_id, role) VALUES ($1, $2, 'owner')`
+	if _, err := tx.ExecContext(ctx, member, org.ID, ownerID); err != nil {
+		log.WithError(err).Error("Failed to add organization owner")
+		return fmt.Errorf("failed to add organization owner: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithError(err).Error("Failed to commit organization")
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	org.Role = "owner"
+	log.WithField("org_id", org.ID).Info("Organization created")
+	return nil
+}
+
+func (r *organizationRepository) Get(ctx context.Context, orgID, userID string) (*models.Organization, error) {
+	query := `
+		SELECT o.id, o.name, m.role, o.created_at, o.updated_at
+		FROM organizations o
+		JOIN organization_members m ON m.org_id = o.id
+		WHERE o.id = $1 AND m.user_id = $2
+	`
+
+	org := &models.Organization{}
+	err := r.db.QueryRowContext(ctx, query, orgID, userID).Scan(&org.ID, &org.Name, &org.Role, &org.CreatedAt, &org.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to get organization")
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return org, nil
+}
+
+func (r *organizationRepository) ListForUser(ctx context.Context, userID string) ([]*models.Organization, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "list_organizations",
+		"user_id":   userID,
+	})
+
+	query := `
+		SELECT o.id, o.name, m.role, o.created_at, o.updated_at
+		FROM organizations o
+		JOIN organization_members m ON m.org_id = o.id
+		WHERE m.user_id = $1
+		ORDER BY o.name, o.created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to list organizations")
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	defer rows.Close()
+
+	orgs := []*models.Organization{}
+	for rows.Next() {
+		org := &models.Organization{}
+		if err := rows.Scan(&org.ID, &org.Name, &org.Role, &org.CreatedAt, &org.UpdatedAt); err != nil {
+			log.WithError(err).Error("Failed to scan organization")
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		orgs = append(orgs, org)
+	}
+
+	return orgs, rows.Err()
+}
+
+func (r *organizationRepository) UpdateName(ctx context.Context, orgID, name string) error {
+	query := `UPDATE organizations SET name = $1, updated_at = $2 WHERE id = $3`
+
+	result, err := r.db.ExecContext(ctx, query, name, time.Now(), orgID)
+	if err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to rename organization")
+		return fmt.Errorf("failed to update organization: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return ErrOrganizationNotFound
+	}
+
+	return nil
+}
+
+func (r *organizationRepository) Delete(ctx context.Context, orgID string) ([]string, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "delete_organization",
+		"org_id":    orgID,
+	})
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.WithError(err).Error("Failed to begin transaction")
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT user_id FROM organization_members WHERE org_id = $1 FOR UPDATE`, orgID)
+	if err != nil {
+		log.WithError(err).Error("Failed to load organization members")
+		return nil, fmt.Errorf("failed to load organization members: %w", err)
+	}
+	var memberIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan member: %w", err)
+		}
+		memberIDs = append(memberIDs, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load organization members: %w", err)
+	}
+
+	// Members and invitations go with it; sessions in it fall back to the
+	// personal workspace
+	result, err := tx.ExecContext(ctx, `DELETE FROM organizations WHERE id = $1`, orgID)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete organization")
+		return nil, fmt.Errorf("failed to delete organization: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if deleted == 0 {
+		return nil, ErrOrganizationNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithError(err).Error("Failed to commit organization deletion")
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.WithField("members", len(memberIDs)).Info("Organization deleted")
+	return memberIDs, nil
+}
+
+// Members
+
+func (r *organizationRepository) GetMember(ctx context.Context, orgID, userID string) (*models.OrganizationMember, error) {
+	query := `
+		SELECT m.org_id, m.user_id, u.email, u.name, m.role, m.created_at, m.updated_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.org_id = $1 AND m.user_id = $2
+	`
+
+	member, err := scanOrganizationMember(r.db.QueryRowContext(ctx, query, orgID, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrMemberNotFound
+	}
+	if err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to get organization member")
+		return nil, fmt.Errorf("failed to get organization member: %w", err)
+	}
+
+	return member, nil
+}
+
+func (r *organizationRepository) ListMembers(ctx context.Context, orgID string) ([]*models.OrganizationMember, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "list_organization_members",
+		"org_id":    orgID,
+	})
+
+	query := `
+		SELECT m.org_id, m.user_id, u.email, u.name, m.role, m.created_at, m.updated_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.org_id = $1
+		ORDER BY m.created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, orgID)
+	if err != nil {
+		log.WithError(err).Error("Failed to list organization members")
+		return nil, fmt.Errorf("failed to list organization members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []*models.OrganizationMember{}
+	for rows.Next() {
+		member, err := scanOrganizationMember(rows)
+		if err != nil {
+			log.WithError(err).Error("Failed to scan organization member")
+			return nil, fmt.Errorf("failed to scan organization member: %w", err)
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+func (r *organizationRepository) HasMemberWithEmail(ctx context.Context, orgID, email string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM organization_members m
+			JOIN users u ON u.id = m.user_id
+			WHERE m.org_id = $1 AND LOWER(u.email) = LOWER($2)
+		)
+	`
+
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, query, orgID, email).Scan(&exists); err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to look up organization member")
+		return false, fmt.Errorf("failed to look up organization member: %w", err)
+	}
+
+	return exists, nil
+}
+
+func (r *organizationRepository) UpdateMemberRole(ctx context.Context, orgID, userID, role string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "update_organization_member_role",
+		"org_id":    orgID,
+		"user_id":   userID,
+		"role":      role,
+	})
+
+	err := r.changeMember(ctx, orgID, userID, role != "owner", func(tx *sql.Tx) error {
+		query := `UPDATE organization_members SET role = $1, updated_at = $2 WHERE org_id = $3 AND user_id = $4`
+		_, err := tx.ExecContext(ctx, query, role, time.Now(), orgID, userID)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrMemberNotFound) && !errors.Is(err, ErrLastOwner) {
+		log.WithError(err).Error("Failed to update organization member role")
+		return fmt.Errorf("failed to update member role: %w", err)
+	}
+
+	return err
+}
+
+func (r *organizationRepository) RemoveMember(ctx context.Context, orgID, userID string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "remove_organization_member",
+		"org_id":    orgID,
+		"user_id":   userID,
+	})
+
+	err := r.changeMember(ctx, orgID, userID, true, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM organization_members WHERE org_id = $1 AND user_id = $2`, orgID, userID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET org_id = NULL WHERE user_id = $1 AND org_id = $2`, userID, orgID)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrMemberNotFound) && !errors.Is(err, ErrLastOwner) {
+		log.WithError(err).Error("Failed to remove organization member")
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+
+	return err
+}
+
+// changeMember runs change against a member of the organization, holding
+// the organization's row lock so that concurrent changes can't leave it
+// without an owner. If demotesOwner is set and the member is the last owner,
+// the change is refused with ErrLastOwner.
+func (r *organizationRepository) changeMember(ctx context.Context, orgID, userID string, demotesOwner bool, change func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM organizations WHERE id = $1 FOR UPDATE`, orgID).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return ErrMemberNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var role string
+	err = tx.QueryRowContext(ctx, `SELECT role FROM organization_members WHERE org_id = $1 AND user_id = $2`, orgID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return ErrMemberNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if role == "owner" && demotesOwner {
+		var owners int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM organization_members WHERE org_id = $1 AND role = 'owner'`, orgID).Scan(&owners); err != nil {
+			return err
+		}
+		if owners <= 1 {
+			return ErrLastOwner
+		}
+	}
+
+	if err := change(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func scanOrganizationMember(row rowScanner) (*models.OrganizationMember, error) {
+	member := &models.OrganizationMember{}
+	var name sql.NullString
+
+	err := row.Scan(&member.OrgID, &member.UserID, &member.Email, &name, &member.Role, &member.CreatedAt, &member.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	member.Name = name.String
+	return member, nil
+}
+
+// Invitations
+
+func (r *organizationRepository) SaveInvitation(ctx context.Context, invitation *models.OrganizationInvitation, token string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "save_organization_invitation",
+		"org_id":    invitation.OrgID,
+	})
+
+	query := `
+		INSERT INTO organization_invitations (org_id, email, role, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (org_id, email) DO UPDATE SET
+			role = EXCLUDED.role,
+			token_hash = EXCLUDED.token_hash,
+			invited_by = EXCLUDED.invited_by,
+			expires_at = EXCLUDED.expires_at,
+			created_at = CURRENT_TIMESTAMP
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		invitation.OrgID, invitation.Email, invitation.Role, hashToken(token), invitation.InvitedBy, invitation.ExpiresAt,
+	).Scan(&invitation.ID, &invitation.CreatedAt)
+	if err != nil {
+		log.WithError(err).Error("Failed to save organization invitation")
+		return fmt.Errorf("failed to save invitation: %w", err)
+	}
+
+	return nil
+}
+
+func (r *organizationRepository) ListInvitations(ctx context.Context, orgID string) ([]*models.OrganizationInvitation, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "list_organization_invitations",
+		"org_id":    orgID,
+	})
+
+	query := `
+		SELECT id, org_id, email, role, invited_by, expires_at, created_at
+		FROM organization_invitations
+		WHERE org_id = $1 AND expires_at > $2
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, orgID, time.Now())
+	if err != nil {
+		log.WithError(err).Error("Failed to list organization invitations")
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := []*models.OrganizationInvitation{}
+	for rows.Next() {
+		invitation, err := scanOrganizationInvitation(rows)
+		if err != nil {
+			log.WithError(err).Error("Failed to scan organization invitation")
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+func (r *organizationRepository) DeleteInvitation(ctx context.Context, orgID, invitationID string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM organization_invitations WHERE id = $1 AND org_id = $2`, invitationID, orgID)
+	if err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to delete organization invitation")
+		return fmt.Errorf("failed to delete invitation: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return ErrInvitationNotFound
+	}
+
+	return nil
+}
+
+func (r *organizationRepository) AcceptInvitation(ctx context.Context, token, userID, email string) (*models.OrganizationInvitation, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "accept_organization_invitation",
+		"user_id":   userID,
+	})
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.WithError(err).Error("Failed to begin transaction")
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT id, org_id, email, role, invited_by, expires_at, created_at
+		FROM organization_invitations
+		WHERE token_hash = $1 AND expires_at > $2
+		FOR UPDATE
+	`
+	invitation, err := scanOrganizationInvitation(tx.QueryRowContext(ctx, query, hashToken(token), time.Now()))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to load organization invitation")
+		return nil, fmt.Errorf("failed to load invitation: %w", err)
+	}
+	if !strings.EqualFold(invitation.Email, email) {
+		return nil, ErrInvitationNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM organization_invitations WHERE id = $1`, invitation.ID); err != nil {
+		log.WithError(err).Error("Failed to delete organization invitation")
+		return nil, fmt.Errorf("failed to delete invitation: %w", err)
+	}
+
+	member := `
+		INSERT INTO organization_members (org_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (org_id, user_id) DO NOTHING
+	`
+	result, err := tx.ExecContext(ctx, member, invitation.OrgID, userID, invitation.Role)
+	if err != nil {
+		log.WithError(err).Error("Failed to add organization member")
+		return nil, fmt.Errorf("failed to add member: %w", err)
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	// The invitation is used up either way
+	if err := tx.Commit(); err != nil {
+		log.WithError(err).Error("Failed to commit invitation acceptance")
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if added == 0 {
+		return invitation, ErrAlreadyMember
+	}
+
+	log.WithField("org_id", invitation.OrgID).Info("Organization invitation accepted")
+	return invitation, nil
+}
+
+func (r *organizationRepository) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM organization_invitations WHERE expires_at <= $1`, time.Now())
+	if err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to delete expired organization invitations")
+		return 0, fmt.Errorf("failed to delete expired invitations: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+func scanOrganizationInvitation(row rowScanner) (*models.OrganizationInvitation, error) {
+	invitation := &models.OrganizationInvitation{}
+	var invitedBy sql.NullString
+
+	err := row.Scan(
+		&invitation.ID, &invitation.OrgID, &invitation.Email, &invitation.Role,
+		&invitedBy, &invitation.ExpiresAt, &invitation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if invitedBy.Valid {
+		invitation.InvitedBy = &invitedBy.String
+	}
+	return invitation, nil
+}
+
+// Sessions
+
+func (r *organizationRepository) SetSessionOrganization(ctx context.Context, userID, sessionID, orgID string) error {
+	query := `
+		UPDATE refresh_tokens
+		SET org_id = $1
+		WHERE user_id = $2
+		AND family_id = $3
+		AND revoked_at IS NULL
+		AND expires_at > $4
+	`
+
+	result, err := r.db.ExecContext(ctx, query, nullString(orgID), userID, sessionID, time.Now())
+	if err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to switch session organization")
+		return fmt.Errorf("failed to switch session organization: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+)
+
+type OutboxRepository interface {
+	Enqueue(ctx context.Context, email *models.OutboxEmail) error
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxEmail, error)
+	MarkSent(ctx context.Context, id string) error
+	MarkRetry(ctx context.Context, id, lastError string, nextAttemptAt time.Time) error
+	MarkFailed(ctx context.Context, id, lastError string) error
+}
+
+type outboxRepository struct {
+	db  *sql.DB
+	log logger.Logger
+}
+
+func NewOutboxRepository(db *sql.DB, log logger.Logger) OutboxRepository {
+	return &outboxRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *outboxRepository) Enqueue(ctx context.Context, email *models.OutboxEmail) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "enqueue_email",
+		"template":  email.Template,
+	})
+
+	email.ID = uuid.New().String()
+	email.Status = "pending"
+	email.CreatedAt = time.Now()
+	email.NextAttemptAt = email.CreatedAt
+
+	query := `
+		INSERT INTO email_outbox (
+			id, to_address, template, subject, text_body, html_body,
+			status, next_attempt_at, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+	`
+
+	_, err := r.db.ExecContext(
+		ctx, query,
+		email.ID, email.ToAddress, email.Template, email.Subject,
+		email.TextBody, email.HTMLBody, email.Status,
+		email.NextAttemptAt, email.CreatedAt,
+	)
+
+	if err != nil {
+		log.WithError(err).Error("Failed to enqueue email")
+		return fmt.Errorf("failed to enqueue email: %w", err)
+	}
+
+	log.WithField("email_id", email.ID).Debug("Email enqueued")
+	return nil
+}
+
+// ClaimDue locks up to limit pending emails that are due for delivery and
+// pushes their next attempt out by lease, so concurrent workers (or a worker
+// that crashes mid-send) never deliver the same email twice within the lease.
+func (r *outboxRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxEmail, error) {
+	log := r.log.WithContext(ctx).WithField("operation", "claim_due_emails")
+
+	now := time.Now()
+	query := `
+		UPDATE email_outbox
+		SET attempts = attempts + 1, next_attempt_at = $1, updated_at = $2
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE status = 'pending' AND next_attempt_at <= $2
+			ORDER BY next_attempt_at ASC
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, to_address, template, subject, text_body, html_body,
+			status, attempts, last_error, next_attempt_at, sent_at, created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, now.Add(lease), now, limit)
+	if err != nil {
+		log.WithError(err).Error("Failed to claim due emails")
+		return nil, fmt.Errorf("failed to claim due emails: %w", err)
+	}
+	defer rows.Close()
+
+	var emails []*models.OutboxEmail
+	for rows.Next() {
+		var email models.OutboxEmail
+		if err := rows.Scan(
+			&email.ID, &email.ToAddress, &email.Template, &email.Subject,
+			&email.TextBody, &email.HTMLBody, &email.Status, &email.Attempts,
+			&email.LastError, &email.NextAttemptAt, &email.SentAt, &email.CreatedAt,
+		); err != nil {
+			log.WithError(err).Error("Failed to scan outbox email")
+			continue
+		}
+		emails = append(emails, &email)
+	}
+
+	return emails, nil
+}
+
+// MarkSent and MarkFailed clear the rendered bodies: they carry single-use
+// links that have no business outliving delivery. The row itself stays as a
+// record of what was sent to whom.
+func (r *outboxRepository) MarkSent(ctx context.Context, id string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "mark_email_sent",
+		"email_id":  id,
+	})
+
+	query := `
+		UPDATE email_outbox 
+		SET status = 'sent', sent_at = $1, last_error = NULL,
+			text_body = '', html_body = NULL, updated_at = $1
+		WHERE id = $2
+	`
+
+	_, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		log.WithError(err).Error("Failed to mark email as sent")
+		return fmt.Errorf("failed to mark email as sent: %w", err)
+	}
+
+	return nil
+}
+
+func (r *outboxRepository) MarkRetry(ctx context.Context, id, lastError string, nextAttemptAt time.Time) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "mark_email_retry",
+		"email_id":  id,
+	})
+
+	query := `
+		UPDATE email_outbox 
+		SET last_error = $1, next_attempt_at = $2, updated_at = $3
+		WHERE id = $4
+	`
+
+	_, err := r.db.ExecContext(ctx, query, lastError, nextAttemptAt, time.Now(), id)
+	if err != nil {
+		log.WithError(err).Error("Failed to schedule email retry")
+		return fmt.Errorf("failed to schedule email retry: %w", err)
+	}
+
+	return nil
+}
+
+func (r *outboxRepository) MarkFailed(ctx context.Context, id, lastError string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "mark_email_failed",
+		"email_id":  id,
+	})
+
+	query := `
+		UPDATE email_outbox 
+		SET status = 'failed', last_error = $1,
+			text_body = '', html_body = NULL, updated_at = $2
+		WHERE id = $3
+	`
+
+	_, err := r.db.ExecContext(ctx, query, lastError, time.Now(), id)
+	if err != nil {
+		log.WithError(err).Error("Failed to mark email as failed")
+		return fmt.Errorf("failed to mark email as failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+)
+
+type PasswordHistoryRepository interface {
+	Add(ctx context.Context, userID, passwordHash string, keep int) error
+	Recent(ctx context.Context, userID string, limit int) ([]string, error)
+}
+
+type passwordHistoryRepository struct {
+	db  *sql.DB
+	log logger.Logger
+}
+
+func NewPasswordHistoryRepository(db *sql.DB, log logger.Logger) PasswordHistoryRepository {
+	return &passwordHistoryRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Add records a password hash and drops all but the keep most recent
+func (r *passwordHistoryRepository) Add(ctx context.Context, userID, passwordHash string, keep int) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "add_password_history",
+		"user_id":   userID,
+	})
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.WithError(err).Error("Failed to begin transaction")
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO password_history (user_id, password_hash) VALUES ($1, $2)`,
+		userID, passwordHash,
+	); err != nil {
+		log.WithError(err).Error("Failed to add password history")
+		return fmt.Errorf("failed to add password history: %w", err)
+	}
+
+	query := `
+		DELETE FROM password_history
+		WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM password_history
+			WHERE user_id = $1
+			ORDER BY created_at DESC
+			LIMIT $2
+		)
+	`
+	if _, err := tx.ExecContext(ctx, query, userID, keep); err != nil {
+		log.WithError(err).Error("Failed to trim password history")
+		return fmt.Errorf("failed to trim password history: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithError(err).Error("Failed to commit password history")
+		return fmt.Errorf("failed to commit password history: %w", err)
+	}
+
+	return nil
+}
+
+// Recent returns the user's most recent password hashes, newest first
+func (r *passwordHistoryRepository) Recent(ctx context.Context, userID string, limit int) ([]string, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "get_password_history",
+		"user_id":   userID,
+	})
+
+	query := `
+		SELECT password_hash FROM password_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		log.WithError(err).Error("Failed to get password history")
+		return nil, fmt.Errorf("failed to get password history: %w", err)
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("failed to scan password history: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
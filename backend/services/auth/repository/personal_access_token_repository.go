@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+)
+
+// ErrPersonalAccessTokenNotFound is returned when no token matches
+var ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+
+type PersonalAccessTokenRepository interface {
+	Create(ctx context.Context, token *models.PersonalAccessToken, tokenHash string) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error)
+	ListByUser(ctx context.Context, userID string) ([]*models.PersonalAccessToken, error)
+	Delete(ctx context.Context, userID, id string) error
+	TouchLastUsed(ctx context.Context, id string) error
+}
+
+type personalAccessTokenRepository struct {
+	db  *sql.DB
+	log logger.Logger
+}
+
+func NewPersonalAccessTokenRepository(db *sql.DB, log logger.Logger) PersonalAccessTokenRepository {
+	return &personalAccessTokenRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *personalAccessTokenRepository) Create(ctx context.Context, token *models.PersonalAccessToken, tokenHash string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "create_personal_access_token",
+		"user_id":   token.UserID,
+	})
+
+	query := `
+		INSERT INTO personal_access_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		token.UserID, token.Name, tokenHash, token.Prefix, pq.Array(token.Scopes), token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		log.WithError(err).Error("Failed to create personal access token")
+		return fmt.Errorf("failed to create personal access token: %w", err)
+	}
+
+	log.WithField("token_id", token.ID).Info("Personal access token created")
+	return nil
+}
+
+func (r *personalAccessTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	query := `
+		SELECT id, user_id, name, token_prefix, scopes, expires_at, last_used_at, created_at
+		FROM personal_access_tokens
+		WHERE token_hash = $1
+	`
+
+	token, err := scanPersonalAccessToken(r.db.QueryRowContext(ctx, query, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, ErrPersonalAccessTokenNotFound
+	}
+	if err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to get personal access token")
+		return nil, fmt.Errorf("failed to get personal access token: %w", err)
+	}
+
+	return token, nil
+}
+
+func (r *personalAccessTokenRepository) ListByUser(ctx context.Context, userID string) ([]*models.PersonalAccessToken, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "list_personal_access_tokens",
+		"user_id":   userID,
+	})
+
+	query := `
+		SELECT id, user_id, name, token_prefix, scopes, expires_at, last_used_at, created_at
+		FROM personal_access_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to list personal access tokens")
+		return nil, fmt.Errorf("failed to list personal access tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []*models.PersonalAccessToken{}
+	for rows.Next() {
+		token, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			log.WithError(err).Error("Failed to scan personal access token")
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (r *personalAccessTokenRepository) Delete(ctx context.Context, userID, id string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "delete_personal_access_token",
+		"user_id":   userID,
+		"token_id":  id,
+	})
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM personal_access_tokens WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete personal access token")
+		return fmt.Errorf("failed to delete personal access token: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return ErrPersonalAccessTokenNotFound
+	}
+
+	log.Info("Personal access token deleted")
+	return nil
+}
+
+// TouchLastUsed records that the token was just used. Writes are skipped if
+// it was already marked within the last minute, so busy scripts don't write
+// on every request.
+func (r *personalAccessTokenRepository) TouchLastUsed(ctx context.Context, id string) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to update token last used: %w", err)
+	}
+
+	return nil
+}
+
+func scanPersonalAccessToken(row rowScanner) (*models.PersonalAccessToken, error) {
+	token := &models.PersonalAccessToken{}
+	var expiresAt, lastUsedAt sql.NullTime
+
+	err := row.Scan(
+		&token.ID, &token.UserID, &token.Name, &token.Prefix, pq.Array(&token.Scopes),
+		&expiresAt, &lastUsedAt, &token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+
+	return token, nil
+}
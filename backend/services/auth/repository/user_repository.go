@@ -2,12 +2,16 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
 	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
 )
@@ -18,18 +22,53 @@ type UserRepository interface {
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
 	UpdateLastLogin(ctx context.Context, userID string) error
 	UpdatePassword(ctx context.Context, userID, passwordHash string) error
+	ReplacePasswordHash(ctx context.Context, userID, oldHash, newHash string) error
 	MarkEmailVerified(ctx context.Context, userID string) error
+	SetActive(ctx context.Context, userID string, active bool) error
+	UpdateProfile(ctx context.Context, user *models.User) error
+
+	// Administration
+	Search(ctx context.Context, filter models.UserFilter) ([]*models.User, int, error)
+	SetRoles(ctx context.Context, userID string, roles []string) error
+	AddRoleByEmail(ctx context.Context, email, role string) (bool, error)
+	RequirePasswordReset(ctx context.Context, userID string) error
+
+	// Account deletion
+	ScheduleDeletion(ctx context.Context, userID string, at time.Time) error
+	CancelDeletion(ctx context.Context, userID string) (bool, error)
+	ListDueDeletions(ctx context.Context, now time.Time, limit int) ([]*models.User, error)
+	Delete(ctx context.Context, userID string) error
 
 	// Refresh token management
-	StoreRefreshToken(ctx context.Context, userID, token string, expiresIn time.Duration) error
+	StoreRefreshToken(ctx context.Context, userID, token string, client models.ClientInfo, expiresIn time.Duration) (string, error)
+	RotateRefreshToken(ctx context.Context, userID, oldToken, newToken string, client models.ClientInfo, expiresIn time.Duration) (familyID, orgID string, err error)
 	ValidateRefreshToken(ctx context.Context, userID, token string) (bool, error)
 	RevokeRefreshToken(ctx context.Context, userID, token string) error
+	RevokeRefreshTokenFamily(ctx context.Context, userID, familyID string) error
+	RevokeOtherRefreshTokens(ctx context.Context, userID, keepFamilyID string) ([]string, error)
 	RevokeAllRefreshTokens(ctx context.Context, userID string) error
 
+	// Sessions (one per refresh token family)
+	ListSessions(ctx context.Context, userID string) ([]*models.Session, error)
+
 	// Password reset
 	StorePasswordResetToken(ctx context.Context, userID, token string, expiresAt time.Time) error
 	ValidatePasswordResetToken(ctx context.Context, token string) (string, error)
+	PeekPasswordResetToken(ctx context.Context, token string) (string, error)
 	DeletePasswordResetToken(ctx context.Context, token string) error
+
+	// Email verification
+	StoreEmailVerificationToken(ctx context.Context, userID, token string, expiresAt time.Time) error
+	ValidateEmailVerificationToken(ctx context.Context, token string) (string, error)
+	DeleteEmailVerificationToken(ctx context.Context, token string) error
+
+	// Email change
+	StoreEmailChangeToken(ctx context.Context, userID, newEmail, token string, expiresAt time.Time) error
+	ConfirmEmailChange(ctx context.Context, token string) (userID, previousEmail string, err error)
+
+	// Magic link sign-in
+	StoreMagicLinkToken(ctx context.Context, userID, token, deviceToken string, expiresAt time.Time, maxOutstanding int) error
+	ConsumeMagicLinkToken(ctx context.Context, token, deviceToken string) (string, error)
 }
 
 type userRepository struct {
@@ -48,11 +87,12 @@ func (r *userRepository) Create(ctx context.Context, user *models.User) (*models
 	log := r.log.WithContext(ctx).WithField("operation", "create_user")
 
 	user.ID = uuid.New().String()
+	user.Email = models.NormalizeEmail(user.Email)
 
 	query := `
-		INSERT INTO users (id, email, password_hash, name, photo_url, is_active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, created_at, updated_at
+		INSERT INTO users (id, email, password_hash, name, photo_url, is_active, email_verified, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at, roles
 	`
 
 	err := r.db.QueryRowContext(
@@ -64,9 +104,10 @@ func (r *userRepository) Create(ctx context.Context, user *models.User) (*models
 		user.Name,
 		user.PhotoURL,
 		user.IsActive,
+		user.EmailVerified,
 		user.CreatedAt,
 		user.UpdatedAt,
-	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, pq.Array(&user.Roles))
 
 	if err != nil {
 		log.WithError(err).Error("Failed to create user")
@@ -85,7 +126,8 @@ func (r *userRepository) GetByID(ctx context.Context, id string) (*models.User,
 
 	var user models.User
 	query := `
-		SELECT id, email, password_hash, name, photo_url, is_active, created_at, updated_at
+		SELECT id, email, password_hash, name, photo_url, is_active, email_verified, created_at, updated_at,
+			deletion_scheduled_at, last_login_at, roles, password_reset_required
 		FROM users
 		WHERE id = $1
 	`
@@ -97,8 +139,13 @@ func (r *userRepository) GetByID(ctx context.Context, id string) (*models.User,
 		&user.Name,
 		&user.PhotoURL,
 		&user.IsActive,
+		&user.EmailVerified,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.DeletionScheduledAt,
+		&user.LastLoginAt,
+		pq.Array(&user.Roles),
+		&user.PasswordResetRequired,
 	)
 
 	if err == sql.ErrNoRows {
@@ -121,20 +168,26 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.
 
 	var user models.User
 	query := `
-		SELECT id, email, password_hash, name, photo_url, is_active, created_at, updated_at
+		SELECT id, email, password_hash, name, photo_url, is_active, email_verified, created_at, updated_at,
+			deletion_scheduled_at, last_login_at, roles, password_reset_required
 		FROM users
 		WHERE email = $1
 	`
 
-	err := r.db.QueryRowContext(ctx, query, email).Scan(
+	err := r.db.QueryRowContext(ctx, query, models.NormalizeEmail(email)).Scan(
 		&user.ID,
 		&user.Email,
 		&user.PasswordHash,
 		&user.Name,
 		&user.PhotoURL,
 		&user.IsActive,
+		&user.EmailVerified,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.DeletionScheduledAt,
+		&user.LastLoginAt,
+		pq.Array(&user.Roles),
+		&user.PasswordResetRequired,
 	)
 
 	if err == sql.ErrNoRows {
@@ -157,7 +210,7 @@ func (r *userRepository) UpdateLastLogin(ctx context.Context, userID string) err
 
 	query := `
 		UPDATE users 
-		SET updated_at = $1 
+		SET last_login_at = $1 
 		WHERE id = $2
 	`
 
@@ -176,9 +229,10 @@ func (r *userRepository) UpdatePassword(ctx context.Context, userID, passwordHas
 		"user_id":   userID,
 	})
 
+	// Setting a password satisfies any forced reset
 	query := `
 		UPDATE users 
-		SET password_hash = $1, updated_at = $2 
+		SET password_hash = $1, password_reset_required = false, updated_at = $2 
 		WHERE id = $3
 	`
 
@@ -191,6 +245,29 @@ func (r *userRepository) UpdatePassword(ctx context.Context, userID, passwordHas
 	return nil
 }
 
+// ReplacePasswordHash swaps a hash for a new hash of the same password. It
+// does nothing if the password was changed since oldHash was read.
+func (r *userRepository) ReplacePasswordHash(ctx context.Context, userID, oldHash, newHash string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "replace_password_hash",
+		"user_id":   userID,
+	})
+
+	query := `
+		UPDATE users
+		SET password_hash = $1
+		WHERE id = $2 AND password_hash = $3
+	`
+
+	_, err := r.db.ExecContext(ctx, query, newHash, userID, oldHash)
+	if err != nil {
+		log.WithError(err).Error("Failed to replace password hash")
+		return fmt.Errorf("failed to replace password hash: %w", err)
+	}
+
+	return nil
+}
+
 func (r *userRepository) MarkEmailVerified(ctx context.Context, userID string) error {
 	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
 		"operation": "mark_email_verified",
@@ -212,36 +289,491 @@ func (r *userRepository) MarkEmailVerified(ctx context.Context, userID string) e
 	return nil
 }
 
+func (r *userRepository) SetActive(ctx context.Context, userID string, active bool) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "set_user_active",
+		"user_id":   userID,
+		"active":    active,
+	})
+
+	query := `
+		UPDATE users 
+		SET is_active = $1, updated_at = $2 
+		WHERE id = $3
+	`
+
+	_, err := r.db.ExecContext(ctx, query, active, time.Now(), userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to update user active flag")
+		return fmt.Errorf("failed to update user active flag: %w", err)
+	}
+
+	return nil
+}
+
+func (r *userRepository) UpdateProfile(ctx context.Context, user *models.User) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "update_profile",
+		"user_id":   user.ID,
+	})
+
+	query := `
+		UPDATE users 
+		SET name = $1, photo_url = $2, updated_at = $3 
+		WHERE id = $4
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, user.Name, user.PhotoURL, time.Now(), user.ID).Scan(&user.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return errors.New("user not found")
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to update profile")
+		return fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	return nil
+}
+
+// Administration
+
+// Search returns a page of users matching filter, newest first, and the
+// total number of matches
+func (r *userRepository) Search(ctx context.Context, filter models.UserFilter) ([]*models.User, int, error) {
+	log := r.log.WithContext(ctx).WithField("operation", "search_users")
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.Query != "" {
+		addCondition("(email ILIKE $%[1]d OR name ILIKE $%[1]d)", "%"+escapeLike(filter.Query)+"%")
+	}
+	if filter.Role != "" {
+		addCondition("$%d = ANY(roles)", filter.Role)
+	}
+	if filter.Active != nil {
+		addCondition("is_active = $%d", *filter.Active)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users "+where, args...).Scan(&total); err != nil {
+		log.WithError(err).Error("Failed to count users")
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, email, name, photo_url, is_active, email_verified, created_at, updated_at,
+			deletion_scheduled_at, last_login_at, roles, password_reset_required
+		FROM users
+		%s
+		ORDER BY created_at DESC, id
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
+
+	rows, err := r.db.QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		log.WithError(err).Error("Failed to search users")
+		return nil, 0, fmt.Errorf("failed to search users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		user := &models.User{}
+		if err := rows.Scan(
+			&user.ID, &user.Email, &user.Name, &user.PhotoURL, &user.IsActive, &user.EmailVerified,
+			&user.CreatedAt, &user.UpdatedAt, &user.DeletionScheduledAt, &user.LastLoginAt, pq.Array(&user.Roles),
+			&user.PasswordResetRequired,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, total, rows.Err()
+}
+
+func (r *userRepository) SetRoles(ctx context.Context, userID string, roles []string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "set_user_roles",
+		"user_id":   userID,
+	})
+
+	query := `
+		UPDATE users 
+		SET roles = $1, updated_at = $2 
+		WHERE id = $3
+	`
+
+	result, err := r.db.ExecContext(ctx, query, pq.Array(roles), time.Now(), userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to set user roles")
+		return fmt.Errorf("failed to set user roles: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// AddRoleByEmail grants role to the account with email, reporting whether
+// the account exists
+func (r *userRepository) AddRoleByEmail(ctx context.Context, email, role string) (bool, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "add_user_role",
+		"role":      role,
+	})
+
+	query := `
+		UPDATE users 
+		SET roles = CASE WHEN $1 = ANY(roles) THEN roles ELSE array_append(roles, $1) END
+		WHERE email = $2
+	`
+
+	result, err := r.db.ExecContext(ctx, query, role, models.NormalizeEmail(email))
+	if err != nil {
+		log.WithError(err).Error("Failed to add user role")
+		return false, fmt.Errorf("failed to add user role: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// RequirePasswordReset blocks password sign-in until the password is changed
+func (r *userRepository) RequirePasswordReset(ctx context.Context, userID string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "require_password_reset",
+		"user_id":   userID,
+	})
+
+	query := `
+		UPDATE users 
+		SET password_reset_required = true, updated_at = $1 
+		WHERE id = $2
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), userID); err != nil {
+		log.WithError(err).Error("Failed to require password reset")
+		return fmt.Errorf("failed to require password reset: %w", err)
+	}
+
+	return nil
+}
+
+// escapeLike makes LIKE wildcards in s match literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// Account Deletion
+
+func (r *userRepository) ScheduleDeletion(ctx context.Context, userID string, at time.Time) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "schedule_user_deletion",
+		"user_id":   userID,
+	})
+
+	query := `
+		UPDATE users 
+		SET deletion_scheduled_at = $1, updated_at = $2 
+		WHERE id = $3
+	`
+
+	_, err := r.db.ExecContext(ctx, query, at, time.Now(), userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to schedule user deletion")
+		return fmt.Errorf("failed to schedule deletion: %w", err)
+	}
+
+	return nil
+}
+
+// CancelDeletion clears a scheduled deletion that has not fallen due yet.
+// It reports false when there was nothing to cancel.
+func (r *userRepository) CancelDeletion(ctx context.Context, userID string) (bool, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "cancel_user_deletion",
+		"user_id":   userID,
+	})
+
+	query := `
+		UPDATE users 
+		SET deletion_scheduled_at = NULL, updated_at = $1 
+		WHERE id = $2 
+		AND deletion_scheduled_at > $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now(), userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to cancel user deletion")
+		return false, fmt.Errorf("failed to cancel deletion: %w", err)
+	}
+
+	cancelled, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel deletion: %w", err)
+	}
+
+	return cancelled > 0, nil
+}
+
+func (r *userRepository) ListDueDeletions(ctx context.Context, now time.Time, limit int) ([]*models.User, error) {
+	log := r.log.WithContext(ctx).WithField("operation", "list_due_deletions")
+
+	query := `
+		SELECT id, email, name, deletion_scheduled_at
+		FROM users
+		WHERE deletion_scheduled_at <= $1
+		ORDER BY deletion_scheduled_at
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, now, limit)
+	if err != nil {
+		log.WithError(err).Error("Failed to list due deletions")
+		return nil, fmt.Errorf("failed to list due deletions: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Email, &user.Name, &user.DeletionScheduledAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, &user)
+	}
+
+	return users, rows.Err()
+}
+
+// Delete removes the user; every auth table cascades from users
+func (r *userRepository) Delete(ctx context.Context, userID string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "delete_user",
+		"user_id":   userID,
+	})
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete user")
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	log.Info("User deleted from database")
+	return nil
+}
+
 // Refresh Token Management
+//
+// Refresh tokens are never stored in the clear: rows hold the SHA-256 hash of
+// the token and a family_id shared by every token descended from the same
+// sign-in. Rotation revokes the presented token and inserts its successor in
+// the same family; presenting a token that was already revoked means it was
+// replayed, so the whole family is revoked.
+
+// ErrRefreshTokenNotFound is returned when a refresh token is unknown or has expired
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+// ErrSessionNotFound is returned when revoking a session that does not exist,
+// belongs to another user or has already ended
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrRefreshTokenReused is returned by RotateRefreshToken when the presented
+// token had already been revoked. The token's family has been revoked by the
+// time the error is returned.
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+
+// hashToken is how bearer tokens are stored, so a database leak does not
+// hand out usable tokens
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
 
-func (r *userRepository) StoreRefreshToken(ctx context.Context, userID, token string, expiresIn time.Duration) error {
+// StoreRefreshToken stores a freshly issued token as the start of a new
+// family and returns the family ID, which doubles as the session ID
+func (r *userRepository) StoreRefreshToken(ctx context.Context, userID, token string, client models.ClientInfo, expiresIn time.Duration) (string, error) {
 	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
 		"operation": "store_refresh_token",
 		"user_id":   userID,
 	})
 
-	expiresAt := time.Now().Add(expiresIn)
+	familyID := uuid.New().String()
+	row := refreshTokenRow{
+		userID:     userID,
+		token:      token,
+		familyID:   familyID,
+		deviceName: nullString(client.DeviceName),
+		userAgent:  nullString(client.UserAgent),
+		ipAddress:  nullString(client.IPAddress),
+	}
+	if err := insertRefreshToken(ctx, r.db, row, expiresIn); err != nil {
+		log.WithError(err).Error("Failed to store refresh token")
+		return "", fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return familyID, nil
+}
+
+type refreshTokenRow struct {
+	userID     string
+	token      string
+	familyID   string
+	deviceName sql.NullString
+	userAgent  sql.NullString
+	ipAddress  sql.NullString
+	orgID      sql.NullString
+}
+
+func insertRefreshToken(ctx context.Context, exec execer, row refreshTokenRow, expiresIn time.Duration) error {
 	query := `
-		INSERT INTO refresh_tokens (id, user_id, token, expires_at, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO refresh_tokens (
+			id, user_id, token_hash, family_id, device_name, user_agent, ip_address,
+			org_id, last_used_at, expires_at, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $9)
 	`
 
-	_, err := r.db.ExecContext(
+	now := time.Now()
+	_, err := exec.ExecContext(
 		ctx,
 		query,
 		uuid.New().String(),
-		userID,
-		token,
-		expiresAt,
-		time.Now(),
+		row.userID,
+		hashToken(row.token),
+		row.familyID,
+		row.deviceName,
+		row.userAgent,
+		row.ipAddress,
+		row.orgID,
+		now,
+		now.Add(expiresIn),
 	)
+	return err
+}
 
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// RotateRefreshToken revokes oldToken and stores newToken in the same family,
+// carrying the session's device details and organization forward. It returns
+// the family ID and the organization, which is dropped once the user is no
+// longer a member.
+func (r *userRepository) RotateRefreshToken(ctx context.Context, userID, oldToken, newToken string, client models.ClientInfo, expiresIn time.Duration) (string, string, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "rotate_refresh_token",
+		"user_id":   userID,
+	})
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		log.WithError(err).Error("Failed to store refresh token")
-		return fmt.Errorf("failed to store refresh token: %w", err)
+		log.WithError(err).Error("Failed to begin transaction")
+		return "", "", fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	return nil
+	var (
+		id        string
+		expiresAt time.Time
+		revokedAt sql.NullTime
+		row       = refreshTokenRow{userID: userID, token: newToken}
+	)
+	query := `
+		SELECT t.id, t.family_id, t.device_name, t.user_agent, t.ip_address, m.org_id, t.expires_at, t.revoked_at
+		FROM refresh_tokens t
+		LEFT JOIN organization_members m ON m.org_id = t.org_id AND m.user_id = t.user_id
+		WHERE t.user_id = $1 AND t.token_hash = $2
+		FOR UPDATE OF t
+	`
+	err = tx.QueryRowContext(ctx, query, userID, hashToken(oldToken)).Scan(
+		&id,
+		&row.familyID,
+		&row.deviceName,
+		&row.userAgent,
+		&row.ipAddress,
+		&row.orgID,
+		&expiresAt,
+		&revokedAt,
+	)
+	if err == sql.ErrNoRows {
+		return "", "", ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to load refresh token")
+		return "", "", fmt.Errorf("failed to load refresh token: %w", err)
+	}
+
+	now := time.Now()
+
+	if revokedAt.Valid {
+		revokeFamily := `
+			UPDATE refresh_tokens
+			SET revoked_at = $1
+			WHERE family_id = $2
+			AND revoked_at IS NULL
+		`
+		if _, err := tx.ExecContext(ctx, revokeFamily, now, row.familyID); err != nil {
+			log.WithError(err).Error("Failed to revoke refresh token family")
+			return "", "", fmt.Errorf("failed to revoke refresh token family: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			log.WithError(err).Error("Failed to commit refresh token family revocation")
+			return "", "", fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return row.familyID, "", ErrRefreshTokenReused
+	}
+
+	if !expiresAt.After(now) {
+		return "", "", ErrRefreshTokenNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = $1 WHERE id = $2`, now, id); err != nil {
+		log.WithError(err).Error("Failed to revoke rotated refresh token")
+		return "", "", fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+
+	// The device name is only sent at sign-in; the address and user agent
+	// follow the device as it moves
+	if client.DeviceName != "" {
+		row.deviceName = nullString(client.DeviceName)
+	}
+	if client.UserAgent != "" {
+		row.userAgent = nullString(client.UserAgent)
+	}
+	if client.IPAddress != "" {
+		row.ipAddress = nullString(client.IPAddress)
+	}
+
+	if err := insertRefreshToken(ctx, tx, row, expiresIn); err != nil {
+		log.WithError(err).Error("Failed to store rotated refresh token")
+		return "", "", fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithError(err).Error("Failed to commit refresh token rotation")
+		return "", "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return row.familyID, row.orgID.String, nil
 }
 
 func (r *userRepository) ValidateRefreshToken(ctx context.Context, userID, token string) (bool, error) {
@@ -255,12 +787,12 @@ func (r *userRepository) ValidateRefreshToken(ctx context.Context, userID, token
 		SELECT COUNT(*) 
 		FROM refresh_tokens 
 		WHERE user_id = $1 
-		AND token = $2 
+		AND token_hash = $2 
 		AND expires_at > $3 
 		AND revoked_at IS NULL
 	`
 
-	err := r.db.QueryRowContext(ctx, query, userID, token, time.Now()).Scan(&count)
+	err := r.db.QueryRowContext(ctx, query, userID, hashToken(token), time.Now()).Scan(&count)
 	if err != nil {
 		log.WithError(err).Error("Failed to validate refresh token")
 		return false, fmt.Errorf("failed to validate refresh token: %w", err)
@@ -279,11 +811,11 @@ func (r *userRepository) RevokeRefreshToken(ctx context.Context, userID, token s
 		UPDATE refresh_tokens 
 		SET revoked_at = $1 
 		WHERE user_id = $2 
-		AND token = $3 
+		AND token_hash = $3 
 		AND revoked_at IS NULL
 	`
 
-	_, err := r.db.ExecContext(ctx, query, time.Now(), userID, token)
+	_, err := r.db.ExecContext(ctx, query, time.Now(), userID, hashToken(token))
 	if err != nil {
 		log.WithError(err).Error("Failed to revoke refresh token")
 		return fmt.Errorf("failed to revoke refresh token: %w", err)
@@ -292,6 +824,83 @@ func (r *userRepository) RevokeRefreshToken(ctx context.Context, userID, token s
 	return nil
 }
 
+func (r *userRepository) RevokeRefreshTokenFamily(ctx context.Context, userID, familyID string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "revoke_refresh_token_family",
+		"user_id":   userID,
+		"family_id": familyID,
+	})
+
+	query := `
+		UPDATE refresh_tokens 
+		SET revoked_at = $1 
+		WHERE user_id = $2 
+		AND family_id = $3 
+		AND revoked_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now(), userID, familyID)
+	if err != nil {
+		log.WithError(err).Error("Failed to revoke refresh token family")
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// RevokeOtherRefreshTokens revokes every token outside keepFamilyID and
+// returns the IDs of the sessions ended
+func (r *userRepository) RevokeOtherRefreshTokens(ctx context.Context, userID, keepFamilyID string) ([]string, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "revoke_other_refresh_tokens",
+		"user_id":   userID,
+		"family_id": keepFamilyID,
+	})
+
+	query := `
+		UPDATE refresh_tokens 
+		SET revoked_at = $1 
+		WHERE user_id = $2 
+		AND family_id <> $3 
+		AND revoked_at IS NULL
+		AND expires_at > $1
+		RETURNING family_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, time.Now(), userID, keepFamilyID)
+	if err != nil {
+		log.WithError(err).Error("Failed to revoke other refresh tokens")
+		return nil, fmt.Errorf("failed to revoke other refresh tokens: %w", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	var families []string
+	for rows.Next() {
+		var familyID string
+		if err := rows.Scan(&familyID); err != nil {
+			return nil, fmt.Errorf("failed to scan family id: %w", err)
+		}
+		if !seen[familyID] {
+			seen[familyID] = true
+			families = append(families, familyID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to revoke other refresh tokens: %w", err)
+	}
+
+	return families, nil
+}
+
 func (r *userRepository) RevokeAllRefreshTokens(ctx context.Context, userID string) error {
 	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
 		"operation": "revoke_all_refresh_tokens",
@@ -314,6 +923,58 @@ func (r *userRepository) RevokeAllRefreshTokens(ctx context.Context, userID stri
 	return nil
 }
 
+// ListSessions returns the user's signed-in devices, most recently used
+// first. Each family has at most one live token, which carries the
+// session's current details.
+func (r *userRepository) ListSessions(ctx context.Context, userID string) ([]*models.Session, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "list_sessions",
+		"user_id":   userID,
+	})
+
+	query := `
+		SELECT t.family_id, t.device_name, t.user_agent, t.ip_address,
+		       (SELECT MIN(f.created_at) FROM refresh_tokens f WHERE f.family_id = t.family_id),
+		       t.last_used_at, t.expires_at
+		FROM refresh_tokens t
+		WHERE t.user_id = $1
+		AND t.revoked_at IS NULL
+		AND t.expires_at > $2
+		ORDER BY t.last_used_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, time.Now())
+	if err != nil {
+		log.WithError(err).Error("Failed to list sessions")
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		session := &models.Session{}
+		if err := rows.Scan(
+			&session.ID,
+			&session.DeviceName,
+			&session.UserAgent,
+			&session.IPAddress,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.ExpiresAt,
+		); err != nil {
+			log.WithError(err).Error("Failed to scan session")
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		log.WithError(err).Error("Failed to iterate sessions")
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
 // Password Reset
 
 func (r *userRepository) StorePasswordResetToken(ctx context.Context, userID, token string, expiresAt time.Time) error {
@@ -380,6 +1041,32 @@ func (r *userRepository) ValidatePasswordResetToken(ctx context.Context, token s
 	return userID, nil
 }
 
+// PeekPasswordResetToken returns the token's user without using it up, so a
+// new password can be checked before the token is spent
+func (r *userRepository) PeekPasswordResetToken(ctx context.Context, token string) (string, error) {
+	log := r.log.WithContext(ctx).WithField("operation", "peek_password_reset_token")
+
+	var userID string
+	query := `
+		SELECT user_id
+		FROM password_reset_tokens
+		WHERE token = $1
+		AND expires_at > $2
+		AND used_at IS NULL
+	`
+
+	err := r.db.QueryRowContext(ctx, query, token, time.Now()).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", errors.New("invalid or expired token")
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to look up password reset token")
+		return "", fmt.Errorf("failed to validate token: %w", err)
+	}
+
+	return userID, nil
+}
+
 func (r *userRepository) DeletePasswordResetToken(ctx context.Context, token string) error {
 	log := r.log.WithContext(ctx).WithField("operation", "delete_password_reset_token")
 
@@ -396,3 +1083,283 @@ func (r *userRepository) DeletePasswordResetToken(ctx context.Context, token str
 
 	return nil
 }
+
+// Email Verification
+
+func (r *userRepository) StoreEmailVerificationToken(ctx context.Context, userID, token string, expiresAt time.Time) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "store_email_verification_token",
+		"user_id":   userID,
+	})
+
+	query := `
+		INSERT INTO email_verification_tokens (id, user_id, token, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) 
+		DO UPDATE SET token = $3, expires_at = $4, used_at = NULL, created_at = $5
+	`
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		uuid.New().String(),
+		userID,
+		token,
+		expiresAt,
+		time.Now(),
+	)
+
+	if err != nil {
+		log.WithError(err).Error("Failed to store email verification token")
+		return fmt.Errorf("failed to store email verification token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *userRepository) ValidateEmailVerificationToken(ctx context.Context, token string) (string, error) {
+	log := r.log.WithContext(ctx).WithField("operation", "validate_email_verification_token")
+
+	var userID string
+	query := `
+		UPDATE email_verification_tokens 
+		SET used_at = $1 
+		WHERE token = $2 
+		AND expires_at > $1 
+		AND used_at IS NULL
+		RETURNING user_id
+	`
+
+	err := r.db.QueryRowContext(ctx, query, time.Now(), token).Scan(&userID)
+	if err == sql.ErrNoRows {
+		log.Debug("Email verification token not found or expired")
+		return "", errors.New("invalid or expired token")
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to validate email verification token")
+		return "", fmt.Errorf("failed to validate token: %w", err)
+	}
+
+	return userID, nil
+}
+
+func (r *userRepository) DeleteEmailVerificationToken(ctx context.Context, token string) error {
+	log := r.log.WithContext(ctx).WithField("operation", "delete_email_verification_token")
+
+	query := `
+		DELETE FROM email_verification_tokens 
+		WHERE token = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, token)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete email verification token")
+		return fmt.Errorf("failed to delete token: %w", err)
+	}
+
+	return nil
+}
+
+// Email Change
+
+// ErrEmailTaken is returned when an address change would collide with
+// another account
+var ErrEmailTaken = errors.New("email already in use")
+
+func (r *userRepository) StoreEmailChangeToken(ctx context.Context, userID, newEmail, token string, expiresAt time.Time) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "store_email_change_token",
+		"user_id":   userID,
+	})
+
+	// A new request replaces any change still pending
+	query := `
+		INSERT INTO email_change_tokens (id, user_id, new_email, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) 
+		DO UPDATE SET new_email = $3, token_hash = $4, expires_at = $5, created_at = $6
+	`
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		uuid.New().String(),
+		userID,
+		models.NormalizeEmail(newEmail),
+		hashToken(token),
+		expiresAt,
+		time.Now(),
+	)
+
+	if err != nil {
+		log.WithError(err).Error("Failed to store email change token")
+		return fmt.Errorf("failed to store email change token: %w", err)
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange consumes the token and switches the user to the
+// address it was issued for, which counts as verified since the link was
+// delivered there. It returns the address the user had before.
+func (r *userRepository) ConfirmEmailChange(ctx context.Context, token string) (string, string, error) {
+	log := r.log.WithContext(ctx).WithField("operation", "confirm_email_change")
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.WithError(err).Error("Failed to begin transaction")
+		return "", "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID, newEmail string
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM email_change_tokens 
+		WHERE token_hash = $1 
+		AND expires_at > $2
+		RETURNING user_id, new_email
+	`, hashToken(token), time.Now()).Scan(&userID, &newEmail)
+	if err == sql.ErrNoRows {
+		log.Debug("Email change token not found or expired")
+		return "", "", errors.New("invalid or expired token")
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to consume email change token")
+		return "", "", fmt.Errorf("failed to validate token: %w", err)
+	}
+
+	var previousEmail string
+	err = tx.QueryRowContext(ctx, `SELECT email FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&previousEmail)
+	if err != nil {
+		log.WithError(err).Error("Failed to load user for email change")
+		return "", "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users 
+		SET email = $1, email_verified = true, updated_at = $2 
+		WHERE id = $3
+	`, newEmail, time.Now(), userID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return "", "", ErrEmailTaken
+		}
+		log.WithError(err).Error("Failed to update email")
+		return "", "", fmt.Errorf("failed to update email: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithError(err).Error("Failed to commit email change")
+		return "", "", fmt.Errorf("failed to commit email change: %w", err)
+	}
+
+	log.WithField("user_id", userID).Info("Email address changed")
+	return userID, previousEmail, nil
+}
+
+// Magic Link Sign-In
+
+// ErrMagicLinkInvalid is returned for unknown, expired or used sign-in links
+// and links presented from a different device
+var ErrMagicLinkInvalid = errors.New("invalid or expired sign-in link")
+
+// ErrMagicLinkLimit is returned when a user already has as many sign-in
+// links outstanding as they may
+var ErrMagicLinkLimit = errors.New("too many outstanding sign-in links")
+
+// StoreMagicLinkToken saves a sign-in link bound to the device that asked
+// for it, next to the user's other outstanding links. Links already
+// outstanding are never replaced, so asking for a link to someone else's
+// address can't take away the one they are waiting for; once the user has
+// maxOutstanding unexpired links it returns ErrMagicLinkLimit instead.
+func (r *userRepository) StoreMagicLinkToken(ctx context.Context, userID, token, deviceToken string, expiresAt time.Time, maxOutstanding int) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "store_magic_link_token",
+		"user_id":   userID,
+	})
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.WithError(err).Error("Failed to begin transaction")
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locking the user serializes concurrent requests, so they can't all
+	// see room for one more link
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		log.WithError(err).Error("Failed to lock user")
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM magic_link_tokens WHERE user_id = $1 AND expires_at <= $2`, userID, now); err != nil {
+		log.WithError(err).Error("Failed to delete expired magic link tokens")
+		return fmt.Errorf("failed to delete expired magic link tokens: %w", err)
+	}
+
+	var outstanding int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM magic_link_tokens WHERE user_id = $1`, userID).Scan(&outstanding); err != nil {
+		log.WithError(err).Error("Failed to count magic link tokens")
+		return fmt.Errorf("failed to count magic link tokens: %w", err)
+	}
+	if outstanding >= maxOutstanding {
+		return ErrMagicLinkLimit
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO magic_link_tokens (id, user_id, token_hash, device_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`,
+		uuid.New().String(),
+		userID,
+		hashToken(token),
+		hashToken(deviceToken),
+		expiresAt,
+		now,
+	)
+	if err != nil {
+		log.WithError(err).Error("Failed to store magic link token")
+		return fmt.Errorf("failed to store magic link token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithError(err).Error("Failed to commit magic link token")
+		return fmt.Errorf("failed to commit magic link token: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeMagicLinkToken uses up a sign-in link and returns its user, along
+// with the user's other outstanding links, which have done their job. A
+// link presented from another device is left in place for the right one.
+func (r *userRepository) ConsumeMagicLinkToken(ctx context.Context, token, deviceToken string) (string, error) {
+	log := r.log.WithContext(ctx).WithField("operation", "consume_magic_link_token")
+
+	query := `
+		WITH used AS (
+			DELETE FROM magic_link_tokens
+			WHERE token_hash = $1 AND device_hash = $2 AND expires_at > $3
+			RETURNING user_id
+		), others AS (
+			DELETE FROM magic_link_tokens
+			WHERE user_id IN (SELECT user_id FROM used) AND token_hash <> $1
+		)
+		SELECT user_id FROM used
+	`
+
+	var userID string
+	err := r.db.QueryRowContext(ctx, query, hashToken(token), hashToken(deviceToken), time.Now()).Scan(&userID)
+	if err == sql.ErrNoRows {
+		log.Debug("Magic link token not found, expired or from another device")
+		return "", ErrMagicLinkInvalid
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to consume magic link token")
+		return "", fmt.Errorf("failed to consume magic link token: %w", err)
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+)
+
+type WebAuthnRepository interface {
+	// Credentials
+	CreateCredential(ctx context.Context, cred *models.WebAuthnCredential) error
+	GetCredentialByCredentialID(ctx context.Context, credentialID string) (*models.WebAuthnCredential, error)
+	GetCredentialsByUser(ctx context.Context, userID string) ([]*models.WebAuthnCredential, error)
+	UpdateSignCount(ctx context.Context, id string, signCount int64, backupState bool) (bool, error)
+	DeleteCredential(ctx context.Context, userID, id string) error
+
+	// Ceremony challenges
+	StoreChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error
+	ConsumeChallenge(ctx context.Context, challenge, ceremony string) (*models.WebAuthnChallenge, error)
+}
+
+type webAuthnRepository struct {
+	db  *sql.DB
+	log logger.Logger
+}
+
+func NewWebAuthnRepository(db *sql.DB, log logger.Logger) WebAuthnRepository {
+	return &webAuthnRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *webAuthnRepository) CreateCredential(ctx context.Context, cred *models.WebAuthnCredential) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "create_webauthn_credential",
+		"user_id":   cred.UserID,
+	})
+
+	cred.ID = uuid.New().String()
+	cred.CreatedAt = time.Now()
+
+	query := `
+		INSERT INTO webauthn_credentials (
+			id, user_id, credential_id, public_key, sign_count, aaguid, transports,
+			name, backup_eligible, backup_state, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	_, err := r.db.ExecContext(
+		ctx, query,
+		cred.ID, cred.UserID, cred.CredentialID, cred.PublicKey, cred.SignCount, cred.AAGUID,
+		strings.Join(cred.Transports, ","), cred.Name, cred.BackupEligible, cred.BackupState, cred.CreatedAt,
+	)
+
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return errors.New("credential already registered")
+		}
+		log.WithError(err).Error("Failed to create webauthn credential")
+		return fmt.Errorf("failed to create credential: %w", err)
+	}
+
+	log.Info("WebAuthn credential registered")
+	return nil
+}
+
+func (r *webAuthnRepository) GetCredentialByCredentialID(ctx context.Context, credentialID string) (*models.WebAuthnCredential, error) {
+	query := `
+		SELECT id, user_id, credential_id, public_key, sign_count, aaguid, transports,
+		       name, backup_eligible, backup_state, last_used_at, created_at
+		FROM webauthn_credentials
+		WHERE credential_id = $1
+	`
+
+	cred, err := scanWebAuthnCredential(r.db.QueryRowContext(ctx, query, credentialID))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("credential not found")
+	}
+	if err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to get webauthn credential")
+		return nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+
+	return cred, nil
+}
+
+func (r *webAuthnRepository) GetCredentialsByUser(ctx context.Context, userID string) ([]*models.WebAuthnCredential, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "get_webauthn_credentials",
+		"user_id":   userID,
+	})
+
+	query := `
+		SELECT id, user_id, credential_id, public_key, sign_count, aaguid, transports,
+		       name, backup_eligible, backup_state, last_used_at, created_at
+		FROM webauthn_credentials
+		WHERE user_id = $1
+		ORDER BY created_at ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to get webauthn credentials")
+		return nil, fmt.Errorf("failed to get credentials: %w", err)
+	}
+	defer rows.Close()
+
+	creds := []*models.WebAuthnCredential{}
+	for rows.Next() {
+		cred, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			log.WithError(err).Error("Failed to scan webauthn credential")
+			continue
+		}
+		creds = append(creds, cred)
+	}
+
+	return creds, nil
+}
+
+// UpdateSignCount stores the counter from a successful assertion. It reports
+// false if a concurrent assertion already advanced the counter past it.
+func (r *webAuthnRepository) UpdateSignCount(ctx context.Context, id string, signCount int64, backupState bool) (bool, error) {
+	query := `
+		UPDATE webauthn_credentials
+		SET sign_count = $2, backup_state = $3, last_used_at = $4
+		WHERE id = $1 AND (sign_count < $2 OR $2 = 0)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id, signCount, backupState, time.Now())
+	if err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to update sign count")
+		return false, fmt.Errorf("failed to update sign count: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+func (r *webAuthnRepository) DeleteCredential(ctx context.Context, userID, id string) error {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation":     "delete_webauthn_credential",
+		"user_id":       userID,
+		"credential_id": id,
+	})
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete webauthn credential")
+		return fmt.Errorf("failed to delete credential: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return errors.New("credential not found")
+	}
+
+	log.Info("WebAuthn credential deleted")
+	return nil
+}
+
+// StoreChallenge saves ceremony state and clears out expired challenges
+func (r *webAuthnRepository) StoreChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error {
+	log := r.log.WithContext(ctx).WithField("operation", "store_webauthn_challenge")
+
+	challenge.ID = uuid.New().String()
+	challenge.CreatedAt = time.Now()
+
+	query := `
+		INSERT INTO webauthn_challenges (id, user_id, challenge, ceremony, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.ExecContext(
+		ctx, query,
+		challenge.ID, challenge.UserID, challenge.Challenge, challenge.Ceremony,
+		challenge.ExpiresAt, challenge.CreatedAt,
+	)
+	if err != nil {
+		log.WithError(err).Error("Failed to store webauthn challenge")
+		return fmt.Errorf("failed to store challenge: %w", err)
+	}
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM webauthn_challenges WHERE expires_at < $1`, time.Now()); err != nil {
+		log.WithError(err).Warn("Failed to purge expired webauthn challenges")
+	}
+
+	return nil
+}
+
+// ConsumeChallenge deletes and returns an unexpired challenge, so each
+// challenge can complete at most one ceremony
+func (r *webAuthnRepository) ConsumeChallenge(ctx context.Context, challenge, ceremony string) (*models.WebAuthnChallenge, error) {
+	var c models.WebAuthnChallenge
+	query := `
+		DELETE FROM webauthn_challenges
+		WHERE challenge = $1 AND ceremony = $2 AND expires_at > $3
+		RETURNING id, user_id, challenge, ceremony, expires_at, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, challenge, ceremony, time.Now()).Scan(
+		&c.ID, &c.UserID, &c.Challenge, &c.Ceremony, &c.ExpiresAt, &c.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("challenge not found or expired")
+	}
+	if err != nil {
+		r.log.WithContext(ctx).WithError(err).Error("Failed to consume webauthn challenge")
+		return nil, fmt.Errorf("failed to consume challenge: %w", err)
+	}
+
+	return &c, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebAuthnCredential(row rowScanner) (*models.WebAuthnCredential, error) {
+	var cred models.WebAuthnCredential
+	var transports, name sql.NullString
+
+	err := row.Scan(
+		&cred.ID, &cred.UserID, &cred.CredentialID, &cred.PublicKey, &cred.SignCount, &cred.AAGUID,
+		&transports, &name, &cred.BackupEligible, &cred.BackupState, &cred.LastUsedAt, &cred.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if transports.String != "" {
+		cred.Transports = strings.Split(transports.String, ",")
+	}
+	cred.Name = name.String
+
+	return &cred, nil
+}
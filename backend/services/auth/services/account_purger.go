@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
+	"github.com/mdnaeem95/lifesync/backend/pkg/userdata"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+const accountPurgeBatchSize = 50
+
+// AccountPurger deletes accounts whose deletion grace period has passed.
+// Every other service is asked to erase the user's data first; the user row
+// is only removed once they all succeed, so a failure is retried on the
+// next run.
+type AccountPurger struct {
+	userRepo     repository.UserRepository
+	sources      []userdata.Source
+	revocations  revocation.Store
+	pollInterval time.Duration
+	log          logger.Logger
+}
+
+func NewAccountPurger(userRepo repository.UserRepository, sources []userdata.Source, revocations revocation.Store, pollInterval time.Duration, log logger.Logger) *AccountPurger {
+	return &AccountPurger{
+		userRepo:     userRepo,
+		sources:      sources,
+		revocations:  revocations,
+		pollInterval: pollInterval,
+		log:          log,
+	}
+}
+
+// Start purges due accounts until ctx is cancelled
+func (p *AccountPurger) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	p.log.WithField("poll_interval", p.pollInterval.String()).Info("Account purger started")
+
+	for {
+		p.purgeDue(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			p.log.Info("Account purger stopped")
+			return
+		}
+	}
+}
+
+func (p *AccountPurger) purgeDue(ctx context.Context) {
+	users, err := p.userRepo.ListDueDeletions(ctx, time.Now(), accountPurgeBatchSize)
+	if err != nil {
+		p.log.WithError(err).Error("Failed to list accounts due for deletion")
+		return
+	}
+
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := p.purge(ctx, user); err != nil {
+			p.log.WithError(err).WithField("user_id", user.ID).Error("Failed to delete account, will retry")
+		}
+	}
+}
+
+func (p *AccountPurger) purge(ctx context.Context, user *models.User) error {
+	log := p.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "purge_account",
+		"user_id":   user.ID,
+	})
+
+	for _, source := range p.sources {
+		if err := source.Delete(ctx, user.ID); err != nil {
+			return fmt.Errorf("failed to delete %s data: %w", source.Name(), err)
+		}
+	}
+
+	// Cutoffs are not tied to the users table, so they outlive the account
+	// and reject any access token still in circulation
+	if err := p.revocations.RevokeUser(ctx, user.ID, time.Now(), AccessTokenTTL); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	if err := p.userRepo.Delete(ctx, user.ID); err != nil {
+		return err
+	}
+
+	log.WithField("security_event", "account_deleted").Info("Account permanently deleted")
+	return nil
+}
@@ -0,0 +1,496 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/authz"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
+	"github.com/mdnaeem95/lifesync/backend/pkg/userdata"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+var (
+	ErrIncorrectPassword       = errors.New("current password is incorrect")
+	ErrRecentSignInRequired    = errors.New("sign in again to confirm this change")
+	ErrEmailUnchanged          = errors.New("new email matches the current one")
+	ErrEmailInUse              = errors.New("email already in use")
+	ErrInvalidEmailChangeToken = errors.New("invalid or expired email change token")
+	ErrDeletionNotScheduled    = errors.New("account deletion not scheduled")
+)
+
+// AccountService lets signed-in users manage their own account
+type AccountService interface {
+	GetProfile(ctx context.Context, userID string) (*models.PublicUser, error)
+	UpdateProfile(ctx context.Context, userID string, req models.UpdateProfileRequest) (*models.PublicUser, error)
+	ChangePassword(ctx context.Context, userID, sessionID string, req models.ChangePasswordRequest) (*models.PasswordChangeResponse, error)
+	RequestEmailChange(ctx context.Context, userID, sessionID string, req models.ChangeEmailRequest) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+	ScheduleDeletion(ctx context.Context, userID, sessionID string, req models.DeleteAccountRequest) (time.Time, error)
+	CancelDeletion(ctx context.Context, userID string) error
+	ExportData(ctx context.Context, userID string, w io.Writer) error
+}
+
+type accountService struct {
+	userRepo       repository.UserRepository
+	jwtService     JWTService
+	tokenIssuer    TokenIssuer
+	emailService   EmailService
+	loginGuard     LoginGuard
+	passwordPolicy PasswordPolicy
+	hasher         PasswordHasher
+	revocations    revocation.Store
+	sources        []userdata.Source
+	cfg            *config.AuthConfig
+	log            logger.Logger
+}
+
+// NewAccountService creates the account service. sources are the other
+// services holding user data, included in exports.
+func NewAccountService(userRepo repository.UserRepository, jwtService JWTService, tokenIssuer TokenIssuer, emailService EmailService, loginGuard LoginGuard, passwordPolicy PasswordPolicy, hasher PasswordHasher, revocations revocation.Store, sources []userdata.Source, cfg *config.AuthConfig, log logger.Logger) AccountService {
+	return &accountService{
+		userRepo:       userRepo,
+		jwtService:     jwtService,
+		tokenIssuer:    tokenIssuer,
+		emailService:   emailService,
+		loginGuard:     loginGuard,
+		passwordPolicy: passwordPolicy,
+		hasher:         hasher,
+		revocations:    revocations,
+		sources:        sources,
+		cfg:            cfg,
+		log:            log,
+	}
+}
+
+func (s *accountService) GetProfile(ctx context.Context, userID string) (*models.PublicUser, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return user.ToPublicUser(), nil
+}
+
+func (s *accountService) UpdateProfile(ctx context.Context, userID string, req models.UpdateProfileRequest) (*models.PublicUser, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "update_profile",
+		"user_id":   userID,
+	})
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		user.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.PhotoURL != nil {
+		if *req.PhotoURL == "" {
+			user.PhotoURL = nil
+		} else {
+			user.PhotoURL = req.PhotoURL
+		}
+	}
+
+	if err := s.userRepo.UpdateProfile(ctx, user); err != nil {
+		return nil, err
+	}
+
+	log.Info("Profile updated")
+	return user.ToPublicUser(), nil
+}
+
+// ChangePassword sets a new password and signs out every other device. The
+// caller's own access token is revoked with the rest, so a replacement is
+// returned; the caller's refresh token stays valid when its session is known.
+func (s *accountService) ChangePassword(ctx context.Context, userID, sessionID string, req models.ChangePasswordRequest) (*models.PasswordChangeResponse, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "change_password",
+		"user_id":   userID,
+	})
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.verifyCurrentPassword(ctx, user, sessionID, req.CurrentPassword); err != nil {
+		return nil, err
+	}
+
+	if err := s.passwordPolicy.Validate(ctx, req.NewPassword, user); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		log.WithError(err).Error("Failed to hash new password")
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, userID, hashedPassword); err != nil {
+		return nil, err
+	}
+	user.PasswordResetRequired = false
+
+	if err := s.passwordPolicy.Remember(ctx, userID, hashedPassword); err != nil {
+		log.WithError(err).Warn("Failed to record password history")
+	}
+
+	if err := s.revocations.RevokeUser(ctx, userID, time.Now(), AccessTokenTTL); err != nil {
+		log.WithError(err).Warn("Failed to revoke access tokens after password change")
+		// Continue anyway
+	}
+
+	var response *models.PasswordChangeResponse
+	if sessionID != "" {
+		if _, err := s.userRepo.RevokeOtherRefreshTokens(ctx, userID, sessionID); err != nil {
+			return nil, fmt.Errorf("failed to revoke other sessions: %w", err)
+		}
+
+		// The session keeps the organization it had switched to
+		accessToken, err := s.jwtService.GenerateAccessToken(user, sessionID, authz.OrgFromContext(ctx))
+		if err != nil {
+			log.WithError(err).Error("Failed to generate access token")
+			return nil, fmt.Errorf("failed to generate access token: %w", err)
+		}
+
+		response = &models.PasswordChangeResponse{
+			AccessToken: accessToken,
+			ExpiresIn:   int(AccessTokenTTL.Seconds()),
+		}
+	} else {
+		// The caller's session is unknown, so end them all and start afresh
+		if err := s.userRepo.RevokeAllRefreshTokens(ctx, userID); err != nil {
+			return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+
+		tokens, err := s.tokenIssuer.IssueTokens(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+
+		response = &models.PasswordChangeResponse{
+			AccessToken:  tokens.AccessToken,
+			RefreshToken: tokens.RefreshToken,
+			ExpiresIn:    tokens.ExpiresIn,
+		}
+	}
+
+	alert := SecurityAlert{
+		Title:       "Your password was changed",
+		Description: "The password for your FlowTime account was just changed from your account settings, and your other devices were signed out.",
+	}
+	if err := s.emailService.SendSecurityAlert(ctx, user, alert); err != nil {
+		log.WithError(err).Warn("Failed to send password change alert")
+	}
+
+	log.WithField("security_event", "password_changed").Info("Password changed")
+	return response, nil
+}
+
+// RequestEmailChange sends a confirmation link to the new address. The
+// account keeps its current address until the link is followed.
+func (s *accountService) RequestEmailChange(ctx context.Context, userID, sessionID string, req models.ChangeEmailRequest) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "request_email_change",
+		"user_id":   userID,
+	})
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	newEmail := models.NormalizeEmail(req.NewEmail)
+	if strings.EqualFold(newEmail, user.Email) {
+		return ErrEmailUnchanged
+	}
+
+	if err := s.verifyCurrentPassword(ctx, user, sessionID, req.Password); err != nil {
+		return err
+	}
+
+	if existing, err := s.userRepo.GetByEmail(ctx, newEmail); err == nil && existing != nil {
+		return ErrEmailInUse
+	}
+
+	token, err := generateSecureToken()
+	if err != nil {
+		log.WithError(err).Error("Failed to generate email change token")
+		return fmt.Errorf("failed to generate email change token: %w", err)
+	}
+
+	expiresAt := time.Now().Add(s.cfg.EmailVerificationTTL)
+	if err := s.userRepo.StoreEmailChangeToken(ctx, userID, newEmail, token, expiresAt); err != nil {
+		return err
+	}
+
+	if err := s.emailService.SendEmailChangeVerification(ctx, user, newEmail, token, s.cfg.EmailVerificationTTL); err != nil {
+		log.WithError(err).Error("Failed to send email change verification")
+		return fmt.Errorf("failed to send email change verification: %w", err)
+	}
+
+	log.Info("Email change requested")
+	return nil
+}
+
+// ConfirmEmailChange switches the account to the new address and lets the
+// old address know it happened
+func (s *accountService) ConfirmEmailChange(ctx context.Context, token string) error {
+	log := s.log.WithContext(ctx).WithField("operation", "confirm_email_change")
+
+	userID, previousEmail, err := s.userRepo.ConfirmEmailChange(ctx, token)
+	if err != nil {
+		if errors.Is(err, repository.ErrEmailTaken) {
+			return ErrEmailInUse
+		}
+		if strings.Contains(err.Error(), "invalid or expired token") {
+			return ErrInvalidEmailChangeToken
+		}
+		return err
+	}
+
+	log = log.WithField("user_id", userID)
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		log.WithError(err).Warn("Failed to load user after email change")
+		return nil
+	}
+
+	notice := *user
+	notice.Email = previousEmail
+	alert := SecurityAlert{
+		Title:       "Your email address was changed",
+		Description: fmt.Sprintf("The email address on your FlowTime account was changed to %s. You will no longer receive account emails at this address.", user.Email),
+	}
+	if err := s.emailService.SendSecurityAlert(ctx, &notice, alert); err != nil {
+		log.WithError(err).Warn("Failed to send email change alert")
+	}
+
+	log.WithField("security_event", "email_changed").Info("Email address changed")
+	return nil
+}
+
+// ScheduleDeletion marks the account for deletion once the grace period has
+// passed. Until then the user can still sign in and cancel.
+func (s *accountService) ScheduleDeletion(ctx context.Context, userID, sessionID string, req models.DeleteAccountRequest) (time.Time, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "schedule_account_deletion",
+		"user_id":   userID,
+	})
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := s.verifyCurrentPassword(ctx, user, sessionID, req.Password); err != nil {
+		return time.Time{}, err
+	}
+
+	// Asking again keeps the original date
+	if user.DeletionScheduledAt != nil {
+		return *user.DeletionScheduledAt, nil
+	}
+
+	deleteAt := time.Now().Add(s.cfg.AccountDeletionGracePeriod).UTC()
+	if err := s.userRepo.ScheduleDeletion(ctx, userID, deleteAt); err != nil {
+		return time.Time{}, err
+	}
+
+	alert := SecurityAlert{
+		Title:       "Your account is scheduled for deletion",
+		Description: fmt.Sprintf("Your FlowTime account and all of its data will be permanently deleted on %s. To keep your account, sign in and cancel the deletion from your account settings before then.", deleteAt.Format("2 January 2006")),
+	}
+	if err := s.emailService.SendSecurityAlert(ctx, user, alert); err != nil {
+		log.WithError(err).Warn("Failed to send deletion scheduled alert")
+	}
+
+	log.WithFields(map[string]interface{}{
+		"security_event": "account_deletion_scheduled",
+		"delete_at":      deleteAt,
+	}).Info("Account deletion scheduled")
+	return deleteAt, nil
+}
+
+func (s *accountService) CancelDeletion(ctx context.Context, userID string) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "cancel_account_deletion",
+		"user_id":   userID,
+	})
+
+	cancelled, err := s.userRepo.CancelDeletion(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return ErrDeletionNotScheduled
+	}
+
+	log.WithField("security_event", "account_deletion_cancelled").Info("Account deletion cancelled")
+	return nil
+}
+
+// ExportData writes a zip archive of everything stored about the user in
+// this and every other service. A service that cannot be reached fails the
+// export rather than leaving it silently incomplete.
+func (s *accountService) ExportData(ctx context.Context, userID string, w io.Writer) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "export_account_data",
+		"user_id":   userID,
+	})
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	authFiles, err := s.exportAuthData(ctx, user)
+	if err != nil {
+		return err
+	}
+	exports := []userdata.Export{{Source: "account", Files: authFiles}}
+
+	for _, source := range s.sources {
+		files, err := source.Export(ctx, userID)
+		if err != nil {
+			log.WithError(err).WithField("source", source.Name()).Error("Failed to export user data")
+			return fmt.Errorf("failed to export %s data: %w", source.Name(), err)
+		}
+		exports = append(exports, userdata.Export{Source: source.Name(), Files: files})
+	}
+
+	if err := userdata.WriteArchive(w, userID, time.Now(), exports); err != nil {
+		return err
+	}
+
+	log.Info("Account data exported")
+	return nil
+}
+
+// exportAuthData returns the user's profile and signed-in devices
+func (s *accountService) exportAuthData(ctx context.Context, user *models.User) ([]userdata.File, error) {
+	sessions, err := s.userRepo.ListSessions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if sessions == nil {
+		sessions = []*models.Session{}
+	}
+
+	profile := user.ToPublicUser()
+
+	sessionRows := make([][]string, 0, len(sessions))
+	for _, session := range sessions {
+		sessionRows = append(sessionRows, []string{
+			session.ID, derefString(session.DeviceName), derefString(session.UserAgent), derefString(session.IPAddress),
+			session.CreatedAt.UTC().Format(time.RFC3339), session.LastUsedAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	profileJSON, err := userdata.JSONFile("profile.json", profile)
+	if err != nil {
+		return nil, err
+	}
+	profileCSV, err := userdata.CSVFile("profile.csv",
+		[]string{"id", "email", "name", "photo_url", "email_verified", "created_at"},
+		[][]string{{
+			profile.ID, profile.Email, profile.Name, derefString(profile.PhotoURL),
+			strconv.FormatBool(profile.EmailVerified), profile.CreatedAt.UTC().Format(time.RFC3339),
+		}})
+	if err != nil {
+		return nil, err
+	}
+	sessionsJSON, err := userdata.JSONFile("sessions.json", sessions)
+	if err != nil {
+		return nil, err
+	}
+	sessionsCSV, err := userdata.CSVFile("sessions.csv",
+		[]string{"id", "device_name", "user_agent", "ip_address", "created_at", "last_used_at"},
+		sessionRows)
+	if err != nil {
+		return nil, err
+	}
+
+	return []userdata.File{profileJSON, profileCSV, sessionsJSON, sessionsCSV}, nil
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// verifyCurrentPassword re-authenticates the user before a sensitive change.
+// Accounts without a password (social, magic link or passkey sign-in only)
+// have nothing to check, so the caller's session must instead have started
+// within RecentSignInWindow. Wrong guesses count towards the sign-in lockout
+// so this cannot be used to guess faster than sign-in allows.
+func (s *accountService) verifyCurrentPassword(ctx context.Context, user *models.User, sessionID, password string) error {
+	if user.PasswordHash == "" {
+		return s.verifyRecentSignIn(ctx, user.ID, sessionID)
+	}
+
+	ip := models.ClientInfoFromContext(ctx).IPAddress
+	if err := s.loginGuard.Check(ctx, user.Email, ip); err != nil {
+		return err
+	}
+
+	if match, _, err := s.hasher.Verify(password, user.PasswordHash); err != nil || !match {
+		s.log.WithContext(ctx).WithField("user_id", user.ID).Warn("Incorrect current password")
+		s.loginGuard.RecordFailure(ctx, user.Email, ip)
+		return ErrIncorrectPassword
+	}
+
+	s.loginGuard.RecordSuccess(ctx, user.Email)
+	return nil
+}
+
+// verifyRecentSignIn checks that the session was started by a sign-in, not
+// kept alive by refreshes, within RecentSignInWindow. Tokens without a
+// session can't show this.
+func (s *accountService) verifyRecentSignIn(ctx context.Context, userID, sessionID string) error {
+	if sessionID == "" {
+		return ErrRecentSignInRequired
+	}
+
+	sessions, err := s.userRepo.ListSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.ID == sessionID {
+			if time.Since(session.CreatedAt) > s.cfg.RecentSignInWindow {
+				break
+			}
+			return nil
+		}
+	}
+
+	s.log.WithContext(ctx).WithField("user_id", userID).Warn("Sign-in too old to confirm a sensitive change")
+	return ErrRecentSignInRequired
+}
+
+func (s *accountService) getUser(ctx context.Context, userID string) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "user not found") {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
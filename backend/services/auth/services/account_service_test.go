@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+type fakeAccountUserRepo struct {
+	repository.UserRepository
+	user      *models.User
+	sessions  []*models.Session
+	scheduled bool
+}
+
+func (f *fakeAccountUserRepo) GetByID(ctx context.Context, id string) (*models.User, error) {
+	return f.user, nil
+}
+
+func (f *fakeAccountUserRepo) ListSessions(ctx context.Context, userID string) ([]*models.Session, error) {
+	return f.sessions, nil
+}
+
+func (f *fakeAccountUserRepo) ScheduleDeletion(ctx context.Context, userID string, deleteAt time.Time) error {
+	f.scheduled = true
+	return nil
+}
+
+func TestPasswordlessAccountNeedsRecentSignIn(t *testing.T) {
+	const window = 10 * time.Minute
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		sessionID string
+		started   time.Time
+		wantErr   error
+	}{
+		{"fresh sign-in", "s1", now.Add(-time.Minute), nil},
+		{"old sign-in kept alive by refreshes", "s1", now.Add(-2 * window), ErrRecentSignInRequired},
+		{"unknown session", "s2", now.Add(-time.Minute), ErrRecentSignInRequired},
+		{"no session", "", now.Add(-time.Minute), ErrRecentSignInRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeAccountUserRepo{
+				user:     &models.User{ID: "u1", Email: "user@example.com"},
+				sessions: []*models.Session{{ID: "s1", CreatedAt: tt.started}},
+			}
+			cfg := &config.AuthConfig{RecentSignInWindow: window}
+			s := NewAccountService(repo, nil, nil, &fakeEmails{}, nil, nil, nil, nil, nil, cfg, logger.New())
+
+			_, err := s.ScheduleDeletion(context.Background(), "u1", tt.sessionID, models.DeleteAccountRequest{})
+			if err != tt.wantErr {
+				t.Fatalf("ScheduleDeletion() error = %v, want %v", err, tt.wantErr)
+			}
+			if repo.scheduled != (tt.wantErr == nil) {
+				t.Errorf("deletion scheduled = %v", repo.scheduled)
+			}
+		})
+	}
+}
@@ -0,0 +1,387 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdnaeem95/lifesync/backend/pkg/authz"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+var (
+	// ErrUserNotFound is returned by admin operations on an unknown user ID
+	ErrUserNotFound = errors.New("user not found")
+	// ErrCannotModifySelf stops admins locking themselves out
+	ErrCannotModifySelf = errors.New("cannot perform this action on your own account")
+	// ErrTargetOutranksActor stops staff acting on accounts with a higher
+	// role than their own, such as support signing out an admin
+	ErrTargetOutranksActor = errors.New("cannot perform this action on an account with a higher role")
+)
+
+// Audit trail actions
+const (
+	AuditUserSearch         = "user.search"
+	AuditUserView           = "user.view"
+	AuditUserUnlock         = "user.unlock"
+	AuditUserDeactivate     = "user.deactivate"
+	AuditUserActivate       = "user.activate"
+	AuditUserPasswordReset  = "user.force_password_reset"
+	AuditUserRevokeSessions = "user.revoke_sessions"
+	AuditUserSetRoles       = "user.set_roles"
+	defaultAdminPageSize    = 50
+	maxAdminPageSize        = 200
+)
+
+// PasswordResetRequester emails a user a password reset link
+type PasswordResetRequester interface {
+	RequestPasswordReset(ctx context.Context, email string) error
+}
+
+// AdminService holds staff actions on user accounts. Every action, reads
+// included, is written to the audit trail.
+type AdminService interface {
+	SearchUsers(ctx context.Context, actor models.AdminActor, filter models.UserFilter) (*models.UserListResponse, error)
+	GetUser(ctx context.Context, actor models.AdminActor, userID string) (*models.AdminUserDetail, error)
+	UnlockUser(ctx context.Context, actor models.AdminActor, userID string) error
+	DeactivateUser(ctx context.Context, actor models.AdminActor, userID string) error
+	ActivateUser(ctx context.Context, actor models.AdminActor, userID string) error
+	ForcePasswordReset(ctx context.Context, actor models.AdminActor, userID string) error
+	RevokeSessions(ctx context.Context, actor models.AdminActor, userID string) error
+	SetRoles(ctx context.Context, actor models.AdminActor, userID string, roles []string) ([]string, error)
+	ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error)
+}
+
+type adminService struct {
+	userRepo       repository.UserRepository
+	auditRepo      repository.AuditRepository
+	loginGuard     LoginGuard
+	passwordResets PasswordResetRequester
+	revocations    revocation.Store
+	log            logger.Logger
+}
+
+func NewAdminService(userRepo repository.UserRepository, auditRepo repository.AuditRepository, loginGuard LoginGuard, passwordResets PasswordResetRequester, revocations revocation.Store, log logger.Logger) AdminService {
+	return &adminService{
+		userRepo:       userRepo,
+		auditRepo:      auditRepo,
+		loginGuard:     loginGuard,
+		passwordResets: passwordResets,
+		revocations:    revocations,
+		log:            log,
+	}
+}
+
+// SearchUsers lists users, optionally filtered by email or name, role and
+// active flag
+func (s *adminService) SearchUsers(ctx context.Context, actor models.AdminActor, filter models.UserFilter) (*models.UserListResponse, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAdminPageSize
+	}
+	if filter.Limit > maxAdminPageSize {
+		filter.Limit = maxAdminPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	filter.Query = strings.TrimSpace(filter.Query)
+
+	users, total, err := s.userRepo.Search(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	details := map[string]interface{}{"results": len(users)}
+	if filter.Query != "" {
+		details["query"] = filter.Query
+	}
+	if filter.Role != "" {
+		details["role"] = filter.Role
+	}
+	if filter.Active != nil {
+		details["active"] = *filter.Active
+	}
+	s.audit(ctx, actor, AuditUserSearch, "", details)
+
+	return &models.UserListResponse{Users: users, Total: total}, nil
+}
+
+// GetUser returns a user with their signed-in devices
+func (s *adminService) GetUser(ctx context.Context, actor models.AdminActor, userID string) (*models.AdminUserDetail, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.userRepo.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if sessions == nil {
+		sessions = []*models.Session{}
+	}
+
+	s.audit(ctx, actor, AuditUserView, userID, nil)
+	return &models.AdminUserDetail{User: user, Sessions: sessions}, nil
+}
+
+// UnlockUser clears failed sign-in attempts and any lockout on the account
+func (s *adminService) UnlockUser(ctx context.Context, actor models.AdminActor, userID string) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "admin_unlock_user",
+		"user_id":   userID,
+		"actor_id":  actor.UserID,
+	})
+
+	user, err := s.getTargetUser(ctx, actor, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.loginGuard.Unlock(ctx, user.Email); err != nil {
+		return fmt.Errorf("failed to unlock user: %w", err)
+	}
+
+	s.audit(ctx, actor, AuditUserUnlock, userID, nil)
+	log.WithField("security_event", "admin_unlock").Info("Sign-in lockout cleared by admin")
+	return nil
+}
+
+// DeactivateUser blocks sign-in and immediately ends every session,
+// including access tokens that have not yet expired
+func (s *adminService) DeactivateUser(ctx context.Context, actor models.AdminActor, userID string) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "admin_deactivate_user",
+		"user_id":   userID,
+		"actor_id":  actor.UserID,
+	})
+
+	if userID == actor.UserID {
+		return ErrCannotModifySelf
+	}
+
+	if _, err := s.getTargetUser(ctx, actor, userID); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.SetActive(ctx, userID, false); err != nil {
+		return fmt.Errorf("failed to deactivate user: %w", err)
+	}
+
+	if err := s.signOutEverywhere(ctx, userID); err != nil {
+		return err
+	}
+
+	s.audit(ctx, actor, AuditUserDeactivate, userID, nil)
+	log.WithField("security_event", "admin_deactivate").Info("User deactivated by admin")
+	return nil
+}
+
+// ActivateUser lets a deactivated user sign in again
+func (s *adminService) ActivateUser(ctx context.Context, actor models.AdminActor, userID string) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "admin_activate_user",
+		"user_id":   userID,
+		"actor_id":  actor.UserID,
+	})
+
+	if _, err := s.getTargetUser(ctx, actor, userID); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.SetActive(ctx, userID, true); err != nil {
+		return fmt.Errorf("failed to activate user: %w", err)
+	}
+
+	s.audit(ctx, actor, AuditUserActivate, userID, nil)
+	log.Info("User activated by admin")
+	return nil
+}
+
+// ForcePasswordReset signs the user out everywhere, refuses password sign-in
+// until the password is changed and emails them a reset link
+func (s *adminService) ForcePasswordReset(ctx context.Context, actor models.AdminActor, userID string) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "admin_force_password_reset",
+		"user_id":   userID,
+		"actor_id":  actor.UserID,
+	})
+
+	user, err := s.getTargetUser(ctx, actor, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.RequirePasswordReset(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.signOutEverywhere(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.passwordResets.RequestPasswordReset(ctx, user.Email); err != nil {
+		// The reset is already enforced; the user can ask for another link
+		log.WithError(err).Warn("Failed to send forced password reset email")
+	}
+
+	s.audit(ctx, actor, AuditUserPasswordReset, userID, nil)
+	log.WithField("security_event", "admin_force_password_reset").Info("Password reset forced by admin")
+	return nil
+}
+
+// RevokeSessions signs the user out of every device
+func (s *adminService) RevokeSessions(ctx context.Context, actor models.AdminActor, userID string) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "admin_revoke_sessions",
+		"user_id":   userID,
+		"actor_id":  actor.UserID,
+	})
+
+	if _, err := s.getTargetUser(ctx, actor, userID); err != nil {
+		return err
+	}
+
+	if err := s.signOutEverywhere(ctx, userID); err != nil {
+		return err
+	}
+
+	s.audit(ctx, actor, AuditUserRevokeSessions, userID, nil)
+	log.WithField("security_event", "admin_revoke_sessions").Info("Sessions revoked by admin")
+	return nil
+}
+
+// SetRoles replaces the user's roles. Their access tokens are revoked so the
+// change applies from their next refresh.
+func (s *adminService) SetRoles(ctx context.Context, actor models.AdminActor, userID string, roles []string) ([]string, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "admin_set_roles",
+		"user_id":   userID,
+		"actor_id":  actor.UserID,
+	})
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := authz.NormalizeRoles(user.Roles)
+	roles = authz.NormalizeRoles(roles)
+	if userID == actor.UserID && !authz.HasAny(roles, authz.RoleAdmin) {
+		return nil, ErrCannotModifySelf
+	}
+
+	if err := s.userRepo.SetRoles(ctx, userID, roles); err != nil {
+		return nil, err
+	}
+
+	if err := s.revocations.RevokeUser(ctx, userID, time.Now(), AccessTokenTTL); err != nil {
+		return nil, fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	s.audit(ctx, actor, AuditUserSetRoles, userID, map[string]interface{}{
+		"previous_roles": previous,
+		"roles":          roles,
+	})
+	log.WithFields(map[string]interface{}{
+		"security_event": "admin_set_roles",
+		"roles":          roles,
+	}).Info("User roles changed by admin")
+	return roles, nil
+}
+
+func (s *adminService) ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAdminPageSize
+	}
+	if filter.Limit > maxAdminPageSize {
+		filter.Limit = maxAdminPageSize
+	}
+	return s.auditRepo.List(ctx, filter)
+}
+
+// signOutEverywhere revokes every refresh token and access token the user
+// holds
+func (s *adminService) signOutEverywhere(ctx context.Context, userID string) error {
+	if err := s.userRepo.RevokeAllRefreshTokens(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	if err := s.revocations.RevokeUser(ctx, userID, time.Now(), AccessTokenTTL); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	return nil
+}
+
+// audit records an action that has already happened. A failed write can't
+// undo it, so the entry is logged in full instead.
+func (s *adminService) audit(ctx context.Context, actor models.AdminActor, action, targetUserID string, details map[string]interface{}) {
+	client := models.ClientInfoFromContext(ctx)
+	entry := &models.AuditEntry{
+		ActorID:    actor.UserID,
+		ActorEmail: actor.Email,
+		Action:     action,
+		Details:    details,
+	}
+	if targetUserID != "" {
+		entry.TargetUserID = &targetUserID
+	}
+	if client.IPAddress != "" {
+		entry.IPAddress = &client.IPAddress
+	}
+	if client.UserAgent != "" {
+		entry.UserAgent = &client.UserAgent
+	}
+
+	if err := s.auditRepo.Add(ctx, entry); err != nil {
+		s.log.WithContext(ctx).WithError(err).WithFields(map[string]interface{}{
+			"security_event": "audit_write_failed",
+			"actor_id":       actor.UserID,
+			"action":         action,
+			"target_user_id": targetUserID,
+			"details":        details,
+		}).Error("Failed to write admin audit entry")
+	}
+}
+
+// getTargetUser loads the user an action changes, refusing users whose role
+// ranks above the actor's
+func (s *adminService) getTargetUser(ctx context.Context, actor models.AdminActor, userID string) (*models.User, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if authz.Rank(user.Roles) > authz.Rank(actor.Roles) {
+		s.log.WithContext(ctx).WithFields(map[string]interface{}{
+			"user_id":  userID,
+			"actor_id": actor.UserID,
+		}).Warn("Admin action refused on a higher-ranked account")
+		return nil, ErrTargetOutranksActor
+	}
+
+	return user, nil
+}
+
+func (s *adminService) getUser(ctx context.Context, userID string) (*models.User, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "user not found") {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
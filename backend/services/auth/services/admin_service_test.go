@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/authz"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+type fakeAdminUserRepo struct {
+	repository.UserRepository
+	users       map[string]*models.User
+	deactivated []string
+	resets      []string
+	signedOut   []string
+}
+
+func (f *fakeAdminUserRepo) GetByID(ctx context.Context, id string) (*models.User, error) {
+	return f.users[id], nil
+}
+
+func (f *fakeAdminUserRepo) SetActive(ctx context.Context, userID string, active bool) error {
+	if !active {
+		f.deactivated = append(f.deactivated, userID)
+	}
+	return nil
+}
+
+func (f *fakeAdminUserRepo) RequirePasswordReset(ctx context.Context, userID string) error {
+	f.resets = append(f.resets, userID)
+	return nil
+}
+
+func (f *fakeAdminUserRepo) RevokeAllRefreshTokens(ctx context.Context, userID string) error {
+	f.signedOut = append(f.signedOut, userID)
+	return nil
+}
+
+type fakeAuditRepo struct {
+	repository.AuditRepository
+	entries []*models.AuditEntry
+}
+
+func (f *fakeAuditRepo) Add(ctx context.Context, entry *models.AuditEntry) error {
+	f.entries = append(f.entries, entry)
+	return nil
+}
+
+type fakePasswordResets struct{}
+
+func (fakePasswordResets) RequestPasswordReset(ctx context.Context, email string) error {
+	return nil
+}
+
+func TestAdminActionsRefuseHigherRankedTargets(t *testing.T) {
+	const (
+		supportID = "1b0b6a3e-5f0e-4c41-9d59-3c2f4f1a0001"
+		adminID   = "1b0b6a3e-5f0e-4c41-9d59-3c2f4f1a0002"
+		userID    = "1b0b6a3e-5f0e-4c41-9d59-3c2f4f1a0003"
+		peerID    = "1b0b6a3e-5f0e-4c41-9d59-3c2f4f1a0004"
+	)
+	roles := map[string][]string{
+		supportID: {authz.RoleUser, authz.RoleSupport},
+		adminID:   {authz.RoleUser, authz.RoleAdmin},
+		userID:    {authz.RoleUser},
+		peerID:    {authz.RoleUser, authz.RoleSupport},
+	}
+
+	actions := map[string]func(AdminService, models.AdminActor, string) error{
+		"deactivate": func(s AdminService, actor models.AdminActor, id string) error {
+			return s.DeactivateUser(context.Background(), actor, id)
+		},
+		"force password reset": func(s AdminService, actor models.AdminActor, id string) error {
+			return s.ForcePasswordReset(context.Background(), actor, id)
+		},
+		"revoke sessions": func(s AdminService, actor models.AdminActor, id string) error {
+			return s.RevokeSessions(context.Background(), actor, id)
+		},
+	}
+
+	tests := []struct {
+		name    string
+		actor   string
+		target  string
+		wantErr error
+	}{
+		{"support on user", supportID, userID, nil},
+		{"support on support", supportID, peerID, nil},
+		{"support on admin", supportID, adminID, ErrTargetOutranksActor},
+		{"admin on support", adminID, supportID, nil},
+	}
+
+	for action, do := range actions {
+		for _, tt := range tests {
+			t.Run(action+"/"+tt.name, func(t *testing.T) {
+				users := make(map[string]*models.User)
+				for id, r := range roles {
+					users[id] = &models.User{ID: id, Email: id + "@example.com", Roles: r}
+				}
+				repo := &fakeAdminUserRepo{users: users}
+				audit := &fakeAuditRepo{}
+				s := NewAdminService(repo, audit, nil, fakePasswordResets{}, &fakeRevocations{}, logger.New())
+
+				actor := models.AdminActor{UserID: tt.actor, Roles: roles[tt.actor]}
+				err := do(s, actor, tt.target)
+				if err != tt.wantErr {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				changed := len(repo.deactivated) + len(repo.resets) + len(repo.signedOut)
+				if (changed > 0) != (tt.wantErr == nil) {
+					t.Errorf("account changed = %v", changed > 0)
+				}
+				if (len(audit.entries) > 0) != (tt.wantErr == nil) {
+					t.Errorf("audited = %v", len(audit.entries) > 0)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+// Auth event types
+const (
+	AuthEventSignUp               = "signup"
+	AuthEventSignIn               = "signin"
+	AuthEventRefresh              = "refresh"
+	AuthEventSignOut              = "signout"
+	AuthEventPasswordResetRequest = "password_reset_request"
+	AuthEventPasswordReset        = "password_reset"
+	AuthEventTokenReuse           = "token_reuse"
+	AuthEventAppAuthorized        = "app_authorized"
+	AuthEventAppRevoked           = "app_revoked"
+)
+
+// Auth event outcomes
+const (
+	AuthOutcomeSuccess = "success"
+	AuthOutcomeFailure = "failure"
+)
+
+// Sign-in methods recorded with signin events. Social sign-ins record the
+// provider name.
+const (
+	AuthMethodPassword  = "password"
+	AuthMethodMFA       = "mfa"
+	AuthMethodMagicLink = "magic_link"
+	AuthMethodPasskey   = "passkey"
+)
+
+const (
+	defaultAuthEventPageSize = 50
+	maxAuthEventPageSize     = 200
+)
+
+// AuthEventRecorder records authentication events. Recording never fails
+// the operation being recorded.
+type AuthEventRecorder interface {
+	Record(ctx context.Context, event *models.AuthEvent)
+}
+
+// AuthEventService keeps the security log of authentication events for
+// users to review their own activity and for staff investigating accounts
+type AuthEventService interface {
+	AuthEventRecorder
+	ListForUser(ctx context.Context, userID string, before *time.Time, limit int) ([]*models.AuthEvent, error)
+	Search(ctx context.Context, filter models.AuthEventFilter) ([]*models.AuthEvent, error)
+	// Purge deletes events older than the retention period
+	Purge(ctx context.Context) (int64, error)
+}
+
+type authEventService struct {
+	eventRepo repository.AuthEventRepository
+	retention time.Duration
+	log       logger.Logger
+}
+
+// NewAuthEventService creates the service. A retention of zero keeps events
+// forever.
+func NewAuthEventService(eventRepo repository.AuthEventRepository, retention time.Duration, log logger.Logger) AuthEventService {
+	return &authEventService{
+		eventRepo: eventRepo,
+		retention: retention,
+		log:       log,
+	}
+}
+
+// Record fills in the client's IP address, user agent and request ID from
+// ctx and stores the event. A failed write is logged in full instead.
+func (s *authEventService) Record(ctx context.Context, event *models.AuthEvent) {
+	client := models.ClientInfoFromContext(ctx)
+	if event.IPAddress == "" {
+		event.IPAddress = client.IPAddress
+	}
+	if event.UserAgent == "" {
+		event.UserAgent = client.UserAgent
+	}
+	if event.RequestID == "" {
+		event.RequestID = client.RequestID
+	}
+
+	if err := s.eventRepo.Add(ctx, event); err != nil {
+		s.log.WithContext(ctx).WithError(err).WithFields(map[string]interface{}{
+			"security_event": "auth_event_write_failed",
+			"event_type":     event.Type,
+			"outcome":        event.Outcome,
+			"method":         event.Method,
+			"reason":         event.Reason,
+			"user_id":        event.UserID,
+			"email":          event.Email,
+			"ip_address":     event.IPAddress,
+		}).Error("Failed to write auth event")
+	}
+}
+
+// ListForUser returns the user's own events, newest first
+func (s *authEventService) ListForUser(ctx context.Context, userID string, before *time.Time, limit int) ([]*models.AuthEvent, error) {
+	return s.Search(ctx, models.AuthEventFilter{
+		UserID: userID,
+		Before: before,
+		Limit:  limit,
+	})
+}
+
+func (s *authEventService) Search(ctx context.Context, filter models.AuthEventFilter) ([]*models.AuthEvent, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuthEventPageSize
+	}
+	if filter.Limit > maxAuthEventPageSize {
+		filter.Limit = maxAuthEventPageSize
+	}
+	return s.eventRepo.List(ctx, filter)
+}
+
+func (s *authEventService) Purge(ctx context.Context) (int64, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+	return s.eventRepo.DeleteBefore(ctx, time.Now().Add(-s.retention))
+}
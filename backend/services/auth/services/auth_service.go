@@ -6,35 +6,69 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
 	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
 	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
 	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// TokenIssuer issues a fresh access/refresh token pair for an
+// already-authenticated user
+type TokenIssuer interface {
+	IssueTokens(ctx context.Context, user *models.User) (*models.AuthResponse, error)
+}
+
 type AuthService interface {
+	TokenIssuer
 	SignUp(ctx context.Context, req models.SignUpRequest) (*models.AuthResponse, error)
 	SignIn(ctx context.Context, req models.SignInRequest) (*models.AuthResponse, error)
+	CompleteMFASignIn(ctx context.Context, req models.MFASignInRequest) (*models.AuthResponse, error)
 	RefreshToken(ctx context.Context, refreshToken string) (*models.TokenResponse, error)
-	SignOut(ctx context.Context, userID, refreshToken string) error
+	SignOut(ctx context.Context, userID, refreshToken string, accessToken revocation.Token) error
 	VerifyEmail(ctx context.Context, token string) error
+	ResendVerificationEmail(ctx context.Context, email string) error
 	RequestPasswordReset(ctx context.Context, email string) error
 	ResetPassword(ctx context.Context, token, newPassword string) error
 }
 
+const passwordResetTTL = 1 * time.Hour
+
 type authService struct {
-	userRepo   repository.UserRepository
-	jwtService JWTService
-	log        logger.Logger
+	userRepo       repository.UserRepository
+	jwtService     JWTService
+	emailService   EmailService
+	mfaService     MFAService
+	loginGuard     LoginGuard
+	passwordPolicy PasswordPolicy
+	hasher         PasswordHasher
+	revocations    revocation.Store
+	events         AuthEventRecorder
+	logins         LoginRecorder
+	cfg            *config.AuthConfig
+	log            logger.Logger
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
-func NewAuthService(userRepo repository.UserRepository, jwtService JWTService, log logger.Logger) AuthService {
+func NewAuthService(userRepo repository.UserRepository, jwtService JWTService, emailService EmailService, mfaService MFAService, loginGuard LoginGuard, passwordPolicy PasswordPolicy, hasher PasswordHasher, revocations revocation.Store, events AuthEventRecorder, logins LoginRecorder, cfg *config.AuthConfig, log logger.Logger) AuthService {
 	return &authService{
-		userRepo:   userRepo,
-		jwtService: jwtService,
-		log:        log,
+		userRepo:       userRepo,
+		jwtService:     jwtService,
+		emailService:   emailService,
+		mfaService:     mfaService,
+		loginGuard:     loginGuard,
+		passwordPolicy: passwordPolicy,
+		hasher:         hasher,
+		revocations:    revocations,
+		events:         events,
+		logins:         logins,
+		cfg:            cfg,
+		log:            log,
 	}
 }
 
@@ -45,11 +79,17 @@ func (s *authService) SignUp(ctx context.Context, req models.SignUpRequest) (*mo
 	existingUser, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err == nil && existingUser != nil {
 		log.WithField("email", req.Email).Warn("User already exists")
+		s.events.Record(ctx, &models.AuthEvent{Type: AuthEventSignUp, Outcome: AuthOutcomeFailure, Email: req.Email, Reason: "email_taken"})
 		return nil, errors.New("user already exists")
 	}
 
+	if err := s.passwordPolicy.Validate(ctx, req.Password, &models.User{Email: req.Email, Name: req.Name}); err != nil {
+		s.events.Record(ctx, &models.AuthEvent{Type: AuthEventSignUp, Outcome: AuthOutcomeFailure, Email: req.Email, Reason: "password_rejected"})
+		return nil, err
+	}
+
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(req.Password)
 	if err != nil {
 		log.WithError(err).Error("Failed to hash password")
 		return nil, fmt.Errorf("failed to hash password: %w", err)
@@ -59,7 +99,7 @@ func (s *authService) SignUp(ctx context.Context, req models.SignUpRequest) (*mo
 	user := &models.User{
 		Email:        req.Email,
 		Name:         req.Name,
-		PasswordHash: string(hashedPassword),
+		PasswordHash: hashedPassword,
 		IsActive:     true,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
@@ -71,62 +111,187 @@ func (s *authService) SignUp(ctx context.Context, req models.SignUpRequest) (*mo
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	// Generate tokens
-	accessToken, err := s.jwtService.GenerateAccessToken(createdUser.ID, createdUser.Email)
-	if err != nil {
-		log.WithError(err).Error("Failed to generate access token")
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	if err := s.passwordPolicy.Remember(ctx, createdUser.ID, createdUser.PasswordHash); err != nil {
+		log.WithError(err).Warn("Failed to record password history")
 	}
 
-	refreshToken, err := s.jwtService.GenerateRefreshToken(createdUser.ID)
-	if err != nil {
-		log.WithError(err).Error("Failed to generate refresh token")
-		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	// Issue email verification token
+	if err := s.issueEmailVerification(ctx, createdUser); err != nil {
+		log.WithError(err).Warn("Failed to issue email verification token")
+		// Don't fail the signup for this - the user can request a new one
 	}
 
-	// Store refresh token
-	if err := s.userRepo.StoreRefreshToken(ctx, createdUser.ID, refreshToken, 30*24*time.Hour); err != nil {
-		log.WithError(err).Error("Failed to store refresh token")
-		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	s.events.Record(ctx, &models.AuthEvent{Type: AuthEventSignUp, Outcome: AuthOutcomeSuccess, UserID: createdUser.ID, Email: createdUser.Email, Method: AuthMethodPassword})
+	log.WithField("user_id", createdUser.ID).Info("User successfully created")
+
+	// Sign-in would refuse the unverified account, so sign-up mustn't hand
+	// out the session it would have refused
+	if s.cfg.RequireEmailVerification {
+		return &models.AuthResponse{
+			User:                      createdUser.ToPublicUser(),
+			EmailVerificationRequired: true,
+		}, nil
 	}
 
-	log.WithField("user_id", createdUser.ID).Info("User successfully created")
+	// Generate tokens
+	response, err := s.IssueTokens(ctx, createdUser)
+	if err != nil {
+		return nil, err
+	}
 
-	return &models.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    3600, // 1 hour in seconds
-		User:         createdUser.ToPublicUser(),
-	}, nil
+	// The device the account was created on is not a new device later
+	s.logins.RecordLogin(ctx, createdUser, AuthMethodPassword)
+
+	return response, nil
 }
 
 func (s *authService) SignIn(ctx context.Context, req models.SignInRequest) (*models.AuthResponse, error) {
 	log := s.log.WithContext(ctx).WithField("operation", "signin")
 
+	// Refuse early while the account or address is backing off
+	ip := models.ClientInfoFromContext(ctx).IPAddress
+	if err := s.loginGuard.Check(ctx, req.Email, ip); err != nil {
+		log.WithField("email", req.Email).Warn("Signin throttled")
+		s.recordSignInFailure(ctx, nil, req.Email, "throttled")
+		return nil, err
+	}
+
 	// Get user by email
 	user, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil {
 		log.WithField("email", req.Email).Debug("User not found")
+		// Spend as long as a real password check so timing doesn't reveal
+		// whether the account exists
+		s.hasher.Verify(req.Password, s.dummyPasswordHash())
+		s.loginGuard.RecordFailure(ctx, req.Email, ip)
+		s.recordSignInFailure(ctx, nil, req.Email, "unknown_email")
 		return nil, errors.New("invalid credentials")
 	}
 
 	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+	match, rehash, err := s.hasher.Verify(req.Password, user.PasswordHash)
+	if err != nil {
+		log.WithError(err).WithField("user_id", user.ID).Error("Failed to verify password hash")
+	}
+	if !match {
 		log.WithField("email", req.Email).Debug("Invalid password")
+		s.recordSignInFailure(ctx, user, req.Email, "invalid_password")
+		if s.loginGuard.RecordFailure(ctx, req.Email, ip) {
+			alert := SecurityAlert{
+				Title:       "Sign-in temporarily locked",
+				Description: fmt.Sprintf("There were too many failed attempts to sign in to your FlowTime account, so sign-in is locked for %s. Resetting your password unlocks it straight away.", humanizeDuration(s.cfg.LoginLockoutDuration)),
+			}
+			if err := s.emailService.SendSecurityAlert(ctx, user, alert); err != nil {
+				log.WithError(err).Warn("Failed to send lockout alert")
+			}
+		}
 		return nil, errors.New("invalid credentials")
 	}
 
+	// Move hashes made with an older algorithm or parameters onto the
+	// current ones while the password is at hand
+	if rehash {
+		s.upgradePasswordHash(ctx, user, req.Password)
+	}
+
 	// Check if user is active
 	if !user.IsActive {
 		log.WithField("email", req.Email).Warn("Inactive user attempted to sign in")
+		s.recordSignInFailure(ctx, user, req.Email, "account_inactive")
 		return nil, errors.New("account is inactive")
 	}
 
+	// An admin has forced a reset; only a password reset lets them back in
+	if user.PasswordResetRequired {
+		log.WithField("user_id", user.ID).Info("User with forced password reset attempted to sign in")
+		s.recordSignInFailure(ctx, user, req.Email, "password_reset_required")
+		return nil, ErrPasswordResetRequired
+	}
+
+	// Check if email verification is required
+	if s.cfg.RequireEmailVerification && !user.EmailVerified {
+		log.WithField("user_id", user.ID).Info("Unverified user attempted to sign in")
+		s.recordSignInFailure(ctx, user, req.Email, "email_not_verified")
+		return nil, errors.New("email not verified")
+	}
+
+	// Require a second factor if the user has one set up. The failure count
+	// is only reset once that has been passed, so a known password can't be
+	// used to keep resetting it between wrong codes.
+	if err := s.mfaService.Challenge(ctx, user); err != nil {
+		return nil, err
+	}
+	s.loginGuard.RecordSuccess(ctx, req.Email)
+
 	// Generate tokens
-	accessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Email)
+	response, err := s.IssueTokens(ctx, user)
 	if err != nil {
-		log.WithError(err).Error("Failed to generate access token")
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+		return nil, err
+	}
+
+	s.logins.RecordLogin(ctx, user, AuthMethodPassword)
+
+	s.events.Record(ctx, &models.AuthEvent{Type: AuthEventSignIn, Outcome: AuthOutcomeSuccess, UserID: user.ID, Email: user.Email, Method: AuthMethodPassword})
+	log.WithField("user_id", user.ID).Info("User successfully signed in")
+
+	return response, nil
+}
+
+// CompleteMFASignIn exchanges a sign-in challenge and a TOTP or recovery
+// code for tokens
+func (s *authService) CompleteMFASignIn(ctx context.Context, req models.MFASignInRequest) (*models.AuthResponse, error) {
+	log := s.log.WithContext(ctx).WithField("operation", "signin_mfa")
+
+	userID, err := s.mfaService.VerifyChallenge(ctx, req.ChallengeToken, req.Code)
+	if err != nil {
+		var throttled *LoginThrottledError
+		switch {
+		case errors.Is(err, ErrInvalidMFACode):
+			s.events.Record(ctx, &models.AuthEvent{Type: AuthEventSignIn, Outcome: AuthOutcomeFailure, UserID: userID, Method: AuthMethodMFA, Reason: "invalid_code"})
+		case errors.As(err, &throttled):
+			s.events.Record(ctx, &models.AuthEvent{Type: AuthEventSignIn, Outcome: AuthOutcomeFailure, UserID: userID, Method: AuthMethodMFA, Reason: "throttled"})
+		}
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to get user")
+		return nil, errors.New("user not found")
+	}
+
+	// The account may have been deactivated since the challenge was issued
+	if !user.IsActive {
+		log.WithField("user_id", user.ID).Warn("Inactive user attempted to sign in")
+		s.events.Record(ctx, &models.AuthEvent{Type: AuthEventSignIn, Outcome: AuthOutcomeFailure, UserID: user.ID, Email: user.Email, Method: AuthMethodMFA, Reason: "account_inactive"})
+		return nil, errors.New("account is inactive")
+	}
+
+	response, err := s.IssueTokens(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logins.RecordLogin(ctx, user, AuthMethodMFA)
+
+	s.events.Record(ctx, &models.AuthEvent{Type: AuthEventSignIn, Outcome: AuthOutcomeSuccess, UserID: user.ID, Email: user.Email, Method: AuthMethodMFA})
+	log.WithField("user_id", user.ID).Info("User successfully signed in with second factor")
+
+	return response, nil
+}
+
+// IssueTokens generates and stores a new token pair for the user. Every
+// sign-in method ends here, so an admin's forced password reset is enforced
+// here rather than by each method.
+func (s *authService) IssueTokens(ctx context.Context, user *models.User) (*models.AuthResponse, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "issue_tokens",
+		"user_id":   user.ID,
+	})
+
+	if user.PasswordResetRequired {
+		log.Info("User with forced password reset attempted to sign in")
+		return nil, ErrPasswordResetRequired
 	}
 
 	refreshToken, err := s.jwtService.GenerateRefreshToken(user.ID)
@@ -135,24 +300,23 @@ func (s *authService) SignIn(ctx context.Context, req models.SignInRequest) (*mo
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
-	// Store refresh token
-	if err := s.userRepo.StoreRefreshToken(ctx, user.ID, refreshToken, 30*24*time.Hour); err != nil {
+	// Store refresh token, starting a new session for the requesting device
+	sessionID, err := s.userRepo.StoreRefreshToken(ctx, user.ID, refreshToken, models.ClientInfoFromContext(ctx), 30*24*time.Hour)
+	if err != nil {
 		log.WithError(err).Error("Failed to store refresh token")
 		return nil, fmt.Errorf("failed to store refresh token: %w", err)
 	}
 
-	// Update last login
-	if err := s.userRepo.UpdateLastLogin(ctx, user.ID); err != nil {
-		log.WithError(err).Warn("Failed to update last login")
-		// Don't fail the login for this
+	accessToken, err := s.jwtService.GenerateAccessToken(user, sessionID, "")
+	if err != nil {
+		log.WithError(err).Error("Failed to generate access token")
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
-	log.WithField("user_id", user.ID).Info("User successfully signed in")
-
 	return &models.AuthResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
-		ExpiresIn:    3600, // 1 hour in seconds
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
 		User:         user.ToPublicUser(),
 	}, nil
 }
@@ -164,13 +328,7 @@ func (s *authService) RefreshToken(ctx context.Context, refreshToken string) (*m
 	claims, err := s.jwtService.ValidateRefreshToken(refreshToken)
 	if err != nil {
 		log.WithError(err).Debug("Invalid refresh token")
-		return nil, errors.New("invalid refresh token")
-	}
-
-	// Check if refresh token is valid in database
-	valid, err := s.userRepo.ValidateRefreshToken(ctx, claims.UserID, refreshToken)
-	if err != nil || !valid {
-		log.WithField("user_id", claims.UserID).Debug("Refresh token not found in database")
+		s.events.Record(ctx, &models.AuthEvent{Type: AuthEventRefresh, Outcome: AuthOutcomeFailure, Reason: "invalid_token"})
 		return nil, errors.New("invalid refresh token")
 	}
 
@@ -178,44 +336,75 @@ func (s *authService) RefreshToken(ctx context.Context, refreshToken string) (*m
 	user, err := s.userRepo.GetByID(ctx, claims.UserID)
 	if err != nil {
 		log.WithError(err).Error("Failed to get user")
+		s.events.Record(ctx, &models.AuthEvent{Type: AuthEventRefresh, Outcome: AuthOutcomeFailure, Reason: "unknown_user"})
 		return nil, errors.New("user not found")
 	}
 
-	// Generate new tokens
-	newAccessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Email)
-	if err != nil {
-		log.WithError(err).Error("Failed to generate new access token")
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	// Hold refreshes to the checks sign-in makes, so a session doesn't
+	// outlive the account being deactivated or its email requirement. The
+	// refresh token is left unused and works again once the account is
+	// back in good standing.
+	if !user.IsActive {
+		log.WithField("user_id", user.ID).Warn("Inactive user attempted to refresh tokens")
+		s.events.Record(ctx, &models.AuthEvent{Type: AuthEventRefresh, Outcome: AuthOutcomeFailure, UserID: user.ID, Email: user.Email, Reason: "account_inactive"})
+		return nil, errors.New("account is inactive")
+	}
+	if s.cfg.RequireEmailVerification && !user.EmailVerified {
+		log.WithField("user_id", user.ID).Info("Unverified user attempted to refresh tokens")
+		s.events.Record(ctx, &models.AuthEvent{Type: AuthEventRefresh, Outcome: AuthOutcomeFailure, UserID: user.ID, Email: user.Email, Reason: "email_not_verified"})
+		return nil, errors.New("email not verified")
 	}
 
+	// Generate new tokens
 	newRefreshToken, err := s.jwtService.GenerateRefreshToken(user.ID)
 	if err != nil {
 		log.WithError(err).Error("Failed to generate new refresh token")
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
-	// Revoke old refresh token
-	if err := s.userRepo.RevokeRefreshToken(ctx, user.ID, refreshToken); err != nil {
-		log.WithError(err).Warn("Failed to revoke old refresh token")
-		// Continue anyway
+	// Revoke the old refresh token and store its successor atomically
+	familyID, orgID, err := s.userRepo.RotateRefreshToken(ctx, user.ID, refreshToken, newRefreshToken, models.ClientInfoFromContext(ctx), 30*24*time.Hour)
+	switch {
+	case errors.Is(err, repository.ErrRefreshTokenReused):
+		log.WithFields(map[string]interface{}{
+			"security_event": "refresh_token_reuse",
+			"user_id":        user.ID,
+			"family_id":      familyID,
+		}).Warn("Revoked refresh token was presented again; token family revoked")
+		// Whoever holds the stolen token may hold the session's access
+		// tokens too
+		revokeSessionAccessTokens(ctx, s.revocations, familyID, log)
+		s.events.Record(ctx, &models.AuthEvent{Type: AuthEventTokenReuse, Outcome: AuthOutcomeFailure, UserID: user.ID, Email: user.Email, Reason: "session_revoked"})
+		return nil, errors.New("invalid refresh token")
+	case errors.Is(err, repository.ErrRefreshTokenNotFound):
+		log.WithField("user_id", user.ID).Debug("Refresh token not found in database")
+		s.events.Record(ctx, &models.AuthEvent{Type: AuthEventRefresh, Outcome: AuthOutcomeFailure, UserID: user.ID, Email: user.Email, Reason: "token_not_found"})
+		return nil, errors.New("invalid refresh token")
+	case err != nil:
+		log.WithError(err).Error("Failed to rotate refresh token")
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
 	}
 
-	// Store new refresh token
-	if err := s.userRepo.StoreRefreshToken(ctx, user.ID, newRefreshToken, 30*24*time.Hour); err != nil {
-		log.WithError(err).Error("Failed to store new refresh token")
-		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	newAccessToken, err := s.jwtService.GenerateAccessToken(user, familyID, orgID)
+	if err != nil {
+		log.WithError(err).Error("Failed to generate new access token")
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
+	s.events.Record(ctx, &models.AuthEvent{Type: AuthEventRefresh, Outcome: AuthOutcomeSuccess, UserID: user.ID, Email: user.Email})
 	log.WithField("user_id", user.ID).Info("Tokens successfully refreshed")
 
 	return &models.TokenResponse{
 		AccessToken:  newAccessToken,
 		RefreshToken: newRefreshToken,
-		ExpiresIn:    3600, // 1 hour in seconds
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
 	}, nil
 }
 
-func (s *authService) SignOut(ctx context.Context, userID, refreshToken string) error {
+// SignOut ends the session the refresh token belongs to and revokes the
+// access token used for the request. Without a refresh token every session
+// and every access token the user holds is revoked.
+func (s *authService) SignOut(ctx context.Context, userID, refreshToken string, accessToken revocation.Token) error {
 	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
 		"operation": "signout",
 		"user_id":   userID,
@@ -227,14 +416,35 @@ func (s *authService) SignOut(ctx context.Context, userID, refreshToken string)
 			log.WithError(err).Warn("Failed to revoke refresh token")
 			// Continue anyway
 		}
+
+		if accessToken.ID != "" {
+			if err := s.revocations.RevokeToken(ctx, accessToken); err != nil {
+				log.WithError(err).Warn("Failed to revoke access token")
+				// Continue anyway
+			}
+		}
+		// Along with any other access token the session was issued
+		if accessToken.SessionID != "" {
+			revokeSessionAccessTokens(ctx, s.revocations, accessToken.SessionID, log)
+		}
 	} else {
 		// Revoke all refresh tokens for the user
 		if err := s.userRepo.RevokeAllRefreshTokens(ctx, userID); err != nil {
 			log.WithError(err).Warn("Failed to revoke all refresh tokens")
 			// Continue anyway
 		}
+
+		if err := s.revocations.RevokeUser(ctx, userID, time.Now(), AccessTokenTTL); err != nil {
+			log.WithError(err).Warn("Failed to revoke access tokens")
+			// Continue anyway
+		}
 	}
 
+	event := &models.AuthEvent{Type: AuthEventSignOut, Outcome: AuthOutcomeSuccess, UserID: userID}
+	if refreshToken == "" {
+		event.Reason = "all_sessions"
+	}
+	s.events.Record(ctx, event)
 	log.Info("User successfully signed out")
 	return nil
 }
@@ -242,14 +452,76 @@ func (s *authService) SignOut(ctx context.Context, userID, refreshToken string)
 func (s *authService) VerifyEmail(ctx context.Context, token string) error {
 	log := s.log.WithContext(ctx).WithField("operation", "verify_email")
 
-	// TODO: Implement email verification logic
-	// This would typically:
-	// 1. Validate the email verification token
-	// 2. Mark the user's email as verified
-	// 3. Delete the verification token
+	// Validate verification token and get user ID
+	userID, err := s.userRepo.ValidateEmailVerificationToken(ctx, token)
+	if err != nil {
+		log.WithError(err).Debug("Invalid verification token")
+		return errors.New("invalid or expired verification token")
+	}
+
+	// Mark email as verified
+	if err := s.userRepo.MarkEmailVerified(ctx, userID); err != nil {
+		log.WithError(err).Error("Failed to mark email as verified")
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	// Delete the used verification token
+	if err := s.userRepo.DeleteEmailVerificationToken(ctx, token); err != nil {
+		log.WithError(err).Warn("Failed to delete used verification token")
+		// Continue anyway
+	}
 
-	log.Warn("Email verification not implemented")
-	return errors.New("email verification not implemented")
+	log.WithField("user_id", userID).Info("Email successfully verified")
+	return nil
+}
+
+func (s *authService) ResendVerificationEmail(ctx context.Context, email string) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "resend_verification_email",
+		"email":     email,
+	})
+
+	// Get user by email
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		// Don't reveal if user exists or not
+		log.Debug("User not found for verification resend")
+		return nil
+	}
+
+	if user.EmailVerified {
+		log.WithField("user_id", user.ID).Debug("Email already verified")
+		return nil
+	}
+
+	if err := s.issueEmailVerification(ctx, user); err != nil {
+		log.WithError(err).Error("Failed to issue email verification token")
+		return err
+	}
+
+	log.WithField("user_id", user.ID).Info("Verification email resent")
+	return nil
+}
+
+// issueEmailVerification creates a fresh verification token for the user,
+// replacing any token issued before.
+func (s *authService) issueEmailVerification(ctx context.Context, user *models.User) error {
+	token, err := generateSecureToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate verification token: %w", err)
+	}
+
+	expiresAt := time.Now().Add(s.cfg.EmailVerificationTTL)
+	if err := s.userRepo.StoreEmailVerificationToken(ctx, user.ID, token, expiresAt); err != nil {
+		return fmt.Errorf("failed to store verification token: %w", err)
+	}
+
+	if err := s.emailService.SendEmailVerification(ctx, user, token, s.cfg.EmailVerificationTTL); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+
+	s.log.WithContext(ctx).WithField("user_id", user.ID).Info("Email verification token issued")
+	return nil
 }
 
 func (s *authService) RequestPasswordReset(ctx context.Context, email string) error {
@@ -263,28 +535,31 @@ func (s *authService) RequestPasswordReset(ctx context.Context, email string) er
 	if err != nil {
 		// Don't reveal if user exists or not
 		log.WithField("email", email).Debug("User not found for password reset")
+		s.events.Record(ctx, &models.AuthEvent{Type: AuthEventPasswordResetRequest, Outcome: AuthOutcomeFailure, Email: email, Reason: "unknown_email"})
 		return nil
 	}
 
 	// Generate reset token
-	tokenBytes := make([]byte, 32)
-	if _, err := rand.Read(tokenBytes); err != nil {
+	resetToken, err := generateSecureToken()
+	if err != nil {
 		log.WithError(err).Error("Failed to generate reset token")
 		return fmt.Errorf("failed to generate reset token: %w", err)
 	}
-	resetToken := hex.EncodeToString(tokenBytes)
 
 	// Store reset token with 1 hour expiry
-	expiresAt := time.Now().Add(1 * time.Hour)
+	expiresAt := time.Now().Add(passwordResetTTL)
 	if err := s.userRepo.StorePasswordResetToken(ctx, user.ID, resetToken, expiresAt); err != nil {
 		log.WithError(err).Error("Failed to store reset token")
 		return fmt.Errorf("failed to store reset token: %w", err)
 	}
 
-	// TODO: Send reset email
-	// This would typically send an email with a link like:
-	// https://app.flowtime.ai/reset-password?token=resetToken
+	// Send reset email
+	if err := s.emailService.SendPasswordReset(ctx, user, resetToken, passwordResetTTL); err != nil {
+		log.WithError(err).Error("Failed to send reset email")
+		return fmt.Errorf("failed to send reset email: %w", err)
+	}
 
+	s.events.Record(ctx, &models.AuthEvent{Type: AuthEventPasswordResetRequest, Outcome: AuthOutcomeSuccess, UserID: user.ID, Email: user.Email})
 	log.WithField("user_id", user.ID).Info("Password reset requested")
 	return nil
 }
@@ -292,38 +567,143 @@ func (s *authService) RequestPasswordReset(ctx context.Context, email string) er
 func (s *authService) ResetPassword(ctx context.Context, token, newPassword string) error {
 	log := s.log.WithContext(ctx).WithField("operation", "reset_password")
 
-	// Validate reset token and get user ID
-	userID, err := s.userRepo.ValidatePasswordResetToken(ctx, token)
+	// Check the new password before spending the token, so the user can try
+	// another one with the same link
+	userID, err := s.userRepo.PeekPasswordResetToken(ctx, token)
 	if err != nil {
+		log.WithError(err).Debug("Invalid reset token")
+		s.events.Record(ctx, &models.AuthEvent{Type: AuthEventPasswordReset, Outcome: AuthOutcomeFailure, Reason: "invalid_token"})
+		return errors.New("invalid or expired reset token")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		log.WithError(err).Debug("Reset token user not found")
+		return errors.New("invalid or expired reset token")
+	}
+
+	if err := s.passwordPolicy.Validate(ctx, newPassword, user); err != nil {
+		s.events.Record(ctx, &models.AuthEvent{Type: AuthEventPasswordReset, Outcome: AuthOutcomeFailure, UserID: user.ID, Email: user.Email, Reason: "password_rejected"})
+		return err
+	}
+
+	// Use up the reset token
+	if _, err := s.userRepo.ValidatePasswordResetToken(ctx, token); err != nil {
 		log.WithError(err).Debug("Invalid reset token")
 		return errors.New("invalid or expired reset token")
 	}
 
 	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(newPassword)
 	if err != nil {
 		log.WithError(err).Error("Failed to hash new password")
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
 	// Update password
-	if err := s.userRepo.UpdatePassword(ctx, userID, string(hashedPassword)); err != nil {
+	if err := s.userRepo.UpdatePassword(ctx, userID, hashedPassword); err != nil {
 		log.WithError(err).Error("Failed to update password")
 		return fmt.Errorf("failed to update password: %w", err)
 	}
 
+	if err := s.passwordPolicy.Remember(ctx, userID, hashedPassword); err != nil {
+		log.WithError(err).Warn("Failed to record password history")
+	}
+
 	// Revoke all refresh tokens for security
 	if err := s.userRepo.RevokeAllRefreshTokens(ctx, userID); err != nil {
 		log.WithError(err).Warn("Failed to revoke refresh tokens after password reset")
 		// Continue anyway
 	}
 
+	// And any access tokens still in circulation
+	if err := s.revocations.RevokeUser(ctx, userID, time.Now(), AccessTokenTTL); err != nil {
+		log.WithError(err).Warn("Failed to revoke access tokens after password reset")
+		// Continue anyway
+	}
+
 	// Delete the used reset token
 	if err := s.userRepo.DeletePasswordResetToken(ctx, token); err != nil {
 		log.WithError(err).Warn("Failed to delete used reset token")
 		// Continue anyway
 	}
 
+	// Proving control of the mailbox lifts any sign-in lockout
+	if err := s.loginGuard.Unlock(ctx, user.Email); err != nil {
+		log.WithError(err).Warn("Failed to clear sign-in lockout after password reset")
+	}
+
+	// Let the user know their password changed
+	alert := SecurityAlert{
+		Title:       "Your password was changed",
+		Description: "The password for your FlowTime account was just reset using a password reset link.",
+	}
+	if err := s.emailService.SendSecurityAlert(ctx, user, alert); err != nil {
+		log.WithError(err).Warn("Failed to send password change alert")
+	}
+
+	s.events.Record(ctx, &models.AuthEvent{Type: AuthEventPasswordReset, Outcome: AuthOutcomeSuccess, UserID: user.ID, Email: user.Email})
 	log.WithField("user_id", userID).Info("Password successfully reset")
 	return nil
 }
+
+// recordSignInFailure records a failed password sign-in. user is nil when
+// the email doesn't belong to an account.
+func (s *authService) recordSignInFailure(ctx context.Context, user *models.User, email, reason string) {
+	event := &models.AuthEvent{
+		Type:    AuthEventSignIn,
+		Outcome: AuthOutcomeFailure,
+		Email:   email,
+		Method:  AuthMethodPassword,
+		Reason:  reason,
+	}
+	if user != nil {
+		event.UserID = user.ID
+	}
+	s.events.Record(ctx, event)
+}
+
+// ErrPasswordResetRequired is returned by every sign-in when an admin has forced a
+// password reset on the account
+var ErrPasswordResetRequired = errors.New("password reset required")
+
+// dummyPasswordHash is compared against when there is no account, so a
+// failed sign-in costs the same either way
+func (s *authService) dummyPasswordHash() string {
+	s.dummyHashOnce.Do(func() {
+		s.dummyHash, _ = s.hasher.Hash("not-a-real-password")
+	})
+	return s.dummyHash
+}
+
+// upgradePasswordHash replaces the user's stored hash with one from the
+// current hasher. Failing only leaves the old hash in place for next time.
+func (s *authService) upgradePasswordHash(ctx context.Context, user *models.User, password string) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "upgrade_password_hash",
+		"user_id":   user.ID,
+	})
+
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		log.WithError(err).Warn("Failed to rehash password")
+		return
+	}
+
+	if err := s.userRepo.ReplacePasswordHash(ctx, user.ID, user.PasswordHash, hash); err != nil {
+		log.WithError(err).Warn("Failed to store upgraded password hash")
+		return
+	}
+
+	user.PasswordHash = hash
+	log.Info("Password hash upgraded")
+}
+
+// generateSecureToken returns a random 256-bit hex encoded token
+func generateSecureToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(tokenBytes), nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+// fakeTokenUserRepo stores refresh tokens, counting the sessions started
+type fakeTokenUserRepo struct {
+	repository.UserRepository
+	sessions int
+}
+
+func (f *fakeTokenUserRepo) StoreRefreshToken(ctx context.Context, userID, token string, client models.ClientInfo, ttl time.Duration) (string, error) {
+	f.sessions++
+	return "6f1c7c1e-2a43-4d8b-9a57-6f0e5d1f1a01", nil
+}
+
+func TestIssueTokensRefusesForcedPasswordReset(t *testing.T) {
+	tests := []struct {
+		name          string
+		resetRequired bool
+		wantErr       error
+	}{
+		{"in good standing", false, nil},
+		{"reset forced by an admin", true, ErrPasswordResetRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeTokenUserRepo{}
+			jwtService := NewJWTService("test-secret-that-is-long-enough-for-hs256", logger.New())
+			s := NewAuthService(repo, jwtService, nil, nil, nil, nil, nil, nil, nil, nil, &config.AuthConfig{}, logger.New())
+
+			user := &models.User{ID: "u1", Email: "user@example.com", IsActive: true, PasswordResetRequired: tt.resetRequired}
+			_, err := s.IssueTokens(context.Background(), user)
+			if err != tt.wantErr {
+				t.Fatalf("IssueTokens() error = %v, want %v", err, tt.wantErr)
+			}
+			if (repo.sessions > 0) != (tt.wantErr == nil) {
+				t.Errorf("session started = %v", repo.sessions > 0)
+			}
+		})
+	}
+}
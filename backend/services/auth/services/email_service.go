@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/mailer"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+// EmailService renders transactional emails and places them in the outbox.
+// Delivery happens asynchronously in the OutboxWorker, so a mail server
+// outage never fails the request that triggered the email.
+type EmailService interface {
+	SendEmailVerification(ctx context.Context, user *models.User, token string, expiresIn time.Duration) error
+	SendPasswordReset(ctx context.Context, user *models.User, token string, expiresIn time.Duration) error
+	SendSecurityAlert(ctx context.Context, user *models.User, alert SecurityAlert) error
+	SendEmailChangeVerification(ctx context.Context, user *models.User, newEmail, token string, expiresIn time.Duration) error
+	SendMagicLink(ctx context.Context, user *models.User, token string, expiresIn time.Duration) error
+	SendOrganizationInvitation(ctx context.Context, email string, inviter *models.User, org *models.Organization, role, token string, expiresIn time.Duration) error
+}
+
+// SecurityAlert describes account activity the user should know about
+type SecurityAlert struct {
+	Title       string
+	Description string
+	OccurredAt  time.Time
+	IPAddress   string
+	UserAgent   string
+}
+
+type emailService struct {
+	outboxRepo repository.OutboxRepository
+	renderer   *mailer.Renderer
+	baseURL    string
+	log        logger.Logger
+}
+
+func NewEmailService(outboxRepo repository.OutboxRepository, renderer *mailer.Renderer, cfg *config.AuthConfig, log logger.Logger) EmailService {
+	return &emailService{
+		outboxRepo: outboxRepo,
+		renderer:   renderer,
+		baseURL:    cfg.AppBaseURL,
+		log:        log,
+	}
+}
+
+func (s *emailService) SendEmailVerification(ctx context.Context, user *models.User, token string, expiresIn time.Duration) error {
+	return s.enqueue(ctx, mailer.TemplateEmailVerification, user.Email, map[string]interface{}{
+		"Name":      displayName(user),
+		"Email":     user.Email,
+		"ActionURL": s.link("/verify-email", token),
+		"ExpiresIn": humanizeDuration(expiresIn),
+	})
+}
+
+func (s *emailService) SendPasswordReset(ctx context.Context, user *models.User, token string, expiresIn time.Duration) error {
+	return s.enqueue(ctx, mailer.TemplatePasswordReset, user.Email, map[string]interface{}{
+		"Name":      displayName(user),
+		"ActionURL": s.link("/reset-password", token),
+		"ExpiresIn": humanizeDuration(expiresIn),
+	})
+}
+
+// SendEmailChangeVerification asks the new address to confirm an email change
+func (s *emailService) SendEmailChangeVerification(ctx context.Context, user *models.User, newEmail, token string, expiresIn time.Duration) error {
+	return s.enqueue(ctx, mailer.TemplateEmailChange, newEmail, map[string]interface{}{
+		"Name":      displayName(user),
+		"Email":     newEmail,
+		"ActionURL": s.link("/confirm-email", token),
+		"ExpiresIn": humanizeDuration(expiresIn),
+	})
+}
+
+// SendMagicLink emails a passwordless sign-in link
+func (s *emailService) SendMagicLink(ctx context.Context, user *models.User, token string, expiresIn time.Duration) error {
+	return s.enqueue(ctx, mailer.TemplateMagicLink, user.Email, map[string]interface{}{
+		"Name":      displayName(user),
+		"ActionURL": s.link("/magic-link", token),
+		"ExpiresIn": humanizeDuration(expiresIn),
+	})
+}
+
+// SendOrganizationInvitation invites an address, which may not have an
+// account yet, to join an organization
+func (s *emailService) SendOrganizationInvitation(ctx context.Context, email string, inviter *models.User, org *models.Organization, role, token string, expiresIn time.Duration) error {
+	inviterName := inviter.Name
+	if inviterName == "" {
+		inviterName = inviter.Email
+	}
+
+	return s.enqueue(ctx, mailer.TemplateOrganizationInvitation, email, map[string]interface{}{
+		"InviterName":      inviterName,
+		"OrganizationName": org.Name,
+		"Role":             roleArticle(role),
+		"ActionURL":        s.link("/invitations/accept", token),
+		"ExpiresIn":        humanizeDuration(expiresIn),
+	})
+}
+
+func (s *emailService) SendSecurityAlert(ctx context.Context, user *models.User, alert SecurityAlert) error {
+	if alert.OccurredAt.IsZero() {
+		alert.OccurredAt = time.Now()
+	}
+
+	// Default to the device that made the request
+	client := models.ClientInfoFromContext(ctx)
+	if alert.IPAddress == "" {
+		alert.IPAddress = client.IPAddress
+	}
+	if alert.UserAgent == "" {
+		alert.UserAgent = client.UserAgent
+	}
+
+	return s.enqueue(ctx, mailer.TemplateSecurityAlert, user.Email, map[string]interface{}{
+		"Name":        displayName(user),
+		"Title":       alert.Title,
+		"Description": alert.Description,
+		"OccurredAt":  alert.OccurredAt.UTC(),
+		"IPAddress":   alert.IPAddress,
+		"UserAgent":   alert.UserAgent,
+		"ActionURL":   s.baseURL + "/forgot-password",
+	})
+}
+
+func (s *emailService) enqueue(ctx context.Context, template, to string, data map[string]interface{}) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "enqueue_email",
+		"template":  template,
+	})
+
+	msg, err := s.renderer.Render(template, to, data)
+	if err != nil {
+		log.WithError(err).Error("Failed to render email")
+		return fmt.Errorf("failed to render email: %w", err)
+	}
+
+	email := &models.OutboxEmail{
+		ToAddress: msg.To,
+		Template:  template,
+		Subject:   msg.Subject,
+		TextBody:  msg.TextBody,
+	}
+	if msg.HTMLBody != "" {
+		email.HTMLBody = &msg.HTMLBody
+	}
+
+	if err := s.outboxRepo.Enqueue(ctx, email); err != nil {
+		log.WithError(err).Error("Failed to enqueue email")
+		return err
+	}
+
+	log.WithField("email_id", email.ID).Info("Email queued for delivery")
+	return nil
+}
+
+func (s *emailService) link(path, token string) string {
+	return s.baseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func displayName(user *models.User) string {
+	if user.Name != "" {
+		return user.Name
+	}
+	return "there"
+}
+
+// roleArticle puts the right indefinite article before an organization role
+func roleArticle(role string) string {
+	if strings.ContainsRune("aeiou", rune(role[0])) {
+		return "an " + role
+	}
+	return "a " + role
+}
+
+func humanizeDuration(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%d days", int(d.Hours()/24))
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	case d >= time.Hour:
+		return "1 hour"
+	default:
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	}
+}
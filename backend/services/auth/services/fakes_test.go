@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+)
+
+// fakeRevocations records revocations. Methods the tests don't use panic
+// through the nil embedded Store.
+type fakeRevocations struct {
+	revocation.Store
+	tokens   []revocation.Token
+	users    []string
+	sessions []string
+}
+
+func (f *fakeRevocations) RevokeToken(ctx context.Context, token revocation.Token) error {
+	f.tokens = append(f.tokens, token)
+	return nil
+}
+
+func (f *fakeRevocations) RevokeUser(ctx context.Context, userID string, before time.Time, ttl time.Duration) error {
+	f.users = append(f.users, userID)
+	return nil
+}
+
+func (f *fakeRevocations) RevokeSession(ctx context.Context, sessionID string, before time.Time, ttl time.Duration) error {
+	f.sessions = append(f.sessions, sessionID)
+	return nil
+}
+
+// fakeEmails records security alerts and sign-in links. Methods the tests
+// don't use panic through the nil embedded EmailService.
+type fakeEmails struct {
+	EmailService
+	alerts     []SecurityAlert
+	magicLinks []string
+}
+
+func (f *fakeEmails) SendMagicLink(ctx context.Context, user *models.User, token string, expiresIn time.Duration) error {
+	f.magicLinks = append(f.magicLinks, token)
+	return nil
+}
+
+func (f *fakeEmails) SendSecurityAlert(ctx context.Context, user *models.User, alert SecurityAlert) error {
+	f.alerts = append(f.alerts, alert)
+	return nil
+}
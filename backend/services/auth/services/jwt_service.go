@@ -1,39 +1,99 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/mdnaeem95/lifesync/backend/pkg/authz"
+	"github.com/mdnaeem95/lifesync/backend/pkg/jwks"
 	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
 )
 
+// AccessTokenTTL is the lifetime of access tokens, and so how long a
+// revocation entry has to be kept
+const AccessTokenTTL = 1 * time.Hour
+
+// ErrVerifyOnly is returned when a verify-only JWT service is asked to sign
+var ErrVerifyOnly = errors.New("jwt service can only verify tokens")
+
 type JWTService interface {
-	GenerateAccessToken(userID, email string) (string, error)
+	GenerateAccessToken(user *models.User, sessionID, orgID string) (string, error)
+	GenerateClientAccessToken(user *models.User, clientID string, scopes []string) (string, *AccessTokenClaims, error)
 	GenerateRefreshToken(userID string) (string, error)
 	ValidateAccessToken(token string) (*AccessTokenClaims, error)
 	ValidateRefreshToken(token string) (*RefreshTokenClaims, error)
+	GenerateMFAChallengeToken(userID, challengeID string, ttl time.Duration) (string, error)
+	ValidateMFAChallengeToken(token string) (*MFAChallengeClaims, error)
 }
 
+// jwtService signs with either a shared HS256 secret or the active key of a
+// key ring. Asymmetric verification only needs a source of public keys.
 type jwtService struct {
-	secret string
-	log    logger.Logger
+	secret  string
+	keyRing *jwks.KeyRing
+	keys    jwks.KeySource
+	log     logger.Logger
 }
 
 type AccessTokenClaims struct {
+	UserID    string   `json:"user_id"`
+	Email     string   `json:"email"`
+	SessionID string   `json:"sid,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	OrgID     string   `json:"org_id,omitempty"`
+	Type      string   `json:"type"`
+	jwt.RegisteredClaims
+}
+
+// Scopes splits the space separated scope claim. Tokens issued before
+// scopes were added get the scopes of their roles; tokens issued to apps
+// never do.
+func (c *AccessTokenClaims) Scopes() []string {
+	if c.Scope == "" && c.ClientID == "" {
+		return authz.ScopesForRoles(c.Roles)
+	}
+	return authz.ParseScope(c.Scope)
+}
+
+// RevocationToken identifies the token for revocation checks and entries
+func (c *AccessTokenClaims) RevocationToken() revocation.Token {
+	token := revocation.Token{
+		ID:        c.ID,
+		UserID:    c.UserID,
+		SessionID: c.SessionID,
+	}
+	if c.IssuedAt != nil {
+		token.IssuedAt = c.IssuedAt.Time
+	}
+	if c.ExpiresAt != nil {
+		token.ExpiresAt = c.ExpiresAt.Time
+	}
+	return token
+}
+
+type RefreshTokenClaims struct {
 	UserID string `json:"user_id"`
-	Email  string `json:"email"`
 	Type   string `json:"type"`
 	jwt.RegisteredClaims
 }
 
-type RefreshTokenClaims struct {
+// MFAChallengeClaims identify a user who has passed the password step of
+// sign-in but still owes a second factor
+type MFAChallengeClaims struct {
 	UserID string `json:"user_id"`
 	Type   string `json:"type"`
 	jwt.RegisteredClaims
 }
 
+// NewJWTService signs and verifies with a shared HS256 secret
 func NewJWTService(secret string, log logger.Logger) JWTService {
 	return &jwtService{
 		secret: secret,
@@ -41,46 +101,111 @@ func NewJWTService(secret string, log logger.Logger) JWTService {
 	}
 }
 
-func (s *jwtService) GenerateAccessToken(userID, email string) (string, error) {
+// NewKeyRingJWTService signs with the ring's active key (RS256, ES256 or
+// EdDSA) and verifies against every unexpired key in the ring
+func NewKeyRingJWTService(ring *jwks.KeyRing, log logger.Logger) JWTService {
+	return &jwtService{
+		keyRing: ring,
+		keys:    ring,
+		log:     log,
+	}
+}
+
+// NewJWTVerifier verifies asymmetrically signed tokens using public keys
+// only, typically a jwks.RemoteKeySet pointed at the auth service. The
+// Generate methods return ErrVerifyOnly.
+func NewJWTVerifier(keys jwks.KeySource, log logger.Logger) JWTService {
+	return &jwtService{
+		keys: keys,
+		log:  log,
+	}
+}
+
+// GenerateAccessToken issues an access token carrying the user's roles and
+// the scopes they grant. sessionID is the refresh token family the token was
+// issued alongside and becomes the sid claim; orgID is the organization the
+// session has switched to, if any, and becomes the org_id claim.
+func (s *jwtService) GenerateAccessToken(user *models.User, sessionID, orgID string) (string, error) {
 	log := s.log.WithField("operation", "generate_access_token")
 
+	roles := authz.NormalizeRoles(user.Roles)
 	claims := AccessTokenClaims{
-		UserID: userID,
-		Email:  email,
-		Type:   "access",
+		UserID:    user.ID,
+		Email:     user.Email,
+		SessionID: sessionID,
+		Roles:     roles,
+		Scope:     authz.FormatScope(authz.ScopesForRoles(roles)),
+		OrgID:     orgID,
+		Type:      "access",
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(1 * time.Hour)),
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "flowtime-auth",
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.secret))
+	tokenString, err := s.sign(claims)
 	if err != nil {
 		log.WithError(err).Error("Failed to sign access token")
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
 
-	log.WithField("user_id", userID).Debug("Access token generated")
+	log.WithField("user_id", user.ID).Debug("Access token generated")
 	return tokenString, nil
 }
 
+// GenerateClientAccessToken issues an access token to a third-party app
+// acting for the user. It carries only the scopes the user granted the app,
+// no roles and no session, and names the app in the client_id claim. The
+// claims are returned so the caller can keep the jti.
+func (s *jwtService) GenerateClientAccessToken(user *models.User, clientID string, scopes []string) (string, *AccessTokenClaims, error) {
+	log := s.log.WithField("operation", "generate_client_access_token")
+
+	claims := &AccessTokenClaims{
+		UserID:   user.ID,
+		Email:    user.Email,
+		Scope:    authz.FormatScope(scopes),
+		ClientID: clientID,
+		Type:     "access",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "flowtime-auth",
+		},
+	}
+
+	tokenString, err := s.sign(claims)
+	if err != nil {
+		log.WithError(err).Error("Failed to sign client access token")
+		return "", nil, fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	log.WithFields(map[string]interface{}{
+		"user_id":   user.ID,
+		"client_id": clientID,
+	}).Debug("Client access token generated")
+	return tokenString, claims, nil
+}
+
 func (s *jwtService) GenerateRefreshToken(userID string) (string, error) {
 	log := s.log.WithField("operation", "generate_refresh_token")
 
+	// The jti makes every refresh token unique, even two issued to the same
+	// user within the same second
 	claims := RefreshTokenClaims{
 		UserID: userID,
 		Type:   "refresh",
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(30 * 24 * time.Hour)), // 30 days
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "flowtime-auth",
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.secret))
+	tokenString, err := s.sign(claims)
 	if err != nil {
 		log.WithError(err).Error("Failed to sign refresh token")
 		return "", fmt.Errorf("failed to sign token: %w", err)
@@ -93,13 +218,7 @@ func (s *jwtService) GenerateRefreshToken(userID string) (string, error) {
 func (s *jwtService) ValidateAccessToken(tokenString string) (*AccessTokenClaims, error) {
 	log := s.log.WithField("operation", "validate_access_token")
 
-	token, err := jwt.ParseWithClaims(tokenString, &AccessTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.secret), nil
-	})
-
+	token, err := s.parse(tokenString, &AccessTokenClaims{})
 	if err != nil {
 		log.WithError(err).Debug("Failed to parse access token")
 		return nil, err
@@ -122,13 +241,7 @@ func (s *jwtService) ValidateAccessToken(tokenString string) (*AccessTokenClaims
 func (s *jwtService) ValidateRefreshToken(tokenString string) (*RefreshTokenClaims, error) {
 	log := s.log.WithField("operation", "validate_refresh_token")
 
-	token, err := jwt.ParseWithClaims(tokenString, &RefreshTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.secret), nil
-	})
-
+	token, err := s.parse(tokenString, &RefreshTokenClaims{})
 	if err != nil {
 		log.WithError(err).Debug("Failed to parse refresh token")
 		return nil, err
@@ -147,3 +260,96 @@ func (s *jwtService) ValidateRefreshToken(tokenString string) (*RefreshTokenClai
 
 	return claims, nil
 }
+
+// GenerateMFAChallengeToken signs a challenge token whose jti is the ID of
+// the stored challenge
+func (s *jwtService) GenerateMFAChallengeToken(userID, challengeID string, ttl time.Duration) (string, error) {
+	log := s.log.WithField("operation", "generate_mfa_challenge_token")
+
+	claims := MFAChallengeClaims{
+		UserID: userID,
+		Type:   "mfa_challenge",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        challengeID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "flowtime-auth",
+		},
+	}
+
+	tokenString, err := s.sign(claims)
+	if err != nil {
+		log.WithError(err).Error("Failed to sign mfa challenge token")
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	log.WithField("user_id", userID).Debug("MFA challenge token generated")
+	return tokenString, nil
+}
+
+func (s *jwtService) ValidateMFAChallengeToken(tokenString string) (*MFAChallengeClaims, error) {
+	log := s.log.WithField("operation", "validate_mfa_challenge_token")
+
+	token, err := s.parse(tokenString, &MFAChallengeClaims{})
+	if err != nil {
+		log.WithError(err).Debug("Failed to parse mfa challenge token")
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*MFAChallengeClaims)
+	if !ok || !token.Valid {
+		log.Debug("Invalid mfa challenge token claims")
+		return nil, errors.New("invalid token")
+	}
+
+	if claims.Type != "mfa_challenge" {
+		log.Debug("Wrong token type")
+		return nil, errors.New("invalid token type")
+	}
+
+	return claims, nil
+}
+
+// sign encodes claims with the active key, setting the kid header so
+// verifiers can pick the matching public key
+func (s *jwtService) sign(claims jwt.Claims) (string, error) {
+	if s.keyRing != nil {
+		key, err := s.keyRing.SigningKey()
+		if err != nil {
+			return "", err
+		}
+		method := jwt.GetSigningMethod(key.Alg)
+		if method == nil {
+			return "", fmt.Errorf("unsupported signing algorithm %q", key.Alg)
+		}
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = key.Kid
+		return token.SignedString(key.Private)
+	}
+
+	if s.secret == "" {
+		return "", ErrVerifyOnly
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(s.secret))
+}
+
+func (s *jwtService) parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	if s.keys != nil {
+		return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			if kid == "" {
+				return nil, errors.New("missing kid header")
+			}
+			return s.keys.Key(context.Background(), kid)
+		}, jwt.WithValidMethods([]string{jwks.AlgRS256, jwks.AlgES256, jwks.AlgEdDSA}))
+	}
+
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(s.secret), nil
+	})
+}
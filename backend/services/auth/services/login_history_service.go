@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+const (
+	defaultLoginHistoryPageSize = 20
+	maxLoginHistoryPageSize     = 100
+)
+
+// LoginNotifier tells a user about a sign-in from a device or network they
+// haven't used before
+type LoginNotifier interface {
+	NotifyNewLogin(ctx context.Context, user *models.User, login *models.LoginRecord) error
+}
+
+// LoginRecorder records successful sign-ins. Recording never fails the
+// sign-in.
+type LoginRecorder interface {
+	RecordLogin(ctx context.Context, user *models.User, method string)
+}
+
+// LoginHistoryService keeps the history of a user's sign-ins and their last
+// login time
+type LoginHistoryService interface {
+	LoginRecorder
+	List(ctx context.Context, userID string, before *time.Time, limit int) ([]*models.LoginRecord, error)
+	// Purge deletes sign-ins older than the retention period
+	Purge(ctx context.Context) (int64, error)
+}
+
+type loginHistoryService struct {
+	historyRepo repository.LoginHistoryRepository
+	userRepo    repository.UserRepository
+	notifier    LoginNotifier
+	retention   time.Duration
+	log         logger.Logger
+}
+
+// NewLoginHistoryService creates the service. notifier may be nil to turn
+// new login alerts off, and a retention of zero keeps history forever.
+func NewLoginHistoryService(historyRepo repository.LoginHistoryRepository, userRepo repository.UserRepository, notifier LoginNotifier, retention time.Duration, log logger.Logger) LoginHistoryService {
+	return &loginHistoryService{
+		historyRepo: historyRepo,
+		userRepo:    userRepo,
+		notifier:    notifier,
+		retention:   retention,
+		log:         log,
+	}
+}
+
+// RecordLogin stores the sign-in, updates the user's last login time and,
+// if the user has signed in before but never from this device or network,
+// notifies them. A user's first sign-in is never reported as new.
+func (s *loginHistoryService) RecordLogin(ctx context.Context, user *models.User, method string) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "record_login",
+		"user_id":   user.ID,
+	})
+
+	client := models.ClientInfoFromContext(ctx)
+	record := &models.LoginRecord{
+		UserID:            user.ID,
+		Method:            method,
+		IPAddress:         client.IPAddress,
+		Network:           client.Network(),
+		UserAgent:         client.UserAgent,
+		DeviceFingerprint: client.Fingerprint(),
+	}
+
+	seenAny, seenDevice, seenNetwork, err := s.historyRepo.Seen(ctx, user.ID, record.DeviceFingerprint, record.Network)
+	if err != nil {
+		log.WithError(err).Warn("Failed to check login history")
+	} else if seenAny {
+		record.NewDevice = !seenDevice
+		record.NewNetwork = record.Network != "" && !seenNetwork
+	}
+
+	if err := s.historyRepo.Add(ctx, record); err != nil {
+		log.WithError(err).Warn("Failed to record login")
+	}
+
+	now := time.Now()
+	if err := s.userRepo.UpdateLastLogin(ctx, user.ID); err != nil {
+		log.WithError(err).Warn("Failed to update last login")
+	} else {
+		user.LastLoginAt = &now
+	}
+
+	if !record.NewDevice && !record.NewNetwork {
+		return
+	}
+
+	log.WithFields(map[string]interface{}{
+		"security_event": "new_login_location",
+		"new_device":     record.NewDevice,
+		"new_network":    record.NewNetwork,
+	}).Info("Sign-in from a new device or network")
+
+	if s.notifier == nil {
+		return
+	}
+	if err := s.notifier.NotifyNewLogin(ctx, user, record); err != nil {
+		log.WithError(err).Warn("Failed to send new login notification")
+	}
+}
+
+// List returns the user's sign-ins, newest first
+func (s *loginHistoryService) List(ctx context.Context, userID string, before *time.Time, limit int) ([]*models.LoginRecord, error) {
+	if limit <= 0 {
+		limit = defaultLoginHistoryPageSize
+	}
+	if limit > maxLoginHistoryPageSize {
+		limit = maxLoginHistoryPageSize
+	}
+	return s.historyRepo.ListByUser(ctx, userID, before, limit)
+}
+
+func (s *loginHistoryService) Purge(ctx context.Context) (int64, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+	return s.historyRepo.DeleteBefore(ctx, time.Now().Add(-s.retention))
+}
+
+// emailLoginNotifier sends new login notifications as security alert emails
+type emailLoginNotifier struct {
+	emailService EmailService
+}
+
+// NewEmailLoginNotifier notifies users of new sign-ins by email
+func NewEmailLoginNotifier(emailService EmailService) LoginNotifier {
+	return &emailLoginNotifier{emailService: emailService}
+}
+
+func (n *emailLoginNotifier) NotifyNewLogin(ctx context.Context, user *models.User, login *models.LoginRecord) error {
+	description := "Your FlowTime account was just signed in to from a device we haven't seen before."
+	if !login.NewDevice {
+		description = "Your FlowTime account was just signed in to from a network we haven't seen before."
+	}
+
+	return n.emailService.SendSecurityAlert(ctx, user, SecurityAlert{
+		Title:       "New sign-in to your account",
+		Description: description + " If this was you, there's nothing to do. If not, change your password and sign out of your other sessions.",
+		OccurredAt:  login.CreatedAt,
+		IPAddress:   login.IPAddress,
+		UserAgent:   login.UserAgent,
+	})
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+var ErrInvalidMagicLink = errors.New("invalid or expired sign-in link")
+
+// maxOutstandingMagicLinks is how many unexpired links a user may have at
+// once. Further requests send nothing until one is used or expires, which
+// also bounds how many emails anyone can have sent to an address.
+const maxOutstandingMagicLinks = 3
+
+// MagicLinkService signs users in with single-use links emailed to them.
+// Each link is bound to the device that asked for it by a device token that
+// only that device receives.
+type MagicLinkService interface {
+	RequestLink(ctx context.Context, email string) (*models.MagicLinkResponse, error)
+	SignIn(ctx context.Context, req models.MagicLinkVerifyRequest) (*models.AuthResponse, error)
+}
+
+type magicLinkService struct {
+	userRepo     repository.UserRepository
+	mfaService   MFAService
+	tokenIssuer  TokenIssuer
+	emailService EmailService
+	loginGuard   LoginGuard
+	events       AuthEventRecorder
+	logins       LoginRecorder
+	ttl          time.Duration
+	log          logger.Logger
+}
+
+func NewMagicLinkService(userRepo repository.UserRepository, mfaService MFAService, tokenIssuer TokenIssuer, emailService EmailService, loginGuard LoginGuard, events AuthEventRecorder, logins LoginRecorder, cfg *config.AuthConfig, log logger.Logger) MagicLinkService {
+	return &magicLinkService{
+		userRepo:     userRepo,
+		mfaService:   mfaService,
+		tokenIssuer:  tokenIssuer,
+		emailService: emailService,
+		loginGuard:   loginGuard,
+		events:       events,
+		logins:       logins,
+		ttl:          cfg.MagicLinkTTL,
+		log:          log,
+	}
+}
+
+// RequestLink emails a sign-in link if the address belongs to an active
+// account. The response is the same either way, and failures after the
+// device token is generated are only logged, so it never reveals whether
+// the account exists.
+func (s *magicLinkService) RequestLink(ctx context.Context, email string) (*models.MagicLinkResponse, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "request_magic_link",
+		"email":     email,
+	})
+
+	deviceToken, err := generateSecureToken()
+	if err != nil {
+		log.WithError(err).Error("Failed to generate device token")
+		return nil, fmt.Errorf("failed to generate device token: %w", err)
+	}
+
+	response := &models.MagicLinkResponse{
+		Message:     "If the email exists, a sign-in link has been sent",
+		DeviceToken: deviceToken,
+		ExpiresIn:   int(s.ttl.Seconds()),
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		log.Debug("User not found for magic link")
+		return response, nil
+	}
+	if !user.IsActive {
+		log.WithField("user_id", user.ID).Warn("Magic link requested for inactive user")
+		return response, nil
+	}
+
+	token, err := generateSecureToken()
+	if err != nil {
+		log.WithError(err).Error("Failed to generate magic link token")
+		return response, nil
+	}
+
+	err = s.userRepo.StoreMagicLinkToken(ctx, user.ID, token, deviceToken, time.Now().Add(s.ttl), maxOutstandingMagicLinks)
+	if errors.Is(err, repository.ErrMagicLinkLimit) {
+		log.WithFields(map[string]interface{}{
+			"user_id":        user.ID,
+			"security_event": "magic_link_limit",
+		}).Warn("Magic link not sent, too many outstanding")
+		return response, nil
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to store magic link token")
+		return response, nil
+	}
+
+	if err := s.emailService.SendMagicLink(ctx, user, token, s.ttl); err != nil {
+		log.WithError(err).Error("Failed to send magic link email")
+		return response, nil
+	}
+
+	log.WithField("user_id", user.ID).Info("Magic link requested")
+	return response, nil
+}
+
+// SignIn exchanges a link's token, presented with the device token from the
+// request, for tokens. Following the link proves the user owns the address,
+// so it also verifies the email.
+func (s *magicLinkService) SignIn(ctx context.Context, req models.MagicLinkVerifyRequest) (*models.AuthResponse, error) {
+	log := s.log.WithContext(ctx).WithField("operation", "magic_link_signin")
+
+	userID, err := s.userRepo.ConsumeMagicLinkToken(ctx, req.Token, req.DeviceToken)
+	if err != nil {
+		if errors.Is(err, repository.ErrMagicLinkInvalid) {
+			log.WithField("security_event", "magic_link_rejected").Warn("Invalid, expired or wrong-device magic link presented")
+			s.events.Record(ctx, &models.AuthEvent{Type: AuthEventSignIn, Outcome: AuthOutcomeFailure, Method: AuthMethodMagicLink, Reason: "invalid_link"})
+			return nil, ErrInvalidMagicLink
+		}
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to get user")
+		return nil, ErrInvalidMagicLink
+	}
+
+	if !user.IsActive {
+		log.WithField("user_id", user.ID).Warn("Inactive user attempted to sign in")
+		s.events.Record(ctx, &models.AuthEvent{Type: AuthEventSignIn, Outcome: AuthOutcomeFailure, UserID: user.ID, Email: user.Email, Method: AuthMethodMagicLink, Reason: "account_inactive"})
+		return nil, errors.New("account is inactive")
+	}
+
+	if !user.EmailVerified {
+		if err := s.userRepo.MarkEmailVerified(ctx, user.ID); err != nil {
+			log.WithError(err).Warn("Failed to mark email verified")
+		} else {
+			user.EmailVerified = true
+		}
+	}
+
+	// The link only replaces the password, not the second factor. The
+	// lockout is only cleared once that has been passed too, or a stream of
+	// links would give unlimited guesses at it.
+	if err := s.mfaService.Challenge(ctx, user); err != nil {
+		return nil, err
+	}
+	s.loginGuard.RecordSuccess(ctx, user.Email)
+
+	response, err := s.tokenIssuer.IssueTokens(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logins.RecordLogin(ctx, user, AuthMethodMagicLink)
+
+	s.events.Record(ctx, &models.AuthEvent{Type: AuthEventSignIn, Outcome: AuthOutcomeSuccess, UserID: user.ID, Email: user.Email, Method: AuthMethodMagicLink})
+	log.WithField("user_id", user.ID).Info("User successfully signed in with magic link")
+	return response, nil
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+// fakeMagicLinkUserRepo keeps sign-in links like the repository does: every
+// link stays until used, up to the limit
+type fakeMagicLinkUserRepo struct {
+	repository.UserRepository
+	user  *models.User
+	links map[string]string // token to device token
+}
+
+func (f *fakeMagicLinkUserRepo) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	if f.user == nil || models.NormalizeEmail(email) != f.user.Email {
+		return nil, errors.New("user not found")
+	}
+	return f.user, nil
+}
+
+func (f *fakeMagicLinkUserRepo) StoreMagicLinkToken(ctx context.Context, userID, token, deviceToken string, expiresAt time.Time, maxOutstanding int) error {
+	if len(f.links) >= maxOutstanding {
+		return repository.ErrMagicLinkLimit
+	}
+	f.links[token] = deviceToken
+	return nil
+}
+
+func TestRequestLinkKeepsOutstandingLinks(t *testing.T) {
+	repo := &fakeMagicLinkUserRepo{
+		user:  &models.User{ID: "u1", Email: "victim@example.com", IsActive: true},
+		links: make(map[string]string),
+	}
+	emails := &fakeEmails{}
+	s := NewMagicLinkService(repo, nil, nil, emails, nil, nil, nil, &config.AuthConfig{MagicLinkTTL: 15 * time.Minute}, logger.New())
+
+	victim, err := s.RequestLink(context.Background(), "victim@example.com")
+	if err != nil {
+		t.Fatalf("RequestLink() error = %v", err)
+	}
+	victimLink := emails.magicLinks[0]
+
+	// Someone else keeps asking for links to the same address
+	for i := 0; i < maxOutstandingMagicLinks+2; i++ {
+		response, err := s.RequestLink(context.Background(), "Victim@Example.com")
+		if err != nil {
+			t.Fatalf("RequestLink() error = %v", err)
+		}
+		if response.Message != victim.Message || response.ExpiresIn != victim.ExpiresIn || response.DeviceToken == "" {
+			t.Errorf("response %d = %+v, want it to look like any other", i, response)
+		}
+	}
+
+	if repo.links[victimLink] != victim.DeviceToken {
+		t.Error("the victim's link was replaced")
+	}
+	if len(emails.magicLinks) != maxOutstandingMagicLinks {
+		t.Errorf("%d links emailed, want at most %d", len(emails.magicLinks), maxOutstandingMagicLinks)
+	}
+}
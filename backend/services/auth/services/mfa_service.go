@@ -0,0 +1,425 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/totp"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+const (
+	mfaChallengeTTL = 5 * time.Minute
+	// maxChallengeAttempts is how many wrong codes a challenge takes before
+	// it is thrown away and the password has to be entered again
+	maxChallengeAttempts = 5
+	totpIssuer           = "FlowTime"
+	totpSkew             = 1 // accept the previous and next 30s step for clock drift
+	recoveryCodeCount    = 10
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication not enabled")
+	ErrInvalidMFACode    = errors.New("invalid two-factor code")
+)
+
+// MFARequiredError is returned from sign-in when the password was correct
+// but the account needs a second factor before tokens are issued
+type MFARequiredError struct {
+	ChallengeToken string
+	ExpiresIn      time.Duration
+}
+
+func (e *MFARequiredError) Error() string {
+	return "two-factor authentication required"
+}
+
+type MFAService interface {
+	Status(ctx context.Context, userID string) (*models.MFAStatusResponse, error)
+	BeginEnrollment(ctx context.Context, userID string) (*models.MFASetupResponse, error)
+	ConfirmEnrollment(ctx context.Context, userID, code string) ([]string, error)
+	Disable(ctx context.Context, userID, password, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error)
+
+	// Challenge returns an *MFARequiredError if the user has 2FA enabled
+	Challenge(ctx context.Context, user *models.User) error
+	// VerifyChallenge checks the second factor for a challenge and returns the
+	// user ID. The ID is returned with an invalid code too, for the auth log.
+	// Wrong codes count towards the account's sign-in lockout, and returns a
+	// *LoginThrottledError while it applies.
+	VerifyChallenge(ctx context.Context, challengeToken, code string) (string, error)
+}
+
+type mfaService struct {
+	mfaRepo      repository.MFARepository
+	userRepo     repository.UserRepository
+	jwtService   JWTService
+	emailService EmailService
+	hasher       PasswordHasher
+	loginGuard   LoginGuard
+	log          logger.Logger
+}
+
+func NewMFAService(mfaRepo repository.MFARepository, userRepo repository.UserRepository, jwtService JWTService, emailService EmailService, hasher PasswordHasher, loginGuard LoginGuard, log logger.Logger) MFAService {
+	return &mfaService{
+		mfaRepo:      mfaRepo,
+		userRepo:     userRepo,
+		jwtService:   jwtService,
+		emailService: emailService,
+		hasher:       hasher,
+		loginGuard:   loginGuard,
+		log:          log,
+	}
+}
+
+func (s *mfaService) Status(ctx context.Context, userID string) (*models.MFAStatusResponse, error) {
+	cred, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !cred.Enabled() {
+		return &models.MFAStatusResponse{Enabled: false}, nil
+	}
+
+	remaining, err := s.mfaRepo.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MFAStatusResponse{
+		Enabled:                true,
+		RecoveryCodesRemaining: remaining,
+	}, nil
+}
+
+// BeginEnrollment creates a new TOTP secret. It has no effect on sign-in
+// until confirmed with a code from the authenticator app.
+func (s *mfaService) BeginEnrollment(ctx context.Context, userID string) (*models.MFASetupResponse, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "mfa_begin_enrollment",
+		"user_id":   userID,
+	})
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if cred.Enabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.WithError(err).Error("Failed to generate TOTP secret")
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	if err := s.mfaRepo.SavePendingTOTP(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+
+	log.Info("TOTP enrollment started")
+
+	return &models.MFASetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(secret, totpIssuer, user.Email),
+	}, nil
+}
+
+// ConfirmEnrollment enables 2FA once the user proves their authenticator
+// produces valid codes, and returns the initial recovery codes
+func (s *mfaService) ConfirmEnrollment(ctx context.Context, userID, code string) ([]string, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "mfa_confirm_enrollment",
+		"user_id":   userID,
+	})
+
+	cred, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if cred == nil {
+		return nil, ErrMFANotEnabled
+	}
+	if cred.Enabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := totp.Validate(cred.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		log.Debug("Invalid TOTP code during enrollment")
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		log.WithError(err).Error("Failed to generate recovery codes")
+		return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+	}
+
+	if err := s.mfaRepo.EnableTOTP(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+
+	s.sendAlert(ctx, userID, SecurityAlert{
+		Title:       "Two-factor authentication enabled",
+		Description: "Two-factor authentication was turned on for your FlowTime account. You'll need a code from your authenticator app when you sign in.",
+	})
+
+	log.Info("Two-factor authentication enabled")
+	return codes, nil
+}
+
+// Disable turns off 2FA. The caller must re-authenticate with their password
+// (if the account has one) and a current code.
+func (s *mfaService) Disable(ctx context.Context, userID, password, code string) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "mfa_disable",
+		"user_id":   userID,
+	})
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.PasswordHash != "" {
+		if match, _, err := s.hasher.Verify(password, user.PasswordHash); err != nil || !match {
+			log.Warn("Invalid password when disabling two-factor authentication")
+			return errors.New("invalid credentials")
+		}
+	}
+
+	cred, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !cred.Enabled() {
+		return ErrMFANotEnabled
+	}
+
+	if err := s.verifyCode(ctx, cred, code); err != nil {
+		log.Warn("Invalid code when disabling two-factor authentication")
+		return err
+	}
+
+	if err := s.mfaRepo.DeleteTOTP(ctx, userID); err != nil {
+		return err
+	}
+
+	s.sendAlert(ctx, userID, SecurityAlert{
+		Title:       "Two-factor authentication disabled",
+		Description: "Two-factor authentication was turned off for your FlowTime account. Your account is now protected by your password only.",
+	})
+
+	log.Info("Two-factor authentication disabled")
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, invalidating the old ones
+func (s *mfaService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "mfa_regenerate_recovery_codes",
+		"user_id":   userID,
+	})
+
+	cred, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !cred.Enabled() {
+		return nil, ErrMFANotEnabled
+	}
+
+	if err := s.verifyCode(ctx, cred, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		log.WithError(err).Error("Failed to generate recovery codes")
+		return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	log.Info("Recovery codes regenerated")
+	return codes, nil
+}
+
+func (s *mfaService) Challenge(ctx context.Context, user *models.User) error {
+	cred, err := s.mfaRepo.GetTOTP(ctx, user.ID)
+	if err != nil {
+		// Fail closed - never skip the second factor because of a lookup error
+		return err
+	}
+	if !cred.Enabled() {
+		return nil
+	}
+
+	challengeID := uuid.New().String()
+	if err := s.mfaRepo.CreateChallenge(ctx, challengeID, user.ID, time.Now().Add(mfaChallengeTTL)); err != nil {
+		return err
+	}
+
+	challengeToken, err := s.jwtService.GenerateMFAChallengeToken(user.ID, challengeID, mfaChallengeTTL)
+	if err != nil {
+		return fmt.Errorf("failed to generate challenge token: %w", err)
+	}
+
+	s.log.WithContext(ctx).WithField("user_id", user.ID).Info("Two-factor challenge issued")
+	return &MFARequiredError{
+		ChallengeToken: challengeToken,
+		ExpiresIn:      mfaChallengeTTL,
+	}
+}
+
+func (s *mfaService) VerifyChallenge(ctx context.Context, challengeToken, code string) (string, error) {
+	log := s.log.WithContext(ctx).WithField("operation", "mfa_verify_challenge")
+
+	claims, err := s.jwtService.ValidateMFAChallengeToken(challengeToken)
+	if err != nil {
+		log.WithError(err).Debug("Invalid challenge token")
+		return "", errors.New("invalid challenge token")
+	}
+
+	// The challenge is gone once it has been used, or has had too many wrong
+	// codes
+	active, err := s.mfaRepo.ChallengeActive(ctx, claims.ID, claims.UserID)
+	if err != nil {
+		return "", err
+	}
+	if !active {
+		log.WithField("user_id", claims.UserID).Debug("Challenge already used or given up")
+		return "", errors.New("invalid challenge token")
+	}
+
+	cred, err := s.mfaRepo.GetTOTP(ctx, claims.UserID)
+	if err != nil {
+		return "", err
+	}
+	if !cred.Enabled() {
+		// 2FA was disabled after the challenge was issued
+		return "", errors.New("invalid challenge token")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Fresh challenges only take the password, so wrong codes are limited
+	// per account as well as per challenge
+	ip := models.ClientInfoFromContext(ctx).IPAddress
+	if err := s.loginGuard.Check(ctx, user.Email, ip); err != nil {
+		log.WithField("user_id", user.ID).Warn("Two-factor sign-in throttled")
+		return user.ID, err
+	}
+
+	if err := s.verifyCode(ctx, cred, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			log.WithField("user_id", user.ID).Warn("Invalid two-factor code")
+			if err := s.mfaRepo.RecordChallengeFailure(ctx, claims.ID, maxChallengeAttempts); err != nil {
+				log.WithError(err).Warn("Failed to count two-factor failure")
+			}
+			s.loginGuard.RecordFailure(ctx, user.Email, ip)
+		}
+		return user.ID, err
+	}
+
+	consumed, err := s.mfaRepo.ConsumeChallenge(ctx, claims.ID)
+	if err != nil {
+		return "", err
+	}
+	if !consumed {
+		// Completed by a concurrent request
+		return "", errors.New("invalid challenge token")
+	}
+	s.loginGuard.RecordSuccess(ctx, user.Email)
+
+	return user.ID, nil
+}
+
+// verifyCode accepts either a TOTP code or an unused recovery code. Both are
+// single use.
+func (s *mfaService) verifyCode(ctx context.Context, cred *models.TOTPCredential, code string) error {
+	if step, ok := totp.Validate(cred.Secret, code, time.Now(), totpSkew); ok {
+		consumed, err := s.mfaRepo.ConsumeTOTPStep(ctx, cred.UserID, step)
+		if err != nil {
+			return err
+		}
+		if !consumed {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	consumed, err := s.mfaRepo.ConsumeRecoveryCode(ctx, cred.UserID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidMFACode
+	}
+
+	s.log.WithContext(ctx).WithField("user_id", cred.UserID).Info("Recovery code used")
+	return nil
+}
+
+func (s *mfaService) sendAlert(ctx context.Context, userID string, alert SecurityAlert) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return
+	}
+	if err := s.emailService.SendSecurityAlert(ctx, user, alert); err != nil {
+		s.log.WithContext(ctx).WithError(err).Warn("Failed to send two-factor security alert")
+	}
+}
+
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// generateRecoveryCodes returns codes formatted as "xxxxx-xxxxx" along with
+// the hashes to store
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		encoded := recoveryCodeEncoding.EncodeToString(raw)[:10]
+		codes[i] = encoded[:5] + "-" + encoded[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode normalizes user input before hashing so codes are
+// accepted with or without the dash and in any case
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(code)
+	normalized = strings.NewReplacer("-", "", " ", "").Replace(normalized)
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,852 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/authz"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+// OAuth2 error codes, from RFC 6749
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthAccessDenied            = "access_denied"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+)
+
+var (
+	ErrOAuthClientNotFound = errors.New("oauth client not found")
+	ErrOAuthAppNotFound    = errors.New("authorized app not found")
+	ErrInvalidRedirectURI  = errors.New("invalid redirect uri")
+)
+
+// OAuthError is an OAuth2 error response. For authorization requests,
+// RedirectURI is set once the client and its redirect URI check out, and
+// carries the error back to the app; before that the error must only be
+// shown to the user.
+type OAuthError struct {
+	Code        string
+	Description string
+	RedirectURI string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// OAuthService is the OAuth2 provider that lets third-party apps act for
+// users. Apps use the authorization code grant with PKCE, get access tokens
+// limited to the scopes the user consented to, and keep access with
+// rotating refresh tokens until the user revokes the app.
+type OAuthService interface {
+	RegisterClient(ctx context.Context, createdBy string, req models.CreateOAuthClientRequest) (*models.CreateOAuthClientResponse, error)
+	ListClients(ctx context.Context) ([]*models.OAuthClient, error)
+	// DeleteClient removes an app and everything it was issued
+	DeleteClient(ctx context.Context, clientID string) error
+
+	// PrepareAuthorization checks an authorization request and describes it
+	// for the consent page
+	PrepareAuthorization(ctx context.Context, userID string, req models.OAuthAuthorizeRequest) (*models.OAuthConsent, error)
+	// Authorize records the user's answer and returns where to send them
+	// back to the app, with an authorization code if they approved
+	Authorize(ctx context.Context, userID string, req models.OAuthConsentRequest) (*models.OAuthRedirect, error)
+
+	// Token serves the token endpoint's authorization_code and
+	// refresh_token grants
+	Token(ctx context.Context, req models.OAuthTokenRequest) (*models.OAuthTokenResponse, error)
+	// Introspect describes a token issued to the calling client
+	Introspect(ctx context.Context, req models.OAuthTokenActionRequest) (*models.OAuthIntrospection, error)
+	// Revoke revokes a token issued to the calling client. Unknown tokens
+	// are ignored.
+	Revoke(ctx context.Context, req models.OAuthTokenActionRequest) error
+
+	ListAuthorizedApps(ctx context.Context, userID string) ([]*models.OAuthGrant, error)
+	// RevokeApp withdraws the user's consent and revokes every token the
+	// app holds for them
+	RevokeApp(ctx context.Context, userID, clientID string) error
+
+	// Cleanup deletes expired authorization codes and refresh tokens
+	Cleanup(ctx context.Context) (int64, error)
+}
+
+type oauthService struct {
+	oauthRepo       repository.OAuthRepository
+	userRepo        repository.UserRepository
+	jwtService      JWTService
+	revocations     revocation.Store
+	events          AuthEventRecorder
+	codeTTL         time.Duration
+	refreshTokenTTL time.Duration
+	log             logger.Logger
+}
+
+func NewOAuthService(oauthRepo repository.OAuthRepository, userRepo repository.UserRepository, jwtService JWTService, revocations revocation.Store, events AuthEventRecorder, cfg *config.AuthConfig, log logger.Logger) OAuthService {
+	return &oauthService{
+		oauthRepo:       oauthRepo,
+		userRepo:        userRepo,
+		jwtService:      jwtService,
+		revocations:     revocations,
+		events:          events,
+		codeTTL:         cfg.OAuthCodeTTL,
+		refreshTokenTTL: cfg.OAuthRefreshTokenTTL,
+		log:             log,
+	}
+}
+
+// RegisterClient registers an app. Confidential clients get a secret, which
+// the response is the only place to find.
+func (s *oauthService) RegisterClient(ctx context.Context, createdBy string, req models.CreateOAuthClientRequest) (*models.CreateOAuthClientResponse, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "register_oauth_client",
+		"user_id":   createdBy,
+	})
+
+	for _, redirectURI := range req.RedirectURIs {
+		if err := validateRedirectURI(redirectURI); err != nil {
+			return nil, err
+		}
+	}
+
+	client := &models.OAuthClient{
+		Name:         strings.TrimSpace(req.Name),
+		RedirectURIs: req.RedirectURIs,
+		Scopes:       uniqueScopes(req.Scopes),
+		Confidential: req.Confidential,
+		CreatedBy:    createdBy,
+	}
+
+	var secret string
+	if req.Confidential {
+		var err error
+		if secret, err = generateSecureToken(); err != nil {
+			return nil, fmt.Errorf("failed to generate client secret: %w", err)
+		}
+		client.SecretHash = hashClientSecret(secret)
+	}
+
+	if err := s.oauthRepo.CreateClient(ctx, client); err != nil {
+		return nil, err
+	}
+
+	log.WithFields(map[string]interface{}{
+		"security_event": "oauth_client_registered",
+		"client_id":      client.ID,
+		"scopes":         client.Scopes,
+	}).Info("OAuth client registered")
+
+	return &models.CreateOAuthClientResponse{OAuthClient: client, ClientSecret: secret}, nil
+}
+
+func (s *oauthService) ListClients(ctx context.Context) ([]*models.OAuthClient, error) {
+	return s.oauthRepo.ListClients(ctx)
+}
+
+func (s *oauthService) DeleteClient(ctx context.Context, clientID string) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "delete_oauth_client",
+		"client_id": clientID,
+	})
+
+	if _, err := uuid.Parse(clientID); err != nil {
+		return ErrOAuthClientNotFound
+	}
+
+	tokens, err := s.oauthRepo.DeleteClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthClientNotFound) {
+			return ErrOAuthClientNotFound
+		}
+		return err
+	}
+	s.revokeAccessTokens(ctx, tokens)
+
+	log.WithField("security_event", "oauth_client_deleted").Info("OAuth client deleted")
+	return nil
+}
+
+func (s *oauthService) PrepareAuthorization(ctx context.Context, userID string, req models.OAuthAuthorizeRequest) (*models.OAuthConsent, error) {
+	client, redirectURI, scopes, err := s.checkAuthorizeRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	consentRequired, err := s.consentRequired(ctx, userID, client.ID, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OAuthConsent{
+		ClientID:        client.ID,
+		ClientName:      client.Name,
+		RedirectURI:     redirectURI,
+		Scopes:          scopes,
+		ConsentRequired: consentRequired,
+	}, nil
+}
+
+func (s *oauthService) Authorize(ctx context.Context, userID string, req models.OAuthConsentRequest) (*models.OAuthRedirect, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "oauth_authorize",
+		"user_id":   userID,
+		"client_id": req.ClientID,
+	})
+
+	client, redirectURI, scopes, err := s.checkAuthorizeRequest(ctx, req.OAuthAuthorizeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.Approve {
+		log.Info("User denied authorization request")
+		return &models.OAuthRedirect{
+			RedirectURI: redirectWith(redirectURI, map[string]string{
+				"error":             OAuthAccessDenied,
+				"error_description": "The user denied the request",
+				"state":             req.State,
+			}),
+		}, nil
+	}
+
+	consentRequired, err := s.consentRequired(ctx, userID, client.ID, scopes)
+	if err != nil {
+		return nil, err
+	}
+	if consentRequired {
+		if _, err := s.oauthRepo.SaveGrant(ctx, userID, client.ID, scopes); err != nil {
+			return nil, err
+		}
+		log.WithFields(map[string]interface{}{
+			"security_event": "oauth_app_authorized",
+			"scopes":         scopes,
+		}).Info("User authorized app")
+		s.events.Record(ctx, &models.AuthEvent{Type: AuthEventAppAuthorized, Outcome: AuthOutcomeSuccess, UserID: userID})
+	}
+
+	code, err := generateSecureToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate authorization code: %w", err)
+	}
+
+	authCode := &models.OAuthAuthorizationCode{
+		ClientID:            client.ID,
+		UserID:              userID,
+		RedirectURI:         redirectURI,
+		RedirectURIExplicit: req.RedirectURI != "",
+		Scopes:              scopes,
+		CodeChallenge:       req.CodeChallenge,
+		ExpiresAt:           time.Now().Add(s.codeTTL),
+	}
+	if err := s.oauthRepo.SaveAuthorizationCode(ctx, code, authCode); err != nil {
+		return nil, err
+	}
+
+	return &models.OAuthRedirect{
+		RedirectURI: redirectWith(redirectURI, map[string]string{
+			"code":  code,
+			"state": req.State,
+		}),
+	}, nil
+}
+
+// checkAuthorizeRequest validates an authorization request and resolves its
+// redirect URI and scopes. Requests without a scope ask for every scope the
+// client is registered for.
+func (s *oauthService) checkAuthorizeRequest(ctx context.Context, req models.OAuthAuthorizeRequest) (*models.OAuthClient, string, []string, error) {
+	client, err := s.getClient(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, ErrOAuthClientNotFound) {
+			return nil, "", nil, &OAuthError{Code: OAuthInvalidRequest, Description: "Unknown client_id"}
+		}
+		return nil, "", nil, err
+	}
+
+	redirectURI := req.RedirectURI
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !containsString(client.RedirectURIs, redirectURI) {
+		return nil, "", nil, &OAuthError{Code: OAuthInvalidRequest, Description: "redirect_uri is not registered for this client"}
+	}
+
+	// From here on the error can be sent back to the app
+	fail := func(code, description string) (*models.OAuthClient, string, []string, error) {
+		return nil, "", nil, &OAuthError{
+			Code:        code,
+			Description: description,
+			RedirectURI: redirectWith(redirectURI, map[string]string{
+				"error":             code,
+				"error_description": description,
+				"state":             req.State,
+			}),
+		}
+	}
+
+	if req.ResponseType != "code" {
+		return fail(OAuthUnsupportedResponseType, "Only the code response type is supported")
+	}
+	if req.CodeChallengeMethod != "S256" || !validPKCEValue(req.CodeChallenge) {
+		return fail(OAuthInvalidRequest, "PKCE with code_challenge_method S256 is required")
+	}
+
+	scopes := uniqueScopes(authz.ParseScope(req.Scope))
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	if !authz.HasAll(client.Scopes, scopes...) {
+		return fail(OAuthInvalidScope, "The client may not request scope "+req.Scope)
+	}
+
+	return client, redirectURI, scopes, nil
+}
+
+// consentRequired reports whether the user has yet to grant the app any of
+// scopes
+func (s *oauthService) consentRequired(ctx context.Context, userID, clientID string, scopes []string) (bool, error) {
+	grant, err := s.oauthRepo.GetGrant(ctx, userID, clientID)
+	if errors.Is(err, repository.ErrOAuthGrantNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !authz.HasAll(grant.Scopes, scopes...), nil
+}
+
+func (s *oauthService) Token(ctx context.Context, req models.OAuthTokenRequest) (*models.OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case "authorization_code":
+		return s.exchangeCode(ctx, client, req)
+	case "refresh_token":
+		return s.refresh(ctx, client, req)
+	default:
+		return nil, &OAuthError{Code: OAuthUnsupportedGrantType, Description: "Use authorization_code or refresh_token"}
+	}
+}
+
+// exchangeCode redeems an authorization code for the first access and
+// refresh tokens of a new token family
+func (s *oauthService) exchangeCode(ctx context.Context, client *models.OAuthClient, req models.OAuthTokenRequest) (*models.OAuthTokenResponse, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "oauth_exchange_code",
+		"client_id": client.ID,
+	})
+
+	if req.Code == "" || req.CodeVerifier == "" {
+		return nil, &OAuthError{Code: OAuthInvalidRequest, Description: "code and code_verifier are required"}
+	}
+
+	authCode, err := s.oauthRepo.ConsumeAuthorizationCode(ctx, req.Code)
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthCodeInvalid) {
+			return nil, &OAuthError{Code: OAuthInvalidGrant, Description: "Invalid or expired authorization code"}
+		}
+		return nil, err
+	}
+
+	if authCode.ClientID != client.ID {
+		log.WithField("security_event", "oauth_code_client_mismatch").Warn("Authorization code presented by another client")
+		return nil, &OAuthError{Code: OAuthInvalidGrant, Description: "Invalid or expired authorization code"}
+	}
+	if !redirectURIMatches(authCode, req.RedirectURI) {
+		return nil, &OAuthError{Code: OAuthInvalidGrant, Description: "redirect_uri does not match the authorization request"}
+	}
+	if !verifyCodeChallenge(req.CodeVerifier, authCode.CodeChallenge) {
+		log.WithField("user_id", authCode.UserID).Warn("PKCE verification failed")
+		return nil, &OAuthError{Code: OAuthInvalidGrant, Description: "code_verifier does not match the code challenge"}
+	}
+
+	user, err := s.activeUser(ctx, authCode.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	grant, err := s.oauthRepo.GetGrant(ctx, user.ID, client.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthGrantNotFound) {
+			return nil, &OAuthError{Code: OAuthInvalidGrant, Description: "The authorization has been revoked"}
+		}
+		return nil, err
+	}
+
+	accessToken, claims, err := s.jwtService.GenerateClientAccessToken(user, client.ID, authCode.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := generateSecureToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	if err := s.oauthRepo.StoreRefreshToken(ctx, grant.ID, refreshToken, authCode.Scopes, issuedAccessToken(claims), s.refreshTokenTTL); err != nil {
+		return nil, err
+	}
+
+	log.WithField("user_id", user.ID).Info("Authorization code exchanged for tokens")
+	return tokenResponse(accessToken, refreshToken, authCode.Scopes), nil
+}
+
+// refresh rotates a refresh token. Presenting a token that was already
+// rotated means it leaked, so its whole family is revoked.
+func (s *oauthService) refresh(ctx context.Context, client *models.OAuthClient, req models.OAuthTokenRequest) (*models.OAuthTokenResponse, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "oauth_refresh",
+		"client_id": client.ID,
+	})
+
+	if req.RefreshToken == "" {
+		return nil, &OAuthError{Code: OAuthInvalidRequest, Description: "refresh_token is required"}
+	}
+	invalid := &OAuthError{Code: OAuthInvalidGrant, Description: "Invalid or expired refresh token"}
+
+	stored, err := s.oauthRepo.GetRefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthRefreshTokenNotFound) {
+			return nil, invalid
+		}
+		return nil, err
+	}
+	if stored.ClientID != client.ID {
+		log.WithField("security_event", "oauth_refresh_client_mismatch").Warn("Refresh token presented by another client")
+		return nil, invalid
+	}
+	if stored.RevokedAt != nil {
+		s.revokeReusedFamily(ctx, stored)
+		return nil, invalid
+	}
+
+	scopes := uniqueScopes(authz.ParseScope(req.Scope))
+	if len(scopes) == 0 {
+		scopes = stored.Scopes
+	}
+	if !authz.HasAll(stored.Scopes, scopes...) {
+		return nil, &OAuthError{Code: OAuthInvalidScope, Description: "Scope exceeds what the user granted"}
+	}
+
+	user, err := s.activeUser(ctx, stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, claims, err := s.jwtService.GenerateClientAccessToken(user, client.ID, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := generateSecureToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	err = s.oauthRepo.RotateRefreshToken(ctx, req.RefreshToken, refreshToken, issuedAccessToken(claims), s.refreshTokenTTL)
+	switch {
+	case errors.Is(err, repository.ErrOAuthRefreshTokenReused):
+		// Lost a race with another use of the same token
+		s.revokeReusedFamily(ctx, stored)
+		return nil, invalid
+	case errors.Is(err, repository.ErrOAuthRefreshTokenNotFound):
+		return nil, invalid
+	case err != nil:
+		return nil, err
+	}
+
+	log.WithField("user_id", user.ID).Debug("App refresh token rotated")
+	return tokenResponse(accessToken, refreshToken, scopes), nil
+}
+
+func (s *oauthService) revokeReusedFamily(ctx context.Context, stored *models.OAuthRefreshToken) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"security_event": "oauth_refresh_token_reuse",
+		"user_id":        stored.UserID,
+		"client_id":      stored.ClientID,
+		"family_id":      stored.FamilyID,
+	})
+
+	tokens, err := s.oauthRepo.RevokeRefreshTokenFamily(ctx, stored.FamilyID)
+	if err != nil {
+		log.WithError(err).Error("Failed to revoke app token family after reuse")
+		return
+	}
+	s.revokeAccessTokens(ctx, tokens)
+
+	log.Warn("Rotated app refresh token was presented again; token family revoked")
+	s.events.Record(ctx, &models.AuthEvent{Type: AuthEventTokenReuse, Outcome: AuthOutcomeFailure, UserID: stored.UserID, Reason: "app_session_revoked"})
+}
+
+// Introspect is only open to confidential clients, and only describes
+// tokens issued to the caller
+func (s *oauthService) Introspect(ctx context.Context, req models.OAuthTokenActionRequest) (*models.OAuthIntrospection, error) {
+	client, err := s.authenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if !client.Confidential {
+		return nil, &OAuthError{Code: OAuthInvalidClient, Description: "Introspection requires client authentication"}
+	}
+
+	inactive := &models.OAuthIntrospection{Active: false}
+
+	if looksLikeJWT(req.Token) {
+		claims, err := s.jwtService.ValidateAccessToken(req.Token)
+		if err != nil || claims.ClientID != client.ID {
+			return inactive, nil
+		}
+		revoked, err := s.revocations.IsRevoked(ctx, claims.RevocationToken())
+		if err != nil {
+			return nil, fmt.Errorf("failed to check token revocation: %w", err)
+		}
+		if revoked {
+			return inactive, nil
+		}
+
+		introspection := &models.OAuthIntrospection{
+			Active:    true,
+			Scope:     claims.Scope,
+			ClientID:  claims.ClientID,
+			Username:  claims.Email,
+			Subject:   claims.UserID,
+			TokenType: "access_token",
+		}
+		if claims.ExpiresAt != nil {
+			introspection.ExpiresAt = claims.ExpiresAt.Unix()
+		}
+		if claims.IssuedAt != nil {
+			introspection.IssuedAt = claims.IssuedAt.Unix()
+		}
+		return introspection, nil
+	}
+
+	stored, err := s.oauthRepo.GetRefreshToken(ctx, req.Token)
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthRefreshTokenNotFound) {
+			return inactive, nil
+		}
+		return nil, err
+	}
+	if stored.ClientID != client.ID || stored.RevokedAt != nil {
+		return inactive, nil
+	}
+
+	return &models.OAuthIntrospection{
+		Active:    true,
+		Scope:     authz.FormatScope(stored.Scopes),
+		ClientID:  stored.ClientID,
+		Subject:   stored.UserID,
+		TokenType: "refresh_token",
+		ExpiresAt: stored.ExpiresAt.Unix(),
+		IssuedAt:  stored.CreatedAt.Unix(),
+	}, nil
+}
+
+// Revoke follows RFC 7009. Revoking a refresh token revokes its whole family
+// and the access tokens issued with it.
+func (s *oauthService) Revoke(ctx context.Context, req models.OAuthTokenActionRequest) error {
+	log := s.log.WithContext(ctx).WithField("operation", "oauth_revoke_token")
+
+	client, err := s.authenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return err
+	}
+
+	if looksLikeJWT(req.Token) {
+		claims, err := s.jwtService.ValidateAccessToken(req.Token)
+		if err != nil || claims.ClientID != client.ID {
+			return nil
+		}
+		if err := s.revocations.RevokeToken(ctx, claims.RevocationToken()); err != nil {
+			return fmt.Errorf("failed to revoke access token: %w", err)
+		}
+		log.WithFields(map[string]interface{}{
+			"user_id":   claims.UserID,
+			"client_id": client.ID,
+		}).Info("App access token revoked")
+		return nil
+	}
+
+	stored, err := s.oauthRepo.GetRefreshToken(ctx, req.Token)
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthRefreshTokenNotFound) {
+			return nil
+		}
+		return err
+	}
+	if stored.ClientID != client.ID {
+		return nil
+	}
+
+	tokens, err := s.oauthRepo.RevokeRefreshTokenFamily(ctx, stored.FamilyID)
+	if err != nil {
+		return err
+	}
+	s.revokeAccessTokens(ctx, tokens)
+
+	log.WithFields(map[string]interface{}{
+		"user_id":   stored.UserID,
+		"client_id": client.ID,
+	}).Info("App refresh token revoked")
+	return nil
+}
+
+func (s *oauthService) ListAuthorizedApps(ctx context.Context, userID string) ([]*models.OAuthGrant, error) {
+	return s.oauthRepo.ListGrants(ctx, userID)
+}
+
+func (s *oauthService) RevokeApp(ctx context.Context, userID, clientID string) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "revoke_oauth_app",
+		"user_id":   userID,
+		"client_id": clientID,
+	})
+
+	if _, err := uuid.Parse(clientID); err != nil {
+		return ErrOAuthAppNotFound
+	}
+
+	tokens, err := s.oauthRepo.DeleteGrant(ctx, userID, clientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthGrantNotFound) {
+			return ErrOAuthAppNotFound
+		}
+		return err
+	}
+	s.revokeAccessTokens(ctx, tokens)
+
+	log.WithField("security_event", "oauth_app_revoked").Info("User revoked app")
+	s.events.Record(ctx, &models.AuthEvent{Type: AuthEventAppRevoked, Outcome: AuthOutcomeSuccess, UserID: userID})
+	return nil
+}
+
+func (s *oauthService) Cleanup(ctx context.Context) (int64, error) {
+	return s.oauthRepo.DeleteExpired(ctx)
+}
+
+// authenticateClient identifies the calling app. Confidential clients must
+// present their secret; public clients only have a client_id.
+func (s *oauthService) authenticateClient(ctx context.Context, clientID, secret string) (*models.OAuthClient, error) {
+	invalid := &OAuthError{Code: OAuthInvalidClient, Description: "Client authentication failed"}
+
+	if clientID == "" {
+		return nil, invalid
+	}
+
+	client, err := s.getClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, ErrOAuthClientNotFound) {
+			return nil, invalid
+		}
+		return nil, err
+	}
+
+	if !client.Confidential {
+		if secret != "" {
+			return nil, invalid
+		}
+		return client, nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashClientSecret(secret)), []byte(client.SecretHash)) != 1 {
+		s.log.WithContext(ctx).WithFields(map[string]interface{}{
+			"security_event": "oauth_client_auth_failed",
+			"client_id":      clientID,
+		}).Warn("OAuth client presented a wrong secret")
+		return nil, invalid
+	}
+
+	return client, nil
+}
+
+func (s *oauthService) getClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	if _, err := uuid.Parse(clientID); err != nil {
+		return nil, ErrOAuthClientNotFound
+	}
+
+	client, err := s.oauthRepo.GetClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthClientNotFound) {
+			return nil, ErrOAuthClientNotFound
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+// activeUser loads the user a token is for, refusing the grant if they can
+// no longer sign in
+func (s *oauthService) activeUser(ctx context.Context, userID string) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsActive {
+		return nil, &OAuthError{Code: OAuthInvalidGrant, Description: "The account is inactive"}
+	}
+	return user, nil
+}
+
+// revokeAccessTokens revokes access tokens whose refresh token or grant is
+// gone. A failure is only logged: the tokens expire within the hour anyway.
+func (s *oauthService) revokeAccessTokens(ctx context.Context, tokens []models.OAuthAccessToken) {
+	for _, token := range tokens {
+		err := s.revocations.RevokeToken(ctx, revocation.Token{
+			ID:        token.ID,
+			UserID:    token.UserID,
+			IssuedAt:  token.IssuedAt,
+			ExpiresAt: token.ExpiresAt,
+		})
+		if err != nil {
+			s.log.WithContext(ctx).WithError(err).WithFields(map[string]interface{}{
+				"security_event": "oauth_access_token_revocation_failed",
+				"user_id":        token.UserID,
+			}).Error("Failed to revoke app access token")
+		}
+	}
+}
+
+func issuedAccessToken(claims *AccessTokenClaims) models.OAuthAccessToken {
+	token := claims.RevocationToken()
+	return models.OAuthAccessToken{
+		ID:        token.ID,
+		UserID:    token.UserID,
+		IssuedAt:  token.IssuedAt,
+		ExpiresAt: token.ExpiresAt,
+	}
+}
+
+func tokenResponse(accessToken, refreshToken string, scopes []string) *models.OAuthTokenResponse {
+	return &models.OAuthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        authz.FormatScope(scopes),
+	}
+}
+
+// validateRedirectURI accepts https URIs, http URIs on the loopback
+// interface for native apps, and the reverse domain name schemes of
+// RFC 8252, such as com.example.app:/callback
+func validateRedirectURI(redirectURI string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil || u.Scheme == "" {
+		return fmt.Errorf("%w: %s is not an absolute URI", ErrInvalidRedirectURI, redirectURI)
+	}
+	if u.Fragment != "" {
+		return fmt.Errorf("%w: %s has a fragment", ErrInvalidRedirectURI, redirectURI)
+	}
+
+	switch u.Scheme {
+	case "https":
+		if u.Host == "" {
+			return fmt.Errorf("%w: %s has no host", ErrInvalidRedirectURI, redirectURI)
+		}
+	case "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("%w: %s must use https", ErrInvalidRedirectURI, redirectURI)
+		}
+	default:
+		if !strings.Contains(u.Scheme, ".") {
+			return fmt.Errorf("%w: %s must use https or a reverse domain name scheme", ErrInvalidRedirectURI, redirectURI)
+		}
+	}
+
+	return nil
+}
+
+// redirectWith adds params to a registered redirect URI, keeping its own
+// query. Empty params are left out.
+func redirectWith(redirectURI string, params map[string]string) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := u.Query()
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// validPKCEValue checks a code verifier or S256 challenge: 43 to 128 of the
+// unreserved characters of RFC 7636
+func validPKCEValue(value string) bool {
+	if len(value) < 43 || len(value) > 128 {
+		return false
+	}
+	for _, r := range value {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case r == '-', r == '.', r == '_', r == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// redirectURIMatches checks a token request's redirect_uri against its code.
+// One given in the authorization request must be repeated, identically (RFC
+// 6749 section 4.1.3); one that was left out may be too.
+func redirectURIMatches(authCode *models.OAuthAuthorizationCode, redirectURI string) bool {
+	if redirectURI == "" {
+		return !authCode.RedirectURIExplicit
+	}
+	return redirectURI == authCode.RedirectURI
+}
+
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if !validPKCEValue(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// hashClientSecret is how client secrets are stored. They are random, so a
+// fast hash is enough.
+func hashClientSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// looksLikeJWT tells access tokens apart from the opaque refresh tokens
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
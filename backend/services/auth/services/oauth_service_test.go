@@ -0,0 +1,95 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+)
+
+func TestValidPKCEValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{"43 characters", strings.Repeat("a", 43), true},
+		{"128 characters", strings.Repeat("Z", 128), true},
+		{"unreserved characters", "abcXYZ0189-._~" + strings.Repeat("0", 29), true},
+		{"42 characters", strings.Repeat("a", 42), false},
+		{"129 characters", strings.Repeat("a", 129), false},
+		{"empty", "", false},
+		{"plus", strings.Repeat("a", 42) + "+", false},
+		{"slash", strings.Repeat("a", 42) + "/", false},
+		{"padding", strings.Repeat("a", 42) + "=", false},
+		{"space", strings.Repeat("a", 42) + " ", false},
+		{"non-ascii", strings.Repeat("a", 41) + "é", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validPKCEValue(tt.value); got != tt.want {
+				t.Errorf("validPKCEValue(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	// RFC 7636 appendix B
+	const (
+		verifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	)
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{"rfc 7636 example", verifier, challenge, true},
+		{"wrong verifier", "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXl", challenge, false},
+		{"plain method", verifier, verifier, false},
+		{"padded challenge", verifier, challenge + "=", false},
+		{"empty verifier", "", challenge, false},
+		{"invalid verifier", "short", "", false},
+		{"empty challenge", verifier, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyCodeChallenge(tt.verifier, tt.challenge); got != tt.want {
+				t.Errorf("verifyCodeChallenge(%q, %q) = %v, want %v", tt.verifier, tt.challenge, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedirectURIMatches(t *testing.T) {
+	const registered = "https://app.example.com/callback"
+
+	tests := []struct {
+		name        string
+		explicit    bool
+		redirectURI string
+		want        bool
+	}{
+		{"explicit and repeated", true, registered, true},
+		{"explicit and omitted", true, "", false},
+		{"explicit and different", true, "https://app.example.com/other", false},
+		{"explicit with trailing slash", true, registered + "/", false},
+		{"defaulted and omitted", false, "", true},
+		{"defaulted and sent", false, registered, true},
+		{"defaulted and different", false, "https://evil.example/callback", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authCode := &models.OAuthAuthorizationCode{RedirectURI: registered, RedirectURIExplicit: tt.explicit}
+			if got := redirectURIMatches(authCode, tt.redirectURI); got != tt.want {
+				t.Errorf("redirectURIMatches(%q) = %v, want %v", tt.redirectURI, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,470 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
+	"github.com/mdnaeem95/lifesync/backend/pkg/userdata"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+// Organization roles, from least to most privileged
+const (
+	OrgRoleMember = "member"
+	OrgRoleAdmin  = "admin"
+	OrgRoleOwner  = "owner"
+)
+
+var orgRoleRank = map[string]int{
+	OrgRoleMember: 1,
+	OrgRoleAdmin:  2,
+	OrgRoleOwner:  3,
+}
+
+var (
+	// ErrOrganizationNotFound is also returned for organizations the caller
+	// doesn't belong to, so their existence isn't revealed
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrOrgMemberNotFound    = errors.New("organization member not found")
+	ErrOrgForbidden         = errors.New("organization role does not allow this")
+	ErrLastOrgOwner         = errors.New("an organization must keep at least one owner")
+	ErrAlreadyOrgMember     = errors.New("already a member of the organization")
+	ErrInvitationNotFound   = errors.New("invitation not found")
+	ErrInvalidInvitation    = errors.New("invalid or expired invitation")
+)
+
+// OrganizationService manages organizations, their members and
+// invitations, and switches sessions between organizations.
+//
+// Members can see the organization and its members. Admins can also rename
+// it, invite people and manage members ranked below them; owners can do
+// anything, including deleting it. There is always at least one owner.
+type OrganizationService interface {
+	Create(ctx context.Context, userID string, req models.OrganizationRequest) (*models.Organization, error)
+	List(ctx context.Context, userID string) ([]*models.Organization, error)
+	Get(ctx context.Context, userID, orgID string) (*models.Organization, error)
+	Rename(ctx context.Context, userID, orgID string, req models.OrganizationRequest) (*models.Organization, error)
+	Delete(ctx context.Context, userID, orgID string) error
+
+	ListMembers(ctx context.Context, userID, orgID string) ([]*models.OrganizationMember, error)
+	UpdateMemberRole(ctx context.Context, userID, orgID, memberID, role string) (*models.OrganizationMember, error)
+	// RemoveMember removes someone else, or lets the caller leave when
+	// memberID is their own
+	RemoveMember(ctx context.Context, userID, orgID, memberID string) error
+
+	Invite(ctx context.Context, userID, orgID string, req models.CreateInvitationRequest) (*models.OrganizationInvitation, error)
+	ListInvitations(ctx context.Context, userID, orgID string) ([]*models.OrganizationInvitation, error)
+	RevokeInvitation(ctx context.Context, userID, orgID, invitationID string) error
+	AcceptInvitation(ctx context.Context, userID, token string) (*models.Organization, error)
+
+	// Switch moves the session into an organization the user belongs to,
+	// or back to their personal workspace when orgID is empty, and returns
+	// an access token carrying the new org_id claim
+	Switch(ctx context.Context, userID, sessionID, orgID string) (*models.OrganizationSwitchResponse, error)
+
+	// Cleanup deletes expired invitations
+	Cleanup(ctx context.Context) (int64, error)
+}
+
+type organizationService struct {
+	orgRepo       repository.OrganizationRepository
+	userRepo      repository.UserRepository
+	jwtService    JWTService
+	emailService  EmailService
+	revocations   revocation.Store
+	sources       []userdata.OrgSource
+	invitationTTL time.Duration
+	log           logger.Logger
+}
+
+func NewOrganizationService(orgRepo repository.OrganizationRepository, userRepo repository.UserRepository, jwtService JWTService, emailService EmailService, revocations revocation.Store, sources []userdata.OrgSource, cfg *config.AuthConfig, log logger.Logger) OrganizationService {
+	return &organizationService{
+		orgRepo:       orgRepo,
+		userRepo:      userRepo,
+		jwtService:    jwtService,
+		emailService:  emailService,
+		revocations:   revocations,
+		sources:       sources,
+		invitationTTL: cfg.OrgInvitationTTL,
+		log:           log,
+	}
+}
+
+func (s *organizationService) Create(ctx context.Context, userID string, req models.OrganizationRequest) (*models.Organization, error) {
+	org := &models.Organization{Name: strings.TrimSpace(req.Name)}
+	if err := s.orgRepo.Create(ctx, org, userID); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func (s *organizationService) List(ctx context.Context, userID string) ([]*models.Organization, error) {
+	return s.orgRepo.ListForUser(ctx, userID)
+}
+
+func (s *organizationService) Get(ctx context.Context, userID, orgID string) (*models.Organization, error) {
+	org, err := s.orgRepo.Get(ctx, orgID, userID)
+	if errors.Is(err, repository.ErrOrganizationNotFound) {
+		return nil, ErrOrganizationNotFound
+	}
+	return org, err
+}
+
+func (s *organizationService) Rename(ctx context.Context, userID, orgID string, req models.OrganizationRequest) (*models.Organization, error) {
+	if _, err := s.requireRole(ctx, userID, orgID, OrgRoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if err := s.orgRepo.UpdateName(ctx, orgID, strings.TrimSpace(req.Name)); err != nil {
+		if errors.Is(err, repository.ErrOrganizationNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, err
+	}
+
+	return s.Get(ctx, userID, orgID)
+}
+
+// Delete removes the organization with its shared tasks. The services
+// holding its data are asked to erase it first, and the organization is
+// only removed once they all succeed, so nothing is left behind elsewhere.
+// Members' access tokens are revoked, since they may still carry its org_id
+// claim.
+func (s *organizationService) Delete(ctx context.Context, userID, orgID string) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "delete_organization",
+		"user_id":   userID,
+		"org_id":    orgID,
+	})
+
+	if _, err := s.requireRole(ctx, userID, orgID, OrgRoleOwner); err != nil {
+		return err
+	}
+
+	for _, source := range s.sources {
+		if err := source.DeleteOrganization(ctx, orgID); err != nil {
+			log.WithError(err).WithField("source", source.Name()).Error("Failed to delete organization data")
+			return fmt.Errorf("failed to delete %s data: %w", source.Name(), err)
+		}
+	}
+
+	memberIDs, err := s.orgRepo.Delete(ctx, orgID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOrganizationNotFound) {
+			return ErrOrganizationNotFound
+		}
+		return err
+	}
+
+	for _, memberID := range memberIDs {
+		s.revokeAccessTokens(ctx, memberID)
+	}
+
+	log.WithField("security_event", "organization_deleted").Info("Organization deleted")
+	return nil
+}
+
+func (s *organizationService) ListMembers(ctx context.Context, userID, orgID string) ([]*models.OrganizationMember, error) {
+	if _, err := s.requireRole(ctx, userID, orgID, OrgRoleMember); err != nil {
+		return nil, err
+	}
+	return s.orgRepo.ListMembers(ctx, orgID)
+}
+
+func (s *organizationService) UpdateMemberRole(ctx context.Context, userID, orgID, memberID, role string) (*models.OrganizationMember, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "update_organization_member_role",
+		"user_id":   userID,
+		"org_id":    orgID,
+		"member_id": memberID,
+		"role":      role,
+	})
+
+	actor, err := s.requireRole(ctx, userID, orgID, OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := s.getMember(ctx, orgID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManage(actor.Role, target.Role, role) {
+		return nil, ErrOrgForbidden
+	}
+
+	if err := s.orgRepo.UpdateMemberRole(ctx, orgID, memberID, role); err != nil {
+		return nil, mapMemberError(err)
+	}
+
+	log.WithFields(map[string]interface{}{
+		"security_event": "organization_role_changed",
+		"previous_role":  target.Role,
+	}).Info("Organization member role changed")
+
+	target.Role = role
+	target.UpdatedAt = time.Now()
+	return target, nil
+}
+
+// RemoveMember revokes the removed user's access tokens, which may carry
+// the organization's org_id claim; their sessions fall back to the personal
+// workspace at the next refresh.
+func (s *organizationService) RemoveMember(ctx context.Context, userID, orgID, memberID string) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "remove_organization_member",
+		"user_id":   userID,
+		"org_id":    orgID,
+		"member_id": memberID,
+	})
+
+	if memberID != userID {
+		actor, err := s.requireRole(ctx, userID, orgID, OrgRoleAdmin)
+		if err != nil {
+			return err
+		}
+		target, err := s.getMember(ctx, orgID, memberID)
+		if err != nil {
+			return err
+		}
+		if !canManage(actor.Role, target.Role, target.Role) {
+			return ErrOrgForbidden
+		}
+	}
+
+	if err := s.orgRepo.RemoveMember(ctx, orgID, memberID); err != nil {
+		if errors.Is(err, repository.ErrMemberNotFound) && memberID == userID {
+			return ErrOrganizationNotFound
+		}
+		return mapMemberError(err)
+	}
+
+	s.revokeAccessTokens(ctx, memberID)
+
+	log.WithFields(map[string]interface{}{
+		"security_event": "organization_member_removed",
+		"left":           memberID == userID,
+	}).Info("Organization member removed")
+	return nil
+}
+
+// Invite emails an invitation to join the organization. Inviting an
+// address again replaces the earlier invitation.
+func (s *organizationService) Invite(ctx context.Context, userID, orgID string, req models.CreateInvitationRequest) (*models.OrganizationInvitation, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "invite_organization_member",
+		"user_id":   userID,
+		"org_id":    orgID,
+	})
+
+	actor, err := s.requireRole(ctx, userID, orgID, OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if orgRoleRank[req.Role] > orgRoleRank[actor.Role] {
+		return nil, ErrOrgForbidden
+	}
+
+	email := models.NormalizeEmail(req.Email)
+	member, err := s.orgRepo.HasMemberWithEmail(ctx, orgID, email)
+	if err != nil {
+		return nil, err
+	}
+	if member {
+		return nil, ErrAlreadyOrgMember
+	}
+
+	org, err := s.Get(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	inviter, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to get inviter")
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	token, err := generateSecureToken()
+	if err != nil {
+		log.WithError(err).Error("Failed to generate invitation token")
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	invitation := &models.OrganizationInvitation{
+		OrgID:     orgID,
+		Email:     email,
+		Role:      req.Role,
+		InvitedBy: &userID,
+		ExpiresAt: time.Now().Add(s.invitationTTL),
+	}
+	if err := s.orgRepo.SaveInvitation(ctx, invitation, token); err != nil {
+		return nil, err
+	}
+
+	if err := s.emailService.SendOrganizationInvitation(ctx, email, inviter, org, req.Role, token, s.invitationTTL); err != nil {
+		log.WithError(err).Error("Failed to send organization invitation")
+		return nil, fmt.Errorf("failed to send invitation: %w", err)
+	}
+
+	log.WithField("invitation_id", invitation.ID).Info("Organization invitation sent")
+	return invitation, nil
+}
+
+func (s *organizationService) ListInvitations(ctx context.Context, userID, orgID string) ([]*models.OrganizationInvitation, error) {
+	if _, err := s.requireRole(ctx, userID, orgID, OrgRoleAdmin); err != nil {
+		return nil, err
+	}
+	return s.orgRepo.ListInvitations(ctx, orgID)
+}
+
+func (s *organizationService) RevokeInvitation(ctx context.Context, userID, orgID, invitationID string) error {
+	if _, err := s.requireRole(ctx, userID, orgID, OrgRoleAdmin); err != nil {
+		return err
+	}
+
+	err := s.orgRepo.DeleteInvitation(ctx, orgID, invitationID)
+	if errors.Is(err, repository.ErrInvitationNotFound) {
+		return ErrInvitationNotFound
+	}
+	return err
+}
+
+// AcceptInvitation adds the user to the organization they were invited to.
+// The invitation must be addressed to the user's email address, so a
+// forwarded link is of no use to anyone else.
+func (s *organizationService) AcceptInvitation(ctx context.Context, userID, token string) (*models.Organization, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "accept_organization_invitation",
+		"user_id":   userID,
+	})
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to get user")
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	invitation, err := s.orgRepo.AcceptInvitation(ctx, token, userID, user.Email)
+	switch {
+	case errors.Is(err, repository.ErrInvitationNotFound):
+		return nil, ErrInvalidInvitation
+	case errors.Is(err, repository.ErrAlreadyMember):
+		return nil, ErrAlreadyOrgMember
+	case err != nil:
+		return nil, err
+	}
+
+	log.WithFields(map[string]interface{}{
+		"security_event": "organization_member_joined",
+		"org_id":         invitation.OrgID,
+		"role":           invitation.Role,
+	}).Info("Organization invitation accepted")
+
+	return s.Get(ctx, userID, invitation.OrgID)
+}
+
+func (s *organizationService) Switch(ctx context.Context, userID, sessionID, orgID string) (*models.OrganizationSwitchResponse, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "switch_organization",
+		"user_id":   userID,
+		"org_id":    orgID,
+	})
+
+	if sessionID == "" {
+		return nil, ErrCurrentSessionUnknown
+	}
+	if orgID != "" {
+		if _, err := s.requireRole(ctx, userID, orgID, OrgRoleMember); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.orgRepo.SetSessionOrganization(ctx, userID, sessionID, orgID); err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to get user")
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	accessToken, err := s.jwtService.GenerateAccessToken(user, sessionID, orgID)
+	if err != nil {
+		log.WithError(err).Error("Failed to generate access token")
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	log.Info("Session switched organization")
+	return &models.OrganizationSwitchResponse{
+		AccessToken: accessToken,
+		ExpiresIn:   int(AccessTokenTTL.Seconds()),
+		OrgID:       orgID,
+	}, nil
+}
+
+func (s *organizationService) Cleanup(ctx context.Context) (int64, error) {
+	return s.orgRepo.DeleteExpiredInvitations(ctx)
+}
+
+// requireRole returns the user's membership if their role is at least
+// minRole. Non-members get ErrOrganizationNotFound.
+func (s *organizationService) requireRole(ctx context.Context, userID, orgID, minRole string) (*models.OrganizationMember, error) {
+	member, err := s.orgRepo.GetMember(ctx, orgID, userID)
+	if errors.Is(err, repository.ErrMemberNotFound) {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if orgRoleRank[member.Role] < orgRoleRank[minRole] {
+		return nil, ErrOrgForbidden
+	}
+	return member, nil
+}
+
+func (s *organizationService) getMember(ctx context.Context, orgID, userID string) (*models.OrganizationMember, error) {
+	member, err := s.orgRepo.GetMember(ctx, orgID, userID)
+	if errors.Is(err, repository.ErrMemberNotFound) {
+		return nil, ErrOrgMemberNotFound
+	}
+	return member, err
+}
+
+func (s *organizationService) revokeAccessTokens(ctx context.Context, userID string) {
+	if err := s.revocations.RevokeUser(ctx, userID, time.Now(), AccessTokenTTL); err != nil {
+		s.log.WithContext(ctx).WithError(err).WithField("user_id", userID).Warn("Failed to revoke access tokens after organization change")
+	}
+}
+
+// canManage reports whether an actor may change a member with targetRole
+// to newRole. Owners can change anyone; admins only members ranked below
+// them, and to no higher than their own role.
+func canManage(actorRole, targetRole, newRole string) bool {
+	if actorRole == OrgRoleOwner {
+		return true
+	}
+	return orgRoleRank[targetRole] < orgRoleRank[actorRole] && orgRoleRank[newRole] <= orgRoleRank[actorRole]
+}
+
+func mapMemberError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrMemberNotFound):
+		return ErrOrgMemberNotFound
+	case errors.Is(err, repository.ErrLastOwner):
+		return ErrLastOrgOwner
+	default:
+		return err
+	}
+}
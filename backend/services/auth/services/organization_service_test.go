@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/userdata"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+type fakeOrgRepo struct {
+	repository.OrganizationRepository
+	members map[string]string
+	deleted []string
+}
+
+func (f *fakeOrgRepo) GetMember(ctx context.Context, orgID, userID string) (*models.OrganizationMember, error) {
+	role, ok := f.members[userID]
+	if !ok {
+		return nil, repository.ErrMemberNotFound
+	}
+	return &models.OrganizationMember{UserID: userID, Role: role}, nil
+}
+
+func (f *fakeOrgRepo) Delete(ctx context.Context, orgID string) ([]string, error) {
+	f.deleted = append(f.deleted, orgID)
+	var memberIDs []string
+	for userID := range f.members {
+		memberIDs = append(memberIDs, userID)
+	}
+	return memberIDs, nil
+}
+
+type fakeOrgSource struct {
+	err     error
+	deleted []string
+}
+
+func (f *fakeOrgSource) Name() string {
+	return "flowtime"
+}
+
+func (f *fakeOrgSource) DeleteOrganization(ctx context.Context, orgID string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.deleted = append(f.deleted, orgID)
+	return nil
+}
+
+func TestOrganizationDelete(t *testing.T) {
+	const orgID = "org-1"
+
+	tests := []struct {
+		name        string
+		role        string
+		sourceErr   error
+		wantErr     error
+		wantDeleted bool
+	}{
+		{"owner", OrgRoleOwner, nil, nil, true},
+		{"admin", OrgRoleAdmin, nil, ErrOrgForbidden, false},
+		{"source fails", OrgRoleOwner, errors.New("connection refused"), nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeOrgRepo{members: map[string]string{"u1": tt.role}}
+			source := &fakeOrgSource{err: tt.sourceErr}
+			revocations := &fakeRevocations{}
+			s := NewOrganizationService(repo, nil, nil, nil, revocations, []userdata.OrgSource{source}, &config.AuthConfig{}, logger.New())
+
+			err := s.Delete(context.Background(), "u1", orgID)
+			if tt.wantDeleted && err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if !tt.wantDeleted && (err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("Delete() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got := len(repo.deleted) == 1; got != tt.wantDeleted {
+				t.Errorf("organization deleted = %v, want %v", got, tt.wantDeleted)
+			}
+			if tt.wantDeleted {
+				if !reflect.DeepEqual(source.deleted, []string{orgID}) {
+					t.Errorf("source deleted %v, want the organization's data", source.deleted)
+				}
+				if !reflect.DeepEqual(revocations.users, []string{"u1"}) {
+					t.Errorf("revoked %v, want the members' tokens", revocations.users)
+				}
+			}
+		})
+	}
+}
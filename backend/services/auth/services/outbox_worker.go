@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/mailer"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+const (
+	outboxLease         = 5 * time.Minute
+	outboxBaseRetry     = 30 * time.Second
+	outboxMaxRetryDelay = time.Hour
+)
+
+// OutboxWorker drains the email outbox, retrying failed deliveries with
+// exponential backoff until maxAttempts is reached
+type OutboxWorker struct {
+	outboxRepo   repository.OutboxRepository
+	mailer       mailer.Mailer
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	log          logger.Logger
+}
+
+func NewOutboxWorker(outboxRepo repository.OutboxRepository, m mailer.Mailer, pollInterval time.Duration, batchSize, maxAttempts int, log logger.Logger) *OutboxWorker {
+	return &OutboxWorker{
+		outboxRepo:   outboxRepo,
+		mailer:       m,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+		maxAttempts:  maxAttempts,
+		log:          log,
+	}
+}
+
+// Start polls the outbox until ctx is cancelled
+func (w *OutboxWorker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	w.log.WithField("poll_interval", w.pollInterval.String()).Info("Email outbox worker started")
+
+	for {
+		// Keep draining while full batches come back
+		for w.processBatch(ctx) == w.batchSize {
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			w.log.Info("Email outbox worker stopped")
+			return
+		}
+	}
+}
+
+func (w *OutboxWorker) processBatch(ctx context.Context) int {
+	emails, err := w.outboxRepo.ClaimDue(ctx, w.batchSize, outboxLease)
+	if err != nil {
+		w.log.WithError(err).Error("Failed to claim outbox emails")
+		return 0
+	}
+
+	for _, email := range emails {
+		w.deliver(ctx, email)
+	}
+
+	return len(emails)
+}
+
+func (w *OutboxWorker) deliver(ctx context.Context, email *models.OutboxEmail) {
+	log := w.log.WithFields(map[string]interface{}{
+		"email_id": email.ID,
+		"template": email.Template,
+		"attempt":  email.Attempts,
+	})
+
+	msg := &mailer.Message{
+		ID:       email.ID,
+		To:       email.ToAddress,
+		Subject:  email.Subject,
+		TextBody: email.TextBody,
+	}
+	if email.HTMLBody != nil {
+		msg.HTMLBody = *email.HTMLBody
+	}
+
+	// Use a detached context so a shutdown doesn't leave the row half-updated
+	storeCtx := context.WithoutCancel(ctx)
+
+	sendErr := w.mailer.Send(ctx, msg)
+	if sendErr == nil {
+		if err := w.outboxRepo.MarkSent(storeCtx, email.ID); err != nil {
+			log.WithError(err).Error("Email sent but failed to mark as sent")
+			return
+		}
+		log.Info("Email delivered")
+		return
+	}
+
+	if email.Attempts >= w.maxAttempts {
+		log.WithError(sendErr).Error("Email delivery failed permanently")
+		if err := w.outboxRepo.MarkFailed(storeCtx, email.ID, sendErr.Error()); err != nil {
+			log.WithError(err).Error("Failed to mark email as failed")
+		}
+		return
+	}
+
+	nextAttempt := time.Now().Add(retryDelay(email.Attempts))
+	log.WithError(sendErr).WithField("next_attempt_at", nextAttempt).Warn("Email delivery failed, will retry")
+	if err := w.outboxRepo.MarkRetry(storeCtx, email.ID, sendErr.Error(), nextAttempt); err != nil {
+		log.WithError(err).Error("Failed to schedule email retry")
+	}
+}
+
+// retryDelay doubles the wait after every failed attempt, capped at outboxMaxRetryDelay
+func retryDelay(attempts int) time.Duration {
+	delay := outboxBaseRetry
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= outboxMaxRetryDelay {
+			return outboxMaxRetryDelay
+		}
+	}
+	return delay
+}
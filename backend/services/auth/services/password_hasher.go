@@ -0,0 +1,101 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/password"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms
+const (
+	HashArgon2id = "argon2id"
+	HashBcrypt   = "bcrypt"
+)
+
+// PasswordHasher hashes passwords for storage and checks them against
+// stored hashes of any supported algorithm
+type PasswordHasher interface {
+	Hash(plaintext string) (string, error)
+	// Verify reports whether plaintext matches hash, and whether the hash
+	// should be replaced with a fresh one because it uses another algorithm
+	// or other parameters than new hashes do. An empty hash never matches.
+	Verify(plaintext, hash string) (match, rehash bool, err error)
+}
+
+type passwordHasher struct {
+	algorithm  string
+	argon2id   password.Argon2idParams
+	bcryptCost int
+}
+
+// NewPasswordHasher hashes new passwords with the configured algorithm,
+// Argon2id by default, and verifies both Argon2id and bcrypt hashes
+func NewPasswordHasher(cfg *config.AuthConfig) (PasswordHasher, error) {
+	hasher := &passwordHasher{
+		algorithm:  cfg.PasswordHashAlgorithm,
+		argon2id:   password.DefaultArgon2idParams,
+		bcryptCost: cfg.BcryptCost,
+	}
+	hasher.argon2id.Memory = uint32(cfg.Argon2Memory)
+	hasher.argon2id.Iterations = uint32(cfg.Argon2Iterations)
+	hasher.argon2id.Parallelism = uint8(cfg.Argon2Parallelism)
+
+	switch hasher.algorithm {
+	case HashArgon2id:
+		if cfg.Argon2Memory < 8*cfg.Argon2Parallelism || cfg.Argon2Iterations < 1 || cfg.Argon2Parallelism < 1 || cfg.Argon2Parallelism > 255 {
+			return nil, fmt.Errorf("invalid argon2id parameters: memory %d KiB, iterations %d, parallelism %d", cfg.Argon2Memory, cfg.Argon2Iterations, cfg.Argon2Parallelism)
+		}
+	case HashBcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid bcrypt cost: %d", cfg.BcryptCost)
+		}
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm: %s", cfg.PasswordHashAlgorithm)
+	}
+
+	return hasher, nil
+}
+
+func (h *passwordHasher) Hash(plaintext string) (string, error) {
+	if h.algorithm == HashBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), h.bcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+	return password.HashArgon2id(plaintext, h.argon2id)
+}
+
+func (h *passwordHasher) Verify(plaintext, hash string) (bool, bool, error) {
+	switch {
+	case hash == "":
+		return false, false, nil
+
+	case password.IsArgon2id(hash):
+		match, params, err := password.VerifyArgon2id(plaintext, hash)
+		if err != nil || !match {
+			return false, false, err
+		}
+		rehash := h.algorithm != HashArgon2id ||
+			params.Memory != h.argon2id.Memory ||
+			params.Iterations != h.argon2id.Iterations ||
+			params.Parallelism != h.argon2id.Parallelism ||
+			params.KeyLength != h.argon2id.KeyLength
+		return true, rehash, nil
+
+	case password.IsBcrypt(hash):
+		match, err := password.Verify(plaintext, hash)
+		if err != nil || !match {
+			return false, false, err
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		rehash := h.algorithm != HashBcrypt || err != nil || cost != h.bcryptCost
+		return true, rehash, nil
+
+	default:
+		return false, false, password.ErrUnknownHashFormat
+	}
+}
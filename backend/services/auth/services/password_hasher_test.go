@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/password"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestHasher uses small Argon2id parameters to keep the tests fast
+func newTestHasher(t *testing.T, algorithm string, iterations, bcryptCost int) PasswordHasher {
+	t.Helper()
+	hasher, err := NewPasswordHasher(&config.AuthConfig{
+		PasswordHashAlgorithm: algorithm,
+		Argon2Memory:          64,
+		Argon2Iterations:      iterations,
+		Argon2Parallelism:     1,
+		BcryptCost:            bcryptCost,
+	})
+	if err != nil {
+		t.Fatalf("NewPasswordHasher() error = %v", err)
+	}
+	return hasher
+}
+
+func TestPasswordHasherVerify(t *testing.T) {
+	current := newTestHasher(t, HashArgon2id, 1, bcrypt.MinCost)
+
+	hash := func(h PasswordHasher) string {
+		t.Helper()
+		encoded, err := h.Hash("open-sesame")
+		if err != nil {
+			t.Fatalf("Hash() error = %v", err)
+		}
+		return encoded
+	}
+	argon := hash(current)
+
+	tests := []struct {
+		name       string
+		plaintext  string
+		hash       string
+		wantMatch  bool
+		wantRehash bool
+		wantErr    error
+	}{
+		{"current parameters", "open-sesame", argon, true, false, nil},
+		{"wrong password", "open-sesame!", argon, false, false, nil},
+		{"older argon2id parameters", "open-sesame", hash(newTestHasher(t, HashArgon2id, 2, bcrypt.MinCost)), true, true, nil},
+		{"bcrypt", "open-sesame", hash(newTestHasher(t, HashBcrypt, 1, bcrypt.MinCost)), true, true, nil},
+		{"bcrypt, wrong password", "sesame", hash(newTestHasher(t, HashBcrypt, 1, bcrypt.MinCost)), false, false, nil},
+		{"no password", "", "", false, false, nil},
+		{"unknown format", "open-sesame", "md5:abc", false, false, password.ErrUnknownHashFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash, err := current.Verify(tt.plaintext, tt.hash)
+			if match != tt.wantMatch || rehash != tt.wantRehash || !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() = %v, %v, %v, want %v, %v, %v", match, rehash, err, tt.wantMatch, tt.wantRehash, tt.wantErr)
+			}
+		})
+	}
+
+	if !password.IsArgon2id(argon) {
+		t.Errorf("Hash() = %q, want an Argon2id PHC string", argon)
+	}
+}
+
+func TestPasswordHasherVerifyBcryptConfigured(t *testing.T) {
+	current := newTestHasher(t, HashBcrypt, 1, bcrypt.MinCost)
+	sameCost, _ := current.Hash("open-sesame")
+	higherCost, _ := bcrypt.GenerateFromPassword([]byte("open-sesame"), bcrypt.MinCost+1)
+	argon, _ := newTestHasher(t, HashArgon2id, 1, bcrypt.MinCost).Hash("open-sesame")
+
+	tests := []struct {
+		name       string
+		hash       string
+		wantRehash bool
+	}{
+		{"same cost", sameCost, false},
+		{"other cost", string(higherCost), true},
+		{"argon2id", argon, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash, err := current.Verify("open-sesame", tt.hash)
+			if !match || rehash != tt.wantRehash || err != nil {
+				t.Errorf("Verify() = %v, %v, %v, want true, %v, nil", match, rehash, err, tt.wantRehash)
+			}
+		})
+	}
+}
+
+func TestNewPasswordHasherRejectsBadConfig(t *testing.T) {
+	tests := []config.AuthConfig{
+		{PasswordHashAlgorithm: "scrypt"},
+		{PasswordHashAlgorithm: HashArgon2id, Argon2Memory: 4, Argon2Iterations: 1, Argon2Parallelism: 1},
+		{PasswordHashAlgorithm: HashArgon2id, Argon2Memory: 64, Argon2Iterations: 0, Argon2Parallelism: 1},
+		{PasswordHashAlgorithm: HashArgon2id, Argon2Memory: 64 * 1024, Argon2Iterations: 1, Argon2Parallelism: 256},
+		{PasswordHashAlgorithm: HashBcrypt, BcryptCost: bcrypt.MaxCost + 1},
+	}
+
+	for _, cfg := range tests {
+		if _, err := NewPasswordHasher(&cfg); err == nil {
+			t.Errorf("NewPasswordHasher(%+v) succeeded", cfg)
+		}
+	}
+}
+
+// fakeRehashUserRepo records replaced password hashes
+type fakeRehashUserRepo struct {
+	repository.UserRepository
+	oldHash, newHash string
+}
+
+func (f *fakeRehashUserRepo) ReplacePasswordHash(ctx context.Context, userID, oldHash, newHash string) error {
+	f.oldHash, f.newHash = oldHash, newHash
+	return nil
+}
+
+func TestUpgradePasswordHashFromBcrypt(t *testing.T) {
+	hasher := newTestHasher(t, HashArgon2id, 1, bcrypt.MinCost)
+	legacy, err := bcrypt.GenerateFromPassword([]byte("open-sesame"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{ID: "u1", PasswordHash: string(legacy)}
+
+	match, rehash, err := hasher.Verify("open-sesame", user.PasswordHash)
+	if !match || !rehash || err != nil {
+		t.Fatalf("Verify() = %v, %v, %v, want a match needing a rehash", match, rehash, err)
+	}
+
+	repo := &fakeRehashUserRepo{}
+	s := &authService{userRepo: repo, hasher: hasher, log: logger.New()}
+	s.upgradePasswordHash(context.Background(), user, "open-sesame")
+
+	if repo.oldHash != string(legacy) {
+		t.Errorf("replaced hash = %q, want the bcrypt hash", repo.oldHash)
+	}
+	if !password.IsArgon2id(repo.newHash) {
+		t.Fatalf("new hash = %q, want Argon2id", repo.newHash)
+	}
+	if match, rehash, err := hasher.Verify("open-sesame", repo.newHash); !match || rehash || err != nil {
+		t.Errorf("Verify() of the upgraded hash = %v, %v, %v, want a match without rehash", match, rehash, err)
+	}
+}
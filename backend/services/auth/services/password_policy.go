@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/password"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+// bcrypt ignores everything past 72 bytes. Argon2id doesn't, but longer
+// passwords are refused whichever algorithm is configured, so existing
+// bcrypt hashes are never silently truncated and switching algorithms
+// doesn't change which passwords are accepted.
+const maxPasswordBytes = 72
+
+// Password policy violation codes
+const (
+	PasswordTooShort = "too_short"
+	PasswordTooLong  = "too_long"
+	PasswordTooWeak  = "too_weak"
+	PasswordBreached = "breached"
+	PasswordReused   = "reused"
+)
+
+// PasswordViolation is one reason a password was refused
+type PasswordViolation struct {
+	Code        string   `json:"code"`
+	Message     string   `json:"message"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// PasswordPolicyError lists every rule a new password breaks
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	codes := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		codes[i] = v.Code
+	}
+	return "password does not meet policy: " + strings.Join(codes, ", ")
+}
+
+// PasswordPolicy decides whether a password may be set
+type PasswordPolicy interface {
+	// Validate returns a *PasswordPolicyError if user may not use newPassword.
+	// user may be an account that has not been created yet.
+	Validate(ctx context.Context, newPassword string, user *models.User) error
+	// Remember records a password hash the user has just set, so it cannot
+	// be reused
+	Remember(ctx context.Context, userID, passwordHash string) error
+}
+
+type passwordPolicy struct {
+	historyRepo repository.PasswordHistoryRepository
+	breached    *password.BreachedList
+	hasher      PasswordHasher
+	cfg         *config.AuthConfig
+	log         logger.Logger
+}
+
+// NewPasswordPolicy creates the policy. breached may be nil to skip the
+// breached password check. hasher checks new passwords against remembered
+// hashes, whichever algorithm they were made with.
+func NewPasswordPolicy(historyRepo repository.PasswordHistoryRepository, breached *password.BreachedList, hasher PasswordHasher, cfg *config.AuthConfig, log logger.Logger) PasswordPolicy {
+	return &passwordPolicy{
+		historyRepo: historyRepo,
+		breached:    breached,
+		hasher:      hasher,
+		cfg:         cfg,
+		log:         log,
+	}
+}
+
+func (p *passwordPolicy) Validate(ctx context.Context, newPassword string, user *models.User) error {
+	var violations []PasswordViolation
+
+	length := len([]rune(newPassword))
+	tooLong := len(newPassword) > maxPasswordBytes
+	if length < p.cfg.PasswordMinLength {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooShort,
+			Message: fmt.Sprintf("Password must be at least %d characters", p.cfg.PasswordMinLength),
+		})
+	}
+	if tooLong {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooLong,
+			Message: fmt.Sprintf("Password must be at most %d bytes", maxPasswordBytes),
+		})
+	}
+
+	if p.breached.Contains(newPassword) {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordBreached,
+			Message: "This password has appeared in a data breach and can't be used",
+		})
+	}
+
+	var userInputs []string
+	if user != nil {
+		userInputs = []string{user.Email, user.Name}
+	}
+	if strength := password.Estimate(newPassword, userInputs...); strength.Score < p.cfg.PasswordMinScore {
+		message := "Password is too easy to guess"
+		if strength.Warning != "" {
+			message = strength.Warning
+		}
+		violations = append(violations, PasswordViolation{
+			Code:        PasswordTooWeak,
+			Message:     message,
+			Suggestions: strength.Suggestions,
+		})
+	}
+
+	// Comparing against old hashes is slow, so only do it for an otherwise
+	// acceptable password
+	if len(violations) == 0 && user != nil && user.ID != "" {
+		reused, err := p.reused(ctx, newPassword, user)
+		if err != nil {
+			return err
+		}
+		if reused {
+			violations = append(violations, PasswordViolation{
+				Code:    PasswordReused,
+				Message: fmt.Sprintf("Password must be different from your last %d passwords", p.cfg.PasswordHistory),
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		p.log.WithContext(ctx).WithField("violations", len(violations)).Debug("Password rejected by policy")
+		return &PasswordPolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+// reused reports whether newPassword matches the current password or one of
+// the remembered ones
+func (p *passwordPolicy) reused(ctx context.Context, newPassword string, user *models.User) (bool, error) {
+	if p.cfg.PasswordHistory <= 0 {
+		return false, nil
+	}
+
+	hashes, err := p.historyRepo.Recent(ctx, user.ID, p.cfg.PasswordHistory)
+	if err != nil {
+		return false, err
+	}
+
+	// Accounts from before password history only have their current hash
+	if user.PasswordHash != "" {
+		known := false
+		for _, hash := range hashes {
+			if hash == user.PasswordHash {
+				known = true
+				break
+			}
+		}
+		if !known {
+			hashes = append([]string{user.PasswordHash}, hashes...)
+		}
+	}
+
+	for _, hash := range hashes {
+		match, _, err := p.hasher.Verify(newPassword, hash)
+		if err != nil {
+			p.log.WithContext(ctx).WithError(err).WithField("user_id", user.ID).Warn("Skipping unreadable password history hash")
+			continue
+		}
+		if match {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (p *passwordPolicy) Remember(ctx context.Context, userID, passwordHash string) error {
+	if p.cfg.PasswordHistory <= 0 {
+		return nil
+	}
+	return p.historyRepo.Add(ctx, userID, passwordHash, p.cfg.PasswordHistory)
+}
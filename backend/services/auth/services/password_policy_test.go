@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/password"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakePasswordHistory struct {
+	hashes  []string
+	lookups int
+	added   []string
+}
+
+func (f *fakePasswordHistory) Add(ctx context.Context, userID, passwordHash string, keep int) error {
+	f.added = append(f.added, passwordHash)
+	return nil
+}
+
+func (f *fakePasswordHistory) Recent(ctx context.Context, userID string, limit int) ([]string, error) {
+	f.lookups++
+	if len(f.hashes) > limit {
+		return f.hashes[:limit], nil
+	}
+	return f.hashes, nil
+}
+
+func newTestPasswordPolicy(t *testing.T, history *fakePasswordHistory) PasswordPolicy {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	// SHA-1 of "Summer-Breeze-1999"
+	if err := os.WriteFile(path, []byte("F202FF0312C5B8CA073C061D6BBAF555BCA2CC89\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	breached, err := password.LoadBreachedList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.AuthConfig{
+		PasswordMinLength:     8,
+		PasswordMinScore:      2,
+		PasswordHistory:       3,
+		PasswordHashAlgorithm: HashBcrypt,
+		BcryptCost:            bcrypt.MinCost,
+	}
+	hasher, err := NewPasswordHasher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewPasswordPolicy(history, breached, hasher, cfg, logger.New())
+}
+
+func bcryptHash(t *testing.T, plaintext string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+func violationCodes(err error) []string {
+	var policyErr *PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return nil
+	}
+	codes := make([]string, len(policyErr.Violations))
+	for i, v := range policyErr.Violations {
+		codes[i] = v.Code
+	}
+	return codes
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	const strong = "violet-Harbor-Cinnamon-42"
+
+	tests := []struct {
+		name     string
+		password string
+		user     *models.User
+		history  []string
+		want     []string
+	}{
+		{"strong", strong, nil, nil, nil},
+		{"too short", "xQ7!pR", nil, nil, []string{PasswordTooShort, PasswordTooWeak}},
+		{"too long", strings.Repeat("violet-Harbor-", 6), nil, nil, []string{PasswordTooLong}},
+		{"common", "password1", nil, nil, []string{PasswordTooWeak}},
+		{"keyboard walk", "qwertyuiop", nil, nil, []string{PasswordTooWeak}},
+		{"breached", "Summer-Breeze-1999", nil, nil, []string{PasswordBreached}},
+		{"own name", "janedoe!", &models.User{Email: "jane.doe@example.com", Name: "Jane Doe"}, nil, []string{PasswordTooWeak}},
+		{"current password", strong, &models.User{ID: "user-1", PasswordHash: "current"}, nil, []string{PasswordReused}},
+		{"remembered password", strong, &models.User{ID: "user-1"}, []string{"other", "remembered"}, []string{PasswordReused}},
+		{"forgotten password", strong, &models.User{ID: "user-1"}, []string{"other", "other", "other", "remembered"}, nil},
+		{"new account", strong, &models.User{Email: "new@example.com"}, []string{"remembered"}, nil},
+	}
+
+	hashes := map[string]string{
+		"current":    bcryptHash(t, strong),
+		"remembered": bcryptHash(t, strong),
+		"other":      bcryptHash(t, "something-else-entirely"),
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := &fakePasswordHistory{}
+			for _, name := range tt.history {
+				history.hashes = append(history.hashes, hashes[name])
+			}
+			var user *models.User
+			if tt.user != nil {
+				u := *tt.user
+				u.PasswordHash = hashes[u.PasswordHash]
+				user = &u
+			}
+
+			err := newTestPasswordPolicy(t, history).Validate(context.Background(), tt.password, user)
+			if got := violationCodes(err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() violations = %v (error %v), want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyWeakViolationExplains(t *testing.T) {
+	err := newTestPasswordPolicy(t, &fakePasswordHistory{}).Validate(context.Background(), "password1", nil)
+
+	var policyErr *PasswordPolicyError
+	if !errors.As(err, &policyErr) || len(policyErr.Violations) != 1 {
+		t.Fatalf("Validate() error = %v, want one violation", err)
+	}
+	v := policyErr.Violations[0]
+	if v.Message != "This is a very common password" || len(v.Suggestions) == 0 {
+		t.Errorf("violation = %+v, want the estimator's warning and suggestions", v)
+	}
+}
+
+func TestPasswordPolicySkipsHistoryForRejectedPasswords(t *testing.T) {
+	history := &fakePasswordHistory{}
+	policy := newTestPasswordPolicy(t, history)
+
+	if err := policy.Validate(context.Background(), "short", &models.User{ID: "user-1"}); err == nil {
+		t.Fatal("Validate() accepted a short password")
+	}
+	if history.lookups != 0 {
+		t.Errorf("password history read %d times for a rejected password", history.lookups)
+	}
+}
+
+func TestPasswordPolicyRemember(t *testing.T) {
+	history := &fakePasswordHistory{}
+	if err := newTestPasswordPolicy(t, history).Remember(context.Background(), "user-1", "hash"); err != nil {
+		t.Fatalf("Remember() error = %v", err)
+	}
+	if !reflect.DeepEqual(history.added, []string{"hash"}) {
+		t.Errorf("history = %v, want the new hash", history.added)
+	}
+
+	off := &fakePasswordHistory{}
+	policy := NewPasswordPolicy(off, nil, nil, &config.AuthConfig{}, logger.New())
+	if err := policy.Remember(context.Background(), "user-1", "hash"); err != nil || off.added != nil {
+		t.Errorf("Remember() with history off = %v, recorded %v", err, off.added)
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdnaeem95/lifesync/backend/pkg/authz"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/pat"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+var ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+
+// PersonalAccessTokenService manages the tokens users create for scripts and
+// integrations, and verifies them for the auth middlewares
+type PersonalAccessTokenService interface {
+	Create(ctx context.Context, userID string, req models.CreatePersonalAccessTokenRequest) (*models.CreatePersonalAccessTokenResponse, error)
+	List(ctx context.Context, userID string) ([]*models.PersonalAccessToken, error)
+	Revoke(ctx context.Context, userID, tokenID string) error
+	pat.Verifier
+}
+
+type personalAccessTokenService struct {
+	tokenRepo repository.PersonalAccessTokenRepository
+	userRepo  repository.UserRepository
+	log       logger.Logger
+}
+
+func NewPersonalAccessTokenService(tokenRepo repository.PersonalAccessTokenRepository, userRepo repository.UserRepository, log logger.Logger) PersonalAccessTokenService {
+	return &personalAccessTokenService{
+		tokenRepo: tokenRepo,
+		userRepo:  userRepo,
+		log:       log,
+	}
+}
+
+// Create issues a new token. The response is the only time the token itself
+// is available.
+func (s *personalAccessTokenService) Create(ctx context.Context, userID string, req models.CreatePersonalAccessTokenRequest) (*models.CreatePersonalAccessTokenResponse, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "create_personal_access_token",
+		"user_id":   userID,
+	})
+
+	secret, err := pat.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	token := &models.PersonalAccessToken{
+		UserID: userID,
+		Name:   strings.TrimSpace(req.Name),
+		Prefix: pat.DisplayPrefix(secret),
+		Scopes: uniqueScopes(req.Scopes),
+	}
+	if req.ExpiresInDays != nil {
+		expiresAt := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := s.tokenRepo.Create(ctx, token, pat.Hash(secret)); err != nil {
+		return nil, err
+	}
+
+	log.WithFields(map[string]interface{}{
+		"security_event": "personal_access_token_created",
+		"token_id":       token.ID,
+		"scopes":         token.Scopes,
+	}).Info("Personal access token created")
+
+	return &models.CreatePersonalAccessTokenResponse{PersonalAccessToken: token, Token: secret}, nil
+}
+
+func (s *personalAccessTokenService) List(ctx context.Context, userID string) ([]*models.PersonalAccessToken, error) {
+	return s.tokenRepo.ListByUser(ctx, userID)
+}
+
+func (s *personalAccessTokenService) Revoke(ctx context.Context, userID, tokenID string) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "revoke_personal_access_token",
+		"user_id":   userID,
+		"token_id":  tokenID,
+	})
+
+	if _, err := uuid.Parse(tokenID); err != nil {
+		return ErrPersonalAccessTokenNotFound
+	}
+
+	if err := s.tokenRepo.Delete(ctx, userID, tokenID); err != nil {
+		if errors.Is(err, repository.ErrPersonalAccessTokenNotFound) {
+			return ErrPersonalAccessTokenNotFound
+		}
+		return err
+	}
+
+	log.WithField("security_event", "personal_access_token_revoked").Info("Personal access token revoked")
+	return nil
+}
+
+// Verify resolves a token to its owner. Scopes the owner's roles no longer
+// grant are dropped, and tokens stop working when the account is deactivated.
+func (s *personalAccessTokenService) Verify(ctx context.Context, secret string) (*pat.Principal, error) {
+	log := s.log.WithContext(ctx).WithField("operation", "verify_personal_access_token")
+
+	if !pat.IsToken(secret) {
+		return nil, pat.ErrInvalidToken
+	}
+
+	token, err := s.tokenRepo.GetByHash(ctx, pat.Hash(secret))
+	if err != nil {
+		if errors.Is(err, repository.ErrPersonalAccessTokenNotFound) {
+			return nil, pat.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	if token.ExpiresAt != nil && !time.Now().Before(*token.ExpiresAt) {
+		log.WithField("token_id", token.ID).Debug("Expired personal access token used")
+		return nil, pat.ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, token.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token owner: %w", err)
+	}
+	if !user.IsActive {
+		log.WithField("user_id", user.ID).Warn("Personal access token of inactive user used")
+		return nil, pat.ErrInvalidToken
+	}
+
+	granted := authz.ScopesForRoles(user.Roles)
+	scopes := []string{}
+	for _, scope := range token.Scopes {
+		if authz.HasAll(granted, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if err := s.tokenRepo.TouchLastUsed(ctx, token.ID); err != nil {
+		log.WithError(err).Warn("Failed to update token last used")
+	}
+
+	return &pat.Principal{
+		TokenID:   token.ID,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Scopes:    scopes,
+		ExpiresAt: token.ExpiresAt,
+	}, nil
+}
+
+// uniqueScopes drops repeated scopes, keeping the first of each
+func uniqueScopes(scopes []string) []string {
+	seen := make(map[string]bool, len(scopes))
+	unique := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/authz"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/pat"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+// fakePATRepo looks tokens up by hash and records the ones marked used.
+// Methods the tests don't use panic through the nil embedded repository.
+type fakePATRepo struct {
+	repository.PersonalAccessTokenRepository
+	tokens  map[string]*models.PersonalAccessToken
+	err     error
+	touched []string
+}
+
+func (f *fakePATRepo) GetByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	token, ok := f.tokens[tokenHash]
+	if !ok {
+		return nil, repository.ErrPersonalAccessTokenNotFound
+	}
+	return token, nil
+}
+
+func (f *fakePATRepo) TouchLastUsed(ctx context.Context, id string) error {
+	f.touched = append(f.touched, id)
+	return nil
+}
+
+// fakePATUserRepo returns the token's owner
+type fakePATUserRepo struct {
+	repository.UserRepository
+	user *models.User
+}
+
+func (f *fakePATUserRepo) GetByID(ctx context.Context, id string) (*models.User, error) {
+	if f.user == nil || f.user.ID != id {
+		return nil, errors.New("user not found")
+	}
+	return f.user, nil
+}
+
+func TestPersonalAccessTokenVerify(t *testing.T) {
+	secret := pat.Prefix + "0123456789abcdef"
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		secret     string
+		expiresAt  *time.Time
+		scopes     []string
+		roles      []string
+		inactive   bool
+		repoErr    error
+		wantErr    error
+		wantScopes []string
+	}{
+		{
+			name:       "valid token",
+			secret:     secret,
+			expiresAt:  &future,
+			scopes:     []string{authz.ScopeFlowtimeRead},
+			roles:      []string{authz.RoleUser},
+			wantScopes: []string{authz.ScopeFlowtimeRead},
+		},
+		{
+			name:    "not a personal access token",
+			secret:  "eyJhbGciOiJIUzI1NiJ9.e30.sig",
+			roles:   []string{authz.RoleUser},
+			wantErr: pat.ErrInvalidToken,
+		},
+		{
+			name:    "unknown token",
+			secret:  pat.Prefix + "unknown",
+			roles:   []string{authz.RoleUser},
+			wantErr: pat.ErrInvalidToken,
+		},
+		{
+			name:      "expired token",
+			secret:    secret,
+			expiresAt: &past,
+			scopes:    []string{authz.ScopeFlowtimeRead},
+			roles:     []string{authz.RoleUser},
+			wantErr:   pat.ErrInvalidToken,
+		},
+		{
+			name:     "inactive owner",
+			secret:   secret,
+			scopes:   []string{authz.ScopeFlowtimeRead},
+			roles:    []string{authz.RoleUser},
+			inactive: true,
+			wantErr:  pat.ErrInvalidToken,
+		},
+		{
+			name:       "scopes the owner's roles no longer grant are dropped",
+			secret:     secret,
+			scopes:     []string{authz.ScopeFlowtimeRead, authz.ScopeUsersRead, authz.ScopeRolesWrite},
+			roles:      []string{authz.RoleUser},
+			wantScopes: []string{authz.ScopeFlowtimeRead},
+		},
+		{
+			name:       "scopes the owner's roles grant are kept",
+			secret:     secret,
+			scopes:     []string{authz.ScopeUsersRead, authz.ScopeRolesWrite},
+			roles:      []string{authz.RoleSupport},
+			wantScopes: []string{authz.ScopeUsersRead},
+		},
+		{
+			name:    "repository failure is not an invalid token",
+			secret:  secret,
+			roles:   []string{authz.RoleUser},
+			repoErr: errors.New("connection refused"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := &fakePATRepo{
+				tokens: map[string]*models.PersonalAccessToken{
+					pat.Hash(secret): {ID: "token-1", UserID: "user-1", Scopes: tt.scopes, ExpiresAt: tt.expiresAt},
+				},
+				err: tt.repoErr,
+			}
+			users := &fakePATUserRepo{user: &models.User{
+				ID:       "user-1",
+				Email:    "user@example.com",
+				IsActive: !tt.inactive,
+				Roles:    tt.roles,
+			}}
+			s := NewPersonalAccessTokenService(tokens, users, logger.New())
+
+			principal, err := s.Verify(context.Background(), tt.secret)
+
+			if tt.repoErr != nil {
+				if err == nil || errors.Is(err, pat.ErrInvalidToken) {
+					t.Fatalf("Verify() error = %v, want the repository's error", err)
+				}
+				return
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+				}
+				if len(tokens.touched) != 0 {
+					t.Error("rejected token marked as used")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+
+			if principal.TokenID != "token-1" || principal.UserID != "user-1" || principal.Email != "user@example.com" {
+				t.Errorf("principal = %+v", principal)
+			}
+			if !reflect.DeepEqual(principal.Scopes, tt.wantScopes) {
+				t.Errorf("scopes = %v, want %v", principal.Scopes, tt.wantScopes)
+			}
+			if !reflect.DeepEqual(tokens.touched, []string{"token-1"}) {
+				t.Errorf("tokens marked used = %v, want [token-1]", tokens.touched)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrCurrentSessionUnknown is returned when the caller's access token
+	// predates session tracking and carries no sid claim
+	ErrCurrentSessionUnknown = errors.New("current session unknown")
+)
+
+// SessionService lets users see and end their signed-in sessions. A session
+// is a refresh token family; ending one revokes its refresh token, and its
+// access tokens through a cutoff on their sid claim, so the device is signed
+// out straight away.
+type SessionService interface {
+	List(ctx context.Context, userID, currentSessionID string) ([]*models.Session, error)
+	Revoke(ctx context.Context, userID, sessionID string) error
+	RevokeOthers(ctx context.Context, userID, currentSessionID string) (int64, error)
+}
+
+type sessionService struct {
+	userRepo    repository.UserRepository
+	revocations revocation.Store
+	log         logger.Logger
+}
+
+func NewSessionService(userRepo repository.UserRepository, revocations revocation.Store, log logger.Logger) SessionService {
+	return &sessionService{
+		userRepo:    userRepo,
+		revocations: revocations,
+		log:         log,
+	}
+}
+
+func (s *sessionService) List(ctx context.Context, userID, currentSessionID string) ([]*models.Session, error) {
+	sessions, err := s.userRepo.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		session.Current = currentSessionID != "" && session.ID == currentSessionID
+	}
+
+	return sessions, nil
+}
+
+func (s *sessionService) Revoke(ctx context.Context, userID, sessionID string) error {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation":  "revoke_session",
+		"user_id":    userID,
+		"session_id": sessionID,
+	})
+
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return ErrSessionNotFound
+	}
+
+	if err := s.userRepo.RevokeRefreshTokenFamily(ctx, userID, sessionID); err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	revokeSessionAccessTokens(ctx, s.revocations, sessionID, log)
+
+	log.Info("Session revoked")
+	return nil
+}
+
+func (s *sessionService) RevokeOthers(ctx context.Context, userID, currentSessionID string) (int64, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation":  "revoke_other_sessions",
+		"user_id":    userID,
+		"session_id": currentSessionID,
+	})
+
+	if currentSessionID == "" {
+		return 0, ErrCurrentSessionUnknown
+	}
+
+	revoked, err := s.userRepo.RevokeOtherRefreshTokens(ctx, userID, currentSessionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke other sessions: %w", err)
+	}
+
+	for _, sessionID := range revoked {
+		revokeSessionAccessTokens(ctx, s.revocations, sessionID, log)
+	}
+
+	log.WithField("revoked", len(revoked)).Info("Other sessions revoked")
+	return int64(len(revoked)), nil
+}
+
+// revokeSessionAccessTokens cuts off the access tokens already issued to a
+// session whose refresh tokens have been revoked. The session is ended
+// either way, so a failure is only logged.
+func revokeSessionAccessTokens(ctx context.Context, revocations revocation.Store, sessionID string, log logger.Logger) {
+	if err := revocations.RevokeSession(ctx, sessionID, time.Now(), AccessTokenTTL); err != nil {
+		log.WithError(err).WithField("session_id", sessionID).Warn("Failed to revoke session access tokens")
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+type fakeSessionUserRepo struct {
+	repository.UserRepository
+	families []string
+}
+
+func (f *fakeSessionUserRepo) RevokeRefreshTokenFamily(ctx context.Context, userID, familyID string) error {
+	for _, id := range f.families {
+		if id == familyID {
+			return nil
+		}
+	}
+	return repository.ErrSessionNotFound
+}
+
+func (f *fakeSessionUserRepo) RevokeOtherRefreshTokens(ctx context.Context, userID, keepFamilyID string) ([]string, error) {
+	var revoked []string
+	for _, id := range f.families {
+		if id != keepFamilyID {
+			revoked = append(revoked, id)
+		}
+	}
+	return revoked, nil
+}
+
+const (
+	sessionA = "6f1c7c1e-2a43-4d8b-9a57-6f0e5d1f1a01"
+	sessionB = "6f1c7c1e-2a43-4d8b-9a57-6f0e5d1f1a02"
+	sessionC = "6f1c7c1e-2a43-4d8b-9a57-6f0e5d1f1a03"
+)
+
+func TestSessionRevokeCutsOffAccessTokens(t *testing.T) {
+	repo := &fakeSessionUserRepo{families: []string{sessionA, sessionB}}
+	revocations := &fakeRevocations{}
+	s := NewSessionService(repo, revocations, logger.New())
+
+	if err := s.Revoke(context.Background(), "u1", sessionB); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if want := []string{sessionB}; !reflect.DeepEqual(revocations.sessions, want) {
+		t.Errorf("revoked sessions = %v, want %v", revocations.sessions, want)
+	}
+
+	if err := s.Revoke(context.Background(), "u1", sessionC); err != ErrSessionNotFound {
+		t.Errorf("Revoke() unknown session error = %v, want %v", err, ErrSessionNotFound)
+	}
+	if len(revocations.sessions) != 1 {
+		t.Errorf("unknown session was cut off: %v", revocations.sessions)
+	}
+}
+
+func TestSessionRevokeOthersCutsOffAccessTokens(t *testing.T) {
+	repo := &fakeSessionUserRepo{families: []string{sessionA, sessionB, sessionC}}
+	revocations := &fakeRevocations{}
+	s := NewSessionService(repo, revocations, logger.New())
+
+	revoked, err := s.RevokeOthers(context.Background(), "u1", sessionA)
+	if err != nil {
+		t.Fatalf("RevokeOthers() error = %v", err)
+	}
+	if revoked != 2 {
+		t.Errorf("RevokeOthers() = %d, want 2", revoked)
+	}
+	if want := []string{sessionB, sessionC}; !reflect.DeepEqual(revocations.sessions, want) {
+		t.Errorf("revoked sessions = %v, want %v", revocations.sessions, want)
+	}
+}
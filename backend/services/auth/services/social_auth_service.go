@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/oidc"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+var (
+	ErrUnknownProvider     = errors.New("unknown identity provider")
+	ErrProviderEmailUnset  = errors.New("identity provider did not return a verified email")
+	ErrIdentityLinkRefused = errors.New("an account with this email exists but its email is not verified")
+)
+
+type SocialAuthService interface {
+	SignIn(ctx context.Context, provider string, req models.OAuthSignInRequest) (*models.AuthResponse, error)
+}
+
+type socialAuthService struct {
+	verifiers    map[string]*oidc.Verifier
+	userRepo     repository.UserRepository
+	identityRepo repository.IdentityRepository
+	mfaService   MFAService
+	tokenIssuer  TokenIssuer
+	events       AuthEventRecorder
+	logins       LoginRecorder
+	log          logger.Logger
+}
+
+func NewSocialAuthService(verifiers map[string]*oidc.Verifier, userRepo repository.UserRepository, identityRepo repository.IdentityRepository, mfaService MFAService, tokenIssuer TokenIssuer, events AuthEventRecorder, logins LoginRecorder, log logger.Logger) SocialAuthService {
+	return &socialAuthService{
+		verifiers:    verifiers,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		mfaService:   mfaService,
+		tokenIssuer:  tokenIssuer,
+		events:       events,
+		logins:       logins,
+		log:          log,
+	}
+}
+
+// SignIn verifies the provider's ID token and signs in the linked user. A
+// first sign-in links the identity to the account with the same email, if
+// both the provider and the account have verified it, or creates a new
+// account if there is none.
+func (s *socialAuthService) SignIn(ctx context.Context, provider string, req models.OAuthSignInRequest) (*models.AuthResponse, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "social_signin",
+		"provider":  provider,
+	})
+
+	verifier, ok := s.verifiers[provider]
+	if !ok {
+		log.Debug("Sign-in attempted with unconfigured provider")
+		return nil, ErrUnknownProvider
+	}
+
+	claims, err := verifier.Verify(ctx, req.IDToken, req.Nonce)
+	if err != nil {
+		log.WithError(err).Warn("ID token verification failed")
+		return nil, err
+	}
+
+	user, err := s.resolveUser(ctx, provider, claims, req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsActive {
+		log.WithField("user_id", user.ID).Warn("Inactive user attempted to sign in")
+		s.events.Record(ctx, &models.AuthEvent{Type: AuthEventSignIn, Outcome: AuthOutcomeFailure, UserID: user.ID, Email: user.Email, Method: provider, Reason: "account_inactive"})
+		return nil, errors.New("account is inactive")
+	}
+
+	// The provider only replaces the password, not the second factor
+	if err := s.mfaService.Challenge(ctx, user); err != nil {
+		return nil, err
+	}
+
+	response, err := s.tokenIssuer.IssueTokens(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logins.RecordLogin(ctx, user, provider)
+
+	s.events.Record(ctx, &models.AuthEvent{Type: AuthEventSignIn, Outcome: AuthOutcomeSuccess, UserID: user.ID, Email: user.Email, Method: provider})
+	log.WithField("user_id", user.ID).Info("User successfully signed in with identity provider")
+	return response, nil
+}
+
+// resolveUser finds the user linked to the provider identity, linking or
+// creating one on first sign-in
+func (s *socialAuthService) resolveUser(ctx context.Context, provider string, claims *oidc.Claims, fallbackName string) (*models.User, error) {
+	log := s.log.WithContext(ctx).WithField("provider", provider)
+
+	identity, err := s.identityRepo.GetByProviderSubject(ctx, provider, claims.Subject)
+	if err == nil {
+		user, err := s.userRepo.GetByID(ctx, identity.UserID)
+		if err != nil {
+			log.WithError(err).Error("Failed to load user for linked identity")
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		if err := s.identityRepo.TouchLastUsed(ctx, identity.ID); err != nil {
+			log.WithError(err).Warn("Failed to update identity last used")
+		}
+		return user, nil
+	}
+
+	// Linking by email is only safe when the provider vouches for it
+	if claims.Email == "" || !claims.EmailVerified {
+		log.Warn("Identity provider returned no verified email for new identity")
+		return nil, ErrProviderEmailUnset
+	}
+	email := models.NormalizeEmail(claims.Email)
+
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err == nil {
+		// Anyone can sign up with an address they don't own. Linking to such
+		// an account would hand the address's owner an account whose
+		// password someone else may know, so its owner has to verify the
+		// address first.
+		if !user.EmailVerified {
+			log.WithField("user_id", user.ID).Warn("Refused to link identity to user with unverified email")
+			return nil, ErrIdentityLinkRefused
+		}
+		log.WithField("user_id", user.ID).Info("Linking identity to existing user")
+	} else {
+		user, err = s.createUser(ctx, email, claims, fallbackName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	identity = &models.UserIdentity{
+		UserID:     user.ID,
+		Provider:   provider,
+		Subject:    claims.Subject,
+		Email:      &email,
+		LastUsedAt: &now,
+	}
+	if err := s.identityRepo.Create(ctx, identity); err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	return user, nil
+}
+
+func (s *socialAuthService) createUser(ctx context.Context, email string, claims *oidc.Claims, fallbackName string) (*models.User, error) {
+	name := claims.Name
+	if name == "" {
+		name = fallbackName
+	}
+	if name == "" {
+		name = strings.Split(email, "@")[0]
+	}
+
+	var photoURL *string
+	if claims.Picture != "" {
+		photoURL = &claims.Picture
+	}
+
+	// Social-only accounts have no password; password sign-in fails until
+	// the user sets one through the reset flow
+	user := &models.User{
+		Email:         email,
+		Name:          name,
+		PhotoURL:      photoURL,
+		IsActive:      true,
+		EmailVerified: true,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+
+	createdUser, err := s.userRepo.Create(ctx, user)
+	if err != nil {
+		s.log.WithContext(ctx).WithError(err).Error("Failed to create user")
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return createdUser, nil
+}
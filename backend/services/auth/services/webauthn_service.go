@@ -0,0 +1,319 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/webauthn"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+const (
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+)
+
+var ErrWebAuthnChallenge = errors.New("webauthn challenge invalid or expired")
+
+type WebAuthnService interface {
+	BeginRegistration(ctx context.Context, userID string) (*webauthn.CreationOptions, error)
+	FinishRegistration(ctx context.Context, userID string, req models.WebAuthnRegisterRequest) (*models.WebAuthnCredential, error)
+	BeginLogin(ctx context.Context, email string) (*webauthn.RequestOptions, error)
+	FinishLogin(ctx context.Context, req models.WebAuthnLoginRequest) (*models.AuthResponse, error)
+	ListCredentials(ctx context.Context, userID string) ([]*models.WebAuthnCredential, error)
+	DeleteCredential(ctx context.Context, userID, credentialID string) error
+}
+
+type webAuthnService struct {
+	rp           *webauthn.RelyingParty
+	webAuthnRepo repository.WebAuthnRepository
+	userRepo     repository.UserRepository
+	tokenIssuer  TokenIssuer
+	emailService EmailService
+	events       AuthEventRecorder
+	logins       LoginRecorder
+	decoySecret  []byte
+	challengeTTL time.Duration
+	log          logger.Logger
+}
+
+// NewWebAuthnService creates the passkey service. decoySecret keys the
+// made-up credentials offered for emails without passkeys; it must stay the
+// same across restarts and instances.
+func NewWebAuthnService(rp *webauthn.RelyingParty, webAuthnRepo repository.WebAuthnRepository, userRepo repository.UserRepository, tokenIssuer TokenIssuer, emailService EmailService, events AuthEventRecorder, logins LoginRecorder, decoySecret []byte, challengeTTL time.Duration, log logger.Logger) WebAuthnService {
+	return &webAuthnService{
+		rp:           rp,
+		webAuthnRepo: webAuthnRepo,
+		userRepo:     userRepo,
+		tokenIssuer:  tokenIssuer,
+		emailService: emailService,
+		events:       events,
+		logins:       logins,
+		decoySecret:  decoySecret,
+		challengeTTL: challengeTTL,
+		log:          log,
+	}
+}
+
+func (s *webAuthnService) BeginRegistration(ctx context.Context, userID string) (*webauthn.CreationOptions, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "webauthn_begin_registration",
+		"user_id":   userID,
+	})
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.webAuthnRepo.GetCredentialsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	exclude := make([]webauthn.CredentialDescriptor, 0, len(existing))
+	for _, cred := range existing {
+		id, err := base64.RawURLEncoding.DecodeString(cred.CredentialID)
+		if err != nil {
+			continue
+		}
+		exclude = append(exclude, webauthn.NewCredentialDescriptor(id, cred.Transports))
+	}
+
+	challenge, err := s.newChallenge(ctx, &userID, ceremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("WebAuthn registration started")
+
+	return s.rp.CreationOptions(challenge, webauthn.User{
+		ID:          []byte(user.ID),
+		Name:        user.Email,
+		DisplayName: user.Name,
+	}, exclude), nil
+}
+
+func (s *webAuthnService) FinishRegistration(ctx context.Context, userID string, req models.WebAuthnRegisterRequest) (*models.WebAuthnCredential, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "webauthn_finish_registration",
+		"user_id":   userID,
+	})
+
+	clientChallenge, err := req.Credential.Challenge()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := s.consumeChallenge(ctx, clientChallenge, ceremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if state.UserID == nil || *state.UserID != userID {
+		log.Warn("Registration challenge belongs to another user")
+		return nil, ErrWebAuthnChallenge
+	}
+
+	verified, err := s.rp.VerifyRegistration(&req.Credential, state.Challenge)
+	if err != nil {
+		log.WithError(err).Warn("WebAuthn registration verification failed")
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+
+	cred := &models.WebAuthnCredential{
+		UserID:         userID,
+		CredentialID:   base64.RawURLEncoding.EncodeToString(verified.ID),
+		PublicKey:      verified.PublicKey,
+		SignCount:      int64(verified.SignCount),
+		AAGUID:         verified.AAGUID,
+		Transports:     verified.Transports,
+		Name:           name,
+		BackupEligible: verified.BackupEligible,
+		BackupState:    verified.BackupState,
+	}
+	if err := s.webAuthnRepo.CreateCredential(ctx, cred); err != nil {
+		return nil, err
+	}
+
+	if user, err := s.userRepo.GetByID(ctx, userID); err == nil {
+		alert := SecurityAlert{
+			Title:       "A passkey was added to your account",
+			Description: fmt.Sprintf("The passkey %q can now be used to sign in to your FlowTime account.", name),
+		}
+		if err := s.emailService.SendSecurityAlert(ctx, user, alert); err != nil {
+			log.WithError(err).Warn("Failed to send passkey alert")
+		}
+	}
+
+	log.WithField("credential_id", cred.ID).Info("WebAuthn credential registered")
+	return cred, nil
+}
+
+// BeginLogin starts an authentication ceremony. With an email the client is
+// told which credentials to use; without one any discoverable credential
+// for this RP may be offered. Emails of unknown accounts, and of accounts
+// without passkeys, get a decoy credential instead of an empty list, so the
+// endpoint can't be used to probe for either.
+func (s *webAuthnService) BeginLogin(ctx context.Context, email string) (*webauthn.RequestOptions, error) {
+	log := s.log.WithContext(ctx).WithField("operation", "webauthn_begin_login")
+
+	var allow []webauthn.CredentialDescriptor
+	if email != "" {
+		if user, err := s.userRepo.GetByEmail(ctx, email); err == nil {
+			creds, err := s.webAuthnRepo.GetCredentialsByUser(ctx, user.ID)
+			if err != nil {
+				return nil, err
+			}
+			for _, cred := range creds {
+				id, err := base64.RawURLEncoding.DecodeString(cred.CredentialID)
+				if err != nil {
+					continue
+				}
+				allow = append(allow, webauthn.NewCredentialDescriptor(id, cred.Transports))
+			}
+		}
+		if len(allow) == 0 {
+			allow = []webauthn.CredentialDescriptor{s.decoyCredential(email)}
+		}
+	}
+
+	challenge, err := s.newChallenge(ctx, nil, ceremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("WebAuthn login started")
+	return s.rp.RequestOptions(challenge, allow), nil
+}
+
+// decoyCredential makes up a credential for an email with no passkeys. It is
+// derived from the email, so asking twice gives the same answer, as it would
+// for a real passkey, and no authenticator holds it.
+func (s *webAuthnService) decoyCredential(email string) webauthn.CredentialDescriptor {
+	mac := hmac.New(sha256.New, s.decoySecret)
+	mac.Write([]byte("webauthn-decoy:" + models.NormalizeEmail(email)))
+	return webauthn.NewCredentialDescriptor(mac.Sum(nil), []string{"hybrid", "internal"})
+}
+
+// FinishLogin verifies an assertion and signs the credential's owner in.
+// A verified passkey replaces both the password and the second factor.
+func (s *webAuthnService) FinishLogin(ctx context.Context, req models.WebAuthnLoginRequest) (*models.AuthResponse, error) {
+	log := s.log.WithContext(ctx).WithField("operation", "webauthn_finish_login")
+
+	clientChallenge, err := req.Credential.Challenge()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := s.consumeChallenge(ctx, clientChallenge, ceremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	rawID, err := req.Credential.CredentialID()
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid credential id", webauthn.ErrVerification)
+	}
+
+	cred, err := s.webAuthnRepo.GetCredentialByCredentialID(ctx, base64.RawURLEncoding.EncodeToString(rawID))
+	if err != nil {
+		log.Debug("Assertion for unknown credential")
+		return nil, fmt.Errorf("%w: unknown credential", webauthn.ErrVerification)
+	}
+
+	assertion, err := s.rp.VerifyAssertion(&req.Credential, state.Challenge, cred.PublicKey, uint32(cred.SignCount))
+	if err != nil {
+		log.WithError(err).WithField("credential_id", cred.ID).Warn("WebAuthn assertion verification failed")
+		return nil, err
+	}
+
+	if assertion.UserHandle != nil && string(assertion.UserHandle) != cred.UserID {
+		log.WithField("credential_id", cred.ID).Warn("Assertion user handle does not match credential owner")
+		return nil, fmt.Errorf("%w: user handle mismatch", webauthn.ErrVerification)
+	}
+
+	updated, err := s.webAuthnRepo.UpdateSignCount(ctx, cred.ID, int64(assertion.SignCount), assertion.BackupState)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		log.WithField("credential_id", cred.ID).Warn("Concurrent assertion advanced the signature counter")
+		return nil, fmt.Errorf("%w: signature counter did not increase", webauthn.ErrVerification)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, cred.UserID)
+	if err != nil {
+		log.WithError(err).Error("Failed to get user")
+		return nil, errors.New("user not found")
+	}
+
+	if !user.IsActive {
+		log.WithField("user_id", user.ID).Warn("Inactive user attempted to sign in")
+		s.events.Record(ctx, &models.AuthEvent{Type: AuthEventSignIn, Outcome: AuthOutcomeFailure, UserID: user.ID, Email: user.Email, Method: AuthMethodPasskey, Reason: "account_inactive"})
+		return nil, errors.New("account is inactive")
+	}
+
+	response, err := s.tokenIssuer.IssueTokens(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logins.RecordLogin(ctx, user, AuthMethodPasskey)
+
+	s.events.Record(ctx, &models.AuthEvent{Type: AuthEventSignIn, Outcome: AuthOutcomeSuccess, UserID: user.ID, Email: user.Email, Method: AuthMethodPasskey})
+	log.WithField("user_id", user.ID).Info("User successfully signed in with passkey")
+	return response, nil
+}
+
+func (s *webAuthnService) ListCredentials(ctx context.Context, userID string) ([]*models.WebAuthnCredential, error) {
+	return s.webAuthnRepo.GetCredentialsByUser(ctx, userID)
+}
+
+func (s *webAuthnService) DeleteCredential(ctx context.Context, userID, credentialID string) error {
+	return s.webAuthnRepo.DeleteCredential(ctx, userID, credentialID)
+}
+
+func (s *webAuthnService) newChallenge(ctx context.Context, userID *string, ceremony string) (string, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate challenge: %w", err)
+	}
+
+	err = s.webAuthnRepo.StoreChallenge(ctx, &models.WebAuthnChallenge{
+		UserID:    userID,
+		Challenge: challenge,
+		Ceremony:  ceremony,
+		ExpiresAt: time.Now().Add(s.challengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return challenge, nil
+}
+
+// consumeChallenge looks up the ceremony state for the challenge the client
+// signed. Challenges are deleted on first use and expire after the TTL, so
+// a captured response can't be replayed.
+func (s *webAuthnService) consumeChallenge(ctx context.Context, challenge, ceremony string) (*models.WebAuthnChallenge, error) {
+	state, err := s.webAuthnRepo.ConsumeChallenge(ctx, challenge, ceremony)
+	if err != nil {
+		s.log.WithContext(ctx).WithField("ceremony", ceremony).Debug("Unknown or expired webauthn challenge")
+		return nil, ErrWebAuthnChallenge
+	}
+
+	return state, nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/webauthn"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/models"
+	"github.com/mdnaeem95/lifesync/backend/services/auth/repository"
+)
+
+type fakeWebAuthnRepo struct {
+	repository.WebAuthnRepository
+	credentials map[string][]*models.WebAuthnCredential
+}
+
+func (f *fakeWebAuthnRepo) GetCredentialsByUser(ctx context.Context, userID string) ([]*models.WebAuthnCredential, error) {
+	return f.credentials[userID], nil
+}
+
+func (f *fakeWebAuthnRepo) StoreChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error {
+	return nil
+}
+
+type fakeEmailUserRepo struct {
+	repository.UserRepository
+	users map[string]*models.User
+}
+
+func (f *fakeEmailUserRepo) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	if user, ok := f.users[models.NormalizeEmail(email)]; ok {
+		return user, nil
+	}
+	return nil, errors.New("user not found")
+}
+
+func TestBeginLoginDoesNotRevealAccounts(t *testing.T) {
+	passkeyID := base64.RawURLEncoding.EncodeToString([]byte("registered-passkey-credential-id"))
+	users := &fakeEmailUserRepo{users: map[string]*models.User{
+		"passkey@example.com":   {ID: "u1", Email: "passkey@example.com"},
+		"nopasskey@example.com": {ID: "u2", Email: "nopasskey@example.com"},
+	}}
+	creds := &fakeWebAuthnRepo{credentials: map[string][]*models.WebAuthnCredential{
+		"u1": {{CredentialID: passkeyID, Transports: []string{"internal"}}},
+	}}
+	rp := webauthn.New(webauthn.Config{RPID: "localhost", RPName: "FlowTime"})
+	s := NewWebAuthnService(rp, creds, users, nil, nil, nil, nil, []byte("decoy-secret"), 0, logger.New())
+
+	allowed := func(email string) []webauthn.CredentialDescriptor {
+		t.Helper()
+		options, err := s.BeginLogin(context.Background(), email)
+		if err != nil {
+			t.Fatalf("BeginLogin(%q) error = %v", email, err)
+		}
+		return options.AllowCredentials
+	}
+
+	if got := allowed("passkey@example.com"); len(got) != 1 || got[0].ID != passkeyID {
+		t.Errorf("account with a passkey offered %v, want its passkey", got)
+	}
+
+	if got := allowed(""); len(got) != 0 {
+		t.Errorf("discoverable login offered %v, want none", got)
+	}
+
+	for _, email := range []string{"nopasskey@example.com", "nobody@example.com"} {
+		first := allowed(email)
+		if len(first) != 1 || first[0].ID == passkeyID {
+			t.Fatalf("%s offered %v, want one decoy", email, first)
+		}
+		if again := allowed(email); again[0].ID != first[0].ID {
+			t.Errorf("%s decoy changed between requests", email)
+		}
+		if id, _ := base64.RawURLEncoding.DecodeString(first[0].ID); len(id) != 32 {
+			t.Errorf("%s decoy ID is %d bytes", email, len(id))
+		}
+	}
+
+	if allowed("nobody@example.com")[0].ID == allowed("other@example.com")[0].ID {
+		t.Error("different emails share a decoy")
+	}
+	if allowed("Nobody@Example.com")[0].ID != allowed("nobody@example.com")[0].ID {
+		t.Error("decoy depends on the email's case")
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/userdata"
+)
+
+// InternalHandler serves the user data endpoints the auth service calls
+// for data export, account deletion and organization deletion
+type InternalHandler struct {
+	userData userdata.Source
+	orgData  userdata.OrgSource
+	log      logger.Logger
+}
+
+func NewInternalHandler(userData userdata.Source, orgData userdata.OrgSource, log logger.Logger) *InternalHandler {
+	return &InternalHandler{
+		userData: userData,
+		orgData:  orgData,
+		log:      log,
+	}
+}
+
+// ExportUserData returns the user's data as files for the export archive
+func (h *InternalHandler) ExportUserData(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	userID := c.Param("id")
+	if _, err := uuid.Parse(userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	files, err := h.userData.Export(ctx, userID)
+	if err != nil {
+		log.WithError(err).WithField("user_id", userID).Error("Failed to export user data")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export user data"})
+		return
+	}
+
+	c.JSON(http.StatusOK, userdata.ExportResponse{Files: files})
+}
+
+// DeleteUserData erases everything stored for the user
+func (h *InternalHandler) DeleteUserData(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	userID := c.Param("id")
+	if _, err := uuid.Parse(userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.userData.Delete(ctx, userID); err != nil {
+		log.WithError(err).WithField("user_id", userID).Error("Failed to delete user data")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user data"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteOrganizationData erases everything shared in the organization
+func (h *InternalHandler) DeleteOrganizationData(c *gin.Context) {
+	ctx := c.Request.Context()
+	log := h.log.WithContext(ctx)
+
+	orgID := c.Param("id")
+	if _, err := uuid.Parse(orgID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	if err := h.orgData.DeleteOrganization(ctx, orgID); err != nil {
+		log.WithError(err).WithField("org_id", orgID).Error("Failed to delete organization data")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization data"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
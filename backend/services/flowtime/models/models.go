@@ -4,10 +4,12 @@ import (
 	"time"
 )
 
-// Task represents a user's task
+// Task represents a user's task. OrgID is set on tasks shared with an
+// organization, and UserID is then the member who created it.
 type Task struct {
 	ID             string     `json:"id" db:"id"`
 	UserID         string     `json:"user_id" db:"user_id"`
+	OrgID          *string    `json:"org_id,omitempty" db:"org_id"`
 	Title          string     `json:"title" db:"title"`
 	Description    *string    `json:"description,omitempty" db:"description"`
 	Duration       int        `json:"duration" db:"duration"` // in minutes
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/mdnaeem95/lifesync/backend/pkg/authz"
 	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
 	"github.com/mdnaeem95/lifesync/backend/services/flowtime/models"
 )
@@ -15,13 +16,24 @@ type TaskRepository interface {
 	Create(ctx context.Context, task *models.Task) (*models.Task, error)
 	GetByID(ctx context.Context, taskID, userID string) (*models.Task, error)
 	GetByUser(ctx context.Context, userID string, includeCompleted bool) ([]*models.Task, error)
+	// GetAllCreatedBy returns every task the user created, personal or
+	// shared in any organization, whatever the request is scoped to. It is
+	// for data exports.
+	GetAllCreatedBy(ctx context.Context, userID string) ([]*models.Task, error)
 	GetByDateRange(ctx context.Context, userID string, start, end time.Time) ([]*models.Task, error)
-	Update(ctx context.Context, task *models.Task) error
+	// Update, Delete and MarkComplete only change tasks created by userID,
+	// even inside an organization
+	Update(ctx context.Context, task *models.Task, userID string) error
 	Delete(ctx context.Context, taskID, userID string) error
 	MarkComplete(ctx context.Context, taskID, userID string) error
 	GetUpcoming(ctx context.Context, userID string, limit int) ([]*models.Task, error)
 }
 
+// taskRepository scopes every query to the organization in the request
+// context (see authz.WithOrg): inside an organization it works on the tasks
+// shared with its members, and outside one on the user's personal tasks.
+// Members can see every task shared in an organization but only change the
+// ones they created.
 type taskRepository struct {
 	db  *sql.DB
 	log logger.Logger
@@ -40,19 +52,22 @@ func (r *taskRepository) Create(ctx context.Context, task *models.Task) (*models
 	task.ID = uuid.New().String()
 	task.CreatedAt = time.Now()
 	task.UpdatedAt = time.Now()
+	if orgID := authz.OrgFromContext(ctx); orgID != "" {
+		task.OrgID = &orgID
+	}
 
 	query := `
 		INSERT INTO tasks (
-			id, user_id, title, description, duration, scheduled_at,
+			id, user_id, org_id, title, description, duration, scheduled_at,
 			task_type, energy_required, priority, is_flexible,
 			created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 		RETURNING id, created_at, updated_at
 	`
 
 	err := r.db.QueryRowContext(
 		ctx, query,
-		task.ID, task.UserID, task.Title, task.Description,
+		task.ID, task.UserID, task.OrgID, task.Title, task.Description,
 		task.Duration, task.ScheduledAt, task.TaskType,
 		task.EnergyRequired, task.Priority, task.IsFlexible,
 		task.CreatedAt, task.UpdatedAt,
@@ -74,18 +89,20 @@ func (r *taskRepository) GetByID(ctx context.Context, taskID, userID string) (*m
 		"user_id":   userID,
 	})
 
+	owner, ownerArg := ownerFilter(ctx, userID, 2)
+
 	var task models.Task
 	query := `
 		SELECT 
-			id, user_id, title, description, duration, scheduled_at,
+			id, user_id, org_id, title, description, duration, scheduled_at,
 			completed_at, task_type, energy_required, priority, is_flexible,
 			created_at, updated_at
 		FROM tasks
-		WHERE id = $1 AND user_id = $2
+		WHERE id = $1 AND ` + owner + `
 	`
 
-	err := r.db.QueryRowContext(ctx, query, taskID, userID).Scan(
-		&task.ID, &task.UserID, &task.Title, &task.Description,
+	err := r.db.QueryRowContext(ctx, query, taskID, ownerArg).Scan(
+		&task.ID, &task.UserID, &task.OrgID, &task.Title, &task.Description,
 		&task.Duration, &task.ScheduledAt, &task.CompletedAt,
 		&task.TaskType, &task.EnergyRequired, &task.Priority,
 		&task.IsFlexible, &task.CreatedAt, &task.UpdatedAt,
@@ -110,14 +127,14 @@ func (r *taskRepository) GetByUser(ctx context.Context, userID string, includeCo
 		"include_completed": includeCompleted,
 	})
 
+	owner, ownerArg := ownerFilter(ctx, userID, 1)
 	query := `
 		SELECT 
-			id, user_id, title, description, duration, scheduled_at,
+			id, user_id, org_id, title, description, duration, scheduled_at,
 			completed_at, task_type, energy_required, priority, is_flexible,
 			created_at, updated_at
 		FROM tasks
-		WHERE user_id = $1
-	`
+		WHERE ` + owner
 
 	if !includeCompleted {
 		query += " AND completed_at IS NULL"
@@ -125,7 +142,7 @@ func (r *taskRepository) GetByUser(ctx context.Context, userID string, includeCo
 
 	query += " ORDER BY COALESCE(scheduled_at, created_at) ASC"
 
-	rows, err := r.db.QueryContext(ctx, query, userID)
+	rows, err := r.db.QueryContext(ctx, query, ownerArg)
 	if err != nil {
 		log.WithError(err).Error("Failed to get tasks")
 		return nil, fmt.Errorf("failed to get tasks: %w", err)
@@ -136,7 +153,7 @@ func (r *taskRepository) GetByUser(ctx context.Context, userID string, includeCo
 	for rows.Next() {
 		var task models.Task
 		if err := rows.Scan(
-			&task.ID, &task.UserID, &task.Title, &task.Description,
+			&task.ID, &task.UserID, &task.OrgID, &task.Title, &task.Description,
 			&task.Duration, &task.ScheduledAt, &task.CompletedAt,
 			&task.TaskType, &task.EnergyRequired, &task.Priority,
 			&task.IsFlexible, &task.CreatedAt, &task.UpdatedAt,
@@ -151,6 +168,47 @@ func (r *taskRepository) GetByUser(ctx context.Context, userID string, includeCo
 	return tasks, nil
 }
 
+func (r *taskRepository) GetAllCreatedBy(ctx context.Context, userID string) ([]*models.Task, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "get_tasks_created_by",
+		"user_id":   userID,
+	})
+
+	query := `
+		SELECT 
+			id, user_id, org_id, title, description, duration, scheduled_at,
+			completed_at, task_type, energy_required, priority, is_flexible,
+			created_at, updated_at
+		FROM tasks
+		WHERE user_id = $1
+		ORDER BY created_at ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to get tasks")
+		return nil, fmt.Errorf("failed to get tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []*models.Task
+	for rows.Next() {
+		var task models.Task
+		if err := rows.Scan(
+			&task.ID, &task.UserID, &task.OrgID, &task.Title, &task.Description,
+			&task.Duration, &task.ScheduledAt, &task.CompletedAt,
+			&task.TaskType, &task.EnergyRequired, &task.Priority,
+			&task.IsFlexible, &task.CreatedAt, &task.UpdatedAt,
+		); err != nil {
+			log.WithError(err).Error("Failed to scan task")
+			return nil, fmt.Errorf("failed to scan task: %w", err)
+		}
+		tasks = append(tasks, &task)
+	}
+
+	return tasks, rows.Err()
+}
+
 func (r *taskRepository) GetByDateRange(ctx context.Context, userID string, start, end time.Time) ([]*models.Task, error) {
 	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
 		"operation": "get_tasks_by_date_range",
@@ -159,19 +217,20 @@ func (r *taskRepository) GetByDateRange(ctx context.Context, userID string, star
 		"end":       end,
 	})
 
+	owner, ownerArg := ownerFilter(ctx, userID, 1)
 	query := `
 		SELECT 
-			id, user_id, title, description, duration, scheduled_at,
+			id, user_id, org_id, title, description, duration, scheduled_at,
 			completed_at, task_type, energy_required, priority, is_flexible,
 			created_at, updated_at
 		FROM tasks
-		WHERE user_id = $1 
+		WHERE ` + owner + `
 		AND scheduled_at >= $2 
 		AND scheduled_at < $3
 		ORDER BY scheduled_at ASC
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, userID, start, end)
+	rows, err := r.db.QueryContext(ctx, query, ownerArg, start, end)
 	if err != nil {
 		log.WithError(err).Error("Failed to get tasks by date range")
 		return nil, fmt.Errorf("failed to get tasks: %w", err)
@@ -182,7 +241,7 @@ func (r *taskRepository) GetByDateRange(ctx context.Context, userID string, star
 	for rows.Next() {
 		var task models.Task
 		if err := rows.Scan(
-			&task.ID, &task.UserID, &task.Title, &task.Description,
+			&task.ID, &task.UserID, &task.OrgID, &task.Title, &task.Description,
 			&task.Duration, &task.ScheduledAt, &task.CompletedAt,
 			&task.TaskType, &task.EnergyRequired, &task.Priority,
 			&task.IsFlexible, &task.CreatedAt, &task.UpdatedAt,
@@ -196,30 +255,31 @@ func (r *taskRepository) GetByDateRange(ctx context.Context, userID string, star
 	return tasks, nil
 }
 
-func (r *taskRepository) Update(ctx context.Context, task *models.Task) error {
+func (r *taskRepository) Update(ctx context.Context, task *models.Task, userID string) error {
 	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
 		"operation": "update_task",
 		"task_id":   task.ID,
-		"user_id":   task.UserID,
+		"user_id":   userID,
 	})
 
 	task.UpdatedAt = time.Now()
+	owner, ownerArgs := writerFilter(ctx, userID, 11)
 
 	query := `
 		UPDATE tasks SET
 			title = $1, description = $2, duration = $3,
 			scheduled_at = $4, task_type = $5, energy_required = $6,
 			priority = $7, is_flexible = $8, updated_at = $9
-		WHERE id = $10 AND user_id = $11
-	`
+		WHERE id = $10 AND ` + owner
 
-	result, err := r.db.ExecContext(
-		ctx, query,
+	args := append([]interface{}{
 		task.Title, task.Description, task.Duration,
 		task.ScheduledAt, task.TaskType, task.EnergyRequired,
 		task.Priority, task.IsFlexible, task.UpdatedAt,
-		task.ID, task.UserID,
-	)
+		task.ID,
+	}, ownerArgs...)
+
+	result, err := r.db.ExecContext(ctx, query, args...)
 
 	if err != nil {
 		log.WithError(err).Error("Failed to update task")
@@ -242,9 +302,10 @@ func (r *taskRepository) Delete(ctx context.Context, taskID, userID string) erro
 		"user_id":   userID,
 	})
 
-	query := `DELETE FROM tasks WHERE id = $1 AND user_id = $2`
+	owner, ownerArgs := writerFilter(ctx, userID, 2)
+	query := `DELETE FROM tasks WHERE id = $1 AND ` + owner
 
-	result, err := r.db.ExecContext(ctx, query, taskID, userID)
+	result, err := r.db.ExecContext(ctx, query, append([]interface{}{taskID}, ownerArgs...)...)
 	if err != nil {
 		log.WithError(err).Error("Failed to delete task")
 		return fmt.Errorf("failed to delete task: %w", err)
@@ -266,14 +327,15 @@ func (r *taskRepository) MarkComplete(ctx context.Context, taskID, userID string
 		"user_id":   userID,
 	})
 
+	owner, ownerArgs := writerFilter(ctx, userID, 4)
 	query := `
 		UPDATE tasks 
 		SET completed_at = $1, updated_at = $2
-		WHERE id = $3 AND user_id = $4 AND completed_at IS NULL
+		WHERE id = $3 AND ` + owner + ` AND completed_at IS NULL
 	`
 
 	now := time.Now()
-	result, err := r.db.ExecContext(ctx, query, now, now, taskID, userID)
+	result, err := r.db.ExecContext(ctx, query, append([]interface{}{now, now, taskID}, ownerArgs...)...)
 	if err != nil {
 		log.WithError(err).Error("Failed to mark task complete")
 		return fmt.Errorf("failed to mark task complete: %w", err)
@@ -295,20 +357,21 @@ func (r *taskRepository) GetUpcoming(ctx context.Context, userID string, limit i
 		"limit":     limit,
 	})
 
+	owner, ownerArg := ownerFilter(ctx, userID, 1)
 	query := `
 		SELECT 
-			id, user_id, title, description, duration, scheduled_at,
+			id, user_id, org_id, title, description, duration, scheduled_at,
 			completed_at, task_type, energy_required, priority, is_flexible,
 			created_at, updated_at
 		FROM tasks
-		WHERE user_id = $1 
+		WHERE ` + owner + `
 		AND completed_at IS NULL
 		AND scheduled_at > $2
 		ORDER BY scheduled_at ASC
 		LIMIT $3
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, userID, time.Now(), limit)
+	rows, err := r.db.QueryContext(ctx, query, ownerArg, time.Now(), limit)
 	if err != nil {
 		log.WithError(err).Error("Failed to get upcoming tasks")
 		return nil, fmt.Errorf("failed to get upcoming tasks: %w", err)
@@ -319,7 +382,7 @@ func (r *taskRepository) GetUpcoming(ctx context.Context, userID string, limit i
 	for rows.Next() {
 		var task models.Task
 		if err := rows.Scan(
-			&task.ID, &task.UserID, &task.Title, &task.Description,
+			&task.ID, &task.UserID, &task.OrgID, &task.Title, &task.Description,
 			&task.Duration, &task.ScheduledAt, &task.CompletedAt,
 			&task.TaskType, &task.EnergyRequired, &task.Priority,
 			&task.IsFlexible, &task.CreatedAt, &task.UpdatedAt,
@@ -332,3 +395,24 @@ func (r *taskRepository) GetUpcoming(ctx context.Context, userID string, limit i
 
 	return tasks, nil
 }
+
+// ownerFilter returns the condition limiting a query to the tasks the
+// request can see, using placeholder $n, and the argument for it. Inside an
+// organization that is every task shared with it, whoever created it.
+func ownerFilter(ctx context.Context, userID string, n int) (string, interface{}) {
+	if orgID := authz.OrgFromContext(ctx); orgID != "" {
+		return fmt.Sprintf("org_id = $%d", n), orgID
+	}
+	return fmt.Sprintf("user_id = $%d AND org_id IS NULL", n), userID
+}
+
+// writerFilter returns the condition limiting a write to the tasks the
+// request may change, using placeholders from $n, and the arguments for
+// them. Inside an organization that is the tasks shared with it that the
+// user created; the organization roles only govern the organization itself.
+func writerFilter(ctx context.Context, userID string, n int) (string, []interface{}) {
+	if orgID := authz.OrgFromContext(ctx); orgID != "" {
+		return fmt.Sprintf("org_id = $%d AND user_id = $%d", n, n+1), []interface{}{orgID, userID}
+	}
+	return fmt.Sprintf("user_id = $%d AND org_id IS NULL", n), []interface{}{userID}
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/authz"
+)
+
+func TestOwnerFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		orgID    string
+		wantCond string
+		wantArg  interface{}
+	}{
+		{"personal", "", "user_id = $2 AND org_id IS NULL", "u1"},
+		// Every member sees every task shared in the organization
+		{"organization", "o1", "org_id = $2", "o1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.orgID != "" {
+				ctx = authz.WithOrg(ctx, tt.orgID)
+			}
+
+			cond, arg := ownerFilter(ctx, "u1", 2)
+			if cond != tt.wantCond || arg != tt.wantArg {
+				t.Errorf("ownerFilter() = %q, %v, want %q, %v", cond, arg, tt.wantCond, tt.wantArg)
+			}
+		})
+	}
+}
+
+func TestWriterFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		orgID    string
+		wantCond string
+		wantArgs []interface{}
+	}{
+		{"personal", "", "user_id = $4 AND org_id IS NULL", []interface{}{"u1"}},
+		// Members, whatever their role, only change the tasks they created
+		{"organization", "o1", "org_id = $4 AND user_id = $5", []interface{}{"o1", "u1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.orgID != "" {
+				ctx = authz.WithOrg(ctx, tt.orgID)
+			}
+
+			cond, args := writerFilter(ctx, "u1", 4)
+			if cond != tt.wantCond || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("writerFilter() = %q, %v, want %q, %v", cond, args, tt.wantCond, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+)
+
+// UserDataRepository removes everything the flowtime service stores for a
+// user or an organization
+type UserDataRepository interface {
+	DeleteAll(ctx context.Context, userID string) (int64, error)
+	DeleteOrganization(ctx context.Context, orgID string) (int64, error)
+}
+
+type userDataRepository struct {
+	db  *sql.DB
+	log logger.Logger
+}
+
+func NewUserDataRepository(db *sql.DB, log logger.Logger) UserDataRepository {
+	return &userDataRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// DeleteAll deletes the user's rows from every flowtime table in one
+// transaction, including the tasks they shared in organizations, and
+// returns how many were removed
+func (r *userDataRepository) DeleteAll(ctx context.Context, userID string) (int64, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "delete_user_data",
+		"user_id":   userID,
+	})
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.WithError(err).Error("Failed to begin transaction")
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Sessions reference tasks, so they go first
+	tables := []string{"focus_sessions", "tasks", "energy_levels", "energy_patterns", "user_preferences"}
+
+	var total int64
+	for _, table := range tables {
+		result, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = $1", userID)
+		if err != nil {
+			log.WithError(err).WithField("table", table).Error("Failed to delete user data")
+			return 0, fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			total += n
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.WithError(err).Error("Failed to commit user data deletion")
+		return 0, fmt.Errorf("failed to commit user data deletion: %w", err)
+	}
+
+	log.WithField("rows", total).Info("User data deleted")
+	return total, nil
+}
+
+// DeleteOrganization deletes the tasks shared in the organization. Focus
+// sessions on them are kept, detached from the task, as they belong to the
+// members who worked on them.
+func (r *userDataRepository) DeleteOrganization(ctx context.Context, orgID string) (int64, error) {
+	log := r.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "delete_organization_data",
+		"org_id":    orgID,
+	})
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM tasks WHERE org_id = $1`, orgID)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete organization tasks")
+		return 0, fmt.Errorf("failed to delete organization tasks: %w", err)
+	}
+
+	deleted, _ := result.RowsAffected()
+	log.WithField("rows", deleted).Info("Organization data deleted")
+	return deleted, nil
+}
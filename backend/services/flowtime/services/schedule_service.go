@@ -68,8 +68,10 @@ func (s *scheduleService) OptimizeSchedule(ctx context.Context, userID string, d
 	var flexibleTasks []*models.Task
 	var fixedTasks []*models.Task
 
+	// Inside an organization, tasks created by other members can't be
+	// moved and only take up time
 	for _, task := range tasks {
-		if task.IsFlexible && (task.ScheduledAt == nil || !respectCurrent) {
+		if task.UserID == userID && task.IsFlexible && (task.ScheduledAt == nil || !respectCurrent) {
 			flexibleTasks = append(flexibleTasks, task)
 		} else {
 			fixedTasks = append(fixedTasks, task)
@@ -110,7 +112,7 @@ func (s *scheduleService) OptimizeSchedule(ctx context.Context, userID string, d
 
 		// Update task with new schedule
 		task.ScheduledAt = &slot.StartTime
-		if err := s.taskRepo.Update(ctx, task); err != nil {
+		if err := s.taskRepo.Update(ctx, task, userID); err != nil {
 			log.WithError(err).Error("Failed to update task schedule")
 			continue
 		}
@@ -179,7 +181,7 @@ func (s *scheduleService) AutoReschedule(ctx context.Context, userID string) err
 	var overdueTasks []*models.Task
 
 	for _, task := range tasks {
-		if task.IsFlexible && task.ScheduledAt != nil && task.ScheduledAt.Before(now) {
+		if task.UserID == userID && task.IsFlexible && task.ScheduledAt != nil && task.ScheduledAt.Before(now) {
 			overdueTasks = append(overdueTasks, task)
 		}
 	}
@@ -195,7 +197,7 @@ func (s *scheduleService) AutoReschedule(ctx context.Context, userID string) err
 
 		// Use the first available slot
 		task.ScheduledAt = &slots[0].StartTime
-		if err := s.taskRepo.Update(ctx, task); err != nil {
+		if err := s.taskRepo.Update(ctx, task, userID); err != nil {
 			log.WithError(err).Error("Failed to reschedule task")
 			continue
 		}
@@ -153,7 +153,7 @@ func (s *taskService) UpdateTask(ctx context.Context, taskID, userID string, req
 		task.IsFlexible = *req.IsFlexible
 	}
 
-	if err := s.taskRepo.Update(ctx, task); err != nil {
+	if err := s.taskRepo.Update(ctx, task, userID); err != nil {
 		log.WithError(err).Error("Failed to update task")
 		return nil, fmt.Errorf("failed to update task: %w", err)
 	}
@@ -212,7 +212,7 @@ func (s *taskService) RescheduleTask(ctx context.Context, taskID, userID string,
 	// Update scheduled time
 	task.ScheduledAt = &newTime
 
-	if err := s.taskRepo.Update(ctx, task); err != nil {
+	if err := s.taskRepo.Update(ctx, task, userID); err != nil {
 		log.WithError(err).Error("Failed to reschedule task")
 		return fmt.Errorf("failed to reschedule task: %w", err)
 	}
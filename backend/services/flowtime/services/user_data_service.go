@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/userdata"
+	"github.com/mdnaeem95/lifesync/backend/services/flowtime/repository"
+)
+
+// UserDataService is the flowtime service's userdata.Source and
+// userdata.OrgSource. The auth service calls it through the internal API
+// when a user exports their data, their account is deleted or an
+// organization is deleted.
+type UserDataService struct {
+	taskRepo     repository.TaskRepository
+	energyRepo   repository.EnergyRepository
+	sessionRepo  repository.SessionRepository
+	prefRepo     repository.PreferencesRepository
+	userDataRepo repository.UserDataRepository
+	log          logger.Logger
+}
+
+var (
+	_ userdata.Source    = (*UserDataService)(nil)
+	_ userdata.OrgSource = (*UserDataService)(nil)
+)
+
+func NewUserDataService(taskRepo repository.TaskRepository, energyRepo repository.EnergyRepository, sessionRepo repository.SessionRepository, prefRepo repository.PreferencesRepository, userDataRepo repository.UserDataRepository, log logger.Logger) *UserDataService {
+	return &UserDataService{
+		taskRepo:     taskRepo,
+		energyRepo:   energyRepo,
+		sessionRepo:  sessionRepo,
+		prefRepo:     prefRepo,
+		userDataRepo: userDataRepo,
+		log:          log,
+	}
+}
+
+func (s *UserDataService) Name() string {
+	return "flowtime"
+}
+
+// Export returns every table as JSON and CSV
+func (s *UserDataService) Export(ctx context.Context, userID string) ([]userdata.File, error) {
+	log := s.log.WithContext(ctx).WithFields(map[string]interface{}{
+		"operation": "export_user_data",
+		"user_id":   userID,
+	})
+
+	// History queries take a range; cover all of it
+	start, end := time.Time{}, time.Now().AddDate(100, 0, 0)
+
+	// Tasks the user shared in organizations are theirs too
+	tasks, err := s.taskRepo.GetAllCreatedBy(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	energyLevels, err := s.energyRepo.GetHistory(ctx, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := s.sessionRepo.GetHistory(ctx, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	patterns, err := s.energyRepo.GetAllPatterns(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	prefs, err := s.prefRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []userdata.File
+	add := func(name string, v interface{}, header []string, rows [][]string) error {
+		jsonFile, err := userdata.JSONFile(name+".json", v)
+		if err != nil {
+			return err
+		}
+		csvFile, err := userdata.CSVFile(name+".csv", header, rows)
+		if err != nil {
+			return err
+		}
+		files = append(files, jsonFile, csvFile)
+		return nil
+	}
+
+	taskRows := make([][]string, 0, len(tasks))
+	for _, t := range tasks {
+		taskRows = append(taskRows, []string{
+			t.ID, stringOrEmpty(t.OrgID), t.Title, stringOrEmpty(t.Description), strconv.Itoa(t.Duration),
+			formatTime(t.ScheduledAt), formatTime(t.CompletedAt), t.TaskType,
+			strconv.Itoa(t.EnergyRequired), strconv.Itoa(t.Priority), strconv.FormatBool(t.IsFlexible),
+			formatTime(&t.CreatedAt), formatTime(&t.UpdatedAt),
+		})
+	}
+	if err := add("tasks", nonNil(tasks), []string{
+		"id", "org_id", "title", "description", "duration_minutes", "scheduled_at", "completed_at", "task_type",
+		"energy_required", "priority", "is_flexible", "created_at", "updated_at",
+	}, taskRows); err != nil {
+		return nil, err
+	}
+
+	energyRows := make([][]string, 0, len(energyLevels))
+	for _, e := range energyLevels {
+		factors := ""
+		if len(e.Factors) > 0 {
+			if encoded, err := json.Marshal(e.Factors); err == nil {
+				factors = string(encoded)
+			}
+		}
+		energyRows = append(energyRows, []string{
+			e.ID, strconv.Itoa(e.Level), formatTime(&e.RecordedAt), e.Source, factors, formatTime(&e.CreatedAt),
+		})
+	}
+	if err := add("energy_levels", nonNil(energyLevels), []string{
+		"id", "level", "recorded_at", "source", "factors", "created_at",
+	}, energyRows); err != nil {
+		return nil, err
+	}
+
+	sessionRows := make([][]string, 0, len(sessions))
+	for _, fs := range sessions {
+		sessionRows = append(sessionRows, []string{
+			fs.ID, stringOrEmpty(fs.TaskID), formatTime(&fs.StartedAt), formatTime(fs.EndedAt),
+			formatTime(fs.PausedAt), strconv.Itoa(fs.Duration), fs.SessionType, fs.Status,
+			formatTime(&fs.CreatedAt), formatTime(&fs.UpdatedAt),
+		})
+	}
+	if err := add("focus_sessions", nonNil(sessions), []string{
+		"id", "task_id", "started_at", "ended_at", "paused_at", "duration_seconds", "session_type", "status",
+		"created_at", "updated_at",
+	}, sessionRows); err != nil {
+		return nil, err
+	}
+
+	patternRows := make([][]string, 0, len(patterns))
+	for _, p := range patterns {
+		patternRows = append(patternRows, []string{
+			strconv.Itoa(p.DayOfWeek), strconv.Itoa(p.HourOfDay),
+			strconv.FormatFloat(p.AverageEnergy, 'f', 2, 64), strconv.Itoa(p.SampleCount),
+			formatTime(&p.UpdatedAt),
+		})
+	}
+	if err := add("energy_patterns", nonNil(patterns), []string{
+		"day_of_week", "hour_of_day", "average_energy", "sample_count", "updated_at",
+	}, patternRows); err != nil {
+		return nil, err
+	}
+
+	if err := add("preferences", prefs, []string{
+		"work_hours_start", "work_hours_end", "break_duration", "focus_protocol", "energy_update_freq",
+		"notifications_on", "smart_scheduling", "preferred_task_time", "updated_at",
+	}, [][]string{{
+		prefs.WorkHoursStart, prefs.WorkHoursEnd, strconv.Itoa(prefs.BreakDuration), prefs.FocusProtocol,
+		strconv.Itoa(prefs.EnergyUpdateFreq), strconv.FormatBool(prefs.NotificationsOn),
+		strconv.FormatBool(prefs.SmartScheduling), strconv.Itoa(prefs.PreferredTaskTime),
+		formatTime(&prefs.UpdatedAt),
+	}}); err != nil {
+		return nil, err
+	}
+
+	log.WithFields(map[string]interface{}{
+		"tasks":          len(tasks),
+		"energy_levels":  len(energyLevels),
+		"focus_sessions": len(sessions),
+	}).Info("User data exported")
+
+	return files, nil
+}
+
+func (s *UserDataService) Delete(ctx context.Context, userID string) error {
+	if _, err := s.userDataRepo.DeleteAll(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete user data: %w", err)
+	}
+	return nil
+}
+
+func (s *UserDataService) DeleteOrganization(ctx context.Context, orgID string) error {
+	if _, err := s.userDataRepo.DeleteOrganization(ctx, orgID); err != nil {
+		return fmt.Errorf("failed to delete organization data: %w", err)
+	}
+	return nil
+}
+
+// nonNil keeps empty tables as [] rather than null in the JSON export
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
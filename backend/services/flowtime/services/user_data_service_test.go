@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/flowtime/models"
+	"github.com/mdnaeem95/lifesync/backend/services/flowtime/repository"
+)
+
+// fakeTaskRepo holds tasks like the tasks table, applying the same scoping
+// as the real repository
+type fakeTaskRepo struct {
+	repository.TaskRepository
+	tasks []*models.Task
+}
+
+func (f *fakeTaskRepo) GetByUser(ctx context.Context, userID string, includeCompleted bool) ([]*models.Task, error) {
+	var tasks []*models.Task
+	for _, task := range f.tasks {
+		if task.UserID == userID && task.OrgID == nil {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+func (f *fakeTaskRepo) GetAllCreatedBy(ctx context.Context, userID string) ([]*models.Task, error) {
+	var tasks []*models.Task
+	for _, task := range f.tasks {
+		if task.UserID == userID {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+type fakeEnergyRepo struct {
+	repository.EnergyRepository
+}
+
+func (fakeEnergyRepo) GetHistory(ctx context.Context, userID string, start, end time.Time) ([]*models.EnergyLevel, error) {
+	return nil, nil
+}
+
+func (fakeEnergyRepo) GetAllPatterns(ctx context.Context, userID string) ([]*models.EnergyPattern, error) {
+	return nil, nil
+}
+
+type fakeSessionRepo struct {
+	repository.SessionRepository
+}
+
+func (fakeSessionRepo) GetHistory(ctx context.Context, userID string, start, end time.Time) ([]*models.FocusSession, error) {
+	return nil, nil
+}
+
+type fakePreferencesRepo struct {
+	repository.PreferencesRepository
+}
+
+func (fakePreferencesRepo) GetByUserID(ctx context.Context, userID string) (*models.UserPreferences, error) {
+	return &models.UserPreferences{UserID: userID}, nil
+}
+
+func TestUserDataExportIncludesOrganizationTasks(t *testing.T) {
+	orgID := "o1"
+	taskRepo := &fakeTaskRepo{tasks: []*models.Task{
+		{ID: "personal", UserID: "u1"},
+		{ID: "shared", UserID: "u1", OrgID: &orgID},
+		{ID: "teammate", UserID: "u2", OrgID: &orgID},
+	}}
+	s := NewUserDataService(taskRepo, fakeEnergyRepo{}, fakeSessionRepo{}, fakePreferencesRepo{}, nil, logger.New())
+
+	files, err := s.Export(context.Background(), "u1")
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	var tasks []models.Task
+	for _, file := range files {
+		if file.Name == "tasks.json" {
+			if err := json.Unmarshal(file.Data, &tasks); err != nil {
+				t.Fatalf("tasks.json does not decode: %v", err)
+			}
+		}
+	}
+
+	got := make(map[string]bool)
+	for _, task := range tasks {
+		got[task.ID] = true
+	}
+	if len(got) != 2 || !got["personal"] || !got["shared"] {
+		t.Errorf("exported tasks %v, want personal and shared", got)
+	}
+}
@@ -0,0 +1,461 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+)
+
+const (
+	defaultTTL     = time.Minute
+	defaultMaxSize = 10 << 20
+)
+
+// storedHeaders are the response headers kept with a cached response.
+// Everything else is about the request that fetched it.
+var storedHeaders = []string{
+	"Cache-Control",
+	"Content-Encoding",
+	"Content-Language",
+	"Content-Type",
+	"ETag",
+	"Last-Modified",
+	"Vary",
+	"X-Gateway-Response",
+	"X-Service-Name",
+}
+
+// ResponseCache caches GET responses of the routes that have caching
+// enabled, in memory and separately for every user, organization and set of
+// scopes, so a response is only ever served to requests authorized exactly
+// like the one that fetched it. A user's cached responses are dropped when
+// they make a mutating request to the route's path prefix or one of its
+// InvalidateOn prefixes; inside an organization, such a request drops every
+// member's responses cached in it.
+type ResponseCache struct {
+	routes map[string]*routeCache
+	log    logger.Logger
+}
+
+type routeCache struct {
+	prefixes []string // the route's path prefix, then its InvalidateOn prefixes
+	ttl      time.Duration
+	store    *lru
+}
+
+func NewResponseCache(services map[string]config.ServiceConfig, log logger.Logger) *ResponseCache {
+	rc := &ResponseCache{
+		routes: make(map[string]*routeCache),
+		log:    log,
+	}
+
+	for name, svc := range services {
+		for _, route := range svc.Routes {
+			if route.CacheConfig == nil || !route.CacheConfig.Enabled {
+				continue
+			}
+
+			ttl := route.CacheConfig.TTL
+			if ttl <= 0 {
+				ttl = defaultTTL
+			}
+			maxSize := route.CacheConfig.MaxSize
+			if maxSize <= 0 {
+				maxSize = defaultMaxSize
+			}
+
+			rc.routes[routeKey(name, &route)] = &routeCache{
+				prefixes: append([]string{route.PathPrefix}, route.CacheConfig.InvalidateOn...),
+				ttl:      ttl,
+				store:    newLRU(int64(maxSize)),
+			}
+		}
+	}
+
+	return rc
+}
+
+// Handle serves the request with next, from the route's cache when it can.
+// It must see every request, not only those to cached routes, so mutations
+// invalidate what they change.
+func (rc *ResponseCache) Handle(c *gin.Context, serviceName string, route *config.RouteConfig, next gin.HandlerFunc) {
+	method := c.Request.Method
+	if method != http.MethodGet && method != http.MethodHead {
+		next(c)
+		rc.invalidate(c)
+		return
+	}
+
+	cache, ok := rc.routes[routeKey(serviceName, route)]
+	if !ok || !c.GetBool("authenticated") {
+		next(c)
+		return
+	}
+
+	requestDirectives := parseCacheControl(c.GetHeader("Cache-Control"))
+	if _, noStore := requestDirectives["no-store"]; noStore {
+		next(c)
+		return
+	}
+
+	key := cacheKey(c)
+	now := time.Now()
+
+	if e := cache.store.get(key, now); e != nil && e.matches(c.Request.Header) && acceptable(requestDirectives, now.Sub(e.storedAt)) {
+		rc.log.WithFields(map[string]interface{}{
+			"path":       c.Request.URL.Path,
+			"request_id": c.GetString("request_id"),
+		}).Debug("Response served from cache")
+		serveEntry(c, e, now)
+		return
+	}
+
+	// A HEAD response has no body to cache
+	if method == http.MethodHead {
+		next(c)
+		return
+	}
+
+	// Fetch the whole response, so it can be cached, rather than a 304 in
+	// answer to the client's own conditional headers
+	ifNoneMatch := c.GetHeader("If-None-Match")
+	c.Request.Header.Del("If-None-Match")
+	c.Request.Header.Del("If-Modified-Since")
+	requestHeader := c.Request.Header.Clone()
+
+	original := c.Writer
+	writer := &bufferedWriter{ResponseWriter: original, limit: int(cache.store.maxBytes)}
+	c.Writer = writer
+	next(c)
+	c.Writer = original
+
+	if writer.passthrough || !writer.wroteHeader {
+		return
+	}
+
+	header := original.Header()
+	body := writer.body.Bytes()
+	if writer.status == http.StatusOK && header.Get("ETag") == "" {
+		header.Set("ETag", computeETag(body))
+	}
+
+	if ttl, ok := storable(writer.status, header, cache.ttl); ok {
+		cache.store.add(&entry{
+			key:      key,
+			scopes:   scopes(c),
+			vary:     varyValues(header, requestHeader),
+			status:   writer.status,
+			header:   storedHeader(header),
+			body:     bytes.Clone(body),
+			storedAt: now,
+			expires:  now.Add(ttl),
+			size:     int64(len(key) + len(body) + headerSize(header)),
+		}, now)
+	}
+
+	header.Set("X-Cache", "MISS")
+	if writer.status == http.StatusOK && etagMatches(ifNoneMatch, header.Get("ETag")) {
+		writeNotModified(original)
+		return
+	}
+	original.WriteHeader(writer.status)
+	original.Write(body)
+}
+
+// invalidate drops the cached responses the request may have changed
+func (rc *ResponseCache) invalidate(c *gin.Context) {
+	if !c.GetBool("authenticated") {
+		return
+	}
+
+	path := strings.TrimPrefix(c.Request.URL.Path, "/api/v1")
+	now := time.Now()
+	for _, cache := range rc.routes {
+		for _, prefix := range cache.prefixes {
+			if strings.HasPrefix(path, prefix) {
+				for _, name := range scopes(c) {
+					cache.store.invalidate(name, now)
+				}
+				break
+			}
+		}
+	}
+}
+
+func serveEntry(c *gin.Context, e *entry, now time.Time) {
+	header := c.Writer.Header()
+	for name, values := range e.header {
+		header[name] = values
+	}
+	header.Set("Age", strconv.Itoa(int(now.Sub(e.storedAt).Seconds())))
+	header.Set("X-Cache", "HIT")
+
+	if e.status == http.StatusOK && etagMatches(c.GetHeader("If-None-Match"), e.header.Get("ETag")) {
+		writeNotModified(c.Writer)
+		return
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(e.body)))
+	c.Writer.WriteHeader(e.status)
+	if c.Request.Method != http.MethodHead {
+		c.Writer.Write(e.body)
+	}
+}
+
+func writeNotModified(w http.ResponseWriter) {
+	header := w.Header()
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// acceptable reports whether a cached response of the given age satisfies
+// the request's Cache-Control
+func acceptable(directives map[string]string, age time.Duration) bool {
+	if _, noCache := directives["no-cache"]; noCache {
+		return false
+	}
+	if maxAge, ok := directives["max-age"]; ok {
+		seconds, err := strconv.Atoi(maxAge)
+		return err == nil && age <= time.Duration(seconds)*time.Second
+	}
+	return true
+}
+
+// storable reports whether a response may be cached and for how long: the
+// route's TTL, shortened by the response's own s-maxage or max-age
+func storable(status int, header http.Header, ttl time.Duration) (time.Duration, bool) {
+	if status != http.StatusOK || header.Get("Set-Cookie") != "" || header.Get("Vary") == "*" {
+		return 0, false
+	}
+
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	if _, ok := directives["no-store"]; ok {
+		return 0, false
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return 0, false
+	}
+
+	maxAge, ok := directives["s-maxage"]
+	if !ok {
+		maxAge, ok = directives["max-age"]
+	}
+	if ok {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil || seconds <= 0 {
+			return 0, false
+		}
+		ttl = min(ttl, time.Duration(seconds)*time.Second)
+	}
+
+	return ttl, true
+}
+
+// varyValues picks out the request's values of the headers the response
+// varies on
+func varyValues(header, requestHeader http.Header) http.Header {
+	var vary http.Header
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			if vary == nil {
+				vary = make(http.Header)
+			}
+			name = http.CanonicalHeaderKey(name)
+			vary[name] = append([]string{}, requestHeader.Values(name)...)
+		}
+	}
+	return vary
+}
+
+// matches reports whether the request sends the same values as the one the
+// entry was cached for, for every header the response varies on. Missing
+// headers only match missing ones.
+func (e *entry) matches(requestHeader http.Header) bool {
+	for name, values := range e.vary {
+		if strings.Join(requestHeader.Values(name), ",") != strings.Join(values, ",") {
+			return false
+		}
+	}
+	return true
+}
+
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name == "" {
+			continue
+		}
+		directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+	}
+	return directives
+}
+
+// etagMatches applies If-None-Match's weak comparison
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// cacheKey identifies the response and everything that decides what the
+// caller may see: user, organization, roles and scopes
+func cacheKey(c *gin.Context) string {
+	roles := append([]string(nil), c.GetStringSlice("roles")...)
+	sort.Strings(roles)
+	tokenScopes := append([]string(nil), c.GetStringSlice("scopes")...)
+	sort.Strings(tokenScopes)
+
+	return strings.Join([]string{
+		c.GetString("user_id"),
+		c.GetString("org_id"),
+		strings.Join(roles, ","),
+		strings.Join(tokenScopes, ","),
+		c.Request.URL.Path,
+		c.Request.URL.Query().Encode(),
+	}, "\x00")
+}
+
+// scopes are the invalidation scopes of the request's responses. A user's
+// own changes invalidate everything cached for them, wherever they were
+// working; changes inside an organization invalidate everything cached in
+// it.
+func scopes(c *gin.Context) []string {
+	names := []string{"user:" + c.GetString("user_id")}
+	if orgID := c.GetString("org_id"); orgID != "" {
+		names = append(names, "org:"+orgID)
+	}
+	return names
+}
+
+func storedHeader(header http.Header) http.Header {
+	stored := make(http.Header)
+	for _, name := range storedHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			stored[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+	return stored
+}
+
+func headerSize(header http.Header) int {
+	size := 0
+	for _, name := range storedHeaders {
+		for _, value := range header.Values(name) {
+			size += len(name) + len(value)
+		}
+	}
+	return size
+}
+
+func routeKey(serviceName string, route *config.RouteConfig) string {
+	return serviceName + " " + route.Method + " " + route.PathPrefix
+}
+
+// bufferedWriter holds the response back so it can be given an ETag and
+// cached before the client sees it. A response that outgrows the limit
+// can't be cached and is passed through instead.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	limit       int
+	passthrough bool
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.status = code
+	w.wroteHeader = true
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+
+	if w.body.Len()+len(data) > w.limit {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(w.status)
+		if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+			return 0, err
+		}
+		w.body.Reset()
+		return w.ResponseWriter.Write(data)
+	}
+
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bufferedWriter) Status() int {
+	if !w.wroteHeader {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.wroteHeader
+}
+
+func (w *bufferedWriter) Size() int {
+	if w.passthrough {
+		return w.ResponseWriter.Size()
+	}
+	if !w.wroteHeader {
+		return -1
+	}
+	return w.body.Len()
+}
+
+// Flush is a no-op until the response is being passed through, since
+// flushing would send it before it is complete
+func (w *bufferedWriter) Flush() {
+	if w.passthrough {
+		w.ResponseWriter.Flush()
+	}
+}
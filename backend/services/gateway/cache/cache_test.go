@@ -0,0 +1,460 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+)
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		etag        string
+		want        bool
+	}{
+		{"same", `"abc"`, `"abc"`, true},
+		{"different", `"abc"`, `"abd"`, false},
+		{"one of a list", `"x", "abc" ,"y"`, `"abc"`, true},
+		{"none of a list", `"x", "y"`, `"abc"`, false},
+		{"wildcard", ` * `, `"abc"`, true},
+		{"weak candidate", `W/"abc"`, `"abc"`, true},
+		{"weak etag", `"abc"`, `W/"abc"`, true},
+		{"both weak", `W/"abc"`, `W/"abc"`, true},
+		{"unquoted", `abc`, `"abc"`, false},
+		{"no header", ``, `"abc"`, false},
+		{"no etag", `"abc"`, ``, false},
+		{"wildcard without etag", `*`, ``, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagMatches(tt.ifNoneMatch, tt.etag); got != tt.want {
+				t.Errorf("etagMatches(%q, %q) = %v, want %v", tt.ifNoneMatch, tt.etag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStorable(t *testing.T) {
+	const ttl = 5 * time.Minute
+
+	tests := []struct {
+		name    string
+		status  int
+		header  map[string]string
+		wantTTL time.Duration
+		wantOK  bool
+	}{
+		{"plain 200", http.StatusOK, nil, ttl, true},
+		{"not found", http.StatusNotFound, nil, 0, false},
+		{"redirect", http.StatusFound, nil, 0, false},
+		{"set-cookie", http.StatusOK, map[string]string{"Set-Cookie": "session=1"}, 0, false},
+		{"vary star", http.StatusOK, map[string]string{"Vary": "*"}, 0, false},
+		{"vary header", http.StatusOK, map[string]string{"Vary": "Accept-Language"}, ttl, true},
+		{"no-store", http.StatusOK, map[string]string{"Cache-Control": "private, no-store"}, 0, false},
+		{"no-cache", http.StatusOK, map[string]string{"Cache-Control": "no-cache"}, 0, false},
+		{"upper case directive", http.StatusOK, map[string]string{"Cache-Control": "No-Store"}, 0, false},
+		{"shorter max-age", http.StatusOK, map[string]string{"Cache-Control": "max-age=60"}, time.Minute, true},
+		{"longer max-age", http.StatusOK, map[string]string{"Cache-Control": "max-age=3600"}, ttl, true},
+		{"s-maxage wins", http.StatusOK, map[string]string{"Cache-Control": "max-age=600, s-maxage=30"}, 30 * time.Second, true},
+		{"quoted max-age", http.StatusOK, map[string]string{"Cache-Control": `max-age="90"`}, 90 * time.Second, true},
+		{"zero max-age", http.StatusOK, map[string]string{"Cache-Control": "max-age=0"}, 0, false},
+		{"invalid max-age", http.StatusOK, map[string]string{"Cache-Control": "max-age=soon"}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := make(http.Header)
+			for name, value := range tt.header {
+				header.Set(name, value)
+			}
+
+			gotTTL, ok := storable(tt.status, header, ttl)
+			if ok != tt.wantOK || gotTTL != tt.wantTTL {
+				t.Errorf("storable() = %v, %v, want %v, %v", gotTTL, ok, tt.wantTTL, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestAcceptable(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		age          time.Duration
+		want         bool
+	}{
+		{"", time.Hour, true},
+		{"no-cache", 0, false},
+		{"max-age=60", 59 * time.Second, true},
+		{"max-age=60", 60 * time.Second, true},
+		{"max-age=60", 61 * time.Second, false},
+		{"max-age=0", time.Second, false},
+		{"max-age=x", 0, false},
+	}
+
+	for _, tt := range tests {
+		if got := acceptable(parseCacheControl(tt.cacheControl), tt.age); got != tt.want {
+			t.Errorf("acceptable(%q, %v) = %v, want %v", tt.cacheControl, tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestParseCacheControl(t *testing.T) {
+	got := parseCacheControl(` Max-Age=60, no-cache,, private="Set-Cookie" ,`)
+	want := map[string]string{"max-age": "60", "no-cache": "", "private": "Set-Cookie"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCacheControl() = %v, want %v", got, want)
+	}
+}
+
+type keyRequest struct {
+	userID string
+	orgID  string
+	roles  []string
+	scopes []string
+	target string
+}
+
+func testCacheKey(r keyRequest) string {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, r.target, nil)
+	c.Set("user_id", r.userID)
+	c.Set("org_id", r.orgID)
+	c.Set("roles", r.roles)
+	c.Set("scopes", r.scopes)
+	return cacheKey(c)
+}
+
+func TestCacheKey(t *testing.T) {
+	base := keyRequest{
+		userID: "u1",
+		orgID:  "o1",
+		roles:  []string{"user", "support"},
+		scopes: []string{"flowtime:read", "profile:read"},
+		target: "/api/v1/tasks?limit=10&page=2",
+	}
+	baseKey := testCacheKey(base)
+
+	tests := []struct {
+		name   string
+		mutate func(r *keyRequest)
+		same   bool
+	}{
+		{"identical", func(r *keyRequest) {}, true},
+		{"roles in another order", func(r *keyRequest) { r.roles = []string{"support", "user"} }, true},
+		{"scopes in another order", func(r *keyRequest) { r.scopes = []string{"profile:read", "flowtime:read"} }, true},
+		{"query in another order", func(r *keyRequest) { r.target = "/api/v1/tasks?page=2&limit=10" }, true},
+		{"another user", func(r *keyRequest) { r.userID = "u2" }, false},
+		{"another organization", func(r *keyRequest) { r.orgID = "o2" }, false},
+		{"personal workspace", func(r *keyRequest) { r.orgID = "" }, false},
+		{"fewer roles", func(r *keyRequest) { r.roles = []string{"user"} }, false},
+		{"fewer scopes", func(r *keyRequest) { r.scopes = []string{"flowtime:read"} }, false},
+		{"another path", func(r *keyRequest) { r.target = "/api/v1/tasks/1?limit=10&page=2" }, false},
+		{"another query", func(r *keyRequest) { r.target = "/api/v1/tasks?limit=10&page=3" }, false},
+		{"no query", func(r *keyRequest) { r.target = "/api/v1/tasks" }, false},
+		// Fields are separated, so values can't run into each other
+		{"user and org run together", func(r *keyRequest) { r.userID, r.orgID = "u1o", "1" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := base
+			tt.mutate(&r)
+			if got := testCacheKey(r) == baseKey; got != tt.same {
+				t.Errorf("same key = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+func TestCacheKeyDoesNotReorderContext(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	roles := []string{"user", "admin"}
+	c.Set("roles", roles)
+
+	cacheKey(c)
+	if roles[0] != "user" {
+		t.Error("cacheKey() sorted the request's roles in place")
+	}
+}
+
+var (
+	statsRoute = config.RouteConfig{Method: "GET", PathPrefix: "/stats", CacheConfig: &config.CacheConfig{
+		Enabled:      true,
+		InvalidateOn: []string{"/tasks"},
+	}}
+	tasksRoute = config.RouteConfig{Method: "*", PathPrefix: "/tasks"}
+	bigRoute   = config.RouteConfig{Method: "GET", PathPrefix: "/export", CacheConfig: &config.CacheConfig{
+		Enabled: true,
+		MaxSize: 64,
+	}}
+)
+
+func newTestResponseCache() *ResponseCache {
+	return NewResponseCache(map[string]config.ServiceConfig{
+		"flowtime": {Routes: []config.RouteConfig{statsRoute, tasksRoute, bigRoute}},
+	}, logger.New())
+}
+
+// upstream stands in for the proxied service, counting the requests it gets
+type upstream struct {
+	calls   int
+	header  http.Header // request headers of the last call
+	respond func(c *gin.Context)
+}
+
+func (u *upstream) handle(c *gin.Context) {
+	u.calls++
+	u.header = c.Request.Header.Clone()
+	if u.respond != nil {
+		u.respond(c)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", []byte(`{"focus_minutes":90}`))
+}
+
+type testRequest struct {
+	method string
+	target string
+	userID string
+	orgID  string
+	header map[string]string
+}
+
+// serve runs the request through rc as the gateway's service routes do. An
+// empty userID is an unauthenticated request.
+func serve(rc *ResponseCache, route config.RouteConfig, r testRequest, next *upstream) *httptest.ResponseRecorder {
+	if r.method == "" {
+		r.method = http.MethodGet
+	}
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(r.method, r.target, nil)
+	for name, value := range r.header {
+		c.Request.Header.Set(name, value)
+	}
+	if r.userID != "" {
+		c.Set("authenticated", true)
+		c.Set("user_id", r.userID)
+		c.Set("org_id", r.orgID)
+	}
+
+	rc.Handle(c, "flowtime", &route, next.handle)
+	// gin writes a bodyless response's header once the handlers are done
+	c.Writer.WriteHeaderNow()
+	return rec
+}
+
+func TestHandleCachesPerUser(t *testing.T) {
+	rc := newTestResponseCache()
+	next := &upstream{}
+	get := testRequest{target: "/api/v1/stats/insights", userID: "u1"}
+
+	miss := serve(rc, statsRoute, get, next)
+	if miss.Code != http.StatusOK || miss.Header().Get("X-Cache") != "MISS" || miss.Header().Get("ETag") == "" {
+		t.Fatalf("first response = %d, X-Cache %q, ETag %q, want a 200 MISS with an ETag", miss.Code, miss.Header().Get("X-Cache"), miss.Header().Get("ETag"))
+	}
+
+	hit := serve(rc, statsRoute, get, next)
+	if hit.Header().Get("X-Cache") != "HIT" || hit.Header().Get("Age") == "" || next.calls != 1 {
+		t.Fatalf("second response X-Cache %q, Age %q after %d upstream calls, want a HIT from cache", hit.Header().Get("X-Cache"), hit.Header().Get("Age"), next.calls)
+	}
+	if hit.Body.String() != miss.Body.String() || hit.Header().Get("ETag") != miss.Header().Get("ETag") ||
+		hit.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("cached response = %q %v, want the original", hit.Body.String(), hit.Header())
+	}
+
+	other := serve(rc, statsRoute, testRequest{target: get.target, userID: "u2"}, next)
+	if other.Header().Get("X-Cache") != "MISS" || next.calls != 2 {
+		t.Errorf("another user got X-Cache %q, want their own MISS", other.Header().Get("X-Cache"))
+	}
+
+	for i := 0; i < 2; i++ {
+		anonymous := serve(rc, statsRoute, testRequest{target: get.target}, next)
+		if anonymous.Header().Get("X-Cache") != "" {
+			t.Errorf("unauthenticated request got X-Cache %q, want no caching", anonymous.Header().Get("X-Cache"))
+		}
+	}
+	if next.calls != 4 {
+		t.Errorf("upstream calls = %d, want 4", next.calls)
+	}
+
+	fresh := serve(rc, statsRoute, testRequest{target: get.target, userID: "u1", header: map[string]string{"Cache-Control": "no-cache"}}, next)
+	if fresh.Header().Get("X-Cache") != "MISS" || next.calls != 5 {
+		t.Errorf("no-cache request got X-Cache %q, want a fresh response", fresh.Header().Get("X-Cache"))
+	}
+}
+
+func TestHandleInvalidatesAfterMutation(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutation testRequest
+		route    config.RouteConfig
+		dropped  bool
+	}{
+		{"same user, invalidating prefix", testRequest{method: http.MethodPost, target: "/api/v1/tasks", userID: "u1"}, tasksRoute, true},
+		{"same user, cached prefix", testRequest{method: http.MethodDelete, target: "/api/v1/stats/insights", userID: "u1"}, statsRoute, true},
+		{"member of the organization", testRequest{method: http.MethodPatch, target: "/api/v1/tasks/7", userID: "u2", orgID: "o1"}, tasksRoute, true},
+		{"another user", testRequest{method: http.MethodPost, target: "/api/v1/tasks", userID: "u2"}, tasksRoute, false},
+		{"unrelated prefix", testRequest{method: http.MethodPost, target: "/api/v1/energy", userID: "u1"}, tasksRoute, false},
+		{"read", testRequest{target: "/api/v1/tasks", userID: "u1"}, tasksRoute, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := newTestResponseCache()
+			next := &upstream{}
+			get := testRequest{target: "/api/v1/stats/insights", userID: "u1", orgID: "o1"}
+
+			serve(rc, statsRoute, get, next)
+			serve(rc, tt.route, tt.mutation, &upstream{})
+
+			got := serve(rc, statsRoute, get, next).Header().Get("X-Cache")
+			want := "HIT"
+			if tt.dropped {
+				want = "MISS"
+			}
+			if got != want {
+				t.Errorf("X-Cache after the request = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestHandleNotModified(t *testing.T) {
+	rc := newTestResponseCache()
+	next := &upstream{}
+	etag := computeETag([]byte(`{"focus_minutes":90}`))
+	request := func(ifNoneMatch string) testRequest {
+		return testRequest{target: "/api/v1/stats/insights", userID: "u1", header: map[string]string{"If-None-Match": ifNoneMatch}}
+	}
+
+	// On a miss the whole response is fetched and cached, then answered
+	// with a 304
+	miss := serve(rc, statsRoute, request(etag), next)
+	if miss.Code != http.StatusNotModified || miss.Body.Len() != 0 || miss.Header().Get("ETag") != etag {
+		t.Fatalf("miss = %d %q, ETag %q, want an empty 304 with the ETag", miss.Code, miss.Body.String(), miss.Header().Get("ETag"))
+	}
+	if next.header.Get("If-None-Match") != "" {
+		t.Error("the client's If-None-Match was sent upstream")
+	}
+
+	hit := serve(rc, statsRoute, request(`"stale", `+etag), next)
+	if hit.Code != http.StatusNotModified || hit.Header().Get("X-Cache") != "HIT" || hit.Header().Get("Content-Type") != "" {
+		t.Errorf("hit = %d, X-Cache %q, Content-Type %q, want a 304 HIT without a content type", hit.Code, hit.Header().Get("X-Cache"), hit.Header().Get("Content-Type"))
+	}
+
+	changed := serve(rc, statsRoute, request(`"stale"`), next)
+	if changed.Code != http.StatusOK || changed.Body.String() != `{"focus_minutes":90}` {
+		t.Errorf("stale ETag got %d %q, want the full response", changed.Code, changed.Body.String())
+	}
+	if next.calls != 1 {
+		t.Errorf("upstream calls = %d, want 1", next.calls)
+	}
+}
+
+func TestHandleVary(t *testing.T) {
+	rc := newTestResponseCache()
+	next := &upstream{respond: func(c *gin.Context) {
+		c.Header("Vary", "Accept-Language")
+		c.String(http.StatusOK, "hello in "+c.GetHeader("Accept-Language"))
+	}}
+	request := func(language string) testRequest {
+		return testRequest{target: "/api/v1/stats/insights", userID: "u1", header: map[string]string{"Accept-Language": language}}
+	}
+
+	steps := []struct {
+		language string
+		cache    string
+		body     string
+	}{
+		{"en", "MISS", "hello in en"},
+		{"en", "HIT", "hello in en"},
+		{"fr", "MISS", "hello in fr"},
+		{"fr", "HIT", "hello in fr"},
+		{"", "MISS", "hello in "},
+	}
+	for i, step := range steps {
+		rec := serve(rc, statsRoute, request(step.language), next)
+		if rec.Header().Get("X-Cache") != step.cache || rec.Body.String() != step.body {
+			t.Errorf("step %d (%q): X-Cache %q body %q, want %q %q", i, step.language, rec.Header().Get("X-Cache"), rec.Body.String(), step.cache, step.body)
+		}
+	}
+	if got := serve(rc, statsRoute, request(""), next).Header().Get("Vary"); got != "Accept-Language" {
+		t.Errorf("cached Vary = %q, want it kept", got)
+	}
+
+	star := &upstream{respond: func(c *gin.Context) {
+		c.Header("Vary", "*")
+		c.String(http.StatusOK, "anything")
+	}}
+	serve(rc, statsRoute, testRequest{target: "/api/v1/stats/other", userID: "u1"}, star)
+	serve(rc, statsRoute, testRequest{target: "/api/v1/stats/other", userID: "u1"}, star)
+	if star.calls != 2 {
+		t.Errorf("Vary: * response served from cache")
+	}
+}
+
+func TestHandlePassesLargeResponsesThrough(t *testing.T) {
+	rc := newTestResponseCache()
+	chunk := strings.Repeat("x", 40)
+	next := &upstream{respond: func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain")
+		c.Status(http.StatusOK)
+		c.Writer.WriteString(chunk)
+		c.Writer.Flush()
+		c.Writer.WriteString(chunk)
+		if size := c.Writer.Size(); size != 2*len(chunk) {
+			t.Errorf("Size() = %d, want %d", size, 2*len(chunk))
+		}
+	}}
+	get := testRequest{target: "/api/v1/export", userID: "u1"}
+
+	for i := 0; i < 2; i++ {
+		rec := serve(rc, bigRoute, get, next)
+		if rec.Code != http.StatusOK || rec.Body.String() != chunk+chunk || rec.Header().Get("X-Cache") != "" || rec.Header().Get("ETag") != "" {
+			t.Errorf("response %d = %d, %d bytes, X-Cache %q, ETag %q, want the whole body passed through uncached",
+				i, rec.Code, rec.Body.Len(), rec.Header().Get("X-Cache"), rec.Header().Get("ETag"))
+		}
+	}
+	if next.calls != 2 {
+		t.Errorf("upstream calls = %d, want 2", next.calls)
+	}
+}
+
+func TestBufferedWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	w := &bufferedWriter{ResponseWriter: c.Writer, limit: 8}
+
+	if w.Written() || w.Status() != http.StatusOK || w.Size() != -1 {
+		t.Errorf("fresh writer: Written %v, Status %d, Size %d", w.Written(), w.Status(), w.Size())
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.WriteHeader(http.StatusTeapot)
+	w.WriteString("abcd")
+	w.Flush()
+	if rec.Body.Len() != 0 || rec.Flushed || w.Status() != http.StatusAccepted || w.Size() != 4 {
+		t.Fatalf("buffering: sent %q, flushed %v, Status %d, Size %d", rec.Body.String(), rec.Flushed, w.Status(), w.Size())
+	}
+
+	w.Write([]byte("efghij"))
+	if !w.passthrough || rec.Code != http.StatusAccepted || rec.Body.String() != "abcdefghij" || w.Size() != 10 {
+		t.Fatalf("past the limit: passthrough %v, sent %d %q, Size %d", w.passthrough, rec.Code, rec.Body.String(), w.Size())
+	}
+	w.Write([]byte("k"))
+	w.Flush()
+	if rec.Body.String() != "abcdefghijk" || !rec.Flushed {
+		t.Errorf("passing through: sent %q, flushed %v", rec.Body.String(), rec.Flushed)
+	}
+}
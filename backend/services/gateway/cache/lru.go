@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// scopeSweepInterval is how often scopes with nothing cached are forgotten.
+// A scope's invalidation time must outlive any request that was in flight
+// when it was invalidated, so this is well above the request timeouts.
+const scopeSweepInterval = time.Minute
+
+type entry struct {
+	key string
+	// scopes the entry is invalidated through: its user, and its
+	// organization when it was cached in one
+	scopes []string
+	// vary holds the request's values of the headers the response varies
+	// on; the entry only answers requests that send the same
+	vary     http.Header
+	status   int
+	header   http.Header
+	body     []byte
+	storedAt time.Time
+	expires  time.Time
+	size     int64
+}
+
+type scope struct {
+	keys          map[string]struct{}
+	invalidatedAt time.Time
+}
+
+// lru holds one route's cached responses, evicting the least recently used
+// once they take up more than maxBytes
+type lru struct {
+	mu        sync.Mutex
+	maxBytes  int64
+	size      int64
+	order     *list.List // most recently used first
+	entries   map[string]*list.Element
+	scopes    map[string]*scope
+	lastSweep time.Time
+}
+
+func newLRU(maxBytes int64) *lru {
+	return &lru{
+		maxBytes:  maxBytes,
+		order:     list.New(),
+		entries:   make(map[string]*list.Element),
+		scopes:    make(map[string]*scope),
+		lastSweep: time.Now(),
+	}
+}
+
+// get returns the unexpired entry for key
+func (l *lru) get(key string, now time.Time) *entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.entries[key]
+	if !ok {
+		return nil
+	}
+
+	e := elem.Value.(*entry)
+	if !now.Before(e.expires) {
+		l.remove(elem)
+		return nil
+	}
+
+	l.order.MoveToFront(elem)
+	return e
+}
+
+// add caches e unless one of its scopes was invalidated after since, when
+// the request that produced it started: the response may predate the
+// change that invalidated it
+func (l *lru) add(e *entry, since time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e.size > l.maxBytes {
+		return false
+	}
+	for _, name := range e.scopes {
+		if s, ok := l.scopes[name]; ok && s.invalidatedAt.After(since) {
+			return false
+		}
+	}
+
+	if elem, ok := l.entries[e.key]; ok {
+		l.remove(elem)
+	}
+
+	l.entries[e.key] = l.order.PushFront(e)
+	l.size += e.size
+	for _, name := range e.scopes {
+		s, ok := l.scopes[name]
+		if !ok {
+			s = &scope{keys: make(map[string]struct{})}
+			l.scopes[name] = s
+		}
+		s.keys[e.key] = struct{}{}
+	}
+
+	for l.size > l.maxBytes {
+		l.remove(l.order.Back())
+	}
+	return true
+}
+
+// invalidate drops every entry in the scope
+func (l *lru) invalidate(name string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, ok := l.scopes[name]
+	if !ok {
+		s = &scope{keys: make(map[string]struct{})}
+		l.scopes[name] = s
+	}
+	s.invalidatedAt = now
+	for key := range s.keys {
+		l.remove(l.entries[key])
+	}
+
+	if now.Sub(l.lastSweep) > scopeSweepInterval {
+		for name, s := range l.scopes {
+			if len(s.keys) == 0 && now.Sub(s.invalidatedAt) > scopeSweepInterval {
+				delete(l.scopes, name)
+			}
+		}
+		l.lastSweep = now
+	}
+}
+
+func (l *lru) remove(elem *list.Element) {
+	e := l.order.Remove(elem).(*entry)
+	delete(l.entries, e.key)
+	l.size -= e.size
+
+	for _, name := range e.scopes {
+		s := l.scopes[name]
+		delete(s.keys, e.key)
+		if len(s.keys) == 0 && s.invalidatedAt.IsZero() {
+			delete(l.scopes, name)
+		}
+	}
+}
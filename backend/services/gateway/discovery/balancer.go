@@ -0,0 +1,99 @@
+package discovery
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+)
+
+// Load balancing strategies
+const (
+	StrategyRoundRobin         = "round-robin"
+	StrategyRandom             = "random"
+	StrategyLeastConn          = "least-conn"
+	StrategyWeightedRoundRobin = "weighted-round-robin"
+)
+
+// Balancer picks the instance a request goes to from the instances that are
+// currently available. It is never called with an empty list.
+type Balancer interface {
+	Pick(instances []*Instance) *Instance
+}
+
+// NewBalancer returns the balancer for a strategy; an empty strategy means
+// round-robin
+func NewBalancer(strategy string) (Balancer, error) {
+	switch strategy {
+	case "", StrategyRoundRobin:
+		return &roundRobinBalancer{}, nil
+	case StrategyRandom:
+		return randomBalancer{}, nil
+	case StrategyLeastConn:
+		return &leastConnBalancer{}, nil
+	case StrategyWeightedRoundRobin:
+		return &weightedRoundRobinBalancer{current: make(map[*Instance]int)}, nil
+	default:
+		return nil, fmt.Errorf("unknown load balancing strategy %q", strategy)
+	}
+}
+
+type roundRobinBalancer struct {
+	next atomic.Uint64
+}
+
+func (b *roundRobinBalancer) Pick(instances []*Instance) *Instance {
+	n := b.next.Add(1) - 1
+	return instances[n%uint64(len(instances))]
+}
+
+type randomBalancer struct{}
+
+func (randomBalancer) Pick(instances []*Instance) *Instance {
+	return instances[rand.IntN(len(instances))]
+}
+
+// leastConnBalancer picks the instance with the fewest requests in flight.
+// Ties go round-robin, so idle instances share the load evenly.
+type leastConnBalancer struct {
+	next atomic.Uint64
+}
+
+func (b *leastConnBalancer) Pick(instances []*Instance) *Instance {
+	start := int(b.next.Add(1) % uint64(len(instances)))
+
+	var best *Instance
+	for i := range instances {
+		instance := instances[(start+i)%len(instances)]
+		if best == nil || instance.ActiveConnections() < best.ActiveConnections() {
+			best = instance
+		}
+	}
+	return best
+}
+
+// weightedRoundRobinBalancer is nginx's smooth weighted round-robin: weights
+// 5, 1 and 1 give a a b a c a a rather than a a a a a b c. Unavailable
+// instances simply drop out of the rotation.
+type weightedRoundRobinBalancer struct {
+	mu      sync.Mutex
+	current map[*Instance]int
+}
+
+func (b *weightedRoundRobinBalancer) Pick(instances []*Instance) *Instance {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	total := 0
+	var best *Instance
+	for _, instance := range instances {
+		b.current[instance] += instance.Weight
+		total += instance.Weight
+		if best == nil || b.current[instance] > b.current[best] {
+			best = instance
+		}
+	}
+
+	b.current[best] -= total
+	return best
+}
@@ -0,0 +1,188 @@
+package discovery
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testInstances(weights ...int) []*Instance {
+	instances := make([]*Instance, len(weights))
+	for i, weight := range weights {
+		instances[i] = &Instance{URL: string(rune('a' + i)), Weight: weight}
+	}
+	return instances
+}
+
+func pickSequence(b Balancer, instances []*Instance, n int) string {
+	var picks strings.Builder
+	for i := 0; i < n; i++ {
+		picks.WriteString(b.Pick(instances).URL)
+	}
+	return picks.String()
+}
+
+func TestNewBalancer(t *testing.T) {
+	tests := []struct {
+		strategy string
+		want     Balancer
+	}{
+		{"", &roundRobinBalancer{}},
+		{StrategyRoundRobin, &roundRobinBalancer{}},
+		{StrategyRandom, randomBalancer{}},
+		{StrategyLeastConn, &leastConnBalancer{}},
+		{StrategyWeightedRoundRobin, &weightedRoundRobinBalancer{}},
+	}
+
+	for _, tt := range tests {
+		b, err := NewBalancer(tt.strategy)
+		if err != nil {
+			t.Fatalf("NewBalancer(%q) error = %v", tt.strategy, err)
+		}
+		if got, want := reflect.TypeOf(b), reflect.TypeOf(tt.want); got != want {
+			t.Errorf("NewBalancer(%q) = %v, want %v", tt.strategy, got, want)
+		}
+	}
+
+	if _, err := NewBalancer("fastest"); err == nil {
+		t.Error("NewBalancer() accepted an unknown strategy")
+	}
+}
+
+func TestRoundRobinBalancer(t *testing.T) {
+	b, _ := NewBalancer(StrategyRoundRobin)
+	instances := testInstances(1, 1, 1)
+
+	if got := pickSequence(b, instances, 7); got != "abcabca" {
+		t.Errorf("picks = %s, want abcabca", got)
+	}
+
+	// The rotation carries on when an instance becomes unavailable
+	if got := pickSequence(b, instances[:2], 3); got != "bab" {
+		t.Errorf("picks over two instances = %s, want bab", got)
+	}
+}
+
+func TestRandomBalancer(t *testing.T) {
+	b, _ := NewBalancer(StrategyRandom)
+	instances := testInstances(1, 1, 1)
+
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		counts[b.Pick(instances).URL]++
+	}
+	for _, instance := range instances {
+		if n := counts[instance.URL]; n < 800 || n > 1200 {
+			t.Errorf("instance %s picked %d of 3000 times", instance.URL, n)
+		}
+	}
+
+	if got := b.Pick(instances[:1]); got != instances[0] {
+		t.Errorf("Pick() of one instance = %s", got.URL)
+	}
+}
+
+func TestLeastConnBalancer(t *testing.T) {
+	b, _ := NewBalancer(StrategyLeastConn)
+	instances := testInstances(1, 1, 1)
+
+	instances[0].Acquire()
+	instances[0].Acquire()
+	instances[1].Acquire()
+	if got := b.Pick(instances); got != instances[2] {
+		t.Errorf("Pick() = %s, want the idle instance c", got.URL)
+	}
+
+	instances[2].Acquire()
+	instances[2].Acquire()
+	instances[2].Acquire()
+	for i := 0; i < 5; i++ {
+		if got := b.Pick(instances); got != instances[1] {
+			t.Fatalf("Pick() = %s, want b with one request in flight", got.URL)
+		}
+	}
+
+	// Idle instances share the load rather than the first taking it all
+	idle := testInstances(1, 1, 1)
+	counts := make(map[string]int)
+	for i := 0; i < 30; i++ {
+		counts[b.Pick(idle).URL]++
+	}
+	for _, instance := range idle {
+		if counts[instance.URL] != 10 {
+			t.Errorf("idle instance %s picked %d of 30 times, want 10", instance.URL, counts[instance.URL])
+		}
+	}
+}
+
+func TestWeightedRoundRobinBalancer(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights []int
+		picks   int
+		want    string
+	}{
+		{"nginx example", []int{5, 1, 1}, 14, "aabacaaaabacaa"},
+		{"equal weights", []int{1, 1, 1}, 6, "abcabc"},
+		{"two to one", []int{2, 1}, 6, "abaaba"},
+		{"single instance", []int{3}, 3, "aaa"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := NewBalancer(StrategyWeightedRoundRobin)
+			if got := pickSequence(b, testInstances(tt.weights...), tt.picks); got != tt.want {
+				t.Errorf("picks = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeightedRoundRobinBalancerDropsUnavailable(t *testing.T) {
+	b, _ := NewBalancer(StrategyWeightedRoundRobin)
+	instances := testInstances(5, 1, 1)
+	pickSequence(b, instances, 3)
+
+	// While a is unavailable, b and c share its traffic
+	counts := make(map[rune]int)
+	for _, r := range pickSequence(b, instances[1:], 100) {
+		counts[r]++
+	}
+	if counts['a'] != 0 || counts['b'] < 45 || counts['c'] < 45 {
+		t.Errorf("picks without a = %v, want b and c about 50 each", counts)
+	}
+
+	// Once a is back, its weight applies again
+	counts = make(map[rune]int)
+	for _, r := range pickSequence(b, instances, 700) {
+		counts[r]++
+	}
+	if counts['a'] < 495 || counts['b'] < 95 || counts['c'] < 95 {
+		t.Errorf("picks with a back = %v, want about 500, 100 and 100", counts)
+	}
+}
+
+func TestBalancersConcurrentPick(t *testing.T) {
+	for _, strategy := range []string{StrategyRoundRobin, StrategyRandom, StrategyLeastConn, StrategyWeightedRoundRobin} {
+		t.Run(strategy, func(t *testing.T) {
+			b, _ := NewBalancer(strategy)
+			instances := testInstances(3, 2, 1)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						if b.Pick(instances) == nil {
+							t.Error("Pick() returned nil")
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
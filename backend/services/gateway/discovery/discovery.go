@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mdnaeem95/lifesync/backend/internal/config"
@@ -13,6 +15,10 @@ import (
 
 type ServiceDiscovery interface {
 	GetHealthyService(name string) (*config.ServiceConfig, error)
+	// PickInstance chooses an available instance of the service with its
+	// load balancing strategy, preferring instances whose URL is not in
+	// tried
+	PickInstance(name string, tried map[string]bool) (*Instance, error)
 	GetServiceHealth(name string) (*config.ServiceHealth, error)
 	GetAllServicesHealth() map[string]*config.ServiceHealth
 	RegisterService(name string, config config.ServiceConfig)
@@ -21,28 +27,157 @@ type ServiceDiscovery interface {
 	Stop()
 }
 
+// Instance is one backend of a service, with its own health status and
+// circuit breaker
+type Instance struct {
+	URL    string
+	Weight int
+
+	breaker   *CircuitBreaker // nil when circuit breaking is disabled
+	maxActive int64
+	active    atomic.Int64
+
+	mu     sync.RWMutex
+	health *config.ServiceHealth
+}
+
+// Acquire counts a request in flight to the instance; Release must follow
+func (i *Instance) Acquire() {
+	i.active.Add(1)
+}
+
+func (i *Instance) Release() {
+	i.active.Add(-1)
+}
+
+func (i *Instance) ActiveConnections() int64 {
+	return i.active.Load()
+}
+
+// RecordSuccess and RecordFailure feed the outcome of proxied requests to
+// the instance's circuit breaker, alongside its health checks
+func (i *Instance) RecordSuccess() {
+	if i.breaker != nil {
+		i.breaker.RecordSuccess()
+	}
+}
+
+func (i *Instance) RecordFailure() {
+	if i.breaker != nil {
+		i.breaker.RecordFailure()
+	}
+}
+
+// Health returns a copy of the instance's health status
+func (i *Instance) Health() *config.ServiceHealth {
+	i.mu.RLock()
+	health := *i.health
+	i.mu.RUnlock()
+
+	health.ActiveConnections = i.ActiveConnections()
+	if i.breaker != nil {
+		health.CircuitState = i.breaker.State()
+	}
+	return &health
+}
+
+// available reports whether the instance passed its last health check, its
+// circuit breaker lets requests through and it isn't at its concurrency limit
+func (i *Instance) available() bool {
+	i.mu.RLock()
+	healthy := i.health.Status == "healthy"
+	i.mu.RUnlock()
+
+	if !healthy {
+		return false
+	}
+	if i.maxActive > 0 && i.ActiveConnections() >= i.maxActive {
+		return false
+	}
+	return i.breaker == nil || i.breaker.CanRequest()
+}
+
+func (i *Instance) setHealth(health *config.ServiceHealth) {
+	i.mu.Lock()
+	i.health = health
+	i.mu.Unlock()
+}
+
+// InstanceURLs returns the URLs of a service's backends: its load balancing
+// backends, or just its URL when it has none
+func InstanceURLs(svc config.ServiceConfig) []string {
+	if len(svc.LoadBalancing.Backends) == 0 {
+		return []string{svc.URL}
+	}
+	return svc.LoadBalancing.Backends
+}
+
+type service struct {
+	config    config.ServiceConfig
+	instances []*Instance
+	balancer  Balancer
+}
+
+// health sums up the service's instances
+func (s *service) health() *config.ServiceHealth {
+	health := &config.ServiceHealth{
+		Name: s.config.Name,
+		URL:  s.config.URL,
+	}
+
+	healthy, unknown := 0, 0
+	for _, instance := range s.instances {
+		instanceHealth := instance.Health()
+		health.Instances = append(health.Instances, instanceHealth)
+		health.ActiveConnections += instanceHealth.ActiveConnections
+		if instanceHealth.LastChecked.After(health.LastChecked) {
+			health.LastChecked = instanceHealth.LastChecked
+		}
+
+		switch instanceHealth.Status {
+		case "healthy":
+			healthy++
+		case "unknown":
+			unknown++
+		}
+	}
+
+	switch {
+	case healthy == len(s.instances):
+		health.Status = "healthy"
+	case unknown == len(s.instances):
+		health.Status = "unknown"
+	case healthy > 0:
+		health.Status = "degraded"
+		health.Error = fmt.Sprintf("%d of %d instances unhealthy", len(s.instances)-healthy, len(s.instances))
+	default:
+		health.Status = "unhealthy"
+		health.Error = "no healthy instances"
+	}
+
+	return health
+}
+
 type serviceDiscovery struct {
-	services       map[string]config.ServiceConfig
-	health         map[string]*config.ServiceHealth
-	mu             sync.RWMutex
-	log            logger.Logger
-	checkInterval  time.Duration
-	httpClient     *http.Client
-	stopChan       chan struct{}
-	circuitBreaker map[string]*CircuitBreaker
+	services      map[string]*service
+	mu            sync.RWMutex
+	log           logger.Logger
+	checkInterval time.Duration
+	httpClient    *http.Client
+	stopChan      chan struct{}
+	breakerConfig config.CircuitBreakerConfig
 }
 
-func NewServiceDiscovery(log logger.Logger, checkInterval time.Duration) ServiceDiscovery {
+func NewServiceDiscovery(log logger.Logger, checkInterval time.Duration, breakerConfig config.CircuitBreakerConfig) ServiceDiscovery {
 	return &serviceDiscovery{
-		services:      make(map[string]config.ServiceConfig),
-		health:        make(map[string]*config.ServiceHealth),
+		services:      make(map[string]*service),
 		log:           log,
 		checkInterval: checkInterval,
 		httpClient: &http.Client{
 			Timeout: 5 * time.Second,
 		},
-		stopChan:       make(chan struct{}),
-		circuitBreaker: make(map[string]*CircuitBreaker),
+		stopChan:      make(chan struct{}),
+		breakerConfig: breakerConfig,
 	}
 }
 
@@ -50,34 +185,60 @@ func (sd *serviceDiscovery) GetHealthyService(name string) (*config.ServiceConfi
 	sd.mu.RLock()
 	defer sd.mu.RUnlock()
 
-	service, exists := sd.services[name]
+	svc, exists := sd.services[name]
 	if !exists {
 		return nil, fmt.Errorf("service %s not found", name)
 	}
 
-	health, exists := sd.health[name]
-	if !exists || health.Status != "healthy" {
-		return nil, fmt.Errorf("service %s is not healthy", name)
+	for _, instance := range svc.instances {
+		if instance.available() {
+			cfg := svc.config
+			return &cfg, nil
+		}
 	}
 
-	// Check circuit breaker
-	if cb, exists := sd.circuitBreaker[name]; exists && !cb.CanRequest() {
-		return nil, fmt.Errorf("service %s circuit breaker is open", name)
+	return nil, fmt.Errorf("service %s has no healthy instances", name)
+}
+
+func (sd *serviceDiscovery) PickInstance(name string, tried map[string]bool) (*Instance, error) {
+	sd.mu.RLock()
+	defer sd.mu.RUnlock()
+
+	svc, exists := sd.services[name]
+	if !exists {
+		return nil, fmt.Errorf("service %s not found", name)
 	}
 
-	return &service, nil
+	var available, untried []*Instance
+	for _, instance := range svc.instances {
+		if !instance.available() {
+			continue
+		}
+		available = append(available, instance)
+		if !tried[instance.URL] {
+			untried = append(untried, instance)
+		}
+	}
+
+	if len(untried) > 0 {
+		return svc.balancer.Pick(untried), nil
+	}
+	if len(available) > 0 {
+		return svc.balancer.Pick(available), nil
+	}
+	return nil, fmt.Errorf("service %s has no healthy instances", name)
 }
 
 func (sd *serviceDiscovery) GetServiceHealth(name string) (*config.ServiceHealth, error) {
 	sd.mu.RLock()
 	defer sd.mu.RUnlock()
 
-	health, exists := sd.health[name]
+	svc, exists := sd.services[name]
 	if !exists {
 		return nil, fmt.Errorf("health status for service %s not found", name)
 	}
 
-	return health, nil
+	return svc.health(), nil
 }
 
 func (sd *serviceDiscovery) GetAllServicesHealth() map[string]*config.ServiceHealth {
@@ -85,41 +246,69 @@ func (sd *serviceDiscovery) GetAllServicesHealth() map[string]*config.ServiceHea
 	defer sd.mu.RUnlock()
 
 	healthCopy := make(map[string]*config.ServiceHealth)
-	for k, v := range sd.health {
-		healthCopy[k] = v
+	for name, svc := range sd.services {
+		healthCopy[name] = svc.health()
 	}
 
 	return healthCopy
 }
 
 func (sd *serviceDiscovery) RegisterService(name string, cfg config.ServiceConfig) {
-	sd.mu.Lock()
-	defer sd.mu.Unlock()
-
-	sd.services[name] = cfg
-	sd.circuitBreaker[name] = NewCircuitBreaker(3, 2, 30*time.Second)
+	balancer, err := NewBalancer(cfg.LoadBalancing.Strategy)
+	if err != nil {
+		sd.log.WithError(err).WithField("service", name).Warn("Falling back to round-robin load balancing")
+		balancer, _ = NewBalancer(StrategyRoundRobin)
+	}
 
-	// Initialize health status
-	sd.health[name] = &config.ServiceHealth{
-		Name:        name,
-		URL:         cfg.URL,
-		Status:      "unknown",
-		LastChecked: time.Now(),
+	svc := &service{
+		config:   cfg,
+		balancer: balancer,
+	}
+	urls := InstanceURLs(cfg)
+	for _, url := range urls {
+		svc.instances = append(svc.instances, sd.newInstance(name, url, cfg.LoadBalancing.Weights[url]))
 	}
 
+	sd.mu.Lock()
+	sd.services[name] = svc
+	sd.mu.Unlock()
+
 	sd.log.WithFields(map[string]interface{}{
-		"service": name,
-		"url":     cfg.URL,
+		"service":   name,
+		"instances": strings.Join(urls, ","),
+		"strategy":  cfg.LoadBalancing.Strategy,
 	}).Info("Service registered")
 }
 
+func (sd *serviceDiscovery) newInstance(name, url string, weight int) *Instance {
+	if weight < 1 {
+		weight = 1
+	}
+
+	instance := &Instance{
+		URL:    url,
+		Weight: weight,
+		health: &config.ServiceHealth{
+			Name:        name,
+			URL:         url,
+			Status:      "unknown",
+			LastChecked: time.Now(),
+		},
+	}
+
+	if sd.breakerConfig.Enabled {
+		instance.breaker = NewCircuitBreaker(sd.breakerConfig.FailureThreshold, sd.breakerConfig.SuccessThreshold, sd.breakerConfig.Timeout)
+		instance.maxActive = int64(sd.breakerConfig.MaxConcurrentRequests)
+	}
+
+	return instance
+}
+
 func (sd *serviceDiscovery) DeregisterService(name string) {
 	sd.mu.Lock()
 	defer sd.mu.Unlock()
 
 	delete(sd.services, name)
-	delete(sd.health, name)
-	delete(sd.circuitBreaker, name)
 
 	sd.log.WithField("service", name).Info("Service deregistered")
 }
@@ -149,7 +338,7 @@ func (sd *serviceDiscovery) Stop() {
 
 func (sd *serviceDiscovery) checkAllServices() {
 	sd.mu.RLock()
-	services := make(map[string]config.ServiceConfig)
+	services := make(map[string]*service)
 	for k, v := range sd.services {
 		services[k] = v
 	}
@@ -157,25 +346,27 @@ func (sd *serviceDiscovery) checkAllServices() {
 
 	var wg sync.WaitGroup
 	for name, svc := range services {
-		wg.Add(1)
-		go func(n string, s config.ServiceConfig) {
-			defer wg.Done()
-			sd.checkServiceHealth(n, s)
-		}(name, svc)
+		for _, instance := range svc.instances {
+			wg.Add(1)
+			go func(n, path string, i *Instance) {
+				defer wg.Done()
+				sd.checkInstanceHealth(n, path, i)
+			}(name, svc.config.HealthCheckPath, instance)
+		}
 	}
 	wg.Wait()
 }
 
-func (sd *serviceDiscovery) checkServiceHealth(name string, svc config.ServiceConfig) {
+func (sd *serviceDiscovery) checkInstanceHealth(name, healthCheckPath string, instance *Instance) {
 	start := time.Now()
-	healthCheckURL := svc.URL + svc.HealthCheckPath
+	healthCheckURL := instance.URL + healthCheckPath
 
 	resp, err := sd.httpClient.Get(healthCheckURL)
 	responseTime := time.Since(start)
 
 	health := &config.ServiceHealth{
 		Name:         name,
-		URL:          svc.URL,
+		URL:          instance.URL,
 		LastChecked:  time.Now(),
 		ResponseTime: responseTime,
 	}
@@ -183,41 +374,28 @@ func (sd *serviceDiscovery) checkServiceHealth(name string, svc config.ServiceCo
 	if err != nil {
 		health.Status = "unhealthy"
 		health.Error = err.Error()
-
-		// Record failure in circuit breaker
-		if cb, exists := sd.circuitBreaker[name]; exists {
-			cb.RecordFailure()
-		}
+		instance.RecordFailure()
 	} else {
 		defer resp.Body.Close()
 
 		if resp.StatusCode == http.StatusOK {
 			health.Status = "healthy"
-
-			// Record success in circuit breaker
-			if cb, exists := sd.circuitBreaker[name]; exists {
-				cb.RecordSuccess()
-			}
+			instance.RecordSuccess()
 		} else {
 			health.Status = "unhealthy"
 			health.Error = fmt.Sprintf("HTTP status %d", resp.StatusCode)
-
-			// Record failure in circuit breaker
-			if cb, exists := sd.circuitBreaker[name]; exists {
-				cb.RecordFailure()
-			}
+			instance.RecordFailure()
 		}
 	}
 
-	sd.mu.Lock()
-	sd.health[name] = health
-	sd.mu.Unlock()
+	instance.setHealth(health)
 
 	if health.Status != "healthy" {
 		sd.log.WithFields(map[string]interface{}{
-			"service": name,
-			"status":  health.Status,
-			"error":   health.Error,
+			"service":  name,
+			"instance": instance.URL,
+			"status":   health.Status,
+			"error":    health.Error,
 		}).Warn("Service health check failed")
 	}
 }
@@ -264,6 +442,14 @@ func (cb *CircuitBreaker) CanRequest() bool {
 	return false
 }
 
+// State returns closed, open or half-open
+func (cb *CircuitBreaker) State() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.state
+}
+
 func (cb *CircuitBreaker) RecordSuccess() {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
@@ -0,0 +1,70 @@
+package discovery
+
+import (
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// circuitStates are the values reported for circuit breaker states
+var circuitStates = map[string]float64{
+	"closed":    0,
+	"half-open": 1,
+	"open":      2,
+}
+
+var (
+	circuitStateDesc = prometheus.NewDesc(
+		"gateway_circuit_breaker_state",
+		"Circuit breaker state of each instance: 0 closed, 1 half-open, 2 open.",
+		[]string{"service", "instance"}, nil,
+	)
+	upstreamHealthyDesc = prometheus.NewDesc(
+		"gateway_upstream_healthy",
+		"Whether each instance passed its last health check.",
+		[]string{"service", "instance"}, nil,
+	)
+	upstreamInFlightDesc = prometheus.NewDesc(
+		"gateway_upstream_requests_in_flight",
+		"Requests currently proxied to each instance.",
+		[]string{"service", "instance"}, nil,
+	)
+)
+
+// RegisterMetrics reports every instance's health, circuit breaker state
+// and requests in flight, read from service discovery when scraped.
+// Instances without a circuit breaker have no circuit breaker state.
+func RegisterMetrics(sd ServiceDiscovery, registerer prometheus.Registerer) {
+	registerer.MustRegister(&instanceCollector{sd: sd})
+}
+
+type instanceCollector struct {
+	sd ServiceDiscovery
+}
+
+func (ic *instanceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- circuitStateDesc
+	ch <- upstreamHealthyDesc
+	ch <- upstreamInFlightDesc
+}
+
+func (ic *instanceCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, health := range ic.sd.GetAllServicesHealth() {
+		for _, instance := range health.Instances {
+			ic.collectInstance(ch, name, instance)
+		}
+	}
+}
+
+func (ic *instanceCollector) collectInstance(ch chan<- prometheus.Metric, name string, instance *config.ServiceHealth) {
+	if state, ok := circuitStates[instance.CircuitState]; ok {
+		ch <- prometheus.MustNewConstMetric(circuitStateDesc, prometheus.GaugeValue, state, name, instance.URL)
+	}
+
+	healthy := 0.0
+	if instance.Status == "healthy" {
+		healthy = 1
+	}
+	ch <- prometheus.MustNewConstMetric(upstreamHealthyDesc, prometheus.GaugeValue, healthy, name, instance.URL)
+
+	ch <- prometheus.MustNewConstMetric(upstreamInFlightDesc, prometheus.GaugeValue, float64(instance.ActiveConnections), name, instance.URL)
+}
@@ -0,0 +1,51 @@
+package discovery
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeHealthDiscovery reports fixed instance health
+type fakeHealthDiscovery struct {
+	ServiceDiscovery
+	health map[string]*config.ServiceHealth
+}
+
+func (f *fakeHealthDiscovery) GetAllServicesHealth() map[string]*config.ServiceHealth {
+	return f.health
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	sd := &fakeHealthDiscovery{health: map[string]*config.ServiceHealth{
+		"flowtime": {Instances: []*config.ServiceHealth{
+			{URL: "http://a", Status: "healthy", CircuitState: "closed", ActiveConnections: 3},
+			{URL: "http://b", Status: "unhealthy", CircuitState: "open"},
+			{URL: "http://c", Status: "healthy"},
+		}},
+	}}
+	registry := prometheus.NewPedanticRegistry()
+	RegisterMetrics(sd, registry)
+
+	want := `# HELP gateway_circuit_breaker_state Circuit breaker state of each instance: 0 closed, 1 half-open, 2 open.
+# TYPE gateway_circuit_breaker_state gauge
+gateway_circuit_breaker_state{instance="http://a",service="flowtime"} 0
+gateway_circuit_breaker_state{instance="http://b",service="flowtime"} 2
+# HELP gateway_upstream_healthy Whether each instance passed its last health check.
+# TYPE gateway_upstream_healthy gauge
+gateway_upstream_healthy{instance="http://a",service="flowtime"} 1
+gateway_upstream_healthy{instance="http://b",service="flowtime"} 0
+gateway_upstream_healthy{instance="http://c",service="flowtime"} 1
+# HELP gateway_upstream_requests_in_flight Requests currently proxied to each instance.
+# TYPE gateway_upstream_requests_in_flight gauge
+gateway_upstream_requests_in_flight{instance="http://a",service="flowtime"} 3
+gateway_upstream_requests_in_flight{instance="http://b",service="flowtime"} 0
+gateway_upstream_requests_in_flight{instance="http://c",service="flowtime"} 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
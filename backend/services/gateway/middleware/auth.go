@@ -1,16 +1,22 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mdnaeem95/lifesync/backend/internal/config"
 	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/pkg/pat"
+	"github.com/mdnaeem95/lifesync/backend/pkg/revocation"
 	"github.com/mdnaeem95/lifesync/backend/services/auth/services"
 )
 
-func AuthMiddleware(cfg config.AuthGatewayConfig, jwtService services.JWTService, log logger.Logger) gin.HandlerFunc {
+// AuthMiddleware validates access tokens and rejects revoked ones. Personal
+// access tokens are accepted in place of a JWT when tokens is set.
+// revocations may be nil to skip the revocation check.
+func AuthMiddleware(cfg config.AuthGatewayConfig, jwtService services.JWTService, revocations revocation.Checker, tokens pat.Verifier, log logger.Logger) gin.HandlerFunc {
 	// Build skip paths map for O(1) lookup
 	skipPaths := make(map[string]bool)
 	for _, path := range cfg.SkipPaths {
@@ -42,6 +48,38 @@ func AuthMiddleware(cfg config.AuthGatewayConfig, jwtService services.JWTService
 
 		token := parts[1]
 
+		// Personal access tokens are opaque; only the auth service can say
+		// whose they are
+		if pat.IsToken(token) {
+			if tokens == nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+				c.Abort()
+				return
+			}
+
+			principal, err := tokens.Verify(c.Request.Context(), token)
+			if err != nil {
+				if errors.Is(err, pat.ErrInvalidToken) {
+					log.Debug("Personal access token rejected")
+					c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+				} else {
+					log.WithError(err).Error("Personal access token verification failed")
+					c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify token"})
+				}
+				c.Abort()
+				return
+			}
+
+			c.Set("user_id", principal.UserID)
+			c.Set("user_email", principal.Email)
+			c.Set("scopes", principal.Scopes)
+			c.Set("personal_access_token_id", principal.TokenID)
+			c.Set("authenticated", true)
+
+			c.Next()
+			return
+		}
+
 		// Validate token
 		claims, err := jwtService.ValidateAccessToken(token)
 		if err != nil {
@@ -51,9 +89,31 @@ func AuthMiddleware(cfg config.AuthGatewayConfig, jwtService services.JWTService
 			return
 		}
 
+		// Reject tokens revoked before they expired. If the auth service can't
+		// be asked, let the request through rather than failing every call.
+		if revocations != nil {
+			revoked, err := revocations.IsRevoked(c.Request.Context(), claims.RevocationToken())
+			if err != nil {
+				log.WithError(err).Warn("Token revocation check failed")
+			} else if revoked {
+				log.WithField("user_id", claims.UserID).Debug("Revoked token rejected")
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+				c.Abort()
+				return
+			}
+		}
+
 		// Store user info in context
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
+		c.Set("roles", claims.Roles)
+		c.Set("scopes", claims.Scopes())
+		if claims.ClientID != "" {
+			c.Set("oauth_client_id", claims.ClientID)
+		}
+		if claims.OrgID != "" {
+			c.Set("org_id", claims.OrgID)
+		}
 		c.Set("authenticated", true)
 
 		c.Next()
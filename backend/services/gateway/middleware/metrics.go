@@ -5,56 +5,64 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mdnaeem95/lifesync/backend/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
-// Simple in-memory metrics (in production, use Prometheus)
-type Metrics struct {
-	RequestCount   map[string]int64
-	ResponseTime   map[string][]time.Duration
-	ErrorCount     map[string]int64
-	ActiveRequests int64
-}
+var (
+	gatewayRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_requests_total",
+		Help: "Requests handled by the gateway, by target service, route and status code.",
+	}, []string{"service", "route", "method", "status"})
+	gatewayRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_request_duration_seconds",
+		Help:    "Time taken to handle requests, upstream time included, by target service, route and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "route", "method", "status"})
+	gatewayRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gateway_requests_in_flight",
+		Help: "Requests the gateway is currently handling.",
+	})
 
-var globalMetrics = &Metrics{
-	RequestCount: make(map[string]int64),
-	ResponseTime: make(map[string][]time.Duration),
-	ErrorCount:   make(map[string]int64),
-}
+	// RateLimitedRequests counts requests rejected by the gateway's rate
+	// limits: rule is "default" for the global limit and "route" for a
+	// route's own
+	RateLimitedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_rate_limited_requests_total",
+		Help: "Requests rejected by the rate limiter, by the rule that rejected them.",
+	}, []string{"rule"})
+)
 
+// MetricsMiddleware records every request's count and latency. Proxied
+// requests are labelled with their target service and the path prefix of
+// the route they matched, set by the service routes as target_service and
+// target_route; other requests with the gin route template, or "unmatched".
+// Paths themselves would give a series per resource ID.
 func MetricsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
-		service := c.GetString("target_service")
-
-		// Increment active requests
-		globalMetrics.ActiveRequests++
+		gatewayRequestsInFlight.Inc()
+		defer gatewayRequestsInFlight.Dec()
 
 		// Process request
 		c.Next()
 
-		// Record metrics
-		latency := time.Since(start)
-		statusCode := c.Writer.Status()
-
-		key := service + ":" + c.Request.Method + ":" + c.Request.URL.Path
-
-		globalMetrics.RequestCount[key]++
-		globalMetrics.ResponseTime[key] = append(globalMetrics.ResponseTime[key], latency)
-
-		if statusCode >= 400 {
-			globalMetrics.ErrorCount[key]++
+		route := c.GetString("target_route")
+		if route == "" {
+			route = c.FullPath()
 		}
+		if route == "" {
+			route = "unmatched"
+		}
+		labels := []string{c.GetString("target_service"), route, metrics.Method(c.Request.Method), strconv.Itoa(c.Writer.Status())}
 
-		// Decrement active requests
-		globalMetrics.ActiveRequests--
-
-		// Add metrics headers
-		c.Header("X-Response-Time", strconv.FormatInt(latency.Milliseconds(), 10)+"ms")
+		gatewayRequests.WithLabelValues(labels...).Inc()
+		gatewayRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
 	}
 }
 
+// GetMetrics serves the metrics in the Prometheus text format
 func GetMetrics() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.JSON(200, globalMetrics)
-	}
+	return gin.WrapH(metrics.Handler())
 }
@@ -34,6 +34,7 @@ func RateLimitMiddleware(limiter ratelimit.RateLimiter, cfg config.RateLimitConf
 
 		// Check rate limit
 		if !limiter.AllowDefault(key) {
+			RateLimitedRequests.WithLabelValues("default").Inc()
 			log.WithFields(map[string]interface{}{
 				"key":        key,
 				"path":       c.Request.URL.Path,
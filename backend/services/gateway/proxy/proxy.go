@@ -1,8 +1,12 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -13,37 +17,68 @@ import (
 	"github.com/mdnaeem95/lifesync/backend/internal/config"
 	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
 	"github.com/mdnaeem95/lifesync/backend/services/gateway/discovery"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+var upstreamRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gateway_upstream_retries_total",
+	Help: "Requests retried on another instance after one couldn't be reached, by service.",
+}, []string{"service"})
+
+// maxRetryBodySize is the largest request body kept in memory so that the
+// request can be sent again to another instance. Larger requests are only
+// tried once.
+const maxRetryBodySize = 1 << 20
+
 type ProxyHandler struct {
 	serviceDiscovery discovery.ServiceDiscovery
-	proxies          map[string]*httputil.ReverseProxy
+	proxies          map[string]map[string]*httputil.ReverseProxy // service name, then instance URL
 	config           map[string]config.ServiceConfig
 	log              logger.Logger
 }
 
+// attempt tracks one try at proxying a request to an instance
+type attempt struct {
+	err error
+	// retryable is set when another instance will be tried if this one
+	// can't be reached, so the error handler mustn't answer the client
+	retryable bool
+}
+
+type attemptKey struct{}
+
 func NewProxyHandler(sd discovery.ServiceDiscovery, services map[string]config.ServiceConfig, log logger.Logger) *ProxyHandler {
 	ph := &ProxyHandler{
 		serviceDiscovery: sd,
-		proxies:          make(map[string]*httputil.ReverseProxy),
+		proxies:          make(map[string]map[string]*httputil.ReverseProxy),
 		config:           services,
 		log:              log,
 	}
 
-	// Initialize reverse proxies for each service
+	// Initialize a reverse proxy for each instance of each service
 	for name, svc := range services {
-		if err := ph.createProxy(name, svc); err != nil {
-			log.WithError(err).WithField("service", name).Error("Failed to create proxy")
+		ph.proxies[name] = make(map[string]*httputil.ReverseProxy)
+		for _, instanceURL := range discovery.InstanceURLs(svc) {
+			proxy, err := ph.createProxy(name, instanceURL)
+			if err != nil {
+				log.WithError(err).WithFields(map[string]interface{}{
+					"service":  name,
+					"instance": instanceURL,
+				}).Error("Failed to create proxy")
+				continue
+			}
+			ph.proxies[name][instanceURL] = proxy
 		}
 	}
 
 	return ph
 }
 
-func (ph *ProxyHandler) createProxy(name string, svc config.ServiceConfig) error {
-	targetURL, err := url.Parse(svc.URL)
+func (ph *ProxyHandler) createProxy(name, instanceURL string) (*httputil.ReverseProxy, error) {
+	targetURL, err := url.Parse(instanceURL)
 	if err != nil {
-		return fmt.Errorf("invalid service URL: %w", err)
+		return nil, fmt.Errorf("invalid service URL: %w", err)
 	}
 
 	proxy := httputil.NewSingleHostReverseProxy(targetURL)
@@ -56,6 +91,11 @@ func (ph *ProxyHandler) createProxy(name string, svc config.ServiceConfig) error
 		// Add custom headers
 		req.Header.Set("X-Forwarded-Service", name)
 
+		// The client's address is passed on as X-Real-IP, resolved with the
+		// gateway's trusted proxies. X-Forwarded-For is dropped rather than
+		// appended to, since clients can put anything in it.
+		req.Header["X-Forwarded-For"] = nil
+
 		// Add request ID if present
 		if requestID := req.Context().Value("request_id"); requestID != nil {
 			req.Header.Set("X-Gateway-Request-ID", requestID.(string))
@@ -73,8 +113,9 @@ func (ph *ProxyHandler) createProxy(name string, svc config.ServiceConfig) error
 	// Custom error handler
 	proxy.ErrorHandler = func(rw http.ResponseWriter, req *http.Request, err error) {
 		logFields := map[string]interface{}{
-			"service": name,
-			"path":    req.URL.Path,
+			"service":  name,
+			"instance": instanceURL,
+			"path":     req.URL.Path,
 		}
 
 		// Add request ID if present
@@ -82,6 +123,15 @@ func (ph *ProxyHandler) createProxy(name string, svc config.ServiceConfig) error
 			logFields["request_id"] = requestID
 		}
 
+		current, _ := req.Context().Value(attemptKey{}).(*attempt)
+		if current != nil {
+			current.err = err
+			if current.retryable && isDialError(err) {
+				ph.log.WithError(err).WithFields(logFields).Warn("Instance unreachable, trying another")
+				return
+			}
+		}
+
 		ph.log.WithError(err).WithFields(logFields).Error("Proxy error")
 
 		rw.WriteHeader(http.StatusBadGateway)
@@ -110,8 +160,7 @@ func (ph *ProxyHandler) createProxy(name string, svc config.ServiceConfig) error
 		return nil
 	}
 
-	ph.proxies[name] = proxy
-	return nil
+	return proxy, nil
 }
 
 func (ph *ProxyHandler) HandleProxy(serviceName string) gin.HandlerFunc {
@@ -133,15 +182,6 @@ func (ph *ProxyHandler) HandleProxy(serviceName string) gin.HandlerFunc {
 			return
 		}
 
-		// Get the proxy
-		proxy, exists := ph.proxies[serviceName]
-		if !exists {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Proxy not configured for service",
-			})
-			return
-		}
-
 		// Find matching route
 		route := ph.findMatchingRoute(service, c.Request.Method, c.Request.URL.Path)
 		if route == nil {
@@ -187,6 +227,8 @@ func (ph *ProxyHandler) HandleProxy(serviceName string) gin.HandlerFunc {
 			timeout = service.Timeout
 		}
 
+		c.Request.Header.Set("X-Real-IP", c.ClientIP())
+
 		// Create new request with Gin context values
 		ctx := c.Request.Context()
 
@@ -210,39 +252,89 @@ func (ph *ProxyHandler) HandleProxy(serviceName string) gin.HandlerFunc {
 
 		c.Request = c.Request.WithContext(ctx)
 
-		// Add retry logic
-		var lastErr error
-		retryCount := service.RetryCount
-		if retryCount == 0 {
-			retryCount = 1
+		// Requests are retried on another instance only when the instance
+		// can't be reached: nothing has been sent to it then, so even
+		// non-idempotent requests are safe to retry, and nothing has been
+		// written to the client yet
+		attempts := service.RetryCount
+		if attempts == 0 {
+			attempts = 1
 		}
 
-		for i := 0; i < retryCount; i++ {
+		// The transport consumes and closes the body even when it can't
+		// connect, so a retry needs its own copy
+		if attempts > 1 {
+			buffered, err := bufferBody(c.Request, maxRetryBodySize)
+			if err != nil {
+				ph.log.WithError(err).WithField("service", serviceName).Warn("Failed to read request body")
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "Failed to read request body",
+				})
+				return
+			}
+			if !buffered {
+				attempts = 1
+			}
+		}
+
+		var lastErr error
+		tried := make(map[string]bool)
+		for i := 0; i < attempts; i++ {
+			instance, err := ph.serviceDiscovery.PickInstance(serviceName, tried)
+			if err != nil {
+				if i == 0 {
+					ph.log.WithError(err).WithField("service", serviceName).Error("No healthy service available")
+					c.JSON(http.StatusServiceUnavailable, gin.H{
+						"error":   "Service unavailable",
+						"service": serviceName,
+					})
+					return
+				}
+				break
+			}
+
+			proxy, exists := ph.proxies[serviceName][instance.URL]
+			if !exists {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Proxy not configured for service",
+				})
+				return
+			}
+
 			if i > 0 {
 				// Wait before retry
 				time.Sleep(time.Duration(i) * 100 * time.Millisecond)
+				upstreamRetries.WithLabelValues(serviceName).Inc()
 				ph.log.WithFields(map[string]interface{}{
-					"service": serviceName,
-					"attempt": i + 1,
+					"service":  serviceName,
+					"instance": instance.URL,
+					"attempt":  i + 1,
 				}).Debug("Retrying request")
 			}
+			tried[instance.URL] = true
 
 			// Create a response writer wrapper to capture the response
 			writer := &responseWriter{
 				ResponseWriter: c.Writer,
 				statusCode:     http.StatusOK,
 			}
+			current := &attempt{retryable: i < attempts-1}
+			req := c.Request.WithContext(context.WithValue(c.Request.Context(), attemptKey{}, current))
+			if req.GetBody != nil {
+				req.Body, _ = req.GetBody()
+			}
 
 			// Proxy the request
-			proxy.ServeHTTP(writer, c.Request)
+			instance.Acquire()
+			proxy.ServeHTTP(writer, req)
+			instance.Release()
+
+			recordOutcome(instance, current.err, writer.statusCode)
 
-			// Check if we need to retry
-			if writer.statusCode < 500 {
-				// Success or client error, don't retry
+			if current.err == nil || !current.retryable || !isDialError(current.err) {
 				return
 			}
-
-			lastErr = fmt.Errorf("received status code %d", writer.statusCode)
+			lastErr = current.err
 		}
 
 		// All retries failed
@@ -253,6 +345,56 @@ func (ph *ProxyHandler) HandleProxy(serviceName string) gin.HandlerFunc {
 	}
 }
 
+// bufferBody reads the request body into memory and sets GetBody so that it
+// can be sent more than once. A body larger than limit is left to be
+// streamed, readable from the start, and false is returned.
+func bufferBody(r *http.Request, limit int64) (bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return true, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return false, err
+	}
+
+	if int64(len(body)) > limit {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return false, nil
+	}
+
+	r.Body.Close()
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	r.Body, _ = r.GetBody()
+	return true, nil
+}
+
+// recordOutcome tells the instance's circuit breaker how a request went.
+// Requests the client gave up on say nothing about the instance.
+func recordOutcome(instance *discovery.Instance, err error, statusCode int) {
+	switch {
+	case errors.Is(err, context.Canceled):
+	case err != nil:
+		instance.RecordFailure()
+	case statusCode == http.StatusBadGateway, statusCode == http.StatusServiceUnavailable, statusCode == http.StatusGatewayTimeout:
+		instance.RecordFailure()
+	default:
+		instance.RecordSuccess()
+	}
+}
+
+// isDialError reports whether err means the instance couldn't be connected
+// to at all
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
 func (ph *ProxyHandler) findMatchingRoute(service *config.ServiceConfig, method, path string) *config.RouteConfig {
 	// Remove /api/v1 prefix for matching
 	pathForMatching := strings.TrimPrefix(path, "/api/v1")
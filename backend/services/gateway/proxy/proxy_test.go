@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/mdnaeem95/lifesync/backend/services/gateway/discovery"
+)
+
+// orderedDiscovery hands out the service's instances in order, skipping
+// those already tried
+type orderedDiscovery struct {
+	discovery.ServiceDiscovery
+	service   config.ServiceConfig
+	instances []*discovery.Instance
+}
+
+func (d *orderedDiscovery) GetHealthyService(name string) (*config.ServiceConfig, error) {
+	return &d.service, nil
+}
+
+func (d *orderedDiscovery) PickInstance(name string, tried map[string]bool) (*discovery.Instance, error) {
+	for _, instance := range d.instances {
+		if !tried[instance.URL] {
+			return instance, nil
+		}
+	}
+	return d.instances[0], nil
+}
+
+// serverBody behaves like a request body read by net/http's server: it
+// can't be read once closed
+type serverBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *serverBody) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, http.ErrBodyReadAfterClose
+	}
+	return b.Reader.Read(p)
+}
+
+func (b *serverBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+// unreachableURL returns the address of a port nothing listens on
+func unreachableURL(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return "http://" + addr
+}
+
+func TestRetryResendsRequestBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var received []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, string(body))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"small body", `{"title":"Write report"}`},
+		{"body too large to buffer", strings.Repeat("x", maxRetryBodySize+1)},
+		{"no body", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = nil
+			down := unreachableURL(t)
+			service := config.ServiceConfig{
+				Name:          "flowtime",
+				RetryCount:    2,
+				Routes:        []config.RouteConfig{{Method: "*", PathPrefix: "/tasks"}},
+				LoadBalancing: config.LoadBalanceConfig{Backends: []string{down, upstream.URL}},
+			}
+			sd := &orderedDiscovery{
+				service:   service,
+				instances: []*discovery.Instance{{URL: down}, {URL: upstream.URL}},
+			}
+			ph := NewProxyHandler(sd, map[string]config.ServiceConfig{"flowtime": service}, logger.New())
+
+			router := gin.New()
+			router.Any("/api/v1/*path", ph.HandleProxy("flowtime"))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", http.NoBody)
+			if tt.body != "" {
+				req.Body = &serverBody{Reader: strings.NewReader(tt.body)}
+				req.ContentLength = int64(len(tt.body))
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			tooLarge := len(tt.body) > maxRetryBodySize
+			switch {
+			case tooLarge:
+				// Only one attempt is made, on the unreachable instance
+				if w.Code != http.StatusBadGateway || len(received) != 0 {
+					t.Errorf("status = %d, upstream requests = %d; want 502 and none", w.Code, len(received))
+				}
+			case w.Code != http.StatusCreated:
+				t.Errorf("status = %d, want %d", w.Code, http.StatusCreated)
+			case len(received) != 1 || received[0] != tt.body:
+				t.Errorf("upstream received %q, want %q", received, tt.body)
+			}
+		})
+	}
+}
@@ -129,20 +129,20 @@ func min(a, b float64) float64 {
 	return b
 }
 
-// RedisRateLimiter for distributed rate limiting (optional)
-type redisRateLimiter struct {
-	// Redis implementation would go here
-	// Using Lua scripts for atomic operations
-}
-
 // Factory function to create appropriate rate limiter
 func NewRateLimiter(cfg config.RateLimitConfig, log logger.Logger) (RateLimiter, error) {
 	switch cfg.Storage {
 	case "memory":
 		return NewMemoryRateLimiter(cfg, log), nil
 	case "redis":
-		// TODO: Implement Redis rate limiter
-		return nil, fmt.Errorf("redis rate limiter not implemented yet")
+		if cfg.RedisURL == "" {
+			return nil, fmt.Errorf("redis rate limiter requires a redis URL")
+		}
+		client, err := newRedisClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedisRateLimiter(cfg, client, log), nil
 	default:
 		return NewMemoryRateLimiter(cfg, log), nil
 	}
@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisKeyPrefix = "ratelimit:"
+	// redisTimeout bounds each check, so a slow Redis delays requests by
+	// no more than this before the fail policy applies
+	redisTimeout = 250 * time.Millisecond
+)
+
+// newRedisClient connects to cfg.RedisURL, such as
+// redis://:password@localhost:6379/0 or rediss:// for TLS. The pool is
+// bounded, and waiting for a connection or a reply counts against each
+// check's timeout rather than go-redis's own.
+func newRedisClient(cfg config.RateLimitConfig) (*redis.Client, error) {
+	opts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	if cfg.RedisPoolSize > 0 {
+		opts.PoolSize = cfg.RedisPoolSize
+	}
+	opts.PoolTimeout = redisTimeout
+	opts.ContextTimeoutEnabled = true
+	// A retry would only run into the check's timeout
+	opts.MaxRetries = -1
+	return redis.NewClient(opts), nil
+}
+
+// tokenBucketScript takes a token from the bucket at KEYS[1], refilling it
+// first, and returns 1 if there was one. ARGV holds the bucket size, the
+// refill rate in tokens per second and the key's TTL in seconds. The
+// server's clock is used, so gateways with skewed clocks agree.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) + tonumber(time[2]) / 1000000
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('EXPIRE', KEYS[1], ttl)
+return allowed
+`)
+
+// redisRateLimiter keeps token buckets in Redis, so every gateway replica
+// draws from the same buckets. Buckets expire once they would have filled
+// up again, so there is nothing to clean up.
+type redisRateLimiter struct {
+	client      redis.Scripter
+	defaultRule config.RateLimitRule
+	failOpen    bool
+	log         logger.Logger
+}
+
+func NewRedisRateLimiter(cfg config.RateLimitConfig, client redis.Scripter, log logger.Logger) RateLimiter {
+	return &redisRateLimiter{
+		client: client,
+		defaultRule: config.RateLimitRule{
+			RequestsPerMin: cfg.RequestsPerMin,
+			BurstSize:      cfg.BurstSize,
+		},
+		failOpen: cfg.FailOpen,
+		log:      log,
+	}
+}
+
+// Allow applies the fail policy when Redis can't be asked: with FailOpen
+// the request goes through, otherwise it is limited
+func (rl *redisRateLimiter) Allow(key string, rule *config.RateLimitRule) bool {
+	if rule == nil {
+		return rl.AllowDefault(key)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	allowed, err := tokenBucketScript.Run(ctx, rl.client, []string{redisKeyPrefix + key},
+		rule.BurstSize,
+		float64(rule.RequestsPerMin)/60.0,
+		bucketTTL(rule),
+	).Int64()
+	if err != nil {
+		rl.log.WithError(err).WithFields(map[string]interface{}{
+			"key":       key,
+			"fail_open": rl.failOpen,
+		}).Warn("Rate limit check failed")
+		return rl.failOpen
+	}
+
+	if allowed == 1 {
+		return true
+	}
+
+	rl.log.WithFields(map[string]interface{}{
+		"key":   key,
+		"limit": rule.RequestsPerMin,
+	}).Debug("Rate limit exceeded")
+
+	return false
+}
+
+func (rl *redisRateLimiter) AllowDefault(key string) bool {
+	return rl.Allow(key, &rl.defaultRule)
+}
+
+// Cleanup does nothing; Redis expires idle buckets
+func (rl *redisRateLimiter) Cleanup() {}
+
+// bucketTTL is how long an untouched bucket takes to fill up again, after
+// which it is no different from a missing one
+func bucketTTL(rule *config.RateLimitRule) int {
+	if rule.RequestsPerMin <= 0 {
+		return 60
+	}
+	return int(math.Ceil(float64(rule.BurstSize)*60/float64(rule.RequestsPerMin))) + 1
+}
@@ -0,0 +1,220 @@
+package ratelimit
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mdnaeem95/lifesync/backend/internal/config"
+	"github.com/mdnaeem95/lifesync/backend/pkg/logger"
+)
+
+// newTestRedis starts a miniredis whose clock, used by tokenBucketScript's
+// TIME call, the test moves by hand
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, time.Time) {
+	t.Helper()
+	m := miniredis.RunT(t)
+	now := time.Unix(1700000000, 0)
+	m.SetTime(now)
+	return m, now
+}
+
+func newTestRedisLimiter(t *testing.T, addr string, failOpen bool) RateLimiter {
+	t.Helper()
+	cfg := config.RateLimitConfig{
+		RequestsPerMin: 60,
+		BurstSize:      3,
+		RedisURL:       "redis://" + addr,
+		RedisPoolSize:  2,
+		FailOpen:       failOpen,
+	}
+	client, err := newRedisClient(cfg)
+	if err != nil {
+		t.Fatalf("newRedisClient() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return NewRedisRateLimiter(cfg, client, logger.New())
+}
+
+func TestRedisRateLimiterBurstAndRefill(t *testing.T) {
+	m, now := newTestRedis(t)
+	limiter := newTestRedisLimiter(t, m.Addr(), true)
+
+	for i := 0; i < 3; i++ {
+		if !limiter.AllowDefault("ip:1.2.3.4") {
+			t.Fatalf("request %d within the burst was denied", i+1)
+		}
+	}
+	if limiter.AllowDefault("ip:1.2.3.4") {
+		t.Fatal("request past the burst was allowed")
+	}
+	if !limiter.AllowDefault("ip:5.6.7.8") {
+		t.Fatal("another key shared the exhausted bucket")
+	}
+
+	// 60 per minute refills one token a second
+	now = now.Add(500 * time.Millisecond)
+	m.SetTime(now)
+	if limiter.AllowDefault("ip:1.2.3.4") {
+		t.Fatal("allowed before a whole token had refilled")
+	}
+	now = now.Add(500 * time.Millisecond)
+	m.SetTime(now)
+	if !limiter.AllowDefault("ip:1.2.3.4") {
+		t.Fatal("denied after a token had refilled")
+	}
+
+	// A long wait refills the bucket, but no further than the burst
+	now = now.Add(time.Hour)
+	m.SetTime(now)
+	for i := 0; i < 3; i++ {
+		if !limiter.AllowDefault("ip:1.2.3.4") {
+			t.Fatalf("request %d after refilling was denied", i+1)
+		}
+	}
+	if limiter.AllowDefault("ip:1.2.3.4") {
+		t.Fatal("bucket refilled past its burst size")
+	}
+}
+
+func TestRedisRateLimiterRouteRule(t *testing.T) {
+	m, now := newTestRedis(t)
+	limiter := newTestRedisLimiter(t, m.Addr(), true)
+	rule := &config.RateLimitRule{RequestsPerMin: 6, BurstSize: 1}
+
+	if !limiter.Allow("route", rule) {
+		t.Fatal("first request was denied")
+	}
+	if limiter.Allow("route", rule) {
+		t.Fatal("second request within the rule's burst of 1 was allowed")
+	}
+	m.SetTime(now.Add(10 * time.Second))
+	if !limiter.Allow("route", rule) {
+		t.Fatal("denied after the rule's refill interval")
+	}
+
+	want := time.Duration(bucketTTL(rule)) * time.Second
+	if ttl := m.TTL(redisKeyPrefix + "route"); ttl != want {
+		t.Errorf("bucket TTL = %s, want %s", ttl, want)
+	}
+
+	// An expired bucket starts over full
+	m.FastForward(want)
+	if m.Exists(redisKeyPrefix + "route") {
+		t.Fatal("bucket outlived its TTL")
+	}
+}
+
+// unresponsiveAddr accepts connections but never replies, like a hung server
+func unresponsiveAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		close(done)
+		ln.Close()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				<-done
+				conn.Close()
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestRedisRateLimiterFailurePolicy(t *testing.T) {
+	down := func(t *testing.T) string {
+		m := miniredis.RunT(t)
+		addr := m.Addr()
+		m.Close()
+		return addr
+	}
+	failing := func(t *testing.T) string {
+		m := miniredis.RunT(t)
+		m.SetError("BUSY script running")
+		return m.Addr()
+	}
+
+	tests := []struct {
+		name     string
+		addr     func(t *testing.T) string
+		failOpen bool
+		want     bool
+	}{
+		{"unreachable, fail open", down, true, true},
+		{"unreachable, fail closed", down, false, false},
+		{"server error, fail open", failing, true, true},
+		{"server error, fail closed", failing, false, false},
+		{"timeout, fail open", unresponsiveAddr, true, true},
+		{"timeout, fail closed", unresponsiveAddr, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newTestRedisLimiter(t, tt.addr(t), tt.failOpen)
+
+			start := time.Now()
+			if got := limiter.AllowDefault("key"); got != tt.want {
+				t.Errorf("AllowDefault() = %v, want %v", got, tt.want)
+			}
+			if elapsed := time.Since(start); elapsed > 2*redisTimeout {
+				t.Errorf("took %s, want at most about %s", elapsed, redisTimeout)
+			}
+		})
+	}
+}
+
+func TestNewRedisClient(t *testing.T) {
+	client, err := newRedisClient(config.RateLimitConfig{
+		RedisURL:      "rediss://:secret@redis.internal:6380/2",
+		RedisPoolSize: 7,
+	})
+	if err != nil {
+		t.Fatalf("newRedisClient() error = %v", err)
+	}
+	defer client.Close()
+
+	opts := client.Options()
+	if opts.Addr != "redis.internal:6380" || opts.DB != 2 || opts.Password != "secret" || opts.TLSConfig == nil {
+		t.Errorf("options = addr %q db %d TLS %v, want the URL's", opts.Addr, opts.DB, opts.TLSConfig != nil)
+	}
+	if opts.PoolSize != 7 {
+		t.Errorf("PoolSize = %d, want 7", opts.PoolSize)
+	}
+	if opts.PoolTimeout != redisTimeout {
+		t.Errorf("PoolTimeout = %s, want %s", opts.PoolTimeout, redisTimeout)
+	}
+
+	if _, err := newRedisClient(config.RateLimitConfig{RedisURL: "http://redis"}); err == nil {
+		t.Error("newRedisClient() accepted a URL that isn't redis://")
+	}
+}
+
+func TestBucketTTL(t *testing.T) {
+	tests := []struct {
+		rule config.RateLimitRule
+		want int
+	}{
+		{config.RateLimitRule{RequestsPerMin: 60, BurstSize: 10}, 11},
+		{config.RateLimitRule{RequestsPerMin: 7, BurstSize: 1}, 10},
+		{config.RateLimitRule{RequestsPerMin: 600, BurstSize: 1}, 2},
+		{config.RateLimitRule{RequestsPerMin: 0, BurstSize: 5}, 60},
+	}
+
+	for _, tt := range tests {
+		if got := bucketTTL(&tt.rule); got != tt.want {
+			t.Errorf("bucketTTL(%+v) = %d, want %d", tt.rule, got, tt.want)
+		}
+	}
+}
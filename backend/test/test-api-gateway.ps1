@@ -24,7 +24,7 @@ $testEmail = "gateway-test-$(Get-Random)@example.com"
 try {
     $signupBody = @{
         email = $testEmail
-        password = "password123"
+        password = "quiet-harbor-lantern-42"
         name = "Gateway Test User"
     } | ConvertTo-Json
 
@@ -64,7 +64,7 @@ try {
     try {
         $signinBody = @{
             email = $testEmail
-            password = "password123"
+            password = "quiet-harbor-lantern-42"
         } | ConvertTo-Json
 
         $signin = Invoke-RestMethod -Uri "$gatewayUrl/api/v1/auth/signin" `